@@ -64,6 +64,28 @@ func GetNodes(endpoint string) ([]string, error) {
 	return rsp.GetNodes(), nil
 }
 
+func GetReplicaNodes(endpoint string) ([]string, error) {
+	cli, cleanup, err := GetClient(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCNodes
+	req := model.NewNodeRPCRequest()
+	rsp := model.NewNodeRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.RetCode != model.OK {
+		return nil, fmt.Errorf("%s", rsp.RetCode)
+	}
+
+	return rsp.GetReplicas(), nil
+}
+
 func GetRaftState(endpoint string) (string, []string, error) {
 	cli, cleanup, err := GetClient(endpoint)
 	if err != nil {
@@ -112,6 +134,19 @@ func IsNodeIdleOrInvalid(node string) (bool, error) {
 	return false, nil
 }
 
+// IsNodeDelayed returns true if the node replicates with MASTER_DELAY.
+func IsNodeDelayed(node string) (bool, error) {
+	rsp, err := GetNodesRPC(node)
+	if err != nil {
+		return false, err
+	}
+
+	if rsp.RetCode != model.OK {
+		return false, fmt.Errorf("%s", rsp.RetCode)
+	}
+	return rsp.ReplicationDelay > 0, nil
+}
+
 func GetClusterLeader(self string) (string, error) {
 	nodes, err := GetNodes(self)
 	if err != nil {
@@ -167,6 +202,11 @@ func FindBestoneForBackup(self string) (string, error) {
 			if isIorIV {
 				continue
 			}
+
+			// the delayed replica is behind on purpose
+			if delayed, err := IsNodeDelayed(node); err != nil || delayed {
+				continue
+			}
 			if rsp, err := GetMysqlStatusRPC(node); err == nil {
 				GTID := rsp.GTID
 				if GTID.Slave_SQL_Running && GTID.Slave_IO_Running {
@@ -423,14 +463,26 @@ func GetMysqldStatusRPC(node string) (*model.MysqldStatusRPCResponse, error) {
 	return rsp, err
 }
 
-func RequestBackupRPC(fromnode string, conf *config.Config, backupdir string) (*model.BackupRPCResponse, error) {
-	cli, cleanup, err := GetClient(fromnode)
-	if err != nil {
-		return nil, err
-	}
-	defer cleanup()
+// RequestBackupRPC asks the fromnode to backup to backupdir on this node,
+// rate limits the bytes per second of the xenon transport, 0 is no limit.
+func RequestBackupRPC(fromnode string, conf *config.Config, backupdir string, rate int64) (*model.BackupRPCResponse, error) {
+	req := newBackupRequest(conf, backupdir, rate)
+	req.XtrabackupBinDir = conf.Backup.XtrabackupBinDir
+	req.Compress = conf.Backup.Compress
+	req.Encrypt = conf.Backup.Encrypt
+	req.EncryptKeyFile = conf.Backup.EncryptKeyFile
+	return requestBackupRPC(fromnode, conf, req)
+}
 
-	method := model.RPCBackupDo
+// RequestDumpRPC asks the fromnode to send the logical dump(mysqldump/mydumper) to dumpdir on this node,
+// the dump has no compression or encryption.
+func RequestDumpRPC(fromnode string, conf *config.Config, dumpdir string, dumpMethod string, rate int64) (*model.BackupRPCResponse, error) {
+	req := newBackupRequest(conf, dumpdir, rate)
+	req.Method = dumpMethod
+	return requestBackupRPC(fromnode, conf, req)
+}
+
+func newBackupRequest(conf *config.Config, backupdir string, rate int64) *model.BackupRPCRequest {
 	req := model.NewBackupRPCRequest()
 	req.SSHHost = conf.Backup.SSHHost
 	req.SSHUser = conf.Backup.SSHUser
@@ -438,7 +490,21 @@ func RequestBackupRPC(fromnode string, conf *config.Config, backupdir string) (*
 	req.SSHPort = conf.Backup.SSHPort
 	req.IOPSLimits = conf.Backup.BackupIOPSLimits
 	req.BackupDir = backupdir
-	req.XtrabackupBinDir = conf.Backup.XtrabackupBinDir
+	req.Rate = rate
+	return req
+}
+
+func requestBackupRPC(fromnode string, conf *config.Config, req *model.BackupRPCRequest) (*model.BackupRPCResponse, error) {
+	cli, cleanup, err := GetClient(fromnode)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCBackupDo
+	if conf.Backup.Transport == model.BACKUP_TRANSPORT_XENON {
+		return requestStreamBackupRPC(cli, conf.Server.Endpoint, req)
+	}
 	log.Warning("rebuildme.backup.req[%+v].from[%v]", req, fromnode)
 
 	rsp := model.NewBackupRPCResponse(model.OK)
@@ -447,6 +513,81 @@ func RequestBackupRPC(fromnode string, conf *config.Config, backupdir string) (*
 	return rsp, err
 }
 
+// requestStreamBackupRPC prepares the stream on self, asks the donor to stream the backup to it
+// and verifies the size and the checksum of the received stream.
+func requestStreamBackupRPC(cli *xrpc.Client, self string, req *model.BackupRPCRequest) (*model.BackupRPCResponse, error) {
+	prepare, err := PrepareStreamRPC(self, req.BackupDir, req.Method)
+	if err != nil {
+		return nil, err
+	}
+	if prepare.RetCode != model.OK {
+		return model.NewBackupRPCResponse(prepare.RetCode), nil
+	}
+	req.Transport = model.BACKUP_TRANSPORT_XENON
+	req.StreamAddr = self
+	req.StreamToken = prepare.Token
+	log.Warning("rebuildme.backup.stream.req[%+v]", req)
+
+	rsp := model.NewBackupRPCResponse(model.OK)
+	if err = cli.Call(model.RPCBackupDo, req, rsp); err != nil || rsp.RetCode != model.OK {
+		if _, werr := WaitStreamRPC(self, prepare.Token, true); werr != nil {
+			log.Error("rebuildme.backup.stream[%v].abort.error[%v]", prepare.Token, werr)
+		}
+		return rsp, err
+	}
+
+	wait, err := WaitStreamRPC(self, prepare.Token, false)
+	if err != nil {
+		return nil, err
+	}
+	if wait.RetCode != model.OK {
+		rsp.RetCode = wait.RetCode
+		return rsp, nil
+	}
+	if wait.Size != rsp.Size || wait.Checksum != rsp.Checksum {
+		rsp.RetCode = fmt.Sprintf("backup.stream.checksum[%v:%v].mismatch.donor[%v:%v]", wait.Size, wait.Checksum, rsp.Size, rsp.Checksum)
+		return rsp, nil
+	}
+	log.Warning("rebuildme.backup.stream[%v].verified[%v.bytes.checksum:%v]", prepare.Token, wait.Size, wait.Checksum)
+	return rsp, nil
+}
+
+// PrepareStreamRPC used to prepare a stream on the node to receive the backup of the backupMethod into backupdir.
+func PrepareStreamRPC(node string, backupdir string, backupMethod string) (*model.BackupStreamRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCBackupPrepareStream
+	req := model.NewBackupStreamRPCRequest()
+	req.BackupDir = backupdir
+	req.Method = backupMethod
+	rsp := model.NewBackupStreamRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+
+	return rsp, err
+}
+
+// WaitStreamRPC used to wait for the stream on the node to be received.
+func WaitStreamRPC(node string, token string, abort bool) (*model.BackupStreamRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCBackupWaitStream
+	req := model.NewBackupStreamRPCRequest()
+	req.Token = token
+	req.Abort = abort
+	rsp := model.NewBackupStreamRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+
+	return rsp, err
+}
+
 func BackupCancelRPC(self string) (*model.BackupRPCResponse, error) {
 	cli, cleanup, err := GetClient(self)
 	if err != nil {
@@ -462,7 +603,22 @@ func BackupCancelRPC(self string) (*model.BackupRPCResponse, error) {
 	return rsp, err
 }
 
-func DoApplyLogRPC(node string, backupdir string) error {
+func GetBackupStatusRPC(node string) (*model.BackupStatusRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCBackupStatus
+	req := model.NewBackupRPCRequest()
+	rsp := model.NewBackupStatusRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+
+	return rsp, err
+}
+
+func DoApplyLogRPC(node string, conf *config.Config, backupdir string) error {
 	cli, cleanup, err := GetClient(node)
 	if err != nil {
 		return err
@@ -472,12 +628,236 @@ func DoApplyLogRPC(node string, backupdir string) error {
 	method := model.RPCBackupApplyLog
 	req := model.NewBackupRPCRequest()
 	req.BackupDir = backupdir
+	req.Compress = conf.Backup.Compress
+	req.Encrypt = conf.Backup.Encrypt
+	req.EncryptKeyFile = conf.Backup.EncryptKeyFile
 	rsp := model.NewBackupRPCResponse(model.OK)
 	err = cli.Call(method, req, rsp)
 
 	return err
 }
 
+// LoadDumpRPC used to load the logical dump in dumpdir into the mysql of the node.
+func LoadDumpRPC(node string, dumpdir string, dumpMethod string) error {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	method := model.RPCBackupLoadDump
+	req := model.NewBackupRPCRequest()
+	req.BackupDir = dumpdir
+	req.Method = dumpMethod
+	rsp := model.NewBackupRPCResponse(model.OK)
+	if err = cli.Call(method, req, rsp); err != nil {
+		return err
+	}
+	if rsp.RetCode != model.OK {
+		return fmt.Errorf("%s", rsp.RetCode)
+	}
+	return nil
+}
+
+func ListArchivesRPC(node string) (*model.BackupArchiveRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCBackupList
+	req := model.NewBackupArchiveRPCRequest()
+	rsp := model.NewBackupArchiveRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+
+	return rsp, err
+}
+
+func ShowArchiveRPC(node string, id string) (*model.BackupArchiveRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCBackupShow
+	req := model.NewBackupArchiveRPCRequest()
+	req.ID = id
+	rsp := model.NewBackupArchiveRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+
+	return rsp, err
+}
+
+func DeleteArchiveRPC(node string, id string) (*model.BackupArchiveRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCBackupDelete
+	req := model.NewBackupArchiveRPCRequest()
+	req.ID = id
+	rsp := model.NewBackupArchiveRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+
+	return rsp, err
+}
+
+func RestoreArchiveRPC(node string, id string, targetdir string) (*model.BackupArchiveRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCBackupRestore
+	req := model.NewBackupArchiveRPCRequest()
+	req.ID = id
+	req.TargetDir = targetdir
+	rsp := model.NewBackupArchiveRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+
+	return rsp, err
+}
+
+// VerifyArchiveRPC used to verify the archive on the node by restoring it into a scratch mysqld.
+func VerifyArchiveRPC(node string, id string) (*model.BackupArchiveRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCBackupVerify
+	req := model.NewBackupArchiveRPCRequest()
+	req.ID = id
+	rsp := model.NewBackupArchiveRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+
+	return rsp, err
+}
+
+// ScheduledBackupRPC used to dispatch the scheduled backup to the node, the backup runs in background.
+func ScheduledBackupRPC(node string, incremental bool) (*model.BackupArchiveRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCBackupSchedule
+	req := model.NewBackupArchiveRPCRequest()
+	req.Incremental = incremental
+	rsp := model.NewBackupArchiveRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+
+	return rsp, err
+}
+
+func ReplayBinlogsRPC(node string, id string, stopDatetime string, stopGTID string) (*model.BackupArchiveRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCBackupReplay
+	req := model.NewBackupArchiveRPCRequest()
+	req.ID = id
+	req.StopDatetime = stopDatetime
+	req.StopGTID = stopGTID
+	rsp := model.NewBackupArchiveRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+
+	return rsp, err
+}
+
+// rebuild
+func RebuildStartRPC(node string, from string, force bool, rate int64) (*model.RebuildRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCRebuildStart
+	req := model.NewRebuildRPCRequest()
+	req.RebuildFrom = from
+	req.Force = force
+	req.Rate = rate
+	rsp := model.NewRebuildRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+
+	return rsp, err
+}
+
+// RestoreStartRPC used to start the point-in-time restore job of the archive on the node.
+func RestoreStartRPC(node string, id string, toTime string, toGTID string) (*model.RebuildRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCRebuildRestore
+	req := model.NewRebuildRPCRequest()
+	req.RestoreID = id
+	req.RestoreToTime = toTime
+	req.RestoreToGTID = toGTID
+	rsp := model.NewRebuildRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+
+	return rsp, err
+}
+
+func RebuildStatusRPC(node string) (*model.RebuildRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCRebuildStatus
+	req := model.NewRebuildRPCRequest()
+	rsp := model.NewRebuildRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+
+	return rsp, err
+}
+
+func RebuildCancelRPC(node string) (*model.RebuildRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCRebuildCancel
+	req := model.NewRebuildRPCRequest()
+	rsp := model.NewRebuildRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+
+	return rsp, err
+}
+
+func RebuildResumeRPC(node string) (*model.RebuildRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCRebuildResume
+	req := model.NewRebuildRPCRequest()
+	rsp := model.NewRebuildRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+
+	return rsp, err
+}
+
 // raft
 func AddNodeRPC(node string, nodes []string) error {
 	cli, cleanup, err := GetClient(node)
@@ -513,6 +893,23 @@ func AddIdleNodeRPC(node string, nodes []string) error {
 	return err
 }
 
+func AddReplicaNodeRPC(node string, nodes []string) error {
+	cli, cleanup, err := GetClient(node)
+
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	method := model.RPCReplicaNodesAdd
+	req := model.NewNodeRPCRequest()
+	req.Nodes = nodes
+	rsp := model.NewNodeRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+
+	return err
+}
+
 func RemoveNodeRPC(node string, nodes []string) error {
 	cli, cleanup, err := GetClient(node)
 	if err != nil {
@@ -543,6 +940,21 @@ func RemoveIdleNodeRPC(node string, nodes []string) error {
 	return err
 }
 
+func RemoveReplicaNodeRPC(node string, nodes []string) error {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	method := model.RPCReplicaNodesRemove
+	req := model.NewNodeRPCRequest()
+	req.Nodes = nodes
+	rsp := model.NewNodeRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+	return err
+}
+
 func GetNodesRPC(node string) (*model.NodeRPCResponse, error) {
 	cli, cleanup, err := GetClient(node)
 	if err != nil {
@@ -664,6 +1076,130 @@ func RaftDisablePurgeBinlogRPC(node string) error {
 	return err
 }
 
+func RaftPurgePlanRPC(node string) (*model.RaftPurgePlanRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCRaftPurgePlan
+	req := model.NewRaftStatusRPCRequest()
+	rsp := model.NewRaftPurgePlanRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+
+	return rsp, err
+}
+
+func RaftSetPurgeConsumerRPC(node string, consumer model.PurgeConsumer) (*model.RaftPurgeConsumerRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCRaftSetPurgeConsumer
+	req := model.NewRaftPurgeConsumerRPCRequest()
+	req.From = node
+	req.Consumer = consumer
+	rsp := model.NewRaftPurgeConsumerRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+
+	return rsp, err
+}
+
+func RaftRemovePurgeConsumerRPC(node string, name string) (*model.RaftPurgeConsumerRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCRaftRemovePurgeConsumer
+	req := model.NewRaftPurgeConsumerRPCRequest()
+	req.From = node
+	req.Consumer.Name = name
+	rsp := model.NewRaftPurgeConsumerRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+
+	return rsp, err
+}
+
+// SetClusterPurgeConsumer used to set the consumer on the leader of the cluster,
+// the leader carries the consumers to all the nodes and read replicas in the heartbeats.
+func SetClusterPurgeConsumer(self string, consumer model.PurgeConsumer) ([]model.PurgeConsumer, error) {
+	return callLeaderPurgeConsumer(self, func(leader string) (*model.RaftPurgeConsumerRPCResponse, error) {
+		return RaftSetPurgeConsumerRPC(leader, consumer)
+	})
+}
+
+// RemoveClusterPurgeConsumer used to remove the consumer from the leader of the cluster.
+func RemoveClusterPurgeConsumer(self string, name string) ([]model.PurgeConsumer, error) {
+	return callLeaderPurgeConsumer(self, func(leader string) (*model.RaftPurgeConsumerRPCResponse, error) {
+		return RaftRemovePurgeConsumerRPC(leader, name)
+	})
+}
+
+func callLeaderPurgeConsumer(self string, call func(leader string) (*model.RaftPurgeConsumerRPCResponse, error)) ([]model.PurgeConsumer, error) {
+	leader, err := GetClusterLeader(self)
+	if err != nil {
+		return nil, err
+	}
+	if leader == "" {
+		return nil, fmt.Errorf("cluster.has.no.leader")
+	}
+	rsp, err := call(leader)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.RetCode != model.OK {
+		return nil, fmt.Errorf("%s", rsp.RetCode)
+	}
+	return rsp.Consumers, nil
+}
+
+func RaftWatchLeaderRPC(node string, leader string, timeout int) (*model.RaftWatchLeaderRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCRaftWatchLeader
+	req := model.NewRaftWatchLeaderRPCRequest()
+	req.Leader = leader
+	req.Timeout = timeout
+	rsp := model.NewRaftWatchLeaderRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+
+	return rsp, err
+}
+
+// WatchClusterLeader used to follow the leader by the long-poll of the node, the watch is called with the current view at first
+// and on every leader change, until it returns false or the rpc fails.
+func WatchClusterLeader(node string, timeout int, watch func(view model.LeaderView) bool) error {
+	known, first := "", true
+	for {
+		wait := timeout
+		if first {
+			wait = 0
+		}
+		rsp, err := RaftWatchLeaderRPC(node, known, wait)
+		if err != nil {
+			return err
+		}
+		if rsp.RetCode != model.OK {
+			return fmt.Errorf("%s", rsp.RetCode)
+		}
+		if first || rsp.Changed {
+			if !watch(rsp.View) {
+				return nil
+			}
+			known, first = rsp.View.Leader, false
+		}
+	}
+}
+
 func RaftEnableCheckSemiSyncRPC(node string) error {
 	cli, cleanup, err := GetClient(node)
 	if err != nil {
@@ -742,6 +1278,92 @@ func MysqlIsWorkingRPC(node string) (bool, error) {
 	return false, nil
 }
 
+// MysqlVersionRPC returns the mysql server version of the node.
+func MysqlVersionRPC(node string) (string, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	method := model.RPCMysqlVersion
+	req := model.NewMysqlRPCRequest()
+	rsp := model.NewMysqlVersionRPCResponse(model.OK)
+	if err := cli.Call(method, req, rsp); err != nil {
+		return "", err
+	}
+	if rsp.RetCode != model.OK {
+		return "", fmt.Errorf("%s", rsp.RetCode)
+	}
+	return rsp.Version, nil
+}
+
+// PrepareCloneDonorRPC used to prepare the node as the clone donor, returns the repl info to clone from.
+func PrepareCloneDonorRPC(node string) (*model.MysqlCloneRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCMysqlPrepareCloneDonor
+	req := model.NewMysqlRPCRequest()
+	rsp := model.NewMysqlCloneRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+	return rsp, err
+}
+
+// CloneInstanceRPC used to clone the data of the node from the donor.
+func CloneInstanceRPC(node string, donor *model.Repl) (*model.MysqlRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCMysqlCloneInstance
+	req := model.NewMysqlCloneRPCRequest()
+	req.Donor = *donor
+	rsp := model.NewMysqlRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+	return rsp, err
+}
+
+// MysqlDelayedCatchupRPC used to roll the delayed replica forward to the transaction before the gtid,
+// the delay is restored if the catch-up fails.
+func MysqlDelayedCatchupRPC(node string, gtid string, delay int) (*model.MysqlRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCMysqlDelayedCatchup
+	req := model.NewMysqlDelayedCatchupRPCRequest()
+	req.GTID = gtid
+	req.Delay = delay
+	rsp := model.NewMysqlRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+	return rsp, err
+}
+
+// MysqlDelayedResumeRPC used to resume the delay of the delayed replica after the catch-up.
+func MysqlDelayedResumeRPC(node string, delay int) (*model.MysqlRPCResponse, error) {
+	cli, cleanup, err := GetClient(node)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	method := model.RPCMysqlDelayedCatchup
+	req := model.NewMysqlDelayedCatchupRPCRequest()
+	req.Resume = true
+	req.Delay = delay
+	rsp := model.NewMysqlRPCResponse(model.OK)
+	err = cli.Call(method, req, rsp)
+	return rsp, err
+}
+
 func GetMysqlStatusRPC(node string) (*model.MysqlStatusRPCResponse, error) {
 	cli, cleanup, err := GetClient(node)
 	if err != nil {
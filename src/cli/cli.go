@@ -37,6 +37,7 @@ func init() {
 	rootCmd.AddCommand(cmd.NewRaftCommand())
 	rootCmd.AddCommand(cmd.NewXenonCommand())
 	rootCmd.AddCommand(cmd.NewPerfCommand())
+	rootCmd.AddCommand(cmd.NewBackupCommand())
 }
 
 func main() {
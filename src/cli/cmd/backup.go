@@ -0,0 +1,214 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package cmd
+
+import (
+	"cli/callx"
+	"encoding/json"
+	"fmt"
+	"model"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	restoreDir string
+)
+
+func NewBackupCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backup <subcommand>",
+		Short: "local backup archives related commands",
+	}
+
+	cmd.AddCommand(NewBackupListCommand())
+	cmd.AddCommand(NewBackupShowCommand())
+	cmd.AddCommand(NewBackupDeleteCommand())
+	cmd.AddCommand(NewBackupRestoreCommand())
+	cmd.AddCommand(NewBackupVerifyCommand())
+
+	return cmd
+}
+
+// findArchive returns the node which holds the archive and the manifest.
+func findArchive(id string) (string, *model.BackupManifest) {
+	conf, err := GetConfig()
+	ErrorOK(err)
+
+	nodes, err := callx.GetNodes(conf.Server.Endpoint)
+	ErrorOK(err)
+	for _, node := range nodes {
+		rsp, err := callx.ShowArchiveRPC(node, id)
+		if err != nil {
+			log.Warning("backup.show.archive[%v].on[%v].error[%v]", id, node, err)
+			continue
+		}
+		if rsp.RetCode == model.OK {
+			return node, rsp.Manifests[0]
+		}
+	}
+	ErrorOK(fmt.Errorf("archive[%v].not.found.in.nodes%v", id, nodes))
+	return "", nil
+}
+
+func NewBackupListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list the backup archives of all the nodes",
+		Run:   backupListCommandFn,
+	}
+
+	return cmd
+}
+
+func backupListCommandFn(cmd *cobra.Command, args []string) {
+	var rows [][]string
+	conf, err := GetConfig()
+	ErrorOK(err)
+
+	nodes, err := callx.GetNodes(conf.Server.Endpoint)
+	ErrorOK(err)
+	for _, node := range nodes {
+		rsp, err := callx.ListArchivesRPC(node)
+		if err != nil {
+			log.Warning("backup.list.archives.on[%v].error[%v]", node, err)
+			continue
+		}
+		if rsp.RetCode != model.OK {
+			log.Warning("backup.list.archives.on[%v].error[%v]", node, rsp.RetCode)
+			continue
+		}
+		for _, m := range rsp.Manifests {
+			rows = append(rows, []string{
+				m.ID,
+				node,
+				m.Type,
+				m.BaseID,
+				time.Unix(m.End, 0).Format("2006-01-02 15:04:05"),
+				m.Duration().String(),
+				humanBytes(m.Size),
+				m.GTID,
+			})
+		}
+	}
+
+	columns := []string{
+		"ID",
+		"Node",
+		"Type",
+		"Base",
+		"End",
+		"Duration",
+		"Size",
+		"GTID",
+	}
+	callx.PrintQueryOutput(columns, rows)
+}
+
+func NewBackupShowCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show <id>",
+		Short: "show the manifest of the backup archive",
+		Run:   backupShowCommandFn,
+	}
+
+	return cmd
+}
+
+func backupShowCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		ErrorOK(fmt.Errorf("backup.id.is.nil"))
+	}
+
+	_, manifest := findArchive(args[0])
+	b, err := json.MarshalIndent(manifest, "", "\t")
+	ErrorOK(err)
+	fmt.Printf("%s\n", string(b))
+}
+
+func NewBackupDeleteCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete <id>",
+		Short: "delete the backup archive from the node which holds it",
+		Run:   backupDeleteCommandFn,
+	}
+
+	return cmd
+}
+
+func backupDeleteCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		ErrorOK(fmt.Errorf("backup.id.is.nil"))
+	}
+
+	node, _ := findArchive(args[0])
+	log.Warning("backup.prepare.to.delete.archive[%v].on[%v]", args[0], node)
+	rsp, err := callx.DeleteArchiveRPC(node, args[0])
+	ErrorOK(err)
+	RspOK(rsp.RetCode)
+	log.Warning("backup.delete.archive[%v].on[%v].done", args[0], node)
+}
+
+func NewBackupRestoreCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore <id> --to=dir",
+		Short: "restore the backup archive(with the chain it's based on) to the dir on the node which holds it and prepare it",
+		Run:   backupRestoreCommandFn,
+	}
+	cmd.Flags().StringVar(&restoreDir, "to", "", "the empty dir to restore to")
+
+	return cmd
+}
+
+func backupRestoreCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		ErrorOK(fmt.Errorf("backup.id.is.nil"))
+	}
+	if restoreDir == "" {
+		ErrorOK(fmt.Errorf("restore.dir.is.nil"))
+	}
+
+	node, _ := findArchive(args[0])
+	log.Warning("backup.prepare.to.restore.archive[%v].on[%v].to[%v]", args[0], node, restoreDir)
+	rsp, err := callx.RestoreArchiveRPC(node, args[0], restoreDir)
+	ErrorOK(err)
+	RspOK(rsp.RetCode)
+	log.Warning("backup.restore.archive[%v].on[%v].to[%v].done", args[0], node, restoreDir)
+}
+
+func NewBackupVerifyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify <id>",
+		Short: "verify the backup archive by restoring it into a scratch mysqld on the node which holds it",
+		Run:   backupVerifyCommandFn,
+	}
+
+	return cmd
+}
+
+func backupVerifyCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		ErrorOK(fmt.Errorf("backup.id.is.nil"))
+	}
+
+	node, _ := findArchive(args[0])
+	log.Warning("backup.prepare.to.verify.archive[%v].on[%v]", args[0], node)
+	rsp, err := callx.VerifyArchiveRPC(node, args[0])
+	ErrorOK(err)
+	RspOK(rsp.RetCode)
+	verification := rsp.Manifests[0].Verification
+	b, err := json.MarshalIndent(verification, "", "\t")
+	ErrorOK(err)
+	fmt.Printf("%s\n", string(b))
+	if verification.Result != model.BACKUP_VERIFY_PASSED {
+		ErrorOK(fmt.Errorf("backup.verify.archive[%v].on[%v].failed[%v]", args[0], node, verification.Error))
+	}
+	log.Warning("backup.verify.archive[%v].on[%v].passed", args[0], node)
+}
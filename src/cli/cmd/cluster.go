@@ -43,12 +43,16 @@ func NewClusterCommand() *cobra.Command {
 	cmd.AddCommand(NewClusterIdleAddCommand())
 	cmd.AddCommand(NewClusterRemoveCommand())
 	cmd.AddCommand(NewClusterIdleRemoveCommand())
+	cmd.AddCommand(NewClusterReplicaAddCommand())
+	cmd.AddCommand(NewClusterReplicaRemoveCommand())
+	cmd.AddCommand(NewClusterReplicasCommand())
 	cmd.AddCommand(NewClusterStatusCommand())
 	cmd.AddCommand(NewClusterMysqlCommand())
 	cmd.AddCommand(NewClusterGTIDCommand())
 	cmd.AddCommand(NewClusterRaftCommand())
 	cmd.AddCommand(NewClusterXenonCommand())
 	cmd.AddCommand(NewClusterLogCommand())
+	cmd.AddCommand(NewClusterWatchLeaderCommand())
 
 	return cmd
 }
@@ -207,6 +211,158 @@ func clusterIdleRemoveCommandFn(cmd *cobra.Command, args []string) {
 	}
 }
 
+func NewClusterReplicaAddCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "addreplica nodename1,nodename2",
+		Short: "add read replicas to leader(if there is no leader, add to local), the replicas never vote",
+		Run:   clusterReplicaAddCommandFn,
+	}
+
+	return cmd
+}
+
+func clusterReplicaAddCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		ErrorOK(fmt.Errorf("node.name.is.nil"))
+	}
+
+	// send add node rpc to leader
+	{
+		conf, err := GetConfig()
+		ErrorOK(err)
+		self := conf.Server.Endpoint
+		nodes := strings.Split(strings.Trim(args[0], ","), ",")
+
+		leader, err := callx.GetClusterLeader(self)
+		if err != nil {
+			log.Warning("%v", err)
+		}
+		log.Warning("cluster.prepare.to.add.replica.nodes[%v].to.leader[%v]", args[0], leader)
+		if leader != "" {
+			err := callx.AddReplicaNodeRPC(leader, nodes)
+			ErrorOK(err)
+		} else {
+			log.Warning("cluster.canot.found.leader.forward.to[%v]", self)
+			err := callx.AddReplicaNodeRPC(self, nodes)
+			ErrorOK(err)
+		}
+		log.Warning("cluster.add.replica.nodes.to.leader[%v].done", leader)
+	}
+}
+
+func NewClusterReplicaRemoveCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "removereplica nodename1,nodename2",
+		Short: "remove read replicas from leader(if there is no leader, remove from local)",
+		Run:   clusterReplicaRemoveCommandFn,
+	}
+
+	return cmd
+}
+
+func clusterReplicaRemoveCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		ErrorOK(fmt.Errorf("node.name.is.nil"))
+	}
+
+	// send remove node rpc to leader
+	{
+		conf, err := GetConfig()
+		ErrorOK(err)
+		self := conf.Server.Endpoint
+		nodes := strings.Split(strings.Trim(args[0], ","), ",")
+		leader, err := callx.GetClusterLeader(self)
+		if err != nil {
+			log.Warning("%v", err)
+		}
+		log.Warning("cluster.prepare.to.remove.replica.nodes[%v].from.leader[%v]", args[0], leader)
+		if leader != "" {
+			err := callx.RemoveReplicaNodeRPC(leader, nodes)
+			ErrorOK(err)
+		} else {
+			log.Warning("cluster.remove.canot.found.leader.forward.to[%v]", self)
+			err := callx.RemoveReplicaNodeRPC(self, nodes)
+			ErrorOK(err)
+		}
+		log.Warning("cluster.remove.replica.nodes.from.leader[%v].done", leader)
+	}
+}
+
+func NewClusterReplicasCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "replicas",
+		Short: "show the read replicas and their lag",
+		Run:   clusterReplicasCommandFn,
+	}
+
+	return cmd
+}
+
+func clusterReplicasCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		ErrorOK(fmt.Errorf("too.many.args"))
+	}
+
+	var rows [][]string
+	conf, err := GetConfig()
+	ErrorOK(err)
+
+	nodes, err := callx.GetReplicaNodes(conf.Server.Endpoint)
+	ErrorOK(err)
+
+	for _, node := range nodes {
+		raft := "UNKNOW"
+		leader := "UNKNOW"
+		mysqlInfo := "UNKNOW"
+		slaveInfo := "UNKNOW"
+		Seconds_Behind_Master := "UNKNOW"
+		Last_Error := "UNKNOW"
+
+		// raft
+		{
+			if rsp, err := callx.GetNodesRPC(node); err == nil {
+				raft = rsp.State
+				leader = rsp.GetLeader()
+			}
+		}
+
+		// mysql
+		{
+			if rsp, err := callx.GetMysqlStatusRPC(node); err == nil {
+				mysqlInfo = rsp.Status
+				slaveInfo = fmt.Sprintf("[%v/%v]",
+					rsp.GTID.Slave_IO_Running,
+					rsp.GTID.Slave_SQL_Running)
+				Seconds_Behind_Master = rsp.GTID.Seconds_Behind_Master
+				Last_Error = rsp.GTID.Last_Error
+			}
+		}
+
+		row := []string{
+			node,
+			raft,
+			leader,
+			strings.TrimSpace(mysqlInfo),
+			strings.TrimSpace(slaveInfo),
+			Seconds_Behind_Master,
+			Last_Error,
+		}
+		rows = append(rows, row)
+	}
+
+	columns := []string{
+		"ID",
+		"Raft",
+		"Leader",
+		"Mysql",
+		"IO/SQL_Running",
+		"Seconds_Behind",
+		"Last_Error",
+	}
+
+	callx.PrintQueryOutput(columns, rows)
+}
+
 func NewClusterStatusCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "status",
@@ -234,6 +390,7 @@ func clusterStatusCommandFn(cmd *cobra.Command, args []string) {
 		mysqlInfo := "UNKNOW"
 		slaveInfo := "UNKNOW"
 		myLeader := "UNKNOW"
+		labels := "UNKNOW"
 
 		// raft
 		{
@@ -241,6 +398,7 @@ func clusterStatusCommandFn(cmd *cobra.Command, args []string) {
 				raft = fmt.Sprintf("[ViewID:%v EpochID:%v]@%v",
 					rsp.ViewID, rsp.EpochID, rsp.State)
 				myLeader = rsp.GetLeader()
+				labels = formatLabels(rsp.Labels)
 			}
 		}
 
@@ -278,6 +436,7 @@ func clusterStatusCommandFn(cmd *cobra.Command, args []string) {
 			strings.TrimSpace(mysqlInfo),
 			strings.TrimSpace(slaveInfo),
 			myLeader,
+			labels,
 		}
 		rows = append(rows, row)
 	}
@@ -291,11 +450,22 @@ func clusterStatusCommandFn(cmd *cobra.Command, args []string) {
 		"Mysql",
 		"IO/SQL_RUNNING",
 		"MyLeader",
+		"Labels",
 	}
 
 	callx.PrintQueryOutput(columns, rows)
 }
 
+// formatLabels returns the labels as 'key=value' sorted by key and separated by comma.
+func formatLabels(labels map[string]string) string {
+	kvs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		kvs = append(kvs, fmt.Sprintf("%v=%v", k, v))
+	}
+	sort.Strings(kvs)
+	return strings.Join(kvs, ",")
+}
+
 func NewClusterStatusJsonCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "json",
@@ -308,14 +478,15 @@ func NewClusterStatusJsonCommand() *cobra.Command {
 
 func clusterStatusJsonCommandFn(cmd *cobra.Command, args []string) {
 	type Status struct {
-		Id          string `json:"id"`
-		Raft        string `json:"raft"`
-		MysqldInfo  string `json:"mysqld-info"`
-		MonitorInfo string `json:"monitor-info"`
-		BackupInfo  string `json:"backup-info"`
-		MysqlInfo   string `json:"mysql-info"`
-		SlaveInfo   string `json:"slave-info"`
-		MyLeader    string `json:"myleader"`
+		Id          string            `json:"id"`
+		Raft        string            `json:"raft"`
+		MysqldInfo  string            `json:"mysqld-info"`
+		MonitorInfo string            `json:"monitor-info"`
+		BackupInfo  string            `json:"backup-info"`
+		MysqlInfo   string            `json:"mysql-info"`
+		SlaveInfo   string            `json:"slave-info"`
+		MyLeader    string            `json:"myleader"`
+		Labels      map[string]string `json:"labels"`
 	}
 
 	type StatusList struct {
@@ -342,6 +513,7 @@ func clusterStatusJsonCommandFn(cmd *cobra.Command, args []string) {
 				status.Raft = fmt.Sprintf("[ViewID:%v EpochID:%v]@%v",
 					rsp.ViewID, rsp.EpochID, rsp.State)
 				status.MyLeader = rsp.GetLeader()
+				status.Labels = rsp.Labels
 			}
 		}
 
@@ -426,6 +598,7 @@ func clusterGTIDCommandFn(cmd *cobra.Command, args []string) {
 		"Mysql",
 		"Executed_GTID_Set",
 		"Retrieved_GTID_Set",
+		"Channels",
 	}
 
 	callx.PrintQueryOutput(columns, rows)
@@ -443,11 +616,12 @@ func NewClusterGTIDJsonCommand() *cobra.Command {
 
 func clusterGTIDJsonCommandFn(cmd *cobra.Command, args []string) {
 	type GTID struct {
-		ID               string `json:"id"`
-		Raft             string `json:"raft"`
-		Mysql            string `json:"mysql"`
-		ExecutedGTIDSet  string `json:"executed-gtid-set"`
-		RetrievedGTIDSet string `json:"retrieved-gtid-set"`
+		ID               string   `json:"id"`
+		Raft             string   `json:"raft"`
+		Mysql            string   `json:"mysql"`
+		ExecutedGTIDSet  string   `json:"executed-gtid-set"`
+		RetrievedGTIDSet string   `json:"retrieved-gtid-set"`
+		Channels         []string `json:"channels"`
 	}
 
 	type GTIDList struct {
@@ -472,6 +646,7 @@ func clusterGTIDJsonCommandFn(cmd *cobra.Command, args []string) {
 			GTID.Mysql = rsp.Status
 			GTID.ExecutedGTIDSet = strings.ReplaceAll(rsp.GTID.Executed_GTID_Set, "\n", "")
 			GTID.RetrievedGTIDSet = strings.ReplaceAll(rsp.GTID.Retrieved_GTID_Set, "\n", "")
+			GTID.Channels = clusterGTIDChannels(rsp.Channels)
 		}
 	}
 	for _, node := range nodes {
@@ -506,6 +681,7 @@ func clusterGTIDCommandGetRow(node string, raftState string) []string {
 	mysqlInfo := "UNKNOW"
 	Executed_GTID_Set := "UNKNOW"
 	Retrieved_GTID_Set := "UNKNOW"
+	Channels := "UNKNOW"
 
 	// mysql
 	{
@@ -513,6 +689,7 @@ func clusterGTIDCommandGetRow(node string, raftState string) []string {
 			mysqlInfo = rsp.Status
 			Executed_GTID_Set = rsp.GTID.Executed_GTID_Set
 			Retrieved_GTID_Set = rsp.GTID.Retrieved_GTID_Set
+			Channels = strings.Join(clusterGTIDChannels(rsp.Channels), ",")
 		}
 	}
 
@@ -522,11 +699,34 @@ func clusterGTIDCommandGetRow(node string, raftState string) []string {
 		strings.TrimSpace(mysqlInfo),
 		strings.TrimSpace(Executed_GTID_Set),
 		strings.TrimSpace(Retrieved_GTID_Set),
+		Channels,
 	}
 
 	return row
 }
 
+// clusterGTIDChannels returns the channels as 'name[IO/SQL]', the default channel is shown as two quotes.
+func clusterGTIDChannels(channels []model.GTID) []string {
+	var list []string
+	for _, channel := range channels {
+		name := channel.Channel_Name
+		if name == "" {
+			name = "''"
+		}
+		list = append(list, fmt.Sprintf("%s[%s/%s]", name, channel.Slave_IO_Running_Str, channel.Slave_SQL_Running_Str))
+	}
+	return list
+}
+
+// clusterSemiSync returns the semi-sync health as 'master/slave status clients waits no_tx wait_point'.
+func clusterSemiSync(stats *model.MysqlStats) string {
+	if stats == nil || stats.SemiSync == nil {
+		return "UNKNOW"
+	}
+	s := stats.SemiSync
+	return fmt.Sprintf("[%v/%v] clients:%v waits:%v no_tx:%v %v", s.MasterStatus, s.SlaveStatus, s.Clients, s.TxWaits, s.NoTx, s.WaitPoint)
+}
+
 // mysqlstatus
 func NewClusterMysqlCommand() *cobra.Command {
 	cmd := &cobra.Command{
@@ -557,6 +757,7 @@ func clusterMysqlCommandFn(cmd *cobra.Command, args []string) {
 		Master_Log_File := "UNKNOW"
 		slaveInfo := "UNKNOW"
 		Seconds_Behind_Master := "UNKNOW"
+		semiSync := "UNKNOW"
 		Last_Error := "UNKNOW"
 
 		// raft
@@ -577,6 +778,7 @@ func clusterMysqlCommandFn(cmd *cobra.Command, args []string) {
 					rsp.GTID.Slave_IO_Running,
 					rsp.GTID.Slave_SQL_Running)
 				Seconds_Behind_Master = rsp.GTID.Seconds_Behind_Master
+				semiSync = clusterSemiSync(rsp.Stats)
 				Last_Error = rsp.GTID.Last_Error
 			}
 		}
@@ -589,6 +791,7 @@ func clusterMysqlCommandFn(cmd *cobra.Command, args []string) {
 			Master_Log_File,
 			strings.TrimSpace(slaveInfo),
 			Seconds_Behind_Master,
+			strings.TrimSpace(semiSync),
 			Last_Error,
 		}
 		rows = append(rows, row)
@@ -602,6 +805,7 @@ func clusterMysqlCommandFn(cmd *cobra.Command, args []string) {
 		"Master_Log_File/Pos",
 		"IO/SQL_Running",
 		"Seconds_Behind",
+		"Semi-Sync",
 		"Last_Error",
 	}
 
@@ -657,6 +861,9 @@ func clusterRaftCommandFn(cmd *cobra.Command, args []string) {
 			fmt.Sprintf("%v", stats.LeaderGetVoteRequests),
 			fmt.Sprintf("%v", stats.CandidatePromotes),
 			fmt.Sprintf("%v", stats.CandidateDegrades),
+			fmt.Sprintf("%v", stats.LeaderSemiSyncFallbacks),
+			fmt.Sprintf("%v", stats.FollowerRefusedFailovers),
+			fmt.Sprintf("%v", stats.SemiSyncDegraded),
 			fmt.Sprintf("%v", stats.RaftMysqlStatus),
 			fmt.Sprintf("%v", stats.StateUptimes),
 		}
@@ -673,6 +880,9 @@ func clusterRaftCommandFn(cmd *cobra.Command, args []string) {
 		"LGetVotes",
 		"CPromotes",
 		"CDegrades",
+		"LSemiFallbacks",
+		"FRefusedFailovers",
+		"SemiSyncDegraded",
 		"Raft@Mysql",
 		"StateUptimes(sec)",
 	}
@@ -878,3 +1088,28 @@ func clusterLogCommandFn(cmd *cobra.Command, args []string) {
 	cluster.Sync()
 	log.Warning("log: %s", clusterLog)
 }
+
+func NewClusterWatchLeaderCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "watchleader",
+		Short: "print the leader in JSON at first and on every change, until it's interrupted",
+		Run:   clusterWatchLeaderCommandFn,
+	}
+
+	return cmd
+}
+
+func clusterWatchLeaderCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		ErrorOK(fmt.Errorf("too.many.args"))
+	}
+
+	conf, err := GetConfig()
+	ErrorOK(err)
+	err = callx.WatchClusterLeader(conf.Server.Endpoint, 30000, func(view model.LeaderView) bool {
+		viewB, _ := json.Marshal(view)
+		fmt.Printf("%s\n", string(viewB))
+		return true
+	})
+	ErrorOK(err)
+}
@@ -13,9 +13,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"model"
-	"path"
-	"strconv"
-	"strings"
+	"mysql"
+	"raft"
 	"time"
 	"xbase/common"
 
@@ -33,6 +32,8 @@ func NewMysqlCommand() *cobra.Command {
 	cmd.AddCommand(NewMysqlStartCommand())
 	cmd.AddCommand(NewMysqlShutDownCommand())
 	cmd.AddCommand(NewMysqlRebuildMeCommand())
+	cmd.AddCommand(NewMysqlRestoreCommand())
+	cmd.AddCommand(NewMysqlDelayedCatchupCommand())
 	cmd.AddCommand(NewMysqlDoBackupCommand())
 	cmd.AddCommand(NewMysqlCancelBackupCommand())
 	cmd.AddCommand(NewMysqlCreateUserCommand())
@@ -42,6 +43,7 @@ func NewMysqlCommand() *cobra.Command {
 	cmd.AddCommand(NewMysqlSetVarCommand())
 	cmd.AddCommand(NewMysqlKillCommand())
 	cmd.AddCommand(NewMysqlStatusCommand())
+	cmd.AddCommand(NewMysqlPreflightCommand())
 	cmd.AddCommand(NewMysqlCreateUserWithPrivilegesCommand())
 	cmd.AddCommand(NewMysqlGetUserCommand())
 
@@ -152,70 +154,81 @@ func mysqlShutDownCommandFn(cmd *cobra.Command, args []string) {
 var (
 	fromStr string
 	force   bool
+	rateStr string
 )
 
 func NewMysqlRebuildMeCommand() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "rebuildme [--from=endpoint][--force]",
-		Short: "rebuild a slave --from=endpoint --force",
+		Use:   "rebuildme [--from=endpoint][--force][--rate=100MB]",
+		Short: "rebuild a slave --from=endpoint --force --rate=100MB",
 		Run:   mysqlRebuildMeCommandFn,
 	}
 	cmd.Flags().StringVar(&fromStr, "from", "", "--from=endpoint")
 	cmd.Flags().BoolVar(&force, "force", false, "--force")
+	cmd.Flags().StringVar(&rateStr, "rate", "", "--rate=100MB, the bytes per second limit of the backup stream, requires backup-transport xenon")
+	cmd.AddCommand(NewMysqlRebuildMeStatusCommand())
+	cmd.AddCommand(NewMysqlRebuildMeCancelCommand())
+	cmd.AddCommand(NewMysqlRebuildMeResumeCommand())
 
 	return cmd
 }
 
-func getLocalTrxCount(self string, bestone string) (int, error) {
-	count := 0
-
-	rsp1, err := callx.GetGTIDRPC(bestone)
-	if err != nil {
-		return -1, fmt.Errorf("get.gtid.from.bestone[%v].failed[%v]", bestone, err)
-	} else if rsp1.GTID.Executed_GTID_Set == "" {
-		return -1, fmt.Errorf("the.Executed_GTID_Set.of.bestone[%v].is.null", bestone)
-	}
-	fromGTID := rsp1.GTID
-
-	rsp1, err = callx.GetGTIDRPC(self)
-	if err != nil {
-		return -1, fmt.Errorf("get.gtid.from.myself[%v].failed[%v]", self, err)
-	} else if rsp1.GTID.Executed_GTID_Set == "" {
-		return -1, fmt.Errorf("the.Executed_GTID_Set.of.myself[%v].is.null", self)
-	}
-	localGTID := rsp1.GTID
-
-	rsp2, err := callx.GetGTIDSubtractRPC(self, localGTID.Executed_GTID_Set, fromGTID.Executed_GTID_Set)
-	if err != nil {
-		return -1, fmt.Errorf("get.gtid.subtract.from.self[%v].failed[%v]", self, err)
-	}
-	subtract := rsp2.Subtract
-
-	// compute the number of local transactions
-	gtidSet := strings.Split(subtract, "\n")
-	for _, gtid := range gtidSet {
-		gtid = strings.TrimSpace(gtid)
-		gtid = strings.TrimSuffix(gtid, ",")
-		diffs := strings.Split(gtid, ":")[1:]
-		for _, diff := range diffs {
-			values := strings.Split(diff, "-")
-			if len(values) == 1 {
-				count += 1
-			} else {
-				s, _ := strconv.Atoi(values[0])
-				e, _ := strconv.Atoi(values[1])
-				count += e - s + 1
+// waitRebuildDone used to follow the rebuild job on the server until it exits,
+// the progress of xtrabackup and apply-log is printed in place.
+func waitRebuildDone(self string) {
+	step := -1
+	progress := false
+	for {
+		rsp, err := callx.RebuildStatusRPC(self)
+		ErrorOK(err)
+		RspOK(rsp.RetCode)
+
+		job := rsp.Job
+		if job.Step != step || job.State != model.REBUILD_RUNNING {
+			if progress {
+				fmt.Println()
+				progress = false
 			}
 		}
-	}
+		if job.State == model.REBUILD_RUNNING && job.Step != step {
+			step = job.Step
+			log.Warning("S%d/%d-->%s....", job.Step+1, job.Steps, job.StepName)
+		}
 
-	return count, nil
+		switch job.State {
+		case model.REBUILD_RUNNING:
+			switch job.StepName {
+			case "xtrabackup":
+				printBackupProgress(job.From)
+				progress = true
+			case "apply-log":
+				printBackupProgress(self)
+				progress = true
+			}
+			time.Sleep(time.Second * progressBarInterval)
+		case model.REBUILD_DONE:
+			log.Warning("completed OK!")
+			log.Warning("rebuildme.all.done....")
+			return
+		case model.REBUILD_CANCELED:
+			log.Warning("rebuildme.canceled.at.step[%v]....", job.Step+1)
+			return
+		default:
+			log.Panic("rebuildme.%v.at.step[%v].error[%v].you.can.resume.or.cancel.it", job.State, job.Step+1, job.LastError)
+		}
+	}
 }
 
 func mysqlRebuildMeCommandFn(cmd *cobra.Command, args []string) {
 	if len(args) != 0 {
 		ErrorOK(fmt.Errorf("too.many.args"))
 	}
+	var rate int64
+	if rateStr != "" {
+		r, err := parseBytes(rateStr)
+		ErrorOK(err)
+		rate = r
+	}
 
 	log.Warning(`=====prepare.to.rebuildme=====
 			IMPORTANT: Please check that the backup run completes successfully.
@@ -227,304 +240,189 @@ func mysqlRebuildMeCommandFn(cmd *cobra.Command, args []string) {
 	ErrorOK(err)
 
 	self := conf.Server.Endpoint
-	bestone := ""
-	version := strings.TrimSpace(conf.Mysql.Version)
-	datadir := conf.Backup.BackupDir
-	binlogDir := ""
-	binlogPrefix := ""
-	maxAllowedLocalTrxCount := conf.Backup.MaxAllowedLocalTrxCount
+	rsp, err := callx.RebuildStartRPC(self, fromStr, force, rate)
+	ErrorOK(err)
+	RspOK(rsp.RetCode)
+	waitRebuildDone(self)
+}
 
-	// 1. first to check I am leader or not
-	{
-		log.Warning("S1-->check.raft.leader")
-		leader, err := callx.GetClusterLeader(self)
-		ErrorOK(err)
-		if leader == self {
-			log.Panic("I[%v].am.leader.you.cant.rebuildme.sir", self)
-		}
+func NewMysqlRebuildMeStatusCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "rebuild job status in JSON",
+		Run:   mysqlRebuildMeStatusCommandFn,
 	}
 
-	// 2. find the best to backup and check gtid
-	{
-		if fromStr != "" {
-			bestone = fromStr
-		} else {
-			bestone, err = callx.FindBestoneForBackup(self)
-			ErrorOK(err)
-		}
-		log.Warning("S2-->prepare.rebuild.from[%v]....", bestone)
+	return cmd
+}
 
-		// check if there are more than 2 local transactions on the local node than bestone
-		if force {
-			log.Warning("S2-->the.[--force].is.specified.skip.check.gtid")
-		} else {
-			working, err := callx.MysqlIsWorkingRPC(self)
-			ErrorOK(err)
-			if working == false {
-				log.Panic("local.mysql.is.not.working.you.cant.rebuildme.sir")
-			}
-			localTrxCount, err := getLocalTrxCount(self, bestone)
-			ErrorOK(err)
-			if localTrxCount > maxAllowedLocalTrxCount {
-				log.Panic("I[%v].have.[%v].local.transactions.more.than.maxAllowedLocalTrxCount[%v].compared.to.from[%v].you.cant.rebuildme.sir", self, localTrxCount, maxAllowedLocalTrxCount, bestone)
-			}
-		}
+func mysqlRebuildMeStatusCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		ErrorOK(fmt.Errorf("too.many.args"))
 	}
 
-	// 3. check bestone is not in BACKUPING
-	{
-		rsp, err := callx.GetMysqldStatusRPC(bestone)
-		ErrorOK(err)
-		if rsp.BackupStatus == model.MYSQLD_BACKUPING {
-			log.Warning("S3-->check.bestone[%v].is.backuping....", bestone)
-			log.Panic("bestone[%v].is.backuping....", bestone)
-		}
-		log.Warning("S3-->check.bestone[%v].is.OK....", bestone)
-	}
+	conf, err := GetConfig()
+	ErrorOK(err)
 
-	// 4. set learner
-	{
-		log.Warning("S4-->set.learner")
-		if _, err := callx.SetLearnerRPC(self); err != nil {
-			log.Error("SetLearnerRPC.error[%v]", err)
-		}
+	rsp, err := callx.RebuildStatusRPC(conf.Server.Endpoint)
+	ErrorOK(err)
+	RspOK(rsp.RetCode)
+
+	jobB, _ := json.Marshal(rsp.Job)
+	fmt.Printf("%s", string(jobB))
+}
+
+func NewMysqlRebuildMeCancelCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cancel",
+		Short: "cancel the running rebuild job, or roll back the failed one",
+		Run:   mysqlRebuildMeCancelCommandFn,
 	}
 
-	// 5. stop monitor
-	{
-		log.Warning("S5-->stop.monitor")
-		callx.StopMonitorRPC(self)
+	return cmd
+}
+
+func mysqlRebuildMeCancelCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		ErrorOK(fmt.Errorf("too.many.args"))
 	}
 
-	// 6. force kill mysqld
-	{
-		log.Warning("S6-->kill.mysql")
-		err := callx.KillMysqldRPC(self)
-		ErrorOK(err)
+	conf, err := GetConfig()
+	ErrorOK(err)
 
-		// wait
-		err = callx.WaitMysqldShutdownRPC(self)
-		ErrorOK(err)
+	self := conf.Server.Endpoint
+	log.Warning("rebuildme.cancel.begin....")
+	rsp, err := callx.RebuildCancelRPC(self)
+	ErrorOK(err)
+	RspOK(rsp.RetCode)
+	waitRebuildDone(self)
+}
 
-		// set the mysql state to dead, avoid failure to rebuild node with small amounts of data
-		err = callx.SetMysqlStateRPC(self, model.MysqlDead)
-		ErrorOK(err)
+func NewMysqlRebuildMeResumeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "resume",
+		Short: "resume the failed or interrupted rebuild job",
+		Run:   mysqlRebuildMeResumeCommandFn,
 	}
 
-	// 7. check bestone is not in BACKUPING again
-	{
-		rsp, err := callx.GetMysqldStatusRPC(bestone)
-		ErrorOK(err)
-		if rsp.BackupStatus == model.MYSQLD_BACKUPING {
-			log.Warning("S7-->check.bestone[%v].is.backuping....", bestone)
-			log.Panic("bestone[%v].is.backuping....", bestone)
-		}
-		log.Warning("S7-->check.bestone[%v].is.OK....", bestone)
+	return cmd
+}
+
+func mysqlRebuildMeResumeCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		ErrorOK(fmt.Errorf("too.many.args"))
 	}
 
-	// 8. remove data files
-	{
-		// remove mysql data
-		cmds := "bash"
-		args := []string{
-			"-c",
-			fmt.Sprintf("rm -rf %s/*", datadir),
-		}
+	conf, err := GetConfig()
+	ErrorOK(err)
 
-		_, err := common.RunCommand(cmds, args...)
-		ErrorOK(err)
-		log.Warning("S8-->clear.datadir[%v]", datadir)
-
-		/*
-			Remove mysql binlog and index, considering that mysql binlog or index may not be in the same directory as the data.
-			For example, The contents of file my.cnf are as follows:
-
-			#log-bin=/data/mysql-log/mysql-bin/mysql-bin
-			log-bin=./mysql-bin
-			log-bin=/data/mysql-log/mysql-bin/mysql-bin
-			#log-bin=/data/mysql/mysql-bin
-			log-bin-index=/data/mysql/mysql-bin.index
-			log-bin-index=/data/mysql-log/mysql-bin/mysql-bin.index
-			log-bin-index=./mysql-bin.index
-			#log-bin-index=/data/mysql-log/mysql-bin/mysql-bin.index
-
-			The following shell instruction resolves that the paths of log-bin and log-bin-index are
-			/data/mysql-log/mysql-bin/mysql-bin and ./mysql-bin.index respectively.
-		*/
-		args = []string{
-			"-c",
-			fmt.Sprintf("grep 'log-bin=' %s | sed -r '/^#/d' | awk -F '=' '{print $2}' | tail -n 1", conf.Mysql.DefaultsFile),
-		}
-		binlogPrefix, err = common.RunCommand(cmds, args...)
-		ErrorOK(err)
-		binlogPrefix = strings.TrimSpace(binlogPrefix)
-		if binlogPrefix != "" && strings.Index(binlogPrefix, "/") == 0 {
-			binlogDir = path.Dir(binlogPrefix)
-			if binlogDir != path.Dir(datadir+"/") {
-				log.Warning("mysql.binlog.dir[%v].is.different.from.data.dir[%v]", binlogDir, datadir)
-				args = []string{
-					"-c",
-					fmt.Sprintf("rm -f %s/*", binlogDir),
-				}
-
-				_, err := common.RunCommand(cmds, args...)
-				ErrorOK(err)
-				log.Warning("S8-->clear.mysql.binlog[%v.*]", binlogPrefix)
-			}
-		}
+	self := conf.Server.Endpoint
+	log.Warning("rebuildme.resume.begin....")
+	rsp, err := callx.RebuildResumeRPC(self)
+	ErrorOK(err)
+	RspOK(rsp.RetCode)
+	waitRebuildDone(self)
+}
 
-		args = []string{
-			"-c",
-			fmt.Sprintf("grep 'log-bin-index=' %s | sed -r '/^#/d' | awk -F '=' '{print $2}' | tail -n 1", conf.Mysql.DefaultsFile),
-		}
-		indexPath, err := common.RunCommand(cmds, args...)
-		ErrorOK(err)
-		if indexPath != "" && strings.Index(indexPath, "/") == 0 {
-			indexDir := path.Dir(indexPath)
-			if indexDir != path.Dir(datadir+"/") {
-				log.Warning("mysql.binlog.index[%v].is.not.in.data.dir[%v]", indexPath, datadir)
-				args = []string{
-					"-c",
-					fmt.Sprintf("rm -f %s", indexPath),
-				}
-				_, err := common.RunCommand(cmds, args...)
-				ErrorOK(err)
-				log.Warning("S8-->clear.mysql.binlog.index[%v]", indexPath)
-			}
-		}
-	}
+// point-in-time restore
+var (
+	toTime string
+	toGTID string
+)
 
-	// 9. do backup from bestone
-	{
-		log.Warning("S9-->xtrabackup.begin....")
-		rsp, err := callx.RequestBackupRPC(bestone, conf, datadir)
-		ErrorOK(err)
-		RspOK(rsp.RetCode)
-		log.Warning("S9-->xtrabackup.end....")
+func NewMysqlRestoreCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore <backup-id> [--to-time='2006-01-02 15:04:05'][--to-gtid=gtidset]",
+		Short: "restore the local backup archive to this mysql and replay the archived binlogs to the point in time",
+		Run:   mysqlRestoreCommandFn,
 	}
+	cmd.Flags().StringVar(&toTime, "to-time", "", "--to-time='2006-01-02 15:04:05'")
+	cmd.Flags().StringVar(&toGTID, "to-gtid", "", "--to-gtid=gtidset")
 
-	// 10. do apply-log
-	{
-		log.Warning("S10-->apply-log.begin....")
-		err := callx.DoApplyLogRPC(conf.Server.Endpoint, datadir)
-		ErrorOK(err)
+	return cmd
+}
 
-		if version == "mysql80" {
-			/*
-				For 5.7, mysql will not work properly if log-bin-index is specified and log-bin is not specified.
-				But For 8.0, it works fine, mysql will automatically generate a new file based on the current serial number.
-
-				Xtrabackup will copy the nearest binlog from the source to the current data directory,
-				therefore, you need to move the last binlog to the directory specified by log-bin.
-			*/
-			if binlogDir != "" && strings.Index(binlogDir, "/") == 0 {
-				datadir2 := path.Dir(datadir + "/")
-				// if the binlog path is absolute and different from mysql data directory, move the binlog
-				if binlogDir != datadir2 {
-					log.Warning("mysql.binlog.dir[%v].is.different.from.data.dir[%v]", binlogDir, datadir2)
-					binlogBase := path.Base(binlogPrefix)
-					cmds := "bash"
-					args = []string{
-						"-c",
-						fmt.Sprintf("mv %s/%s.* %s", datadir2, binlogBase, binlogDir),
-					}
-					_, err := common.RunCommand(cmds, args...)
-					ErrorOK(err)
-					log.Warning("move.binlog[%v/%v.*].to.dir[%v]", datadir2, binlogBase, binlogDir)
-				}
-			}
-		}
-		log.Warning("S10-->apply-log.end....")
+func mysqlRestoreCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		ErrorOK(fmt.Errorf("backup.id.is.nil"))
 	}
-
-	// 11. start mysqld
-	{
-		log.Warning("S11-->start.mysql.begin...")
-		if _, err := callx.StartMonitorRPC(self); err != nil {
-			log.Error("start.mysql..error[%v]", err)
-		}
-		log.Warning("S11-->start.mysql.end...")
+	if (toTime == "") == (toGTID == "") {
+		ErrorOK(fmt.Errorf("restore.needs.one.of.[--to-time|--to-gtid]"))
 	}
 
-	// 12. wait mysqld running
-	{
-		log.Warning("S12-->wait.mysqld.running.begin....")
-		callx.WaitMysqldRunningRPC(self)
-		log.Warning("S12-->wait.mysqld.running.end....")
-	}
+	log.Warning(`=====prepare.to.restore=====
+			IMPORTANT: The local mysql is restored to the point in time,
+			           the node is kept out of the raft after restored.
+			           The restore runs on xenon, follow it by 'mysql rebuildme status'.
+			`)
 
-	// 13. wait mysql working
-	{
-		log.Warning("S13-->wait.mysql.working.begin....")
-		callx.WaitMysqlWorkingRPC(self)
-		log.Warning("S13-->wait.mysql.working.end....")
-	}
+	conf, err := GetConfig()
+	ErrorOK(err)
+	self := conf.Server.Endpoint
 
-	// 14. stop slave and reset slave all
-	{
-		log.Warning("S14-->stop.and.reset.slave.begin....")
-		if _, err := callx.MysqlResetSlaveAllRPC(self); err != nil {
-			log.Error("mysql.stop.adn.reset.slave.error[%v]", err)
-		}
-		log.Warning("S14-->stop.and.reset.slave.end....")
+	rsp, err := callx.RestoreStartRPC(self, args[0], toTime, toGTID)
+	ErrorOK(err)
+	RspOK(rsp.RetCode)
+	waitRebuildDone(self)
+
+	rsp, err = callx.RebuildStatusRPC(self)
+	ErrorOK(err)
+	RspOK(rsp.RetCode)
+	if rsp.Job.State == model.REBUILD_DONE {
+		log.Warning("restore.all.done.the.raft.is.disabled.please.check.the.data.and.the.old.datadir.is.kept.in[%v]....", rsp.Job.RestoreAside)
 	}
+}
 
-	// 15. set gtid_purged
-	{
-		log.Warning("S15-->reset.master.begin....")
-		if version == "mysql80" {
-			log.Warning("S15-->reset.master.skip.mysql80")
-		} else {
-			callx.MysqlResetMasterRPC(self)
-			log.Warning("S15-->reset.master.end....")
-
-			gtid, err := callx.GetXtrabackupGTIDPurged(self, datadir)
-			ErrorOK(err)
+var (
+	untilGTID     string
+	delayedResume bool
+)
 
-			log.Warning("S15-->set.gtid_purged[%v].begin....", gtid)
-			rsp, err := callx.SetGlobalVarRPC(self, fmt.Sprintf("SET GLOBAL gtid_purged='%s'", gtid))
-			ErrorOK(err)
-			RspOK(rsp.RetCode)
-			log.Warning("S15-->set.gtid_purged.end....")
-		}
+func NewMysqlDelayedCatchupCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delayed-catchup [--until-gtid=gtid][--resume]",
+		Short: "roll the delayed replica forward to just before the gtid, or resume the delay",
+		Run:   mysqlDelayedCatchupCommandFn,
 	}
+	cmd.Flags().StringVar(&untilGTID, "until-gtid", "", "--until-gtid=gtid, the gtid of the bad statement which is not applied")
+	cmd.Flags().BoolVar(&delayedResume, "resume", false, "--resume, restore the replication-delay-seconds and release the sql thread")
 
-	// 16. enable raft
-	{
-		// check whether the state is IDLE or not
-		if conf.Raft.SuperIDLE {
-			log.Warning("S16-->disable.raft.again...")
-			if _, err := callx.DisableRaftRPC(self); err != nil {
-				log.Error("enbleRaftRPC.error[%v]", err)
-			}
-			log.Warning("S16-->run.as.IDLE...")
-		} else {
-			log.Warning("S16-->enable.raft.begin...")
-			if _, err := callx.EnableRaftRPC(self); err != nil {
-				log.Error("enbleRaftRPC.error[%v]", err)
-			}
-			log.Warning("S16-->enable.raft.done...")
-		}
+	return cmd
+}
+
+func mysqlDelayedCatchupCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		ErrorOK(fmt.Errorf("too.many.args"))
+	}
+	if (untilGTID == "") == !delayedResume {
+		ErrorOK(fmt.Errorf("delayed-catchup.needs.one.of.[--until-gtid|--resume]"))
 	}
 
-	// 17. wait change to master
-	{
-		log.Warning("S17-->wait[%v ms].change.to.master...", conf.Raft.ElectionTimeout)
-		time.Sleep(time.Duration(conf.Raft.ElectionTimeout))
+	conf, err := GetConfig()
+	ErrorOK(err)
+	self := conf.Server.Endpoint
+	delay := conf.Raft.ReplicationDelaySeconds
+	if delay <= 0 {
+		ErrorOK(fmt.Errorf("I[%v].am.not.a.delayed.replica.the.replication-delay-seconds.is[%v]", self, delay))
 	}
 
-	// 18. start slave
-	{
-		log.Warning("S18-->start.slave.begin....")
-		if _, err := callx.MysqlStartSlaveRPC(self); err != nil {
-			log.Error("mysql.start.slave.error[%v]", err)
-		} else {
-			log.Warning("S18-->start.slave.end....")
-		}
+	if delayedResume {
+		rsp, err := callx.MysqlDelayedResumeRPC(self, delay)
+		ErrorOK(err)
+		RspOK(rsp.RetCode)
+		log.Warning("delayed.catchup.resume.the.delay[%v].done....", delay)
+		return
 	}
 
-	log.Warning("completed OK!")
-	log.Warning("rebuildme.all.done....")
+	log.Warning(`=====prepare.to.catch.up=====
+			IMPORTANT: The sql thread applies the relay logs without delay and stops before the gtid,
+			           xenon doesn't start it or change the master until 'delayed-catchup --resume'.
+			`)
+	rsp, err := callx.MysqlDelayedCatchupRPC(self, untilGTID, delay)
+	ErrorOK(err)
+	RspOK(rsp.RetCode)
+	log.Warning("delayed.catchup.until.before.gtid[%v].started....", untilGTID)
+	log.Warning("please.check.the.Slave_SQL_Running.with.'xenoncli mysql status'.it.stops.before.the.gtid....")
 }
 
 var (
@@ -579,7 +477,10 @@ func mysqlDoBackupCommandFn(cmd *cobra.Command, args []string) {
 	// 3. do backup from bestone
 	{
 		log.Warning("S3-->xtrabackup.begin....")
-		rsp, err := callx.RequestBackupRPC(bestone, conf, backupdir)
+		var rsp *model.BackupRPCResponse
+		runWithBackupProgress(bestone, func() {
+			rsp, err = callx.RequestBackupRPC(bestone, conf, backupdir, 0)
+		})
 		ErrorOK(err)
 		RspOK(rsp.RetCode)
 		log.Warning("S3-->xtrabackup.end....")
@@ -588,7 +489,9 @@ func mysqlDoBackupCommandFn(cmd *cobra.Command, args []string) {
 	// 4. do apply-log
 	{
 		log.Warning("S4-->apply-log.begin....")
-		err := callx.DoApplyLogRPC(conf.Server.Endpoint, backupdir)
+		runWithBackupProgress(self, func() {
+			err = callx.DoApplyLogRPC(self, conf, backupdir)
+		})
 		ErrorOK(err)
 		log.Warning("S4-->apply-log.end....")
 	}
@@ -821,14 +724,24 @@ func NewMysqlStatusCommand() *cobra.Command {
 }
 
 func mysqlStatusCommandFn(cmd *cobra.Command, args []string) {
-	type Status struct {
+	type Channel struct {
+		Channel_name          string `json:"channel_name"`
 		Slave_io_running      bool   `json:"slave_io_running"`
 		Slave_sql_running     bool   `json:"slave_sql_running"`
-		Mysqldrunning         bool   `json:"mysqld_running"`
-		Mysqlworking          bool   `json:"mysql_working"`
 		Seconds_behind_master string `json:"seconds_behind_master"`
+		Executed_gtid_set     string `json:"executed_gtid_set"`
+		Retrieved_gtid_set    string `json:"retrieved_gtid_set"`
 		Last_error            string `json:"last_error"`
-		Monitor               string `json:"monitor"`
+	}
+	type Status struct {
+		Slave_io_running      bool      `json:"slave_io_running"`
+		Slave_sql_running     bool      `json:"slave_sql_running"`
+		Mysqldrunning         bool      `json:"mysqld_running"`
+		Mysqlworking          bool      `json:"mysql_working"`
+		Seconds_behind_master string    `json:"seconds_behind_master"`
+		Last_error            string    `json:"last_error"`
+		Monitor               string    `json:"monitor"`
+		Channels              []Channel `json:"channels"`
 	}
 	status := &Status{}
 
@@ -850,6 +763,17 @@ func mysqlStatusCommandFn(cmd *cobra.Command, args []string) {
 			status.Slave_sql_running = rsp.GTID.Slave_SQL_Running
 			status.Seconds_behind_master = rsp.GTID.Seconds_Behind_Master
 			status.Last_error = rsp.GTID.Last_Error
+			for _, channel := range rsp.Channels {
+				status.Channels = append(status.Channels, Channel{
+					Channel_name:          channel.Channel_Name,
+					Slave_io_running:      channel.Slave_IO_Running,
+					Slave_sql_running:     channel.Slave_SQL_Running,
+					Seconds_behind_master: channel.Seconds_Behind_Master,
+					Executed_gtid_set:     channel.Executed_GTID_Set,
+					Retrieved_gtid_set:    channel.Retrieved_GTID_Set,
+					Last_error:            channel.Last_Error,
+				})
+			}
 
 			mysqlworking, err := callx.MysqlIsWorkingRPC(self)
 			ErrorOK(err)
@@ -865,6 +789,49 @@ func mysqlStatusCommandFn(cmd *cobra.Command, args []string) {
 	fmt.Printf("%s", string(statusB))
 }
 
+// preflight
+func NewMysqlPreflightCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "preflight",
+		Short: "probe the local mysql capabilities the HA depends on(group replication/galera/binlog/gtid/semi-sync)",
+		Run:   mysqlPreflightCommandFn,
+	}
+
+	return cmd
+}
+
+func mysqlPreflightCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		ErrorOK(fmt.Errorf("too.many.args"))
+	}
+	conf, err := GetConfig()
+	ErrorOK(err)
+
+	// connect the mysql directly, xenon refuses to start if the preflight failed
+	checks, err := mysql.NewMysql(conf.Mysql, conf.Raft.ElectionTimeout, log).Preflight()
+	ErrorOK(err)
+	semiSync := raft.IsSemiSync(conf.Raft.Durability)
+
+	var rows [][]string
+	for _, check := range checks {
+		result := "PASSED"
+		if !check.Passed {
+			result = "FAILED"
+			if !mysql.PreflightRequired(check.Name, semiSync) {
+				result = "ADVISORY"
+			}
+		}
+		rows = append(rows, []string{check.Name, result, check.Detail})
+	}
+	columns := []string{
+		"Check",
+		"Result",
+		"Detail",
+	}
+	callx.PrintQueryOutput(columns, rows)
+	ErrorOK(mysql.PreflightError(checks, semiSync))
+}
+
 var (
 	grantUser     string
 	grantPasswd   string
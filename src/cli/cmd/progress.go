@@ -0,0 +1,119 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package cmd
+
+import (
+	"cli/callx"
+	"fmt"
+	"model"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	progressBarWidth    = 30
+	progressBarInterval = 2
+)
+
+// humanBytes returns the bytes in B/KB/MB/GB/TB.
+func humanBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := uint64(unit), 0
+	for i := n / unit; i >= unit && exp < 3; i /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "KMGT"[exp])
+}
+
+// parseBytes parses the bytes in B/KB/MB/GB/TB(or K/M/G/T), such as 100MB.
+func parseBytes(s string) (int64, error) {
+	str := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	unit := int64(1)
+	if n := len(str); n > 0 {
+		if idx := strings.IndexByte("KMGT", str[n-1]); idx >= 0 {
+			for i := 0; i <= idx; i++ {
+				unit *= 1024
+			}
+			str = str[:n-1]
+		}
+	}
+	n, err := strconv.ParseFloat(str, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("bytes[%v].invalid", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// progressBar renders the backup progress in one line, such as:
+// [=========>                    ]  33.3% 1.2GB/3.6GB files:120 lsn:2616853 COPYING_INNODB eta:3m0s
+func progressBar(p *model.BackupProgress) string {
+	filled := int(p.Percent * progressBarWidth / 100)
+	if filled > progressBarWidth {
+		filled = progressBarWidth
+	}
+	bar := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+
+	size := humanBytes(p.CopiedBytes)
+	if p.TotalBytes > 0 {
+		size += "/" + humanBytes(p.TotalBytes)
+	}
+
+	eta := "unknown"
+	if d := p.ETA(); d >= 0 {
+		eta = d.String()
+	}
+	return fmt.Sprintf("[%s] %5.1f%% %s files:%d lsn:%d %s eta:%s", bar, p.Percent, size, p.CopiedFiles, p.LSN, p.Phase, eta)
+}
+
+// printBackupProgress used to print the backup/applylog progress of the node in place.
+func printBackupProgress(node string) {
+	rsp, err := callx.GetBackupStatusRPC(node)
+	if err != nil || rsp.RetCode != model.OK || rsp.Stats == nil {
+		return
+	}
+	fmt.Printf("\r%s", progressBar(&rsp.Stats.Progress))
+}
+
+// runWithBackupProgress used to run fn and print the backup/applylog progress of the node until fn returns.
+func runWithBackupProgress(node string, fn func()) {
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(time.Second * progressBarInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				printBackupProgress(node)
+			}
+		}
+	}()
+
+	defer func() {
+		close(done)
+		wg.Wait()
+		printBackupProgress(node)
+		fmt.Println()
+	}()
+	fn()
+}
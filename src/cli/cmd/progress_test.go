@@ -0,0 +1,86 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package cmd
+
+import (
+	"model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHumanBytes(t *testing.T) {
+	assert.Equal(t, "512B", humanBytes(512))
+	assert.Equal(t, "1.0KB", humanBytes(1024))
+	assert.Equal(t, "1.5MB", humanBytes(1024*1024*3/2))
+	assert.Equal(t, "2.0GB", humanBytes(2*1024*1024*1024))
+	assert.Equal(t, "4.0TB", humanBytes(4*1024*1024*1024*1024))
+	assert.Equal(t, "4096.0TB", humanBytes(4*1024*1024*1024*1024*1024))
+}
+
+func TestParseBytes(t *testing.T) {
+	for s, want := range map[string]int64{
+		"512":   512,
+		"512B":  512,
+		"10K":   10 * 1024,
+		"100MB": 100 * 1024 * 1024,
+		"1.5mb": 1024 * 1024 * 3 / 2,
+		"2G":    2 * 1024 * 1024 * 1024,
+		" 1TB ": 1024 * 1024 * 1024 * 1024,
+		"0":     0,
+	} {
+		got, err := parseBytes(s)
+		assert.Nil(t, err)
+		assert.Equal(t, want, got, s)
+	}
+
+	for _, s := range []string{"", "MB", "x10M", "-1M"} {
+		_, err := parseBytes(s)
+		assert.Equal(t, "bytes["+s+"].invalid", err.Error())
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	// unknown total.
+	{
+		p := &model.BackupProgress{
+			Phase:       model.BACKUP_PHASE_COPYING_INNODB,
+			CopiedFiles: 3,
+			CopiedBytes: 2048,
+			LSN:         2616853,
+		}
+		want := "[>                             ]   0.0% 2.0KB files:3 lsn:2616853 COPYING_INNODB eta:unknown"
+		assert.Equal(t, want, progressBar(p))
+	}
+
+	// half.
+	{
+		p := &model.BackupProgress{
+			Phase:       model.BACKUP_PHASE_COPYING_NON_INNODB,
+			CopiedFiles: 120,
+			CopiedBytes: 1024 * 1024,
+			TotalBytes:  2 * 1024 * 1024,
+			Percent:     50,
+			Begin:       100,
+			Update:      130,
+		}
+		want := "[===============>              ]  50.0% 1.0MB/2.0MB files:120 lsn:0 COPYING_NON_INNODB eta:30s"
+		assert.Equal(t, want, progressBar(p))
+	}
+
+	// completed.
+	{
+		p := &model.BackupProgress{
+			Phase:   model.BACKUP_PHASE_COMPLETED,
+			Percent: 100,
+		}
+		want := "[==============================] 100.0% 0B files:0 lsn:0 COMPLETED eta:0s"
+		assert.Equal(t, want, progressBar(p))
+	}
+}
@@ -12,11 +12,17 @@ import (
 	"cli/callx"
 	"encoding/json"
 	"fmt"
+	"model"
 	"strings"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	purgeConsumerBinlog string
+	purgeConsumerGTID   string
+)
+
 func NewRaftCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "raft <subcommand>",
@@ -34,6 +40,9 @@ func NewRaftCommand() *cobra.Command {
 	cmd.AddCommand(NewRaftDisablePurgeBinlogCommand())
 	cmd.AddCommand(NewRaftEnableCheckSemiSyncCommand())
 	cmd.AddCommand(NewRaftDisableCheckSemiSyncCommand())
+	cmd.AddCommand(NewRaftPurgePlanCommand())
+	cmd.AddCommand(NewRaftSetPurgeConsumerCommand())
+	cmd.AddCommand(NewRaftRemovePurgeConsumerCommand())
 
 	return cmd
 }
@@ -356,3 +365,97 @@ func raftDisableCheckSemiSyncCommandFn(cmd *cobra.Command, args []string) {
 		log.Warning("[%v].disable.check.semi-sync.done", self)
 	}
 }
+
+func NewRaftPurgePlanCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "purgeplan",
+		Short: "show the binlogs the leader would purge and the guards which hold them back(dry run)",
+		Run:   raftPurgePlanCommandFn,
+	}
+
+	return cmd
+}
+
+func raftPurgePlanCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		ErrorOK(fmt.Errorf("too.many.args"))
+	}
+
+	conf, err := GetConfig()
+	ErrorOK(err)
+	leader, err := callx.GetClusterLeader(conf.Server.Endpoint)
+	ErrorOK(err)
+	if leader == "" {
+		ErrorOK(fmt.Errorf("cluster.has.no.leader"))
+	}
+
+	rsp, err := callx.RaftPurgePlanRPC(leader)
+	ErrorOK(err)
+	RspOK(rsp.RetCode)
+	plan := rsp.Plan
+	if plan.Note != "" {
+		log.Warning("leader[%v].purge.plan.note[%v]", leader, plan.Note)
+	}
+
+	var rows [][]string
+	rows = append(rows, []string{"next", plan.Next, "the smallest binlog all the members are executing"})
+	for _, step := range plan.Steps {
+		rows = append(rows, []string{step.Guard, step.Binlog, step.Reason})
+	}
+	rows = append(rows, []string{"target", plan.Target, strings.Join(plan.Purged, " ")})
+	columns := []string{
+		"Guard",
+		"Binlog",
+		"Reason",
+	}
+	callx.PrintQueryOutput(columns, rows)
+}
+
+func NewRaftSetPurgeConsumerCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "setpurgeconsumer <name> [--binlog=mysql-bin.000001] [--gtid=gtid-set]",
+		Short: "set the position of an external binlog consumer(Debezium, Canal...) on the leader, it keeps the binlogs the consumer needs",
+		Run:   raftSetPurgeConsumerCommandFn,
+	}
+	cmd.Flags().StringVar(&purgeConsumerBinlog, "binlog", "", "the leader binlog the consumer is reading")
+	cmd.Flags().StringVar(&purgeConsumerGTID, "gtid", "", "the gtid set the consumer has executed")
+
+	return cmd
+}
+
+func raftSetPurgeConsumerCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		ErrorOK(fmt.Errorf("purge.consumer.name.is.nil"))
+	}
+
+	conf, err := GetConfig()
+	ErrorOK(err)
+	consumer := model.PurgeConsumer{Name: args[0], Binlog: purgeConsumerBinlog, GTID: purgeConsumerGTID}
+	log.Warning("prepare.to.set.purge.consumer[%+v]", consumer)
+	_, err = callx.SetClusterPurgeConsumer(conf.Server.Endpoint, consumer)
+	ErrorOK(err)
+	log.Warning("set.purge.consumer.done")
+}
+
+func NewRaftRemovePurgeConsumerCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "removepurgeconsumer <name>",
+		Short: "remove the external binlog consumer from the leader",
+		Run:   raftRemovePurgeConsumerCommandFn,
+	}
+
+	return cmd
+}
+
+func raftRemovePurgeConsumerCommandFn(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		ErrorOK(fmt.Errorf("purge.consumer.name.is.nil"))
+	}
+
+	conf, err := GetConfig()
+	ErrorOK(err)
+	log.Warning("prepare.to.remove.purge.consumer[%v]", args[0])
+	_, err = callx.RemoveClusterPurgeConsumer(conf.Server.Endpoint, args[0])
+	ErrorOK(err)
+	log.Warning("remove.purge.consumer.done")
+}
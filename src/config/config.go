@@ -66,6 +66,16 @@ type RaftConfig struct {
 	// Super IDLE can't change to FOLLOWER.
 	SuperIDLE bool `json:"super-idle"`
 
+	// if true, this node is a read replica, it follows the leader but never votes or promotes.
+	Replica bool `json:"replica"`
+
+	// the MASTER_DELAY(seconds) of this node, the delayed node is never promoted or used as a rebuild donor, 0 is none.
+	ReplicationDelaySeconds int `json:"replication-delay-seconds"`
+
+	// the raft id(host:port) of the follower which this node replicates from, it falls back to the leader
+	// when the upstream is down, empty is the leader.
+	ReplicationUpstream string `json:"replication-upstream"`
+
 	// MUST: set in init
 	// the shell command when leader start
 	LeaderStartCommand string `json:"leader-start-command"`
@@ -77,11 +87,52 @@ type RaftConfig struct {
 	// if true, xenon binlog-purge will be skipped, default is false.
 	PurgeBinlogDisabled bool `json:"purge-binlog-disabled"`
 
+	// the binlogs closed in the last purge-binlog-retention-seconds are never purged, 0 is none.
+	PurgeBinlogRetentionSeconds int `json:"purge-binlog-retention-seconds"`
+
+	// the leader keeps at least purge-binlog-retention-mb(MB) binlogs, 0 is none.
+	PurgeBinlogRetentionMB int `json:"purge-binlog-retention-mb"`
+
+	// the external consumers which don't heartbeat in purge-consumer-stale-seconds don't hold the binlogs, 0 is never stale.
+	PurgeConsumerStaleSeconds int `json:"purge-consumer-stale-seconds"`
+
 	// rpc client request tiemout(ms)
 	RequestTimeout int
 
 	// candicate wait timeout(ms) for 2 nodes.
 	CandidateWaitFor2Nodes int `json:"candidate-wait-for-2nodes"`
+
+	// the policy when the leader semi-sync falls back to async(Rpl_semi_sync_master_status is OFF):
+	// alert: count and log it, refuse-failover: the followers don't promote while the leader runs async.
+	SemiSyncDegradePolicy string `json:"semi-sync-degrade-policy"`
+
+	// the shell command when the leader semi-sync falls back to async or recovers,
+	// it's called with the degraded or recovered argument, empty is none.
+	SemiSyncDegradeCommand string `json:"semi-sync-degrade-command"`
+
+	// the durability policy which the leader applies to the semi-sync as the membership changes:
+	// quorum: ACK from (n-1)/2 replicas, ack-n: ACK from durability-acks replicas,
+	// cross-zone: ACK from at least one replica in another zone, async: no semi-sync and the replicas lag at most durability-max-lag.
+	Durability string `json:"durability"`
+
+	// the replicas to ACK for the ack-n durability
+	DurabilityAcks int `json:"durability-acks"`
+
+	// the max replica lag(seconds) for the async durability, 0 means no bound
+	DurabilityMaxLag int `json:"durability-max-lag"`
+
+	// the zone of this node, for the cross-zone durability and the preferred zone
+	Zone string `json:"zone"`
+
+	// the region of this node
+	Region string `json:"region"`
+
+	// the other labels(key/value) of this node, they are exchanged in heartbeats with the zone and region
+	Labels map[string]string `json:"labels"`
+
+	// the zone where the leader is elected, the nodes in other zones don't promote
+	// while the preferred zone has a quorum alive, empty means any zone
+	PreferredZone string `json:"preferred-zone"`
 }
 
 func DefaultRaftConfig() *RaftConfig {
@@ -95,6 +146,9 @@ func DefaultRaftConfig() *RaftConfig {
 		LeaderStopCommand:      "nop",
 		RequestTimeout:         1000,
 		CandidateWaitFor2Nodes: 1000 * 60,
+		SemiSyncDegradePolicy:  "alert",
+		Durability:             "quorum",
+		DurabilityAcks:         1,
 	}
 }
 
@@ -160,6 +214,9 @@ type MysqlConfig struct {
 
 	// replication Gtid Purged
 	ReplGtidPurged string
+
+	// replication channel which xenon manages
+	ReplChannel string
 }
 
 func DefaultMysqlConfig() *MysqlConfig {
@@ -197,6 +254,10 @@ type ReplicationConfig struct {
 	Passwd string `json:"passwd"`
 
 	GtidPurged string `json:"gtid-purged"`
+
+	// the replication channel which xenon manages, the other channels are left alone.
+	// Empty is the default channel, mysql56 always uses the default channel.
+	Channel string `json:"channel"`
 }
 
 func DefaultReplicationConfig() *ReplicationConfig {
@@ -205,6 +266,7 @@ func DefaultReplicationConfig() *ReplicationConfig {
 		Passwd: "repl",
 
 		GtidPurged: "",
+		Channel:    "xenon",
 	}
 }
 
@@ -233,6 +295,73 @@ type BackupConfig struct {
 	MysqldMonitorInterval   int    `json:"mysqld-monitor-interval"`
 	MaxAllowedLocalTrxCount int    `json:"max-allowed-local-trx-count"`
 
+	// the xtrabackup compression of the backup stream: zstd or qpress, empty is none
+	Compress string `json:"backup-compress"`
+
+	// the xtrabackup encryption algorithm(AES128/AES192/AES256) of the backup stream, empty is none
+	Encrypt string `json:"backup-encrypt"`
+
+	// the encryption key file, it must be at the same path on all the nodes
+	EncryptKeyFile string `json:"backup-encrypt-key-file"`
+
+	// the transport of the rebuild backup stream: ssh(sshpass+ssh to xbstream) or xenon(stream over the xenon rpc port)
+	Transport string `json:"backup-transport"`
+
+	// the TLS cert/key of the xenon stream, the stream is plain TCP if they are empty
+	StreamTLSCertFile string `json:"stream-tls-cert-file"`
+	StreamTLSKeyFile  string `json:"stream-tls-key-file"`
+
+	// the CA to verify the peer of the xenon stream, empty means the peer is not verified
+	StreamTLSCAFile string `json:"stream-tls-ca-file"`
+
+	// the method of the rebuild backup: xtrabackup, or the logical mysqldump/mydumper for the small instances
+	Method string `json:"backup-method"`
+
+	// the dir to receive the logical dump, and the mydumper outputs before they are sent
+	DumpDir string `json:"dump-dir"`
+
+	// the mydumper/myloader binary dir
+	MydumperBinDir string `json:"mydumper-bindir"`
+
+	// the cron-like schedule of the local backups, such as "0 2 * * *" or "@daily"
+	// empty means the scheduled backups are disabled
+	Schedule string `json:"schedule"`
+
+	// the cron-like schedule of the incremental backups based on the latest archive
+	// empty means the incremental backups are disabled
+	IncrementalSchedule string `json:"incremental-schedule"`
+
+	// the dir to keep the local backup archives
+	ArchiveDir string `json:"archive-dir"`
+
+	// keep at most retention-count archives, 0 means no limit
+	RetentionCount int `json:"retention-count"`
+
+	// prune the archives older than retention-days, 0 means no limit
+	RetentionDays int `json:"retention-days"`
+
+	// the cron-like schedule to verify the latest archive by restoring it into a scratch mysqld
+	// empty means the scheduled verifications are disabled
+	VerifySchedule string `json:"verify-schedule"`
+
+	// the dir to restore the archive into for the verification, it's removed after
+	VerifyDir string `json:"verify-dir"`
+
+	// the port of the scratch mysqld, 0 means a spare port is picked
+	VerifyPort int `json:"verify-port"`
+
+	// the static tables(db.table, separated by commas) to compare the checksums with the local mysql
+	// they must not be written after the backup is taken, or the good backups fail the verification
+	// empty means no checksums
+	VerifyChecksumTables string `json:"verify-checksum-tables"`
+
+	// the dir to archive the closed binlogs for the point-in-time restore
+	// empty means the binlog archiving is disabled
+	BinlogArchiveDir string `json:"binlog-archive-dir"`
+
+	// the interval(ms) to archive the closed binlogs
+	BinlogArchiveInterval int `json:"binlog-archive-interval"`
+
 	// mysql admin
 	Admin string
 
@@ -262,6 +391,27 @@ func DefaultBackupConfig() *BackupConfig {
 		Parallel:                2,
 		MysqldMonitorInterval:   1000 * 1,
 		MaxAllowedLocalTrxCount: 0,
+		Compress:                "",
+		Encrypt:                 "",
+		EncryptKeyFile:          "",
+		Transport:               "ssh",
+		StreamTLSCertFile:       "",
+		StreamTLSKeyFile:        "",
+		StreamTLSCAFile:         "",
+		Method:                  "xtrabackup",
+		DumpDir:                 "/u01/backup_dump",
+		MydumperBinDir:          "/usr/bin",
+		Schedule:                "",
+		IncrementalSchedule:     "",
+		ArchiveDir:              "/u01/backup_archive",
+		RetentionCount:          7,
+		RetentionDays:           30,
+		VerifySchedule:          "",
+		VerifyDir:               "/u01/backup_verify",
+		VerifyPort:              0,
+		VerifyChecksumTables:    "",
+		BinlogArchiveDir:        "",
+		BinlogArchiveInterval:   1000 * 60,
 		Admin:                   "root",
 		Passwd:                  "",
 		Host:                    "localhost",
@@ -367,6 +517,7 @@ func parseConfig(data []byte) (*Config, error) {
 	conf.Mysql.ReplUser = conf.Replication.User
 	conf.Mysql.ReplPasswd = conf.Replication.Passwd
 	conf.Mysql.ReplGtidPurged = conf.Replication.GtidPurged
+	conf.Mysql.ReplChannel = conf.Replication.Channel
 
 	conf.Mysql.ReplHost = strings.Split(conf.Server.Endpoint, ":")[0]
 	return conf, nil
@@ -23,6 +23,16 @@ func (admin *Admin) NewRouter() (rest.App, error) {
 		// cluster.
 		rest.Post("/v1/cluster/add", v1.ClusterAddHandler(log, xenon)),
 		rest.Post("/v1/cluster/remove", v1.ClusterRemoveHandler(log, xenon)),
+		rest.Post("/v1/cluster/consumer", v1.ClusterConsumerHandler(log, xenon)),
+		rest.Post("/v1/cluster/consumer/remove", v1.ClusterConsumerRemoveHandler(log, xenon)),
+		rest.Get("/v1/cluster/leader/watch", v1.ClusterLeaderWatchHandler(log, xenon)),
+		rest.Get("/v1/cluster/leader/events", v1.ClusterLeaderEventsHandler(log, xenon)),
+
+		// mysql.
+		rest.Get("/v1/mysql/rebuild", v1.MysqlRebuildStatusHandler(log, xenon)),
+		rest.Post("/v1/mysql/rebuild", v1.MysqlRebuildHandler(log, xenon)),
+		rest.Post("/v1/mysql/rebuild/cancel", v1.MysqlRebuildCancelHandler(log, xenon)),
+		rest.Post("/v1/mysql/rebuild/resume", v1.MysqlRebuildResumeHandler(log, xenon)),
 
 		// raft.
 		rest.Get("/v1/raft/status", v1.RaftStatusHandler(log, xenon)),
@@ -9,10 +9,13 @@
 package v1
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"cli/callx"
+	"model"
 	"server"
 	"xbase/xlog"
 
@@ -23,6 +26,41 @@ type peerParams struct {
 	Address string `json:"address"`
 }
 
+type consumerParams struct {
+	Name   string `json:"name"`
+	Binlog string `json:"binlog"`
+	GTID   string `json:"gtid"`
+}
+
+type leaderView struct {
+	Leader    string `json:"leader"`
+	ViewID    uint64 `json:"viewid"`
+	MysqlHost string `json:"mysqlhost"`
+	MysqlPort int    `json:"mysqlport"`
+	Changed   bool   `json:"changed"`
+}
+
+func newLeaderView(rsp *model.RaftWatchLeaderRPCResponse) *leaderView {
+	return &leaderView{
+		Leader:    rsp.View.Leader,
+		ViewID:    rsp.View.ViewID,
+		MysqlHost: rsp.View.MysqlHost,
+		MysqlPort: rsp.View.MysqlPort,
+		Changed:   rsp.Changed,
+	}
+}
+
+const (
+	// the default and the max time(ms) of the leader watch
+	leaderWatchTimeout    = 30000
+	leaderWatchMaxTimeout = 300000
+)
+
+var (
+	// the time(ms) to send the keepalive comment to the leader events subscribers
+	leaderEventsKeepalive = 15000
+)
+
 func ClusterAddHandler(log *xlog.Log, xenon *server.Server) rest.HandlerFunc {
 	f := func(w rest.ResponseWriter, r *rest.Request) {
 		clusterAddHandler(log, xenon, w, r)
@@ -112,3 +150,155 @@ func clusterRemoveHandler(log *xlog.Log, xenon *server.Server, w rest.ResponseWr
 	}
 	log.Warning("api.v1.cluster.remove.nodes.from.leader[%v].done", leader)
 }
+
+// ClusterConsumerHandler registers the external binlog consumer or heartbeats its position on the leader.
+func ClusterConsumerHandler(log *xlog.Log, xenon *server.Server) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		clusterConsumerHandler(log, xenon, w, r)
+	}
+	return f
+}
+
+func clusterConsumerHandler(log *xlog.Log, xenon *server.Server, w rest.ResponseWriter, r *rest.Request) {
+	p := consumerParams{}
+	err := r.DecodeJsonPayload(&p)
+	if err != nil {
+		log.Error("api.v1.cluster.consumer.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if p.Name == "" {
+		rest.Error(w, "api.v1.cluster.consumer.request.name.is.null", http.StatusInternalServerError)
+		return
+	}
+	if p.Binlog == "" && p.GTID == "" {
+		rest.Error(w, "api.v1.cluster.consumer.request.binlog.and.gtid.are.null", http.StatusInternalServerError)
+		return
+	}
+
+	consumer := model.PurgeConsumer{Name: p.Name, Binlog: p.Binlog, GTID: p.GTID}
+	consumers, err := callx.SetClusterPurgeConsumer(xenon.Address(), consumer)
+	if err != nil {
+		log.Error("api.v1.cluster.consumer[%+v].error:%+v", p, err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteJson(consumers)
+}
+
+// ClusterConsumerRemoveHandler removes the external binlog consumer from the leader.
+func ClusterConsumerRemoveHandler(log *xlog.Log, xenon *server.Server) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		clusterConsumerRemoveHandler(log, xenon, w, r)
+	}
+	return f
+}
+
+func clusterConsumerRemoveHandler(log *xlog.Log, xenon *server.Server, w rest.ResponseWriter, r *rest.Request) {
+	p := consumerParams{}
+	err := r.DecodeJsonPayload(&p)
+	if err != nil {
+		log.Error("api.v1.cluster.consumer.remove.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if p.Name == "" {
+		rest.Error(w, "api.v1.cluster.consumer.remove.request.name.is.null", http.StatusInternalServerError)
+		return
+	}
+
+	log.Warning("api.v1.cluster.prepare.to.remove.consumer[%v]", p.Name)
+	consumers, err := callx.RemoveClusterPurgeConsumer(xenon.Address(), p.Name)
+	if err != nil {
+		log.Error("api.v1.cluster.consumer.remove[%+v].error:%+v", p, err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteJson(consumers)
+}
+
+// ClusterLeaderWatchHandler long-polls the leader: it returns at once if the leader isn't the ?leader= one,
+// otherwise it waits for the leader change at most ?timeout=(ms).
+func ClusterLeaderWatchHandler(log *xlog.Log, xenon *server.Server) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		clusterLeaderWatchHandler(log, xenon, w, r)
+	}
+	return f
+}
+
+func clusterLeaderWatchHandler(log *xlog.Log, xenon *server.Server, w rest.ResponseWriter, r *rest.Request) {
+	query := r.URL.Query()
+	timeout := leaderWatchTimeout
+	if s := query.Get("timeout"); s != "" {
+		var err error
+		if timeout, err = strconv.Atoi(s); err != nil || timeout < 0 {
+			rest.Error(w, "api.v1.cluster.leader.watch.request.timeout.is.invalid", http.StatusInternalServerError)
+			return
+		}
+		if timeout > leaderWatchMaxTimeout {
+			timeout = leaderWatchMaxTimeout
+		}
+	}
+
+	rsp, err := callx.RaftWatchLeaderRPC(xenon.Address(), query.Get("leader"), timeout)
+	if err != nil {
+		log.Error("api.v1.cluster.leader.watch.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteJson(newLeaderView(rsp))
+}
+
+// ClusterLeaderEventsHandler streams the leader changes as the server-sent events: the current leader at first
+// (unless it's the ?leader= one) and every change, with the keepalive comments between them.
+func ClusterLeaderEventsHandler(log *xlog.Log, xenon *server.Server) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		clusterLeaderEventsHandler(log, xenon, w, r)
+	}
+	return f
+}
+
+func clusterLeaderEventsHandler(log *xlog.Log, xenon *server.Server, w rest.ResponseWriter, r *rest.Request) {
+	type streamWriter interface {
+		http.ResponseWriter
+		http.Flusher
+	}
+	stream, ok := w.(streamWriter)
+	if !ok {
+		rest.Error(w, "api.v1.cluster.leader.events.streaming.is.unsupported", http.StatusInternalServerError)
+		return
+	}
+	stream.Header().Set("Content-Type", "text/event-stream")
+	stream.Header().Set("Cache-Control", "no-cache")
+	stream.WriteHeader(http.StatusOK)
+	stream.Flush()
+
+	known := r.URL.Query().Get("leader")
+	for {
+		rsp, err := callx.RaftWatchLeaderRPC(xenon.Address(), known, leaderEventsKeepalive)
+		if err == nil && rsp.RetCode != model.OK {
+			err = fmt.Errorf("%s", rsp.RetCode)
+		}
+		if err != nil {
+			log.Error("api.v1.cluster.leader.events.error:%+v", err)
+			fmt.Fprintf(stream, "event: error\ndata: %s\n\n", err.Error())
+			stream.Flush()
+			return
+		}
+
+		if rsp.Changed {
+			data, _ := w.EncodeJson(newLeaderView(rsp))
+			fmt.Fprintf(stream, "event: leader\ndata: %s\n\n", data)
+			known = rsp.View.Leader
+		} else {
+			fmt.Fprintf(stream, ": keepalive\n\n")
+		}
+		stream.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		default:
+		}
+	}
+}
@@ -9,15 +9,21 @@
 package v1
 
 import (
+	"context"
 	"encoding/base64"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 
+	"model"
 	"server"
 	"xbase/common"
 	"xbase/xlog"
 
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestCtlV1ClusterAddRemove(t *testing.T) {
@@ -106,3 +112,169 @@ func TestCtlV1ClusterAddRemove(t *testing.T) {
 		recorded.CodeIs(200)
 	}
 }
+
+func mockCtlV1Handler(xenon *server.Server, routes ...*rest.Route) http.Handler {
+	api := rest.NewApi()
+	authMiddleware := &rest.AuthBasicMiddleware{
+		Realm: "xenon zone",
+		Authenticator: func(userId string, password string) bool {
+			if userId == xenon.MySQLAdmin() && password == xenon.MySQLPasswd() {
+				return true
+			}
+			return false
+		},
+	}
+	api.Use(authMiddleware)
+
+	router, _ := rest.MakeRouter(routes...)
+	api.SetApp(router)
+	return api.MakeHandler()
+}
+
+func TestCtlV1ClusterConsumer(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	servers, cleanup := server.MockServers(log, port, 3)
+	defer cleanup()
+	// the consumers are set on the leader
+	server.MockWaitLeaderEggs(servers, 1)
+
+	xenon := servers[0]
+	handler := mockCtlV1Handler(xenon,
+		rest.Post("/v1/cluster/consumer", ClusterConsumerHandler(log, xenon)),
+		rest.Post("/v1/cluster/consumer/remove", ClusterConsumerRemoveHandler(log, xenon)),
+	)
+
+	p := &consumerParams{
+		Name: "debezium",
+		GTID: "84030605-66aa-11e6-9465-52540e7fd51c:1-100",
+	}
+
+	// 500.
+	{
+		req := test.MakeSimpleRequest("POST", "http://localhost/v1/cluster/consumer", &consumerParams{Name: "debezium"})
+		encoded := base64.StdEncoding.EncodeToString([]byte("root:"))
+		req.Header.Set("Authorization", "Basic "+encoded)
+		recorded := test.RunRequest(t, handler, req)
+		recorded.CodeIs(500)
+	}
+
+	// 200.
+	{
+		req := test.MakeSimpleRequest("POST", "http://localhost/v1/cluster/consumer", p)
+		encoded := base64.StdEncoding.EncodeToString([]byte("root:"))
+		req.Header.Set("Authorization", "Basic "+encoded)
+		recorded := test.RunRequest(t, handler, req)
+		recorded.CodeIs(200)
+
+		var consumers []model.PurgeConsumer
+		err := recorded.DecodeJsonPayload(&consumers)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(consumers))
+		assert.Equal(t, p.GTID, consumers[0].GTID)
+	}
+
+	// 200.
+	{
+		req := test.MakeSimpleRequest("POST", "http://localhost/v1/cluster/consumer/remove", p)
+		encoded := base64.StdEncoding.EncodeToString([]byte("root:"))
+		req.Header.Set("Authorization", "Basic "+encoded)
+		recorded := test.RunRequest(t, handler, req)
+		recorded.CodeIs(200)
+	}
+
+	// 500.
+	{
+		req := test.MakeSimpleRequest("POST", "http://localhost/v1/cluster/consumer/remove", p)
+		encoded := base64.StdEncoding.EncodeToString([]byte("root:"))
+		req.Header.Set("Authorization", "Basic "+encoded)
+		recorded := test.RunRequest(t, handler, req)
+		recorded.CodeIs(500)
+	}
+}
+
+func TestCtlV1ClusterLeaderWatch(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	servers, cleanup := server.MockServers(log, port, 1)
+	defer cleanup()
+
+	xenon := servers[0]
+	handler := mockCtlV1Handler(xenon,
+		rest.Get("/v1/cluster/leader/watch", ClusterLeaderWatchHandler(log, xenon)),
+	)
+
+	// 500.
+	{
+		req := test.MakeSimpleRequest("GET", "http://localhost/v1/cluster/leader/watch?timeout=x", nil)
+		encoded := base64.StdEncoding.EncodeToString([]byte("root:"))
+		req.Header.Set("Authorization", "Basic "+encoded)
+		recorded := test.RunRequest(t, handler, req)
+		recorded.CodeIs(500)
+	}
+
+	// 200, the leader isn't the known one.
+	var known string
+	{
+		req := test.MakeSimpleRequest("GET", "http://localhost/v1/cluster/leader/watch?leader=192.168.0.1:8801", nil)
+		encoded := base64.StdEncoding.EncodeToString([]byte("root:"))
+		req.Header.Set("Authorization", "Basic "+encoded)
+		recorded := test.RunRequest(t, handler, req)
+		recorded.CodeIs(200)
+
+		leader := &leaderView{}
+		err := recorded.DecodeJsonPayload(leader)
+		assert.Nil(t, err)
+		assert.NotEqual(t, "192.168.0.1:8801", leader.Leader)
+		assert.True(t, leader.Changed)
+		known = leader.Leader
+	}
+
+	// 200, timeout.
+	{
+		req := test.MakeSimpleRequest("GET", "http://localhost/v1/cluster/leader/watch?timeout=100&leader="+known, nil)
+		encoded := base64.StdEncoding.EncodeToString([]byte("root:"))
+		req.Header.Set("Authorization", "Basic "+encoded)
+		recorded := test.RunRequest(t, handler, req)
+		recorded.CodeIs(200)
+
+		leader := &leaderView{}
+		err := recorded.DecodeJsonPayload(leader)
+		assert.Nil(t, err)
+		assert.Equal(t, known, leader.Leader)
+		assert.False(t, leader.Changed)
+	}
+}
+
+func TestCtlV1ClusterLeaderEvents(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	servers, cleanup := server.MockServers(log, port, 1)
+	defer cleanup()
+
+	xenon := servers[0]
+	handler := mockCtlV1Handler(xenon,
+		rest.Get("/v1/cluster/leader/events", ClusterLeaderEventsHandler(log, xenon)),
+	)
+
+	keepalive := leaderEventsKeepalive
+	leaderEventsKeepalive = 100
+	defer func() { leaderEventsKeepalive = keepalive }()
+
+	// the current leader at first, then the keepalives until the client leaves.
+	{
+		req := test.MakeSimpleRequest("GET", "http://localhost/v1/cluster/leader/events?leader=192.168.0.1:8801", nil)
+		encoded := base64.StdEncoding.EncodeToString([]byte("root:"))
+		req.Header.Set("Authorization", "Basic "+encoded)
+		ctx, cancel := context.WithTimeout(req.Context(), time.Millisecond*500)
+		defer cancel()
+		recorded := test.RunRequest(t, handler, req.WithContext(ctx))
+		recorded.CodeIs(200)
+		recorded.HeaderIs("Content-Type", "text/event-stream")
+
+		body := recorded.Recorder.Body.String()
+		assert.True(t, strings.HasPrefix(body, "event: leader\ndata: {"), body)
+		assert.Equal(t, 1, strings.Count(body, "event: leader"), body)
+		assert.Contains(t, body, ": keepalive\n\n")
+	}
+}
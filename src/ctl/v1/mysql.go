@@ -0,0 +1,112 @@
+/*
+ * RadonDB
+ *
+ * Copyright 2021 The RadonDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"net/http"
+
+	"cli/callx"
+	"model"
+	"server"
+	"xbase/xlog"
+
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+type rebuildParams struct {
+	From  string `json:"from"`
+	Force bool   `json:"force"`
+	Rate  int64  `json:"rate"`
+}
+
+// MysqlRebuildStatusHandler impl.
+func MysqlRebuildStatusHandler(log *xlog.Log, xenon *server.Server) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		mysqlRebuildStatusHandler(log, xenon, w, r)
+	}
+	return f
+}
+
+func mysqlRebuildStatusHandler(log *xlog.Log, xenon *server.Server, w rest.ResponseWriter, r *rest.Request) {
+	rsp, err := callx.RebuildStatusRPC(xenon.Address())
+	if err != nil {
+		log.Error("api.v1.mysql.rebuild.status.error:%+v", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteJson(rsp.Job)
+}
+
+// MysqlRebuildHandler impl.
+func MysqlRebuildHandler(log *xlog.Log, xenon *server.Server) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		mysqlRebuildHandler(log, xenon, w, r)
+	}
+	return f
+}
+
+func mysqlRebuildHandler(log *xlog.Log, xenon *server.Server, w rest.ResponseWriter, r *rest.Request) {
+	p := rebuildParams{}
+	if r.ContentLength > 0 {
+		if err := r.DecodeJsonPayload(&p); err != nil {
+			log.Error("api.v1.mysql.rebuild.error:%+v", err)
+			rest.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	address := xenon.Address()
+	log.Warning("api.v1.mysql.rebuild.[%v].prepare.to.rebuild.from[%v].force[%v].rate[%v]", address, p.From, p.Force, p.Rate)
+	rsp, err := callx.RebuildStartRPC(address, p.From, p.Force, p.Rate)
+	mysqlRebuildResponse(log, w, "start", rsp, err)
+}
+
+// MysqlRebuildCancelHandler impl.
+func MysqlRebuildCancelHandler(log *xlog.Log, xenon *server.Server) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		mysqlRebuildCancelHandler(log, xenon, w, r)
+	}
+	return f
+}
+
+func mysqlRebuildCancelHandler(log *xlog.Log, xenon *server.Server, w rest.ResponseWriter, r *rest.Request) {
+	address := xenon.Address()
+	log.Warning("api.v1.mysql.rebuild.[%v].prepare.to.cancel", address)
+	rsp, err := callx.RebuildCancelRPC(address)
+	mysqlRebuildResponse(log, w, "cancel", rsp, err)
+}
+
+// MysqlRebuildResumeHandler impl.
+func MysqlRebuildResumeHandler(log *xlog.Log, xenon *server.Server) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		mysqlRebuildResumeHandler(log, xenon, w, r)
+	}
+	return f
+}
+
+func mysqlRebuildResumeHandler(log *xlog.Log, xenon *server.Server, w rest.ResponseWriter, r *rest.Request) {
+	address := xenon.Address()
+	log.Warning("api.v1.mysql.rebuild.[%v].prepare.to.resume", address)
+	rsp, err := callx.RebuildResumeRPC(address)
+	mysqlRebuildResponse(log, w, "resume", rsp, err)
+}
+
+func mysqlRebuildResponse(log *xlog.Log, w rest.ResponseWriter, action string, rsp *model.RebuildRPCResponse, err error) {
+	if err != nil {
+		log.Error("api.v1.mysql.rebuild.%s.error:%+v", action, err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if rsp.RetCode != model.OK {
+		log.Error("api.v1.mysql.rebuild.%s.error:rsp[%v] != [OK]", action, rsp.RetCode)
+		rest.Error(w, rsp.RetCode, http.StatusInternalServerError)
+		return
+	}
+	w.WriteJson(rsp.Job)
+}
@@ -0,0 +1,100 @@
+/*
+ * RadonDB
+ *
+ * Copyright 2021 The RadonDB Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package v1
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"server"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ant0ine/go-json-rest/rest/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCtlV1MysqlRebuild(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	servers, cleanup := server.MockServers(log, port, 1)
+	defer cleanup()
+
+	xenon := servers[0]
+	api := rest.NewApi()
+	authMiddleware := &rest.AuthBasicMiddleware{
+		Realm: "xenon zone",
+		Authenticator: func(userId string, password string) bool {
+			if userId == xenon.MySQLAdmin() && password == xenon.MySQLPasswd() {
+				return true
+			}
+			return false
+		},
+	}
+	api.Use(authMiddleware)
+
+	router, _ := rest.MakeRouter(
+		rest.Get("/v1/mysql/rebuild", MysqlRebuildStatusHandler(log, xenon)),
+		rest.Post("/v1/mysql/rebuild", MysqlRebuildHandler(log, xenon)),
+		rest.Post("/v1/mysql/rebuild/cancel", MysqlRebuildCancelHandler(log, xenon)),
+		rest.Post("/v1/mysql/rebuild/resume", MysqlRebuildResumeHandler(log, xenon)),
+	)
+	api.SetApp(router)
+	handler := api.MakeHandler()
+
+	// status 401.
+	{
+		req := test.MakeSimpleRequest("GET", "http://localhost/v1/mysql/rebuild", nil)
+		recorded := test.RunRequest(t, handler, req)
+		recorded.CodeIs(401)
+	}
+
+	// status 200.
+	{
+		req := test.MakeSimpleRequest("GET", "http://localhost/v1/mysql/rebuild", nil)
+		encoded := base64.StdEncoding.EncodeToString([]byte("root:"))
+		req.Header.Set("Authorization", "Basic "+encoded)
+		recorded := test.RunRequest(t, handler, req)
+		recorded.CodeIs(200)
+		got := recorded.Recorder.Body.String()
+		assert.True(t, strings.Contains(got, `"State":"NONE"`))
+	}
+
+	// cancel 500, no job.
+	{
+		req := test.MakeSimpleRequest("POST", "http://localhost/v1/mysql/rebuild/cancel", nil)
+		encoded := base64.StdEncoding.EncodeToString([]byte("root:"))
+		req.Header.Set("Authorization", "Basic "+encoded)
+		recorded := test.RunRequest(t, handler, req)
+		recorded.CodeIs(500)
+	}
+
+	// resume 500, no job.
+	{
+		req := test.MakeSimpleRequest("POST", "http://localhost/v1/mysql/rebuild/resume", nil)
+		encoded := base64.StdEncoding.EncodeToString([]byte("root:"))
+		req.Header.Set("Authorization", "Basic "+encoded)
+		recorded := test.RunRequest(t, handler, req)
+		recorded.CodeIs(500)
+	}
+
+	// start 200, the job fails in background.
+	{
+		p := &rebuildParams{From: "127.0.0.1:1", Force: true}
+		req := test.MakeSimpleRequest("POST", "http://localhost/v1/mysql/rebuild", p)
+		encoded := base64.StdEncoding.EncodeToString([]byte("root:"))
+		req.Header.Set("Authorization", "Basic "+encoded)
+		recorded := test.RunRequest(t, handler, req)
+		recorded.CodeIs(200)
+		got := recorded.Recorder.Body.String()
+		assert.True(t, strings.Contains(got, `"From":"127.0.0.1:1"`))
+	}
+}
@@ -8,13 +8,77 @@
 
 package model
 
+import (
+	"time"
+)
+
 const (
 	RPCBackupStatus   = "BackupRPC.GetBackupStatus"
 	RPCBackupDo       = "BackupRPC.DoBackup"
 	RPCBackupCancel   = "BackupRPC.CancelBackup"
 	RPCBackupApplyLog = "BackupRPC.DoApplyLog"
+	RPCBackupLoadDump = "BackupRPC.LoadDump"
+	RPCBackupList     = "BackupRPC.ListArchives"
+	RPCBackupShow     = "BackupRPC.ShowArchive"
+	RPCBackupDelete   = "BackupRPC.DeleteArchive"
+	RPCBackupRestore  = "BackupRPC.RestoreArchive"
+	RPCBackupReplay   = "BackupRPC.ReplayBinlogs"
+	RPCBackupVerify   = "BackupRPC.VerifyArchive"
+	RPCBackupSchedule = "BackupRPC.ScheduledBackup"
+
+	RPCBackupPrepareStream = "BackupRPC.PrepareStream"
+	RPCBackupWaitStream    = "BackupRPC.WaitStream"
+)
+
+type BACKUP_PHASE string
+
+const (
+	BACKUP_PHASE_NONE               BACKUP_PHASE = "NONE"
+	BACKUP_PHASE_COPYING_INNODB     BACKUP_PHASE = "COPYING_INNODB"
+	BACKUP_PHASE_LOCKING            BACKUP_PHASE = "LOCKING"
+	BACKUP_PHASE_COPYING_NON_INNODB BACKUP_PHASE = "COPYING_NON_INNODB"
+	BACKUP_PHASE_FINISHING          BACKUP_PHASE = "FINISHING"
+	BACKUP_PHASE_PREPARING          BACKUP_PHASE = "PREPARING"
+	BACKUP_PHASE_COMPLETED          BACKUP_PHASE = "COMPLETED"
 )
 
+// BackupProgress is parsed from the outputs of xtrabackup.
+type BackupProgress struct {
+	// The current phase of the backup/applylog
+	Phase BACKUP_PHASE
+
+	// How many files have been copied
+	CopiedFiles uint64
+
+	// How many bytes have been copied
+	CopiedBytes uint64
+
+	// The estimated bytes of the backup, 0 if unknown
+	TotalBytes uint64
+
+	// The last LSN reported by xtrabackup
+	LSN uint64
+
+	// The percent of the backup/applylog
+	Percent float64
+
+	// Unix time of the begin and the last update
+	Begin  int64
+	Update int64
+}
+
+// ETA returns the estimated time to complete, -1 if unknown.
+func (p *BackupProgress) ETA() time.Duration {
+	if p.Phase == BACKUP_PHASE_COMPLETED {
+		return 0
+	}
+	if p.Percent <= 0 || p.Update <= p.Begin {
+		return -1
+	}
+	elapsed := float64(p.Update - p.Begin)
+	return time.Duration(elapsed*(100-p.Percent)/p.Percent) * time.Second
+}
+
 type BackupStats struct {
 	// How many times backup have been called
 	Backups uint64
@@ -36,6 +100,104 @@ type BackupStats struct {
 
 	// The last backup command info  we call
 	LastCMD string
+
+	// The progress of the running or the last backup/applylog
+	Progress BackupProgress
+}
+
+const (
+	BACKUP_TYPE_FULL        = "full"
+	BACKUP_TYPE_INCREMENTAL = "incremental"
+)
+
+const (
+	BACKUP_COMPRESS_ZSTD   = "zstd"
+	BACKUP_COMPRESS_QPRESS = "qpress"
+)
+
+const (
+	BACKUP_TRANSPORT_SSH   = "ssh"
+	BACKUP_TRANSPORT_XENON = "xenon"
+)
+
+const (
+	BACKUP_METHOD_XTRABACKUP = "xtrabackup"
+	BACKUP_METHOD_MYSQLDUMP  = "mysqldump"
+	BACKUP_METHOD_MYDUMPER   = "mydumper"
+)
+
+const (
+	BACKUP_VERIFY_PASSED = "passed"
+	BACKUP_VERIFY_FAILED = "failed"
+)
+
+// BackupVerification is the result of restoring the archive into a scratch mysqld.
+type BackupVerification struct {
+	// passed or failed
+	Result string `json:"result"`
+
+	// Unix time of the verification begin and end
+	Begin int64 `json:"begin"`
+	End   int64 `json:"end"`
+
+	// The count of the user tables in the restored mysql
+	Tables int `json:"tables"`
+
+	// The checksums of the verify-checksum-tables(static) which match the local mysql
+	Checksums map[string]string `json:"checksums,omitempty"`
+
+	// The reason of the failure
+	Error string `json:"error,omitempty"`
+}
+
+// BackupManifest describes a local backup archive, it is written as manifest.json
+// next to the archive.
+type BackupManifest struct {
+	// The backup id, also the dir name under the archive-dir
+	ID string `json:"id"`
+
+	// The backup type: full or incremental
+	Type string `json:"type"`
+
+	// The id of the backup which the incremental is based on, empty for full
+	BaseID string `json:"base-id,omitempty"`
+
+	// The archive file path
+	Archive string `json:"archive"`
+
+	// Unix time of the backup begin and end
+	Begin int64 `json:"begin"`
+	End   int64 `json:"end"`
+
+	// The archive size in bytes
+	Size uint64 `json:"size"`
+
+	// The sha256 checksum of the archive
+	Checksum string `json:"checksum"`
+
+	// The binlog position and the executed GTID set of the backup
+	BinlogFile string `json:"binlog-file"`
+	BinlogPos  uint64 `json:"binlog-pos"`
+	GTID       string `json:"gtid"`
+
+	// The LSN range of the backup
+	FromLSN uint64 `json:"from-lsn"`
+	ToLSN   uint64 `json:"to-lsn"`
+
+	// The xtrabackup compression(zstd/qpress) of the backup, empty is none
+	Compress string `json:"compress,omitempty"`
+
+	// The xtrabackup encryption algorithm and the key file, empty is none
+	Encrypt        string `json:"encrypt,omitempty"`
+	EncryptKeyFile string `json:"encrypt-key-file,omitempty"`
+
+	// The last verification of the archive, nil if it's never verified
+	Verification *BackupVerification `json:"verification,omitempty"`
+}
+
+// Duration returns the backup duration.
+func (m *BackupManifest) Duration() time.Duration {
+	return time.Duration(m.End-m.Begin) * time.Second
 }
 
 type BackupRPCRequest struct {
@@ -62,9 +224,107 @@ type BackupRPCRequest struct {
 
 	// The xtrabackup/xbstream binary dir
 	XtrabackupBinDir string
+
+	// The incremental dirs to apply on the BackupDir in order
+	IncrementalDirs []string
+
+	// The xtrabackup compression(zstd/qpress), empty is none
+	Compress string
+
+	// The xtrabackup encryption algorithm and the key file, empty is none
+	Encrypt        string
+	EncryptKeyFile string
+
+	// The transport of the backup stream: ssh or xenon, empty is ssh
+	Transport string
+
+	// The xenon endpoint and the token to stream the backup to if the transport is xenon
+	StreamAddr  string
+	StreamToken string
+
+	// The byte rate(bytes per second) limit of the xenon stream, 0 is no limit
+	Rate int64
+
+	// The backup method: xtrabackup, mysqldump or mydumper, empty is xtrabackup
+	Method string
 }
 
 type BackupRPCResponse struct {
+	// The bytes and the sha256 checksum of the backup stream sent by the xenon transport
+	Size     uint64
+	Checksum string
+
+	// Return code to rpc client:
+	// OK or other errors
+	RetCode string
+}
+
+type BackupStreamRPCRequest struct {
+	// The IP of this request
+	From string
+
+	// The token of the stream
+	Token string
+
+	// The dir to extract the stream into
+	BackupDir string
+
+	// The backup method of the stream, empty is xtrabackup
+	Method string
+
+	// Abort the stream if it has not been connected
+	Abort bool
+}
+
+type BackupStreamRPCResponse struct {
+	// The token of the prepared stream
+	Token string
+
+	// The bytes and the sha256 checksum of the received stream
+	Size     uint64
+	Checksum string
+
+	// Return code to rpc client:
+	// OK or other errors
+	RetCode string
+}
+
+type BackupStatusRPCResponse struct {
+	// The backup status
+	Status MYSQLD_STATUS
+
+	// The backup stats with the progress
+	Stats *BackupStats
+
+	// Return code to rpc client:
+	// OK or other errors
+	RetCode string
+}
+
+type BackupArchiveRPCRequest struct {
+	// The IP of this request
+	From string
+
+	// The backup id, empty means all
+	ID string
+
+	// The dir to restore the archive to
+	TargetDir string
+
+	// Replay the archived binlogs until the datetime, such as "2018-06-06 12:00:00"
+	StopDatetime string
+
+	// Replay the archived binlogs until the GTID set is reached
+	StopGTID string
+
+	// The scheduled backup is incremental
+	Incremental bool
+}
+
+type BackupArchiveRPCResponse struct {
+	// The manifests of the archives
+	Manifests []*BackupManifest
+
 	// Return code to rpc client:
 	// OK or other errors
 	RetCode string
@@ -77,3 +337,23 @@ func NewBackupRPCRequest() *BackupRPCRequest {
 func NewBackupRPCResponse(code string) *BackupRPCResponse {
 	return &BackupRPCResponse{RetCode: code}
 }
+
+func NewBackupStreamRPCRequest() *BackupStreamRPCRequest {
+	return &BackupStreamRPCRequest{}
+}
+
+func NewBackupStreamRPCResponse(code string) *BackupStreamRPCResponse {
+	return &BackupStreamRPCResponse{RetCode: code}
+}
+
+func NewBackupStatusRPCResponse(code string) *BackupStatusRPCResponse {
+	return &BackupStatusRPCResponse{RetCode: code}
+}
+
+func NewBackupArchiveRPCRequest() *BackupArchiveRPCRequest {
+	return &BackupArchiveRPCRequest{}
+}
+
+func NewBackupArchiveRPCResponse(code string) *BackupArchiveRPCResponse {
+	return &BackupArchiveRPCResponse{RetCode: code}
+}
@@ -24,6 +24,10 @@ const (
 	RPCMysqlResetMaster              = "MysqlRPC.ResetMaster"
 	RPCMysqlResetSlaveAll            = "MysqlRPC.ResetSlaveAll"
 	RPCMysqlIsWorking                = "MysqlRPC.IsWorking"
+	RPCMysqlVersion                  = "MysqlRPC.Version"
+	RPCMysqlPrepareCloneDonor        = "MysqlRPC.PrepareCloneDonor"
+	RPCMysqlCloneInstance            = "MysqlRPC.CloneInstance"
+	RPCMysqlDelayedCatchup           = "MysqlRPC.DelayedCatchup"
 )
 
 type (
@@ -40,6 +44,9 @@ const (
 
 // GTID info
 type GTID struct {
+	// Replication channel, empty is the default channel
+	Channel_Name string
+
 	// Mysql master log file which the slave is reading
 	Master_Log_File string
 
@@ -134,6 +141,33 @@ type MysqlStats struct {
 	// How many times the mysqld have been down
 	// Which is measured by mysql ping
 	MysqlDowns uint64
+
+	// The semi-sync health, nil if it can't be got
+	SemiSync *SemiSyncStatus
+}
+
+// SemiSyncStatus tuple, the source/replica names are mapped to the master/slave ones.
+type SemiSyncStatus struct {
+	// Rpl_semi_sync_master_status: ON if the master waits for the slave ack
+	MasterStatus string
+
+	// Rpl_semi_sync_slave_status: ON if the slave acks
+	SlaveStatus string
+
+	// Rpl_semi_sync_master_clients: how many semi-sync slaves are connected
+	Clients uint64
+
+	// Rpl_semi_sync_master_tx_waits: how many times the master waited for the ack
+	TxWaits uint64
+
+	// Rpl_semi_sync_master_no_tx: how many commits were not acked
+	NoTx uint64
+
+	// Rpl_semi_sync_master_yes_tx: how many commits were acked
+	YesTx uint64
+
+	// rpl_semi_sync_master_wait_point: AFTER_SYNC or AFTER_COMMIT, empty before 5.7
+	WaitPoint string
 }
 
 type MysqlStatusRPCRequest struct {
@@ -154,6 +188,9 @@ type MysqlStatusRPCResponse struct {
 	// Mysql stats
 	Stats *MysqlStats
 
+	// All the replication channels, including the ones xenon doesn't manage
+	Channels []GTID
+
 	// Return code to rpc client:
 	// OK or other errors
 	RetCode string
@@ -217,6 +254,77 @@ func NewMysqlSetStateRPCResponse(code string) *MysqlSetStateRPCResponse {
 	return &MysqlSetStateRPCResponse{RetCode: code}
 }
 
+// version
+type MysqlVersionRPCResponse struct {
+	// The server version, such as 8.0.18
+	Version string
+
+	// Return code to rpc client:
+	// OK or other errors
+	RetCode string
+}
+
+func NewMysqlVersionRPCResponse(code string) *MysqlVersionRPCResponse {
+	return &MysqlVersionRPCResponse{RetCode: code}
+}
+
+// preflight
+type MysqlPreflightCheck struct {
+	// The check name, such as log_bin
+	Name string
+
+	// The mysql is capable of the HA or not
+	Passed bool
+
+	// What is found, the reason if not passed
+	Detail string
+}
+
+// clone
+type MysqlCloneRPCRequest struct {
+	// The IP of this request
+	From string
+
+	// The donor which the data is cloned from
+	Donor Repl
+}
+
+type MysqlCloneRPCResponse struct {
+	// The donor info which the recipient connects to
+	Donor Repl
+
+	// Return code to rpc client:
+	// OK or other errors
+	RetCode string
+}
+
+func NewMysqlCloneRPCRequest() *MysqlCloneRPCRequest {
+	return &MysqlCloneRPCRequest{}
+}
+
+func NewMysqlCloneRPCResponse(code string) *MysqlCloneRPCResponse {
+	return &MysqlCloneRPCResponse{RetCode: code}
+}
+
+// delayed catch-up
+type MysqlDelayedCatchupRPCRequest struct {
+	// The IP of this request
+	From string
+
+	// The sql thread applies until the transaction before the GTID
+	GTID string
+
+	// Resume the MASTER_DELAY and release the sql thread
+	Resume bool
+
+	// The MASTER_DELAY(seconds) to resume, or to restore if the catch-up fails
+	Delay int
+}
+
+func NewMysqlDelayedCatchupRPCRequest() *MysqlDelayedCatchupRPCRequest {
+	return &MysqlDelayedCatchupRPCRequest{}
+}
+
 // user
 type MysqlUserRPCRequest struct {
 	// The IP of this request
@@ -9,11 +9,13 @@
 package model
 
 const (
-	RPCNodesAdd        = "NodeRPC.AddNodes"
-	RPCIdleNodesAdd    = "NodeRPC.AddIdleNodes"
-	RPCNodesRemove     = "NodeRPC.RemoveNodes"
-	RPCIdleNodesRemove = "NodeRPC.RemoveIdleNodes"
-	RPCNodes           = "NodeRPC.GetNodes"
+	RPCNodesAdd           = "NodeRPC.AddNodes"
+	RPCIdleNodesAdd       = "NodeRPC.AddIdleNodes"
+	RPCReplicaNodesAdd    = "NodeRPC.AddReplicaNodes"
+	RPCNodesRemove        = "NodeRPC.RemoveNodes"
+	RPCIdleNodesRemove    = "NodeRPC.RemoveIdleNodes"
+	RPCReplicaNodesRemove = "NodeRPC.RemoveReplicaNodes"
+	RPCNodes              = "NodeRPC.GetNodes"
 )
 
 type NodeRPCRequest struct {
@@ -32,7 +34,7 @@ type NodeRPCResponse struct {
 	ViewID uint64
 
 	// The State of the raft:
-	// FOLLOWER/CANDIDATE/LEADER/IDLE/INVALID
+	// FOLLOWER/CANDIDATE/LEADER/IDLE/INVALID/LEARNER/REPLICA
 	State string
 
 	// The Leader endpoint of the cluster
@@ -41,6 +43,15 @@ type NodeRPCResponse struct {
 	// The Nodes(endpoint) of the cluster
 	Nodes []string
 
+	// The read replicas(endpoint) of the cluster
+	Replicas []string
+
+	// The labels(zone, region and the others) of the node
+	Labels map[string]string
+
+	// The MASTER_DELAY(seconds) of the node, the delayed node is never promoted or used as a rebuild donor
+	ReplicationDelay int
+
 	// Return code to rpc client:
 	// OK or other errors
 	RetCode string
@@ -66,6 +77,10 @@ func (rsp *NodeRPCResponse) GetNodes() []string {
 	return rsp.Nodes
 }
 
+func (rsp *NodeRPCResponse) GetReplicas() []string {
+	return rsp.Replicas
+}
+
 func (rsp *NodeRPCResponse) GetLeader() string {
 	return rsp.Leader
 }
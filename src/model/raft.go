@@ -8,6 +8,10 @@
 
 package model
 
+import (
+	"time"
+)
+
 type RAFTMYSQL_STATUS string
 
 const (
@@ -23,6 +27,10 @@ const (
 	RPCRaftDisablePurgeBinlog   = "RaftRPC.DisablePurgeBinlog"
 	RPCRaftEnableCheckSemiSync  = "RaftRPC.EnableCheckSemiSync"
 	RPCRaftDisableCheckSemiSync = "RaftRPC.DisableCheckSemiSync"
+	RPCRaftPurgePlan            = "RaftRPC.PurgePlan"
+	RPCRaftSetPurgeConsumer     = "RaftRPC.SetPurgeConsumer"
+	RPCRaftRemovePurgeConsumer  = "RaftRPC.RemovePurgeConsumer"
+	RPCRaftWatchLeader          = "RaftRPC.WatchLeader"
 )
 
 // raft
@@ -44,6 +52,9 @@ type Raft struct {
 
 	// The state string(LEADER/CANCIDATE/FOLLOWER/IDLE/INVALID)
 	State string
+
+	// The labels(zone, region and the others) of the rpc call from
+	Labels map[string]string
 }
 
 // replication info
@@ -62,6 +73,9 @@ type Repl struct {
 
 	// Mysql replication GTID purged
 	Repl_GTID_Purged string
+
+	// Mysql replication delay(seconds), MASTER_DELAY of the read replica
+	Master_Delay int
 }
 
 type RaftRPCRequest struct {
@@ -70,13 +84,36 @@ type RaftRPCRequest struct {
 	GTID      GTID
 	Peers     []string
 	IdlePeers []string
+	Replicas  []string
+
+	// If true, the leader semi-sync has fallen back to async
+	SemiSyncDegraded bool
+
+	// The upstream follower which the Repl points to in the cascading replication, empty is the leader
+	Upstream string
+
+	// The mysql endpoint of the leader for the leader watchers, without the replication user
+	Mysql Repl
+
+	// The external binlog consumers of the leader, the members follow them
+	PurgeConsumers []PurgeConsumer
 }
 
 type RaftRPCResponse struct {
 	Raft                  Raft
 	GTID                  GTID
 	Relay_Master_Log_File string
+	Seconds_Behind_Master string
 	RetCode               string
+
+	// The upstream follower which the responder wants to replicate from, empty is the leader
+	Upstream string
+
+	// The mysql endpoint of the responder for its downstreams, without the replication user
+	Repl Repl
+
+	// If true, the responder is running a backup
+	Backuping bool
 }
 
 func NewRaftRPCRequest() *RaftRPCRequest {
@@ -107,6 +144,10 @@ func (req *RaftRPCRequest) GetIdlePeers() []string {
 	return req.IdlePeers
 }
 
+func (req *RaftRPCRequest) GetReplicas() []string {
+	return req.Replicas
+}
+
 func (req *RaftRPCRequest) GetFrom() string {
 	return req.Raft.From
 }
@@ -178,6 +219,15 @@ type RaftStats struct {
 	// How many times the candidate degrades to a follower
 	CandidateDegrades uint64
 
+	// How many times the leader semi-sync fell back to async
+	LeaderSemiSyncFallbacks uint64
+
+	// How many times the follower refused to promote because the leader semi-sync was async
+	FollowerRefusedFailovers uint64
+
+	// If true, the leader semi-sync has fallen back to async
+	SemiSyncDegraded bool
+
 	// How long of the state up
 	StateUptimes uint64
 
@@ -208,3 +258,123 @@ func NewRaftStatusRPCRequest() *RaftStatusRPCRequest {
 func NewRaftStatusRPCResponse(code string) *RaftStatusRPCResponse {
 	return &RaftStatusRPCResponse{RetCode: code}
 }
+
+// PurgeConsumer is an external binlog consumer such as Debezium or Canal,
+// the leader never purges the binlogs it hasn't consumed.
+type PurgeConsumer struct {
+	// The name of the consumer
+	Name string
+
+	// The leader binlog which the consumer is reading, it and the ones after it are kept
+	Binlog string
+
+	// The GTID sets which the consumer has executed, the binlogs with the other GTIDs are kept
+	GTID string
+
+	// The time of the last register or heartbeat, set by the node
+	Updated time.Time
+}
+
+type RaftPurgeConsumerRPCRequest struct {
+	// The endpoint of the rpc call from
+	From string
+
+	Consumer PurgeConsumer
+}
+
+type RaftPurgeConsumerRPCResponse struct {
+	// All the consumers of this raft
+	Consumers []PurgeConsumer
+
+	// Return code to rpc client:
+	// OK or other errors
+	RetCode string
+}
+
+func NewRaftPurgeConsumerRPCRequest() *RaftPurgeConsumerRPCRequest {
+	return &RaftPurgeConsumerRPCRequest{}
+}
+
+func NewRaftPurgeConsumerRPCResponse(code string) *RaftPurgeConsumerRPCResponse {
+	return &RaftPurgeConsumerRPCResponse{RetCode: code}
+}
+
+// PurgePlanStep is the binlog which a purge guard allows to purge to, empty means nothing.
+type PurgePlanStep struct {
+	Guard  string
+	Binlog string
+	Reason string
+}
+
+// PurgePlan is what the leader would purge and why.
+type PurgePlan struct {
+	// The state of the raft, only the leader purges
+	State string
+
+	// Why the leader doesn't purge at all
+	Note string
+
+	// The smallest binlog which all the members are executing
+	Next string
+
+	// The guards run in order, each one can only hold the binlog back
+	Steps []PurgePlanStep
+
+	// The binlog to purge to, empty is nothing
+	Target string
+
+	// The binlogs before the target
+	Purged []string
+}
+
+type RaftPurgePlanRPCResponse struct {
+	Plan *PurgePlan
+
+	// Return code to rpc client:
+	// OK or other errors
+	RetCode string
+}
+
+func NewRaftPurgePlanRPCResponse(code string) *RaftPurgePlanRPCResponse {
+	return &RaftPurgePlanRPCResponse{RetCode: code}
+}
+
+type RaftWatchLeaderRPCRequest struct {
+	// The leader which the watcher knows, empty is none
+	Leader string
+
+	// The max time(ms) to wait for the leader change
+	Timeout int
+}
+
+// LeaderView is the leader which the node follows and its mysql endpoint.
+type LeaderView struct {
+	// The leader, empty is none
+	Leader string
+
+	// The view id of the node
+	ViewID uint64
+
+	// The mysql endpoint of the leader, empty if it's unknown
+	MysqlHost string
+	MysqlPort int
+}
+
+type RaftWatchLeaderRPCResponse struct {
+	View LeaderView
+
+	// If true, the leader isn't the one which the watcher knows
+	Changed bool
+
+	// Return code to rpc client:
+	// OK or other errors
+	RetCode string
+}
+
+func NewRaftWatchLeaderRPCRequest() *RaftWatchLeaderRPCRequest {
+	return &RaftWatchLeaderRPCRequest{}
+}
+
+func NewRaftWatchLeaderRPCResponse(code string) *RaftWatchLeaderRPCResponse {
+	return &RaftWatchLeaderRPCResponse{RetCode: code}
+}
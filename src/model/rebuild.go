@@ -0,0 +1,136 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package model
+
+const (
+	RPCRebuildStart  = "RebuildRPC.Start"
+	RPCRebuildStatus = "RebuildRPC.Status"
+	RPCRebuildCancel = "RebuildRPC.Cancel"
+	RPCRebuildResume = "RebuildRPC.Resume"
+
+	RPCRebuildRestore = "RebuildRPC.Restore"
+)
+
+type REBUILD_STATE string
+
+const (
+	// no rebuild job has been started on this node
+	REBUILD_NONE REBUILD_STATE = "NONE"
+
+	// the job is running the steps
+	REBUILD_RUNNING REBUILD_STATE = "RUNNING"
+
+	// all the steps have been done
+	REBUILD_DONE REBUILD_STATE = "DONE"
+
+	// a step failed, the job can be resumed or rolled back
+	REBUILD_FAILED REBUILD_STATE = "FAILED"
+
+	// xenon went away while the job was running, the job can be resumed or rolled back
+	REBUILD_INTERRUPTED REBUILD_STATE = "INTERRUPTED"
+
+	// the job was canceled and the node has been rolled back
+	REBUILD_CANCELED REBUILD_STATE = "CANCELED"
+)
+
+const (
+	// copy the data by the xtrabackup from the donor
+	REBUILD_METHOD_XTRABACKUP = "xtrabackup"
+
+	// copy the data by the mysql clone plugin, both sides must be 8.0.17+
+	REBUILD_METHOD_CLONE = "clone"
+
+	// load the logical dump from the donor, set by the backup-method
+	REBUILD_METHOD_MYSQLDUMP = BACKUP_METHOD_MYSQLDUMP
+	REBUILD_METHOD_MYDUMPER  = BACKUP_METHOD_MYDUMPER
+)
+
+// RebuildJob is the step state of a rebuild, persisted in the raft meta dir.
+type RebuildJob struct {
+	// The job state
+	State REBUILD_STATE
+
+	// The endpoint which the job rebuilds from, empty means pick the bestone
+	From string
+
+	// Skip the local transactions check
+	Force bool
+
+	// The byte rate(bytes per second) limit of the backup stream, 0 is no limit
+	Rate int64
+
+	// The method to copy the data, selected when the donor is checked
+	Method string
+
+	// The number of steps which have been done
+	Step int
+
+	// The name of the step which is running or failed
+	StepName string
+
+	// The total number of steps
+	Steps int
+
+	// The mysql binlog prefix and dir parsed from the defaults file
+	BinlogPrefix string
+	BinlogDir    string
+
+	// The backup archive which the job restores, empty means the job is a rebuildme
+	RestoreID string
+
+	// Replay the archived binlogs until the datetime or the GTID set
+	RestoreToTime string
+	RestoreToGTID string
+
+	// The dir which the old datadir is moved to by the restore
+	RestoreAside string
+
+	// Unix time of the job begin and the last state change
+	Begin  int64
+	Update int64
+
+	// The last error message of the job
+	LastError string
+}
+
+type RebuildRPCRequest struct {
+	// The IP of this request
+	From string
+
+	// The endpoint to rebuild from, empty means pick the bestone
+	RebuildFrom string
+
+	// Skip the local transactions check
+	Force bool
+
+	// The byte rate(bytes per second) limit of the backup stream, 0 is no limit
+	Rate int64
+
+	// The backup archive to restore and the point in time to replay to
+	RestoreID     string
+	RestoreToTime string
+	RestoreToGTID string
+}
+
+type RebuildRPCResponse struct {
+	// The rebuild job
+	Job RebuildJob
+
+	// Return code to rpc client:
+	// OK or other errors
+	RetCode string
+}
+
+func NewRebuildRPCRequest() *RebuildRPCRequest {
+	return &RebuildRPCRequest{}
+}
+
+func NewRebuildRPCResponse(code string) *RebuildRPCResponse {
+	return &RebuildRPCResponse{RetCode: code}
+}
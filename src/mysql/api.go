@@ -12,6 +12,8 @@ import (
 	"database/sql"
 	"fmt"
 	"model"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -99,6 +101,17 @@ func (m *Mysql) GTIDGreaterThan(gtid *model.GTID) (bool, model.GTID, error) {
 		return false, this, err
 	}
 
+	// the MariaDB positions are compared by the domains, the seq is increasing in the domain
+	if IsMariaGTIDPos(this.Retrieved_GTID_Set) && IsMariaGTIDPos(gtid.Retrieved_GTID_Set) {
+		thisPos, _ := ParseMariaGTIDPos(this.Retrieved_GTID_Set)
+		gtidPos, _ := ParseMariaGTIDPos(gtid.Retrieved_GTID_Set)
+		log.Warning("mysql.gtid.compare.this[%v].from[%v]", this, gtid)
+		ahead, behind := thisPos.Compare(gtidPos)
+		if ahead != behind {
+			return ahead, this, nil
+		}
+	}
+
 	a := strings.ToUpper(fmt.Sprintf("%s:%016d", this.Master_Log_File, this.Read_Master_Log_Pos))
 	b := strings.ToUpper(fmt.Sprintf("%s:%016d", gtid.Master_Log_File, gtid.Read_Master_Log_Pos))
 	log.Warning("mysql.gtid.compare.this[%v].from[%v]", this, gtid)
@@ -126,6 +139,22 @@ func (m *Mysql) GetLocalGTID(gtid string) (string, error) {
 		return "", err
 	}
 
+	// the MariaDB local gtids are the domain-server-seq which the server is the server_id
+	if IsMariaDB(m.conf.Version) {
+		pos, err := ParseMariaGTIDPos(gtid)
+		if err != nil {
+			log.Error("mysql.GetLocalGTID.error[%v]", err)
+			return "", err
+		}
+		local := make(MariaGTIDPos)
+		for domain, g := range pos {
+			if mariaGTIDServer(g) == uuid {
+				local[domain] = g
+			}
+		}
+		return local.String(), nil
+	}
+
 	s_gtid := strings.Split(gtid, ",")
 	for _, gtid := range s_gtid {
 		if strings.Contains(gtid, uuid) {
@@ -150,6 +179,11 @@ func (m *Mysql) CheckGTID(followerGTID *model.GTID, candidateGTID *model.GTID) b
 	if err != nil {
 		log.Error("mysql.CheckGTID.error[%v]", err)
 	}
+	// the MariaDB seq is compared in the domain whichever server generates it,
+	// the follower local gtids are compared with the whole candidate position
+	if IsMariaDB(m.conf.Version) {
+		cGTID = cExecutedGTID
+	}
 
 	// follower never generate events, should vote, but if some one execute reset master, this may be error
 	// if a normal restart the follower retrived_gtid_set will be "" can't setState(INVALID)
@@ -202,8 +236,11 @@ func (m *Mysql) StopSlaveIOThread() error {
 	return m.mysqlHandler.StopSlaveIOThread(db)
 }
 
-// StartSlave used to start the slave.
+// StartSlave used to start the slave, it does nothing while the delayed catch-up holds the sql thread.
 func (m *Mysql) StartSlave() error {
+	if m.isSlaveHeld() {
+		return nil
+	}
 	db, err := m.getDB()
 	if err != nil {
 		return err
@@ -222,6 +259,9 @@ func (m *Mysql) StopSlave() error {
 
 // ChangeMasterTo used to do the 'change master to' command.
 func (m *Mysql) ChangeMasterTo(repl *model.Repl) error {
+	if m.isSlaveHeld() {
+		return errors.New("mysql.slave.is.held.by.the.delayed.catchup")
+	}
 	db, err := m.getDB()
 	if err != nil {
 		return err
@@ -229,6 +269,71 @@ func (m *Mysql) ChangeMasterTo(repl *model.Repl) error {
 	return m.mysqlHandler.ChangeMasterTo(db, repl)
 }
 
+// LoadSlaveHeld used to keep the hold of the delayed catch-up in the dir, the sql thread is held again
+// if xenon restarts before DelayedResume.
+func (m *Mysql) LoadSlaveHeld(dir string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.heldFile = filepath.Join(dir, slaveHeldFile)
+	if _, err := os.Stat(m.heldFile); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.WithStack(err)
+	}
+	m.slaveHeld = true
+	m.log.Warning("mysql.slave.is.held.by.the.delayed.catchup[%v]", m.heldFile)
+	return nil
+}
+
+// DelayedCatchup holds the sql thread, clears the MASTER_DELAY and applies the relay logs until the transaction
+// before the gtid, the 'start slave' and 'change master to' are refused until DelayedResume.
+// The delay is restored if the sql thread can't start until the gtid.
+func (m *Mysql) DelayedCatchup(gtid string, delay int) error {
+	// the UNTIL master_gtid_pos of MariaDB applies the transaction
+	if IsMariaDB(m.conf.Version) {
+		return errors.New("mariadb.start.slave.until.sql_before_gtids.unsupported")
+	}
+	db, err := m.getDB()
+	if err != nil {
+		return err
+	}
+
+	if err := m.setSlaveHeld(true); err != nil {
+		return err
+	}
+	if err := m.mysqlHandler.SetMasterDelay(db, 0); err != nil {
+		m.setSlaveHeld(false)
+		return err
+	}
+	if err := m.mysqlHandler.StartSlaveUntilGTID(db, gtid); err != nil {
+		// the sql thread is kept held if the delay isn't back, it would apply the transaction at once
+		if err1 := m.mysqlHandler.SetMasterDelay(db, delay); err1 != nil {
+			m.log.Error("mysql.delayed.catchup.restore.the.delay[%v].error[%v]", delay, err1)
+			return err
+		}
+		m.setSlaveHeld(false)
+		return err
+	}
+	return nil
+}
+
+// DelayedResume restores the MASTER_DELAY, releases the sql thread and starts the slave.
+func (m *Mysql) DelayedResume(delay int) error {
+	db, err := m.getDB()
+	if err != nil {
+		return err
+	}
+
+	if err := m.mysqlHandler.SetMasterDelay(db, delay); err != nil {
+		return err
+	}
+	if err := m.setSlaveHeld(false); err != nil {
+		return err
+	}
+	return m.mysqlHandler.StartSlave(db)
+}
+
 // ChangeToMaster used to do the 'reset slave all' command.
 func (m *Mysql) ChangeToMaster() error {
 	db, err := m.getDB()
@@ -314,6 +419,11 @@ func (m *Mysql) RelayMasterLogFile() string {
 	return m.pingEntry.Relay_Master_Log_File
 }
 
+// SecondsBehindMaster returns the Seconds_Behind_Master of the last ping, NULL or empty if the replication is broken.
+func (m *Mysql) SecondsBehindMaster() string {
+	return m.pingEntry.Seconds_Behind_Master
+}
+
 // WaitMysqlWorks used to wait for the mysqld to work.
 func (m *Mysql) WaitMysqlWorks(timeout int) error {
 	maxRunTime := time.Duration(timeout) * time.Millisecond
@@ -404,6 +514,71 @@ func (m *Mysql) PurgeBinlogsTo(binlog string) error {
 	return m.mysqlHandler.PurgeBinlogsTo(db, binlog)
 }
 
+// GetBinaryLogs used to get the binlog files, the last one is the active.
+func (m *Mysql) GetBinaryLogs() ([]string, error) {
+	db, err := m.getDB()
+	if err != nil {
+		return nil, err
+	}
+	return m.mysqlHandler.GetBinaryLogs(db)
+}
+
+// GetBinlogBasename used to get the log_bin_basename.
+func (m *Mysql) GetBinlogBasename() (string, error) {
+	db, err := m.getDB()
+	if err != nil {
+		return "", err
+	}
+	return m.mysqlHandler.GetBinlogBasename(db)
+}
+
+// GetBinlogPreviousGTIDs used to get the GTIDs before the binlog.
+func (m *Mysql) GetBinlogPreviousGTIDs(binlog string) (string, error) {
+	db, err := m.getDB()
+	if err != nil {
+		return "", err
+	}
+	return m.mysqlHandler.GetBinlogPreviousGTIDs(db, binlog)
+}
+
+// GetVersion used to get the server version.
+func (m *Mysql) GetVersion() (string, error) {
+	db, err := m.getDB()
+	if err != nil {
+		return "", err
+	}
+	return m.mysqlHandler.GetVersion(db)
+}
+
+// PrepareCloneDonor used to install the clone plugin and grant the replication user to clone from here,
+// returns the repl info which the recipient connects with.
+func (m *Mysql) PrepareCloneDonor() (*model.Repl, error) {
+	db, err := m.getDB()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.mysqlHandler.InstallClonePlugin(db); err != nil {
+		return nil, err
+	}
+	if err := m.mysqlHandler.GrantCloneDonorPrivileges(db, m.conf.ReplUser); err != nil {
+		return nil, err
+	}
+	repl := m.GetRepl()
+	return &repl, nil
+}
+
+// CloneInstance used to clone the data from the donor, the mysqld restarts after that.
+func (m *Mysql) CloneInstance(donor *model.Repl) error {
+	db, err := m.getDB()
+	if err != nil {
+		return err
+	}
+	if err := m.mysqlHandler.InstallClonePlugin(db); err != nil {
+		return err
+	}
+	return m.mysqlHandler.CloneInstance(db, donor)
+}
+
 // EnableSemiSyncMaster used to enable the semi-sync on master.
 func (m *Mysql) EnableSemiSyncMaster() error {
 	db, err := m.getDB()
@@ -431,6 +606,15 @@ func (m *Mysql) DisableSemiSyncMaster() error {
 	return m.mysqlHandler.DisableSemiSyncMaster(db)
 }
 
+// DisableSemiSyncSlave used to disable the semi-sync on slave.
+func (m *Mysql) DisableSemiSyncSlave() error {
+	db, err := m.getDB()
+	if err != nil {
+		return err
+	}
+	return m.mysqlHandler.DisableSemiSyncSlave(db)
+}
+
 // SetSemiSyncMasterTimeout used to set semi-sync master timeout.
 func (m *Mysql) SetSemiSyncMasterTimeout(timeout uint64) error {
 	db, err := m.getDB()
@@ -440,6 +624,43 @@ func (m *Mysql) SetSemiSyncMasterTimeout(timeout uint64) error {
 	return m.mysqlHandler.SetSemiSyncMasterTimeout(db, timeout)
 }
 
+// InstallSemiSyncPlugins used to install the semi-sync plugins if they are missing.
+func (m *Mysql) InstallSemiSyncPlugins() error {
+	db, err := m.getDB()
+	if err != nil {
+		return err
+	}
+	return m.mysqlHandler.InstallSemiSyncPlugins(db)
+}
+
+// GetSemiSyncStatus used to get the semi-sync health.
+func (m *Mysql) GetSemiSyncStatus() (*model.SemiSyncStatus, error) {
+	db, err := m.getDB()
+	if err != nil {
+		return nil, err
+	}
+	return m.mysqlHandler.GetSemiSyncStatus(db)
+}
+
+// CheckSemiSyncWaitPoint used to verify the semi-sync is lossless,
+// the wait point is set to AFTER_SYNC if it's AFTER_COMMIT.
+func (m *Mysql) CheckSemiSyncWaitPoint() error {
+	log := m.log
+	db, err := m.getDB()
+	if err != nil {
+		return err
+	}
+	status, err := m.mysqlHandler.GetSemiSyncStatus(db)
+	if err != nil || status == nil {
+		return err
+	}
+	if status.WaitPoint != "" && status.WaitPoint != SemiSyncWaitPointAfterSync {
+		log.Warning("mysql.semisync.wait.point[%v].is.not.%v.set.it", status.WaitPoint, SemiSyncWaitPointAfterSync)
+		return m.mysqlHandler.SetSemiSyncWaitPoint(db, SemiSyncWaitPointAfterSync)
+	}
+	return nil
+}
+
 // CheckUserExists used to check the user exists or not.
 func (m *Mysql) CheckUserExists(user string, host string) (bool, error) {
 	db, err := m.getDB()
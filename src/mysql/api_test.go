@@ -11,11 +11,14 @@ package mysql
 import (
 	"config"
 	"fmt"
+	"io/ioutil"
 	"model"
+	"os"
 	"testing"
 	"xbase/xlog"
 
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/DATA-DOG/go-sqlmock.v1"
 )
@@ -140,6 +143,136 @@ func TestChangeMasterTo(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestDelayedCatchup(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	// log
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := config.DefaultMysqlConfig()
+	mysql := NewMysql(conf, 10000, log)
+	mysql.db = db
+
+	// catch up until before the gtid
+	{
+		mock.ExpectExec("STOP SLAVE SQL_THREAD").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("CHANGE MASTER TO MASTER_DELAY = 0").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("START SLAVE SQL_THREAD UNTIL SQL_BEFORE_GTIDS = '84030605-66aa-11e6-9465-52540e7fd51c:160'").WillReturnResult(sqlmock.NewResult(1, 1))
+		err = mysql.DelayedCatchup("84030605-66aa-11e6-9465-52540e7fd51c:160", 3600)
+		assert.Nil(t, err)
+	}
+
+	// the sql thread is held
+	{
+		err = mysql.StartSlave()
+		assert.Nil(t, err)
+		repl := mysql.GetRepl()
+		err = mysql.ChangeMasterTo(&repl)
+		assert.NotNil(t, err)
+	}
+
+	// resume the delay
+	{
+		mock.ExpectExec("STOP SLAVE SQL_THREAD").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("CHANGE MASTER TO MASTER_DELAY = 3600").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("START SLAVE").WillReturnResult(sqlmock.NewResult(1, 1))
+		err = mysql.DelayedResume(3600)
+		assert.Nil(t, err)
+
+		mock.ExpectExec("START SLAVE").WillReturnResult(sqlmock.NewResult(1, 1))
+		err = mysql.StartSlave()
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestDelayedCatchupError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	// log
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := config.DefaultMysqlConfig()
+	mysql := NewMysql(conf, 10000, log)
+	mysql.db = db
+
+	// the until start failed, the delay is restored and the sql thread is released
+	{
+		mock.ExpectExec("STOP SLAVE SQL_THREAD").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("CHANGE MASTER TO MASTER_DELAY = 0").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("START SLAVE SQL_THREAD UNTIL SQL_BEFORE_GTIDS = '84030605-66aa-11e6-9465-52540e7fd51c:160'").WillReturnError(errors.New("mock.until.error"))
+		mock.ExpectExec("STOP SLAVE SQL_THREAD").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("CHANGE MASTER TO MASTER_DELAY = 3600").WillReturnResult(sqlmock.NewResult(1, 1))
+		err = mysql.DelayedCatchup("84030605-66aa-11e6-9465-52540e7fd51c:160", 3600)
+		assert.NotNil(t, err)
+		assert.False(t, mysql.isSlaveHeld())
+	}
+
+	// the delay isn't restored, the sql thread is kept held
+	{
+		mock.ExpectExec("STOP SLAVE SQL_THREAD").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("CHANGE MASTER TO MASTER_DELAY = 0").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("START SLAVE SQL_THREAD UNTIL SQL_BEFORE_GTIDS = '84030605-66aa-11e6-9465-52540e7fd51c:160'").WillReturnError(errors.New("mock.until.error"))
+		mock.ExpectExec("STOP SLAVE SQL_THREAD").WillReturnError(errors.New("mock.delay.error"))
+		err = mysql.DelayedCatchup("84030605-66aa-11e6-9465-52540e7fd51c:160", 3600)
+		assert.NotNil(t, err)
+		assert.True(t, mysql.isSlaveHeld())
+	}
+
+	// MariaDB is refused before the delay is cleared
+	{
+		conf.Version = "mariadb10"
+		err = mysql.DelayedCatchup("0-1-160", 3600)
+		assert.Equal(t, "mariadb.start.slave.until.sql_before_gtids.unsupported", err.Error())
+	}
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestDelayedCatchupRestart(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	dir, err := ioutil.TempDir("", "xenon-delayed-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// log
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := config.DefaultMysqlConfig()
+	mysql := NewMysql(conf, 10000, log)
+	mysql.db = db
+	assert.Nil(t, mysql.LoadSlaveHeld(dir))
+	assert.False(t, mysql.isSlaveHeld())
+
+	mock.ExpectExec("STOP SLAVE SQL_THREAD").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("CHANGE MASTER TO MASTER_DELAY = 0").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("START SLAVE SQL_THREAD UNTIL SQL_BEFORE_GTIDS = '84030605-66aa-11e6-9465-52540e7fd51c:160'").WillReturnResult(sqlmock.NewResult(1, 1))
+	assert.Nil(t, mysql.DelayedCatchup("84030605-66aa-11e6-9465-52540e7fd51c:160", 3600))
+
+	// xenon restarts, the sql thread is still held
+	restarted := NewMysql(conf, 10000, log)
+	restarted.db = db
+	assert.Nil(t, restarted.LoadSlaveHeld(dir))
+	assert.True(t, restarted.isSlaveHeld())
+	assert.Nil(t, restarted.StartSlave())
+	repl := restarted.GetRepl()
+	assert.NotNil(t, restarted.ChangeMasterTo(&repl))
+
+	// resume releases the hold
+	mock.ExpectExec("STOP SLAVE SQL_THREAD").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("CHANGE MASTER TO MASTER_DELAY = 3600").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("START SLAVE").WillReturnResult(sqlmock.NewResult(1, 1))
+	assert.Nil(t, restarted.DelayedResume(3600))
+
+	restarted = NewMysql(conf, 10000, log)
+	assert.Nil(t, restarted.LoadSlaveHeld(dir))
+	assert.False(t, restarted.isSlaveHeld())
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
 func TestChangeToMaster(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.Nil(t, err)
@@ -918,6 +1051,34 @@ func TestPurgeBinlogsTo(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestGetBinaryLogs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	// log
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := config.DefaultMysqlConfig()
+	mysql := NewMysql(conf, 10000, log)
+	mysql.db = db
+
+	query := "SHOW BINARY LOGS"
+	columns := []string{"Log_name", "File_size"}
+	mockRows := sqlmock.NewRows(columns).AddRow("mysql-bin.000001", "154")
+	mock.ExpectQuery(query).WillReturnRows(mockRows)
+	got, err := mysql.GetBinaryLogs()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"mysql-bin.000001"}, got)
+
+	query = "SELECT @@GLOBAL.LOG_BIN_BASENAME"
+	columns = []string{"@@GLOBAL.LOG_BIN_BASENAME"}
+	mockRows = sqlmock.NewRows(columns).AddRow("/u01/mysql/data/mysql-bin")
+	mock.ExpectQuery(query).WillReturnRows(mockRows)
+	basename, err := mysql.GetBinlogBasename()
+	assert.Nil(t, err)
+	assert.Equal(t, "/u01/mysql/data/mysql-bin", basename)
+}
+
 func TestSetMasterSysVars(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.Nil(t, err)
@@ -10,7 +10,16 @@ package mysql
 
 import (
 	"fmt"
+	"io/ioutil"
 	"model"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// the hold of the delayed catch-up is kept in the meta dir
+	slaveHeldFile = "delayed-catchup.held"
 )
 
 func (m *Mysql) setState(state model.MysqlState) {
@@ -25,6 +34,34 @@ func (m *Mysql) getState() model.MysqlState {
 	return m.state
 }
 
+// setSlaveHeld persists the hold to the heldFile before it's set, the sql thread is still held after xenon restarts.
+func (m *Mysql) setSlaveHeld(held bool) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.heldFile != "" {
+		if held {
+			tmp := m.heldFile + ".tmp"
+			if err := ioutil.WriteFile(tmp, nil, 0644); err != nil {
+				return errors.WithStack(err)
+			}
+			if err := os.Rename(tmp, m.heldFile); err != nil {
+				os.Remove(tmp)
+				return errors.WithStack(err)
+			}
+		} else if err := os.Remove(m.heldFile); err != nil && !os.IsNotExist(err) {
+			return errors.WithStack(err)
+		}
+	}
+	m.slaveHeld = held
+	return nil
+}
+
+func (m *Mysql) isSlaveHeld() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.slaveHeld
+}
+
 func (m *Mysql) setOption(o Option) {
 	m.option = o
 }
@@ -0,0 +1,286 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018-2019 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"model"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	_ MysqlHandler = &MariaDB10{}
+)
+
+// MariaDB10 tuple.
+// The GTID is the domain-server-seq position instead of the uuid set, the channel is the connection name,
+// the replication uses MASTER_USE_GTID and the semi-sync is built in since 10.3.
+type MariaDB10 struct {
+	MysqlBase
+}
+
+// connection returns the connection name clause, empty for the default connection.
+func (my *MariaDB10) connection() string {
+	if my.getReplChannel() == "" {
+		return ""
+	}
+	return fmt.Sprintf(" '%s'", my.getReplChannel())
+}
+
+// showAllSlavesStatus returns the rows of SHOW ALL SLAVES STATUS, the columns are renamed to the MySQL ones:
+// Connection_name to Channel_Name, Gtid_IO_Pos to Retrieved_Gtid_Set and Gtid_Slave_Pos to Executed_Gtid_Set.
+func (my *MariaDB10) showAllSlavesStatus(db *sql.DB) ([]map[string]string, error) {
+	rows, err := QueryWithTimeout(db, my.queryTimeout, "SHOW ALL SLAVES STATUS")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		row["Channel_Name"] = row["Connection_name"]
+		row["Retrieved_Gtid_Set"] = row["Gtid_IO_Pos"]
+		row["Executed_Gtid_Set"] = row["Gtid_Slave_Pos"]
+	}
+	return rows, nil
+}
+
+// gtidCurrentPos returns the gtid_current_pos, which has the replicated and the local generated GTIDs.
+func (my *MariaDB10) gtidCurrentPos(db *sql.DB) (string, error) {
+	rows, err := QueryWithTimeout(db, my.queryTimeout, "SELECT @@GLOBAL.gtid_current_pos")
+	if err != nil {
+		return "", err
+	}
+	if len(rows) > 0 {
+		return rows[0]["@@GLOBAL.gtid_current_pos"], nil
+	}
+	return "", nil
+}
+
+// Ping used to check the health and get the Relay_Master_Log_File and Seconds_Behind_Master of the xenon connection.
+func (my *MariaDB10) Ping(db *sql.DB) (*PingEntry, error) {
+	pe := &PingEntry{}
+	rows, err := my.showAllSlavesStatus(db)
+	if err != nil {
+		return nil, err
+	}
+	if row := my.channelRow(rows); row != nil {
+		pe.Relay_Master_Log_File = row["Relay_Master_Log_File"]
+		pe.Seconds_Behind_Master = row["Seconds_Behind_Master"]
+	}
+	return pe, nil
+}
+
+// SetReadOnly used to set mysql to readonly, there is no super_read_only in MariaDB.
+func (my *MariaDB10) SetReadOnly(db *sql.DB, readonly bool) error {
+	enabled := 0
+	if readonly {
+		enabled = 1
+	}
+	cmd := fmt.Sprintf("SET GLOBAL read_only = %d", enabled)
+	return ExecuteWithTimeout(db, my.queryTimeout, cmd)
+}
+
+// GetSlaveGTID gets the gtid from the xenon connection,
+// the Executed_GTID_Set is the gtid_current_pos like the gtid_executed of MySQL.
+func (my *MariaDB10) GetSlaveGTID(db *sql.DB) (*model.GTID, error) {
+	gtid := &model.GTID{}
+	rows, err := my.showAllSlavesStatus(db)
+	if err != nil {
+		return gtid, err
+	}
+	if row := my.channelRow(rows); row != nil {
+		slaveGTIDFromRow(gtid, row)
+		if gtid.Executed_GTID_Set, err = my.gtidCurrentPos(db); err != nil {
+			return gtid, err
+		}
+	}
+	return gtid, nil
+}
+
+// GetSlaveChannels gets the gtid of all the connections.
+func (my *MariaDB10) GetSlaveChannels(db *sql.DB) ([]model.GTID, error) {
+	rows, err := my.showAllSlavesStatus(db)
+	if err != nil {
+		return nil, err
+	}
+	channels := make([]model.GTID, len(rows))
+	for i, row := range rows {
+		slaveGTIDFromRow(&channels[i], row)
+	}
+	return channels, nil
+}
+
+// GetMasterGTID used to get binlog info from master, the Executed_GTID_Set is the gtid_current_pos.
+func (my *MariaDB10) GetMasterGTID(db *sql.DB) (*model.GTID, error) {
+	gtid, err := my.MysqlBase.GetMasterGTID(db)
+	if err != nil {
+		return nil, err
+	}
+	if gtid.Executed_GTID_Set, err = my.gtidCurrentPos(db); err != nil {
+		return nil, err
+	}
+	return gtid, nil
+}
+
+// GetUUID returns the server_id, it's the server of the domain-server-seq.
+func (my *MariaDB10) GetUUID(db *sql.DB) (string, error) {
+	rows, err := QueryWithTimeout(db, my.queryTimeout, "SELECT @@GLOBAL.server_id")
+	if err != nil {
+		return "", err
+	}
+	if len(rows) > 0 {
+		return rows[0]["@@GLOBAL.server_id"], nil
+	}
+	return "", nil
+}
+
+// StartSlaveIOThread used to start the io thread.
+func (my *MariaDB10) StartSlaveIOThread(db *sql.DB) error {
+	cmd := "START SLAVE" + my.connection() + " IO_THREAD"
+	return ExecuteWithTimeout(db, my.queryTimeout, cmd)
+}
+
+// StopSlaveIOThread used to stop the io thread.
+func (my *MariaDB10) StopSlaveIOThread(db *sql.DB) error {
+	cmd := "STOP SLAVE" + my.connection() + " IO_THREAD"
+	return ExecuteWithTimeout(db, my.queryTimeout, cmd)
+}
+
+// StartSlave used to start slave.
+func (my *MariaDB10) StartSlave(db *sql.DB) error {
+	cmd := "START SLAVE" + my.connection()
+	return ExecuteWithTimeout(db, my.queryTimeout, cmd)
+}
+
+// StopSlave used to stop the slave.
+func (my *MariaDB10) StopSlave(db *sql.DB) error {
+	cmd := "STOP SLAVE" + my.connection()
+	return ExecuteWithTimeout(db, my.queryTimeout, cmd)
+}
+
+func (my *MariaDB10) changeMasterToCommands(master *model.Repl) []string {
+	var args []string
+
+	args = append(args, fmt.Sprintf("MASTER_HOST = '%s'", master.Master_Host))
+	args = append(args, fmt.Sprintf("MASTER_PORT = %d", master.Master_Port))
+	args = append(args, fmt.Sprintf("MASTER_USER = '%s'", master.Repl_User))
+	args = append(args, fmt.Sprintf("MASTER_PASSWORD = '%s'", master.Repl_Password))
+	args = append(args, "MASTER_USE_GTID = slave_pos")
+	if master.Master_Delay > 0 {
+		args = append(args, fmt.Sprintf("MASTER_DELAY = %d", master.Master_Delay))
+	}
+	changeMasterTo := "CHANGE MASTER" + my.connection() + " TO\n  " + strings.Join(args, ",\n  ")
+	return []string{changeMasterTo}
+}
+
+// SetMasterDelay stops the sql thread and sets the MASTER_DELAY.
+func (my *MariaDB10) SetMasterDelay(db *sql.DB, delay int) error {
+	cmds := []string{"STOP SLAVE" + my.connection() + " SQL_THREAD",
+		fmt.Sprintf("CHANGE MASTER"+my.connection()+" TO MASTER_DELAY = %d", delay)}
+	return ExecuteSuperQueryListWithTimeout(db, my.queryTimeout, cmds)
+}
+
+// StartSlaveUntilGTID is unsupported, the UNTIL master_gtid_pos of MariaDB applies the transaction.
+func (my *MariaDB10) StartSlaveUntilGTID(db *sql.DB, gtid string) error {
+	return errors.New("mariadb.start.slave.until.sql_before_gtids.unsupported")
+}
+
+// legacyConnectionCommands returns the commands to drop the default connection if xenon set it up
+// before the named connection was configured.
+func (my *MariaDB10) legacyConnectionCommands(db *sql.DB, master *model.Repl) ([]string, error) {
+	if my.getReplChannel() == "" {
+		return nil, nil
+	}
+	rows, err := my.showAllSlavesStatus(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if row["Channel_Name"] == "" && row["Master_User"] == master.Repl_User {
+			return []string{"STOP SLAVE ''", "RESET SLAVE '' ALL"}, nil
+		}
+	}
+	return nil, nil
+}
+
+// ChangeMasterTo stop the xenon connection and replicate from the master by the gtid_slave_pos,
+// the Repl_GTID_Purged is the gtid_slave_pos to start from.
+func (my *MariaDB10) ChangeMasterTo(db *sql.DB, master *model.Repl) error {
+	legacy, err := my.legacyConnectionCommands(db, master)
+	if err != nil {
+		return err
+	}
+	cmds := []string{}
+	cmds = append(cmds, legacy...)
+	cmds = append(cmds, "STOP SLAVE"+my.connection())
+	if master.Repl_GTID_Purged != "" {
+		cmds = append(cmds, "RESET MASTER")
+		cmds = append(cmds, "RESET SLAVE"+my.connection()+" ALL")
+		cmds = append(cmds, fmt.Sprintf("SET GLOBAL gtid_slave_pos='%s'", master.Repl_GTID_Purged))
+	}
+	cmds = append(cmds, my.changeMasterToCommands(master)...)
+	cmds = append(cmds, "START SLAVE"+my.connection())
+	return ExecuteSuperQueryListWithTimeout(db, my.queryTimeout, cmds)
+}
+
+// ChangeToMaster changes a slave to be master.
+func (my *MariaDB10) ChangeToMaster(db *sql.DB) error {
+	cmds := []string{"STOP SLAVE" + my.connection(),
+		"RESET SLAVE" + my.connection() + " ALL"} //"ALL" makes it forget the master host:port
+	return ExecuteSuperQueryListWithTimeout(db, my.queryTimeout, cmds)
+}
+
+// ResetSlaveAll used to reset slave.
+func (my *MariaDB10) ResetSlaveAll(db *sql.DB) error {
+	return my.ChangeToMaster(db)
+}
+
+// WaitUntilAfterGTID used to do 'SELECT MASTER_GTID_WAIT' command.
+// https://mariadb.com/kb/en/master_gtid_wait/
+func (my *MariaDB10) WaitUntilAfterGTID(db *sql.DB, targetGTID string) error {
+	query := fmt.Sprintf("SELECT MASTER_GTID_WAIT('%s')", targetGTID)
+	return Execute(db, query)
+}
+
+// GetGTIDSubtract returns the domains of the subsetGTID which are ahead of the setGTID,
+// there is no GTID_SUBTRACT in MariaDB so it's done here.
+func (my *MariaDB10) GetGTIDSubtract(db *sql.DB, subsetGTID string, setGTID string) (string, error) {
+	return MariaGTIDSubtract(subsetGTID, setGTID)
+}
+
+// GetBinlogPreviousGTIDs returns the domain positions of the Gtid_list event, such as 0-1-100.
+func (my *MariaDB10) GetBinlogPreviousGTIDs(db *sql.DB, binlog string) (string, error) {
+	list, err := my.binlogEventInfo(db, binlog, "Gtid_list")
+	if err != nil {
+		return "", err
+	}
+	return strings.Trim(list, "[]"), nil
+}
+
+// Preflight used to probe the capabilities, the GTID is always on in MariaDB.
+func (my *MariaDB10) Preflight(db *sql.DB) ([]model.MysqlPreflightCheck, error) {
+	return my.preflight(db, true)
+}
+
+// InstallSemiSyncPlugins does nothing, the semi-sync is built in since 10.3.
+func (my *MariaDB10) InstallSemiSyncPlugins(db *sql.DB) error {
+	return nil
+}
+
+// SetSemiWaitSlaveCount does nothing, MariaDB always waits for one slave.
+func (my *MariaDB10) SetSemiWaitSlaveCount(db *sql.DB, count int) error {
+	return nil
+}
+
+// mariaGTIDServer returns the server_id of the gtid as the uuid, see GetUUID.
+func mariaGTIDServer(gtid MariaGTID) string {
+	return strconv.FormatUint(uint64(gtid.Server), 10)
+}
@@ -0,0 +1,245 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018-2019 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysql
+
+import (
+	"testing"
+
+	"config"
+	"model"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func mariaSlavesRows() sqlmock.Rows {
+	columns := []string{"Connection_name", "Master_User", "Master_Log_File", "Read_Master_Log_Pos", "Relay_Master_Log_File", "Slave_IO_Running", "Slave_SQL_Running", "Gtid_IO_Pos", "Gtid_Slave_Pos"}
+	return sqlmock.NewRows(columns).
+		AddRow("", "repl", "mysql-bin.000001", "120", "mysql-bin.000001", "Yes", "Yes", "0-1-90", "0-1-90").
+		AddRow("xenon", "repl", "mysql-bin.000002", "147", "mysql-bin.000002", "Yes", "Yes", "0-1-100", "0-1-100,1-2-20")
+}
+
+func TestMariaDB10Handler(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := config.DefaultMysqlConfig()
+	conf.Version = "mariadb10"
+
+	mysql := NewMysql(conf, 10000, log)
+	want := new(MariaDB10)
+	want.SetQueryTimeout(10000)
+	got := mysql.mysqlHandler
+	assert.Equal(t, want, got)
+
+	assert.True(t, IsMariaDB("mariadb10"))
+	assert.False(t, IsMariaDB("mysql57"))
+	assert.False(t, HasSuperReadOnly("mariadb10"))
+	assert.False(t, HasSuperReadOnly("mysql56"))
+	assert.True(t, HasSuperReadOnly("mysql57"))
+}
+
+func TestMariaDB10Status(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	mariadb := new(MariaDB10)
+	mariadb.SetQueryTimeout(10000)
+	mariadb.SetReplChannel("xenon")
+
+	// ping
+	{
+		mock.ExpectQuery("SHOW ALL SLAVES STATUS").WillReturnRows(mariaSlavesRows())
+		got, err := mariadb.Ping(db)
+		assert.Nil(t, err)
+		assert.Equal(t, &PingEntry{Relay_Master_Log_File: "mysql-bin.000002"}, got)
+	}
+
+	// slave gtid of the xenon connection
+	{
+		mock.ExpectQuery("SHOW ALL SLAVES STATUS").WillReturnRows(mariaSlavesRows())
+		mock.ExpectQuery("SELECT @@GLOBAL.gtid_current_pos").WillReturnRows(sqlmock.NewRows([]string{"@@GLOBAL.gtid_current_pos"}).AddRow("0-1-100,1-2-21"))
+		got, err := mariadb.GetSlaveGTID(db)
+		assert.Nil(t, err)
+		assert.Equal(t, "xenon", got.Channel_Name)
+		assert.Equal(t, "mysql-bin.000002", got.Master_Log_File)
+		assert.Equal(t, uint64(147), got.Read_Master_Log_Pos)
+		assert.Equal(t, "0-1-100", got.Retrieved_GTID_Set)
+		assert.Equal(t, "0-1-100,1-2-21", got.Executed_GTID_Set)
+		assert.True(t, got.Slave_IO_Running)
+	}
+
+	// all the connections
+	{
+		mock.ExpectQuery("SHOW ALL SLAVES STATUS").WillReturnRows(mariaSlavesRows())
+		got, err := mariadb.GetSlaveChannels(db)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(got))
+		assert.Equal(t, "", got[0].Channel_Name)
+		assert.Equal(t, "0-1-90", got[0].Executed_GTID_Set)
+		assert.Equal(t, "xenon", got[1].Channel_Name)
+	}
+
+	// master gtid
+	{
+		mock.ExpectQuery("SHOW MASTER STATUS").WillReturnRows(sqlmock.NewRows([]string{"File", "Position"}).AddRow("mysql-bin.000003", "154"))
+		mock.ExpectQuery("SELECT @@GLOBAL.gtid_current_pos").WillReturnRows(sqlmock.NewRows([]string{"@@GLOBAL.gtid_current_pos"}).AddRow("0-2-101"))
+		got, err := mariadb.GetMasterGTID(db)
+		assert.Nil(t, err)
+		assert.Equal(t, "mysql-bin.000003", got.Master_Log_File)
+		assert.Equal(t, uint64(154), got.Read_Master_Log_Pos)
+		assert.Equal(t, "0-2-101", got.Executed_GTID_Set)
+	}
+
+	// uuid is the server_id
+	{
+		mock.ExpectQuery("SELECT @@GLOBAL.server_id").WillReturnRows(sqlmock.NewRows([]string{"@@GLOBAL.server_id"}).AddRow("2"))
+		got, err := mariadb.GetUUID(db)
+		assert.Nil(t, err)
+		assert.Equal(t, "2", got)
+	}
+
+	// subtract is done by the domains
+	{
+		got, err := mariadb.GetGTIDSubtract(db, "0-1-100,1-2-21", "0-1-100,1-2-20")
+		assert.Nil(t, err)
+		assert.Equal(t, "1-2-21", got)
+	}
+
+	// the Gtid_list of the binlog
+	{
+		columns := []string{"Log_name", "Pos", "Event_type", "Server_id", "End_log_pos", "Info"}
+		mockRows := sqlmock.NewRows(columns).
+			AddRow("mysql-bin.000002", "4", "Format_desc", "1", "256", "Server ver: 10.6.12-MariaDB-log, Binlog ver: 4").
+			AddRow("mysql-bin.000002", "256", "Gtid_list", "1", "299", "[0-1-100,1-2-21]")
+		mock.ExpectQuery("SHOW BINLOG EVENTS IN 'mysql-bin.000002' LIMIT 3").WillReturnRows(mockRows)
+		got, err := mariadb.GetBinlogPreviousGTIDs(db, "mysql-bin.000002")
+		assert.Nil(t, err)
+		assert.Equal(t, "0-1-100,1-2-21", got)
+	}
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMariaDB10Replication(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	mariadb := new(MariaDB10)
+	mariadb.SetQueryTimeout(10000)
+	repl := &model.Repl{Master_Host: "localhost", Master_Port: 3306, Repl_User: "repl", Repl_Password: "repl"}
+
+	// the default connection
+	{
+		mock.ExpectExec("STOP SLAVE").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("CHANGE MASTER TO MASTER_HOST = 'localhost', MASTER_PORT = 3306, MASTER_USER = 'repl', MASTER_PASSWORD = 'repl', MASTER_USE_GTID = slave_pos").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("START SLAVE").WillReturnResult(sqlmock.NewResult(1, 1))
+		assert.Nil(t, mariadb.ChangeMasterTo(db, repl))
+	}
+
+	// the xenon connection drops the default one set up by xenon, and starts from the purged
+	{
+		mariadb.SetReplChannel("xenon")
+		repl.Repl_GTID_Purged = "0-1-100"
+		mock.ExpectQuery("SHOW ALL SLAVES STATUS").WillReturnRows(mariaSlavesRows())
+		mock.ExpectExec("STOP SLAVE ''").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("RESET SLAVE '' ALL").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("STOP SLAVE 'xenon'").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("RESET MASTER").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("RESET SLAVE 'xenon' ALL").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("SET GLOBAL gtid_slave_pos='0-1-100'").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("CHANGE MASTER 'xenon' TO MASTER_HOST = 'localhost', MASTER_PORT = 3306, MASTER_USER = 'repl', MASTER_PASSWORD = 'repl', MASTER_USE_GTID = slave_pos").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("START SLAVE 'xenon'").WillReturnResult(sqlmock.NewResult(1, 1))
+		assert.Nil(t, mariadb.ChangeMasterTo(db, repl))
+	}
+
+	// slave threads
+	{
+		mock.ExpectExec("START SLAVE 'xenon' IO_THREAD").WillReturnResult(sqlmock.NewResult(1, 1))
+		assert.Nil(t, mariadb.StartSlaveIOThread(db))
+		mock.ExpectExec("STOP SLAVE 'xenon' IO_THREAD").WillReturnResult(sqlmock.NewResult(1, 1))
+		assert.Nil(t, mariadb.StopSlaveIOThread(db))
+		mock.ExpectExec("STOP SLAVE 'xenon'").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("RESET SLAVE 'xenon' ALL").WillReturnResult(sqlmock.NewResult(1, 1))
+		assert.Nil(t, mariadb.ChangeToMaster(db))
+	}
+
+	// the delayed catch-up
+	{
+		mock.ExpectExec("STOP SLAVE 'xenon' SQL_THREAD").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("CHANGE MASTER 'xenon' TO MASTER_DELAY = 0").WillReturnResult(sqlmock.NewResult(1, 1))
+		assert.Nil(t, mariadb.SetMasterDelay(db, 0))
+		assert.NotNil(t, mariadb.StartSlaveUntilGTID(db, "0-1-100"))
+	}
+
+	// read only
+	{
+		mock.ExpectExec("SET GLOBAL read_only = 1").WillReturnResult(sqlmock.NewResult(1, 1))
+		assert.Nil(t, mariadb.SetReadOnly(db, true))
+		assert.Nil(t, mariadb.SetSemiWaitSlaveCount(db, 2))
+	}
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMariaDB10CheckGTID(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := config.DefaultMysqlConfig()
+	conf.Version = "mariadb10"
+	mysql := NewMysql(conf, 10000, log)
+	mysql.SetMysqlHandler(NewMockGTIDMariaDB())
+
+	// the local gtids are the server 2 ones
+	got, err := mysql.GetLocalGTID("0-1-100,1-2-20,2-2-5")
+	assert.Nil(t, err)
+	assert.Equal(t, "1-2-20,2-2-5", got)
+
+	// the candidate has the local gtids of the follower
+	follower := &model.GTID{Executed_GTID_Set: "0-1-100,1-2-20"}
+	candidate := &model.GTID{Executed_GTID_Set: "0-3-101,1-2-20"}
+	assert.False(t, mysql.CheckGTID(follower, candidate))
+
+	// the follower has the local committed gtids
+	follower = &model.GTID{Executed_GTID_Set: "0-1-100,1-2-21"}
+	assert.True(t, mysql.CheckGTID(follower, candidate))
+
+	// the candidate has none
+	assert.False(t, mysql.CheckGTID(follower, &model.GTID{}))
+}
+
+func TestMariaDB10GTIDGreaterThan(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := config.DefaultMysqlConfig()
+	conf.Version = "mariadb10"
+	mysql := NewMysql(conf, 10000, log)
+	mysql.SetMysqlHandler(NewMockGTIDMariaDB())
+
+	// local Retrieved_GTID_Set is 0-1-100
+	{
+		gtid := &model.GTID{Master_Log_File: "mysql-bin.000009", Read_Master_Log_Pos: 1, Retrieved_GTID_Set: "0-1-99"}
+		got, _, err := mysql.GTIDGreaterThan(gtid)
+		assert.Nil(t, err)
+		assert.True(t, got)
+	}
+
+	// behind in the domain 1, the binlog file is ignored
+	{
+		gtid := &model.GTID{Master_Log_File: "mysql-bin.000000", Read_Master_Log_Pos: 1, Retrieved_GTID_Set: "0-1-100,1-2-1"}
+		got, _, err := mysql.GTIDGreaterThan(gtid)
+		assert.Nil(t, err)
+		assert.False(t, got)
+	}
+
+	// same position, falls back to the binlog file:pos
+	{
+		gtid := &model.GTID{Master_Log_File: "mysql-bin.000001", Read_Master_Log_Pos: 122, Retrieved_GTID_Set: "0-1-100"}
+		got, _, err := mysql.GTIDGreaterThan(gtid)
+		assert.Nil(t, err)
+		assert.True(t, got)
+	}
+}
@@ -0,0 +1,127 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018-2019 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysql
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MariaGTID tuple, the MariaDB GTID is domain-server-seq.
+// The seq is increasing in the domain, whichever server generates it.
+type MariaGTID struct {
+	Domain uint32
+	Server uint32
+	Seq    uint64
+}
+
+// String returns the domain-server-seq.
+func (g MariaGTID) String() string {
+	return fmt.Sprintf("%d-%d-%d", g.Domain, g.Server, g.Seq)
+}
+
+// MariaGTIDPos tuple, the last GTID of each domain, such as the gtid_current_pos '0-1-100,1-2-20'.
+type MariaGTIDPos map[uint32]MariaGTID
+
+// ParseMariaGTID parses the domain-server-seq.
+func ParseMariaGTID(gtid string) (MariaGTID, error) {
+	parts := strings.Split(strings.TrimSpace(gtid), "-")
+	if len(parts) != 3 {
+		return MariaGTID{}, errors.Errorf("mariadb.gtid[%s].invalid", gtid)
+	}
+	domain, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return MariaGTID{}, errors.Errorf("mariadb.gtid[%s].invalid.domain", gtid)
+	}
+	server, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return MariaGTID{}, errors.Errorf("mariadb.gtid[%s].invalid.server", gtid)
+	}
+	seq, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return MariaGTID{}, errors.Errorf("mariadb.gtid[%s].invalid.seq", gtid)
+	}
+	return MariaGTID{Domain: uint32(domain), Server: uint32(server), Seq: seq}, nil
+}
+
+// ParseMariaGTIDPos parses the comma separated GTID position, the empty one has no domains.
+// The highest seq wins if a domain shows up more than once.
+func ParseMariaGTIDPos(pos string) (MariaGTIDPos, error) {
+	p := make(MariaGTIDPos)
+	for _, s := range strings.Split(pos, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		gtid, err := ParseMariaGTID(s)
+		if err != nil {
+			return nil, err
+		}
+		if old, ok := p[gtid.Domain]; !ok || gtid.Seq > old.Seq {
+			p[gtid.Domain] = gtid
+		}
+	}
+	return p, nil
+}
+
+// IsMariaGTIDPos returns true if the pos is a non-empty MariaDB GTID position,
+// the MySQL GTID set uuid:interval never parses.
+func IsMariaGTIDPos(pos string) bool {
+	p, err := ParseMariaGTIDPos(pos)
+	return err == nil && len(p) > 0
+}
+
+// String returns the position sorted by the domain.
+func (p MariaGTIDPos) String() string {
+	domains := make([]int, 0, len(p))
+	for domain := range p {
+		domains = append(domains, int(domain))
+	}
+	sort.Ints(domains)
+
+	gtids := make([]string, 0, len(p))
+	for _, domain := range domains {
+		gtids = append(gtids, p[uint32(domain)].String())
+	}
+	return strings.Join(gtids, ",")
+}
+
+// Subtract returns the domains of p which are ahead of o,
+// it's the MariaDB form of GTID_SUBTRACT(p, o): empty if o contains p.
+func (p MariaGTIDPos) Subtract(o MariaGTIDPos) MariaGTIDPos {
+	sub := make(MariaGTIDPos)
+	for domain, gtid := range p {
+		if other, ok := o[domain]; !ok || gtid.Seq > other.Seq {
+			sub[domain] = gtid
+		}
+	}
+	return sub
+}
+
+// Compare returns whether p is ahead of o in some domain, and whether it's behind in some domain.
+// Both are true if they diverge.
+func (p MariaGTIDPos) Compare(o MariaGTIDPos) (ahead bool, behind bool) {
+	return len(p.Subtract(o)) > 0, len(o.Subtract(p)) > 0
+}
+
+// MariaGTIDSubtract returns the subset domains which are ahead of the set.
+func MariaGTIDSubtract(subsetGTID string, setGTID string) (string, error) {
+	subset, err := ParseMariaGTIDPos(subsetGTID)
+	if err != nil {
+		return "", err
+	}
+	set, err := ParseMariaGTIDPos(setGTID)
+	if err != nil {
+		return "", err
+	}
+	return subset.Subtract(set).String(), nil
+}
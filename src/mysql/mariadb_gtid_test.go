@@ -0,0 +1,76 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018-2019 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMariaGTIDPos(t *testing.T) {
+	// the highest seq of the domain wins
+	pos, err := ParseMariaGTIDPos("1-2-20, 0-1-100,0-3-99")
+	assert.Nil(t, err)
+	assert.Equal(t, MariaGTIDPos{0: {Domain: 0, Server: 1, Seq: 100}, 1: {Domain: 1, Server: 2, Seq: 20}}, pos)
+	assert.Equal(t, "0-1-100,1-2-20", pos.String())
+
+	// empty
+	pos, err = ParseMariaGTIDPos("")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(pos))
+	assert.False(t, IsMariaGTIDPos(""))
+
+	// invalid
+	for _, s := range []string{"0-1", "a-1-100", "0-b-100", "0-1-c", "84030605-66aa-11e6-9465-52540e7fd51c:1-160"} {
+		_, err = ParseMariaGTIDPos(s)
+		assert.NotNil(t, err, s)
+		assert.False(t, IsMariaGTIDPos(s))
+	}
+	_, err = ParseMariaGTID("0-1")
+	assert.Equal(t, "mariadb.gtid[0-1].invalid", err.Error())
+	assert.True(t, IsMariaGTIDPos("0-1-100"))
+}
+
+func TestMariaGTIDPosCompare(t *testing.T) {
+	a, _ := ParseMariaGTIDPos("0-1-100,1-2-20")
+	b, _ := ParseMariaGTIDPos("0-1-99,1-2-20")
+	c, _ := ParseMariaGTIDPos("0-1-100,1-2-20,2-3-5")
+	d, _ := ParseMariaGTIDPos("0-1-101,1-2-19")
+
+	ahead, behind := a.Compare(b)
+	assert.True(t, ahead)
+	assert.False(t, behind)
+
+	ahead, behind = a.Compare(c)
+	assert.False(t, ahead)
+	assert.True(t, behind)
+
+	ahead, behind = a.Compare(a)
+	assert.False(t, ahead)
+	assert.False(t, behind)
+
+	// diverged
+	ahead, behind = a.Compare(d)
+	assert.True(t, ahead)
+	assert.True(t, behind)
+
+	// subtract
+	sub, err := MariaGTIDSubtract("0-1-100,1-2-20", "0-1-99,1-2-20")
+	assert.Nil(t, err)
+	assert.Equal(t, "0-1-100", sub)
+	sub, err = MariaGTIDSubtract("0-1-100", "0-1-100,1-2-20")
+	assert.Nil(t, err)
+	assert.Equal(t, "", sub)
+	sub, err = MariaGTIDSubtract("0-1-100,2-2-1", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "0-1-100,2-2-1", sub)
+	_, err = MariaGTIDSubtract("0-1-100", "x")
+	assert.NotNil(t, err)
+}
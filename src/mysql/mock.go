@@ -23,30 +23,47 @@ import (
 
 // MockGTID tuple.
 type MockGTID struct {
-	SetQueryTimeoutFn          func(int)
-	PingFn                     func(*sql.DB) (*PingEntry, error)
-	SetReadOnlyFn              func(*sql.DB, bool) error
-	GetMasterGTIDFn            func(*sql.DB) (*model.GTID, error)
-	GetSlaveGTIDFn             func(*sql.DB) (*model.GTID, error)
-	StartSlaveIOThreadFn       func(*sql.DB) error
-	StopSlaveIOThreadFn        func(*sql.DB) error
-	StartSlaveFn               func(*sql.DB) error
-	StopSlaveFn                func(*sql.DB) error
-	ChangeMasterToFn           func(*sql.DB, *model.Repl) error
-	ChangeToMasterFn           func(*sql.DB) error
-	WaitUntilAfterGTIDFn       func(*sql.DB, string) error
-	GetGTIDSubtractFn          func(*sql.DB, string, string) (string, error)
-	GetUUIDFn                  func(*sql.DB) (string, error)
-	CheckGTIDFn                func(*model.GTID, *model.GTID) bool
-	SetGlobalSysVarFn          func(*sql.DB, string) error
-	ResetMasterFn              func(*sql.DB) error
-	ResetSlaveAllFn            func(*sql.DB) error
-	PurgeBinlogsToFn           func(*sql.DB, string) error
-	EnableSemiSyncMasterFn     func(*sql.DB) error
-	DisableSemiSyncMasterFn    func(*sql.DB) error
-	SelectSysVarFn             func(*sql.DB, string) (string, error)
-	SetSemiWaitSlaveCountFn    func(*sql.DB, int) error
-	SetSemiSyncMasterTimeoutFn func(*sql.DB, uint64) error
+	SetQueryTimeoutFn           func(int)
+	SetReplChannelFn            func(string)
+	ProbeVersionFn              func(*sql.DB) error
+	PingFn                      func(*sql.DB) (*PingEntry, error)
+	SetReadOnlyFn               func(*sql.DB, bool) error
+	GetMasterGTIDFn             func(*sql.DB) (*model.GTID, error)
+	GetSlaveGTIDFn              func(*sql.DB) (*model.GTID, error)
+	GetSlaveChannelsFn          func(*sql.DB) ([]model.GTID, error)
+	StartSlaveIOThreadFn        func(*sql.DB) error
+	StopSlaveIOThreadFn         func(*sql.DB) error
+	StartSlaveFn                func(*sql.DB) error
+	StopSlaveFn                 func(*sql.DB) error
+	ChangeMasterToFn            func(*sql.DB, *model.Repl) error
+	SetMasterDelayFn            func(*sql.DB, int) error
+	StartSlaveUntilGTIDFn       func(*sql.DB, string) error
+	ChangeToMasterFn            func(*sql.DB) error
+	WaitUntilAfterGTIDFn        func(*sql.DB, string) error
+	GetGTIDSubtractFn           func(*sql.DB, string, string) (string, error)
+	GetUUIDFn                   func(*sql.DB) (string, error)
+	CheckGTIDFn                 func(*model.GTID, *model.GTID) bool
+	SetGlobalSysVarFn           func(*sql.DB, string) error
+	ResetMasterFn               func(*sql.DB) error
+	ResetSlaveAllFn             func(*sql.DB) error
+	PurgeBinlogsToFn            func(*sql.DB, string) error
+	GetBinaryLogsFn             func(*sql.DB) ([]string, error)
+	GetBinlogBasenameFn         func(*sql.DB) (string, error)
+	GetBinlogPreviousGTIDsFn    func(*sql.DB, string) (string, error)
+	GetVersionFn                func(*sql.DB) (string, error)
+	PreflightFn                 func(*sql.DB) ([]model.MysqlPreflightCheck, error)
+	InstallClonePluginFn        func(*sql.DB) error
+	GrantCloneDonorPrivilegesFn func(*sql.DB, string) error
+	CloneInstanceFn             func(*sql.DB, *model.Repl) error
+	EnableSemiSyncMasterFn      func(*sql.DB) error
+	DisableSemiSyncMasterFn     func(*sql.DB) error
+	DisableSemiSyncSlaveFn      func(*sql.DB) error
+	SelectSysVarFn              func(*sql.DB, string) (string, error)
+	SetSemiWaitSlaveCountFn     func(*sql.DB, int) error
+	SetSemiSyncMasterTimeoutFn  func(*sql.DB, uint64) error
+	InstallSemiSyncPluginsFn    func(*sql.DB) error
+	GetSemiSyncStatusFn         func(*sql.DB) (*model.SemiSyncStatus, error)
+	SetSemiSyncWaitPointFn      func(*sql.DB, string) error
 
 	// Users
 	GetUserFn                     func(*sql.DB) ([]model.MysqlUser, error)
@@ -72,6 +89,16 @@ func (mogtid *MockGTID) GetSlaveGTID(db *sql.DB) (*model.GTID, error) {
 	return mogtid.GetSlaveGTIDFn(db)
 }
 
+// DefaultGetSlaveChannels returns no channels.
+func DefaultGetSlaveChannels(db *sql.DB) ([]model.GTID, error) {
+	return []model.GTID{}, nil
+}
+
+// GetSlaveChannels mock.
+func (mogtid *MockGTID) GetSlaveChannels(db *sql.DB) ([]model.GTID, error) {
+	return mogtid.GetSlaveChannelsFn(db)
+}
+
 // DefaultGetUUID mock.
 func DefaultGetUUID(db *sql.DB) (string, error) {
 	return "84030605-66aa-11e6-9465-52540e7fd51c", nil
@@ -143,6 +170,26 @@ func (mogtid *MockGTID) ChangeMasterTo(db *sql.DB, gtid *model.Repl) error {
 	return mogtid.ChangeMasterToFn(db, gtid)
 }
 
+// DefaultSetMasterDelay mock.
+func DefaultSetMasterDelay(db *sql.DB, delay int) error {
+	return nil
+}
+
+// SetMasterDelay mock.
+func (mogtid *MockGTID) SetMasterDelay(db *sql.DB, delay int) error {
+	return mogtid.SetMasterDelayFn(db, delay)
+}
+
+// DefaultStartSlaveUntilGTID mock.
+func DefaultStartSlaveUntilGTID(db *sql.DB, gtid string) error {
+	return nil
+}
+
+// StartSlaveUntilGTID mock.
+func (mogtid *MockGTID) StartSlaveUntilGTID(db *sql.DB, gtid string) error {
+	return mogtid.StartSlaveUntilGTIDFn(db, gtid)
+}
+
 // DefaultChangeToMaster mock.
 func DefaultChangeToMaster(db *sql.DB) error {
 	return nil
@@ -186,6 +233,25 @@ func (mogtid *MockGTID) SetQueryTimeout(timeout int) {
 	mogtid.SetQueryTimeoutFn(timeout)
 }
 
+// DefaultSetReplChannel mock.
+func DefaultSetReplChannel(channel string) {
+}
+
+// SetReplChannel mock.
+func (mogtid *MockGTID) SetReplChannel(channel string) {
+	mogtid.SetReplChannelFn(channel)
+}
+
+// DefaultProbeVersion mock.
+func DefaultProbeVersion(db *sql.DB) error {
+	return nil
+}
+
+// ProbeVersion mock.
+func (mogtid *MockGTID) ProbeVersion(db *sql.DB) error {
+	return mogtid.ProbeVersionFn(db)
+}
+
 // DefaultPing mock.
 func DefaultPing(db *sql.DB) (*PingEntry, error) {
 	return &PingEntry{}, nil
@@ -246,6 +312,86 @@ func (mogtid *MockGTID) PurgeBinlogsTo(db *sql.DB, binlog string) error {
 	return mogtid.PurgeBinlogsToFn(db, binlog)
 }
 
+// DefaultGetBinaryLogs mock.
+func DefaultGetBinaryLogs(db *sql.DB) ([]string, error) {
+	return []string{"mysql-bin.000001"}, nil
+}
+
+// GetBinaryLogs mock.
+func (mogtid *MockGTID) GetBinaryLogs(db *sql.DB) ([]string, error) {
+	return mogtid.GetBinaryLogsFn(db)
+}
+
+// DefaultGetBinlogBasename mock.
+func DefaultGetBinlogBasename(db *sql.DB) (string, error) {
+	return "/u01/mysql_20160606/mysql-bin", nil
+}
+
+// GetBinlogBasename mock.
+func (mogtid *MockGTID) GetBinlogBasename(db *sql.DB) (string, error) {
+	return mogtid.GetBinlogBasenameFn(db)
+}
+
+// DefaultGetBinlogPreviousGTIDs mock.
+func DefaultGetBinlogPreviousGTIDs(db *sql.DB, binlog string) (string, error) {
+	return "", nil
+}
+
+// GetBinlogPreviousGTIDs mock.
+func (mogtid *MockGTID) GetBinlogPreviousGTIDs(db *sql.DB, binlog string) (string, error) {
+	return mogtid.GetBinlogPreviousGTIDsFn(db, binlog)
+}
+
+// DefaultGetVersion mock.
+func DefaultGetVersion(db *sql.DB) (string, error) {
+	return "8.0.18", nil
+}
+
+// GetVersion mock.
+func (mogtid *MockGTID) GetVersion(db *sql.DB) (string, error) {
+	return mogtid.GetVersionFn(db)
+}
+
+// DefaultPreflight mock, all the checks passed.
+func DefaultPreflight(db *sql.DB) ([]model.MysqlPreflightCheck, error) {
+	return []model.MysqlPreflightCheck{{Name: "log_bin", Passed: true, Detail: "log_bin is ON"}}, nil
+}
+
+// Preflight mock.
+func (mogtid *MockGTID) Preflight(db *sql.DB) ([]model.MysqlPreflightCheck, error) {
+	return mogtid.PreflightFn(db)
+}
+
+// DefaultInstallClonePlugin mock.
+func DefaultInstallClonePlugin(db *sql.DB) error {
+	return nil
+}
+
+// InstallClonePlugin mock.
+func (mogtid *MockGTID) InstallClonePlugin(db *sql.DB) error {
+	return mogtid.InstallClonePluginFn(db)
+}
+
+// DefaultGrantCloneDonorPrivileges mock.
+func DefaultGrantCloneDonorPrivileges(db *sql.DB, user string) error {
+	return nil
+}
+
+// GrantCloneDonorPrivileges mock.
+func (mogtid *MockGTID) GrantCloneDonorPrivileges(db *sql.DB, user string) error {
+	return mogtid.GrantCloneDonorPrivilegesFn(db, user)
+}
+
+// DefaultCloneInstance mock.
+func DefaultCloneInstance(db *sql.DB, donor *model.Repl) error {
+	return nil
+}
+
+// CloneInstance mock.
+func (mogtid *MockGTID) CloneInstance(db *sql.DB, donor *model.Repl) error {
+	return mogtid.CloneInstanceFn(db, donor)
+}
+
 // DefaultEnableSemiSyncMaster mock.
 func DefaultEnableSemiSyncMaster(db *sql.DB) error {
 	return nil
@@ -266,6 +412,16 @@ func (mogtid *MockGTID) DisableSemiSyncMaster(db *sql.DB) error {
 	return mogtid.DisableSemiSyncMasterFn(db)
 }
 
+// DefaultDisableSemiSyncSlave mock.
+func DefaultDisableSemiSyncSlave(db *sql.DB) error {
+	return nil
+}
+
+// DisableSemiSyncSlave mock.
+func (mogtid *MockGTID) DisableSemiSyncSlave(db *sql.DB) error {
+	return mogtid.DisableSemiSyncSlaveFn(db)
+}
+
 // SetSemiSyncMasterTimeout mock.
 func (mogtid *MockGTID) SetSemiSyncMasterTimeout(db *sql.DB, timeout uint64) error {
 	return mogtid.SetSemiSyncMasterTimeoutFn(db, timeout)
@@ -296,6 +452,36 @@ func SetSemiSyncMasterTimeout(db *sql.DB, timeout uint64) error {
 	return nil
 }
 
+// DefaultInstallSemiSyncPlugins mock.
+func DefaultInstallSemiSyncPlugins(db *sql.DB) error {
+	return nil
+}
+
+// InstallSemiSyncPlugins mock.
+func (mogtid *MockGTID) InstallSemiSyncPlugins(db *sql.DB) error {
+	return mogtid.InstallSemiSyncPluginsFn(db)
+}
+
+// DefaultGetSemiSyncStatus mock, nothing is got.
+func DefaultGetSemiSyncStatus(db *sql.DB) (*model.SemiSyncStatus, error) {
+	return nil, nil
+}
+
+// GetSemiSyncStatus mock.
+func (mogtid *MockGTID) GetSemiSyncStatus(db *sql.DB) (*model.SemiSyncStatus, error) {
+	return mogtid.GetSemiSyncStatusFn(db)
+}
+
+// DefaultSetSemiSyncWaitPoint mock.
+func DefaultSetSemiSyncWaitPoint(db *sql.DB, point string) error {
+	return nil
+}
+
+// SetSemiSyncWaitPoint mock.
+func (mogtid *MockGTID) SetSemiSyncWaitPoint(db *sql.DB, point string) error {
+	return mogtid.SetSemiSyncWaitPointFn(db, point)
+}
+
 // User handlers.
 
 // CheckUserExists mock.
@@ -398,15 +584,20 @@ func (mogtid *MockGTID) GrantAllPrivileges(db *sql.DB, user string, host, passwd
 func defaultMockGTID() *MockGTID {
 	mock := &MockGTID{}
 	mock.SetQueryTimeoutFn = DefaultSetQueryTimeout
+	mock.SetReplChannelFn = DefaultSetReplChannel
+	mock.ProbeVersionFn = DefaultProbeVersion
 	mock.PingFn = DefaultPing
 	mock.SetReadOnlyFn = DefaultSetReadOnly
 	mock.GetMasterGTIDFn = DefaultGetMasterGTID
 	mock.GetSlaveGTIDFn = DefaultGetSlaveGTID
+	mock.GetSlaveChannelsFn = DefaultGetSlaveChannels
 	mock.StartSlaveIOThreadFn = DefaultStartSlaveIOThread
 	mock.StopSlaveIOThreadFn = DefaultStopSlaveIOThread
 	mock.StartSlaveFn = DefaultStartSlave
 	mock.StopSlaveFn = DefaultStopSlave
 	mock.ChangeMasterToFn = DefaultChangeMasterTo
+	mock.SetMasterDelayFn = DefaultSetMasterDelay
+	mock.StartSlaveUntilGTIDFn = DefaultStartSlaveUntilGTID
 	mock.ChangeToMasterFn = DefaultChangeToMaster
 	mock.WaitUntilAfterGTIDFn = DefaultWaitUntilAfterGTID
 	mock.GetGTIDSubtractFn = DefaultGetGTIDSubtract
@@ -416,11 +607,23 @@ func defaultMockGTID() *MockGTID {
 	mock.ResetMasterFn = DefaultResetMaster
 	mock.ResetSlaveAllFn = DefaultResetSlaveAll
 	mock.PurgeBinlogsToFn = DefaultPurgeBinlogsTo
+	mock.GetBinaryLogsFn = DefaultGetBinaryLogs
+	mock.GetBinlogBasenameFn = DefaultGetBinlogBasename
+	mock.GetBinlogPreviousGTIDsFn = DefaultGetBinlogPreviousGTIDs
+	mock.GetVersionFn = DefaultGetVersion
+	mock.PreflightFn = DefaultPreflight
+	mock.InstallClonePluginFn = DefaultInstallClonePlugin
+	mock.GrantCloneDonorPrivilegesFn = DefaultGrantCloneDonorPrivileges
+	mock.CloneInstanceFn = DefaultCloneInstance
 	mock.EnableSemiSyncMasterFn = DefaultEnableSemiSyncMaster
 	mock.DisableSemiSyncMasterFn = DefaultDisableSemiSyncMaster
+	mock.DisableSemiSyncSlaveFn = DefaultDisableSemiSyncSlave
 	mock.SelectSysVarFn = DefaultSelectSysVar
 	mock.SetSemiWaitSlaveCountFn = DefaultSetSemiWaitSlaveCount
 	mock.SetSemiSyncMasterTimeoutFn = SetSemiSyncMasterTimeout
+	mock.InstallSemiSyncPluginsFn = DefaultInstallSemiSyncPlugins
+	mock.GetSemiSyncStatusFn = DefaultGetSemiSyncStatus
+	mock.SetSemiSyncWaitPointFn = DefaultSetSemiSyncWaitPoint
 
 	// Users.
 	mock.CheckUserExistsFn = DefaultCheckUserExists
@@ -970,7 +1173,9 @@ func setupRPC(rpc *xrpc.Service, mysql *Mysql) {
 
 // NewMockGTIDX1 mock.
 // with GTID{Master_Log_File = "mysql-bin.000001", Read_Master_Log_Pos = 123,
-//           gtid.Executed_GTID_Set = "6127a668-gtid-x555-a28d-5254335479b2:1"}
+//
+//	gtid.Executed_GTID_Set = "6127a668-gtid-x555-a28d-5254335479b2:1"}
+//
 // all functions return is OK
 func NewMockGTIDX1() *MockGTID {
 	mock := defaultMockGTID()
@@ -981,7 +1186,7 @@ func NewMockGTIDX1() *MockGTID {
 
 // PingX1 mock.
 func PingX1(db *sql.DB) (*PingEntry, error) {
-	return &PingEntry{"mysql-bin.000001"}, nil
+	return &PingEntry{"mysql-bin.000001", "0"}, nil
 }
 
 // GetSlaveGTIDX1 mock.
@@ -1000,7 +1205,9 @@ func GetSlaveGTIDX1(db *sql.DB) (*model.GTID, error) {
 
 // NewMockGTIDX3 mock.
 // with GTID{Master_Log_File = "mysql-bin.000003", Read_Master_Log_Pos = 123 ,
-//           gtid.Executed_GTID_Set = "6127a668-gtid-x555-a28d-5254335479b2:1"}
+//
+//	gtid.Executed_GTID_Set = "6127a668-gtid-x555-a28d-5254335479b2:1"}
+//
 // all functions return is OK
 func NewMockGTIDX3() *MockGTID {
 	mock := defaultMockGTID()
@@ -1011,7 +1218,7 @@ func NewMockGTIDX3() *MockGTID {
 
 // PingX3 mock.
 func PingX3(db *sql.DB) (*PingEntry, error) {
-	return &PingEntry{"mysql-bin.000003"}, nil
+	return &PingEntry{"mysql-bin.000003", "0"}, nil
 }
 
 // GetSlaveGTIDX3 mock.
@@ -1030,7 +1237,9 @@ func GetSlaveGTIDX3(db *sql.DB) (*model.GTID, error) {
 
 // NewMockGTIDX5 mock.
 // with GTID{Master_Log_File = "mysql-bin.000005", Read_Master_Log_Pos = 123,
-//           gtid.Executed_GTID_Set = "6127a668-gtid-x555-a28d-5254335479b2:1"}
+//
+//	gtid.Executed_GTID_Set = "6127a668-gtid-x555-a28d-5254335479b2:1"}
+//
 // all functions return is OK
 func NewMockGTIDX5() *MockGTID {
 	mock := defaultMockGTID()
@@ -1041,7 +1250,7 @@ func NewMockGTIDX5() *MockGTID {
 
 // PingX5 mock.
 func PingX5(db *sql.DB) (*PingEntry, error) {
-	return &PingEntry{"mysql-bin.000005"}, nil
+	return &PingEntry{"mysql-bin.000005", "0"}, nil
 }
 
 // GetSlaveGTIDX5 mock.
@@ -1078,6 +1287,51 @@ func NewMockGTIDX5ChangeToMasterError() *MockGTID {
 	return mock
 }
 
+// NewMockGTIDMariaDB mock.
+// with the MariaDB GTID{Master_Log_File = "mysql-bin.000001", Read_Master_Log_Pos = 123, Executed_GTID_Set = "0-1-100,1-2-20"}
+// the server_id is 2 and the GetGTIDSubtract is done by the domains.
+func NewMockGTIDMariaDB() *MockGTID {
+	mock := defaultMockGTID()
+	mock.GetMasterGTIDFn = GetMasterGTIDMariaDB
+	mock.GetSlaveGTIDFn = GetSlaveGTIDMariaDB
+	mock.GetUUIDFn = GetUUIDMariaDB
+	mock.GetGTIDSubtractFn = GetGTIDSubtractMariaDB
+	return mock
+}
+
+// GetSlaveGTIDMariaDB mock.
+func GetSlaveGTIDMariaDB(db *sql.DB) (*model.GTID, error) {
+	gtid := &model.GTID{}
+	gtid.Master_Log_File = "mysql-bin.000001"
+	gtid.Read_Master_Log_Pos = 123
+	gtid.Slave_IO_Running = true
+	gtid.Slave_SQL_Running = true
+	gtid.Slave_IO_Running_Str = "Yes"
+	gtid.Slave_SQL_Running_Str = "Yes"
+	gtid.Executed_GTID_Set = "0-1-100,1-2-20"
+	gtid.Retrieved_GTID_Set = "0-1-100"
+	return gtid, nil
+}
+
+// GetMasterGTIDMariaDB mock.
+func GetMasterGTIDMariaDB(db *sql.DB) (*model.GTID, error) {
+	gtid := &model.GTID{}
+	gtid.Master_Log_File = "mysql-bin.000001"
+	gtid.Read_Master_Log_Pos = 123
+	gtid.Executed_GTID_Set = "0-1-100,1-2-20"
+	return gtid, nil
+}
+
+// GetUUIDMariaDB mock, the server_id.
+func GetUUIDMariaDB(db *sql.DB) (string, error) {
+	return "2", nil
+}
+
+// GetGTIDSubtractMariaDB mock.
+func GetGTIDSubtractMariaDB(db *sql.DB, subsetGTID string, setGTID string) (string, error) {
+	return MariaGTIDSubtract(subsetGTID, setGTID)
+}
+
 // MockMysql mock.
 func MockMysql(log *xlog.Log, port int, h MysqlHandler) (string, *Mysql, func()) {
 	id := fmt.Sprintf("127.0.0.1:%d", port)
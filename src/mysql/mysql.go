@@ -34,6 +34,7 @@ const (
 // PingEntry tuple.
 type PingEntry struct {
 	Relay_Master_Log_File string
+	Seconds_Behind_Master string
 }
 
 // Mysql tuple.
@@ -47,9 +48,12 @@ type Mysql struct {
 	dbmutex      sync.RWMutex
 	mysqlHandler MysqlHandler
 	pingEntry    PingEntry
+	probed       bool
 	pingTicker   *time.Ticker
 	stats        model.MysqlStats
 	downs        int
+	slaveHeld    bool   // the sql thread is held by the delayed catch-up
+	heldFile     string // the hold is persisted to, see LoadSlaveHeld
 }
 
 // NewMysql creates the new Mysql.
@@ -63,6 +67,7 @@ func NewMysql(conf *config.MysqlConfig, queryTimeout int, log *xlog.Log) *Mysql
 		pingTicker:   common.NormalTicker(conf.PingTimeout),
 	}
 	mysql.mysqlHandler.SetQueryTimeout(queryTimeout)
+	mysql.mysqlHandler.SetReplChannel(conf.ReplChannel)
 	return mysql
 }
 
@@ -91,7 +96,7 @@ func (m *Mysql) Ping() {
 		return
 	}
 
-	if pe, err = m.mysqlHandler.Ping(db); err != nil {
+	if pe, err = m.pingHandler(db); err != nil {
 		log.Error("mysql[%v].ping.error[%v].downs:%v,downslimits:%v", m.getConnStr(), err, m.downs, downsLimits)
 		if m.downs > downsLimits {
 			log.Error("mysql.dead.downs:%v,downslimits:%v", m.downs, downsLimits)
@@ -118,6 +123,22 @@ func (m *Mysql) Ping() {
 	m.pingEntry = *pe
 }
 
+// pingHandler probes the server version on the first ping after the mysql is up, then pings.
+// The version is probed again after the ping fails, the mysqld may be restarted with the new release.
+func (m *Mysql) pingHandler(db *sql.DB) (*PingEntry, error) {
+	if !m.probed {
+		if err := m.mysqlHandler.ProbeVersion(db); err != nil {
+			return nil, err
+		}
+		m.probed = true
+	}
+	pe, err := m.mysqlHandler.Ping(db)
+	if err != nil {
+		m.probed = false
+	}
+	return pe, err
+}
+
 // GetUUID used to get local uuid.
 func (m *Mysql) GetUUID() (string, error) {
 	var err error
@@ -171,6 +192,15 @@ func (m *Mysql) GetSlaveGTID() (*model.GTID, error) {
 	return gtid, nil
 }
 
+// GetSlaveChannels used to get the gtid of all the replication channels.
+func (m *Mysql) GetSlaveChannels() ([]model.GTID, error) {
+	db, err := m.getDB()
+	if err != nil {
+		return nil, err
+	}
+	return m.mysqlHandler.GetSlaveChannels(db)
+}
+
 // getDB get the database connection.
 func (m *Mysql) getDB() (*sql.DB, error) {
 	var err error
@@ -25,11 +25,35 @@ type Mysql56 struct {
 	MysqlBase
 }
 
+// SetReplChannel does nothing, there is only the default channel before 5.7.
+func (my *Mysql56) SetReplChannel(channel string) {
+}
+
 //SetSemiWaitSlaveCount used set rpl_semi_sync_master_wait_for_slave_count
 func (my *Mysql56) SetSemiWaitSlaveCount(db *sql.DB, count int) error {
 	return nil
 }
 
+// InstallSemiSyncPlugins used to install the missing semi-sync plugins, there is no super_read_only before 5.7.
+func (my *Mysql56) InstallSemiSyncPlugins(db *sql.DB) error {
+	cmds, loaded, err := my.semiSyncInstallCommands(db)
+	if err != nil {
+		return err
+	}
+	if len(cmds) > 0 {
+		if err := ExecuteSuperQueryListWithTimeout(db, my.queryTimeout, cmds); err != nil {
+			return err
+		}
+	}
+	my.setSemiSync(loaded)
+	return nil
+}
+
+// SetSemiSyncWaitPoint does nothing, the wait point is AFTER_COMMIT before 5.7.
+func (my *Mysql56) SetSemiSyncWaitPoint(db *sql.DB, point string) error {
+	return nil
+}
+
 // ChangeUserPasswd used to change the user password.
 func (my *Mysql56) ChangeUserPasswd(db *sql.DB, user string, host string, passwd string) error {
 	query := fmt.Sprintf("SET PASSWORD FOR `%s`@`%s` = PASSWORD('%s')", user, host, passwd)
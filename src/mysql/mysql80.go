@@ -8,11 +8,135 @@
 
 package mysql
 
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"model"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
 var (
 	_ MysqlHandler = &Mysql80{}
 )
 
+const (
+	// ER_RESTART_SERVER_FAILED: the clone is done, but the mysqld isn't managed by a supervisor to restart.
+	errRestartServerFailed = 3707
+)
+
+var (
+	// The first version which ships the clone plugin.
+	cloneMinVersion = []int{8, 0, 17}
+
+	// The first versions which require the replica/source syntax.
+	replicaMinVersion      = []int{8, 0, 22}
+	sourceMinVersion       = []int{8, 0, 23}
+	binlogStatusMinVersion = []int{8, 2, 0}
+
+	// The first version which ships the rpl_semi_sync_source/replica plugins.
+	semiSyncSourceMinVersion = []int{8, 0, 26}
+)
+
 // Mysql80 tuple.
 type Mysql80 struct {
 	MysqlBase
 }
+
+// CloneSupported returns true if the version ships the clone plugin, such as 8.0.17 or 8.0.22-13.
+func CloneSupported(version string) bool {
+	return versionAtLeast(version, cloneMinVersion)
+}
+
+// versionAtLeast returns true if the version such as 8.0.22-13 is not older than min.
+func versionAtLeast(version string, min []int) bool {
+	fields := strings.SplitN(version, "-", 2)
+	parts := strings.Split(fields[0], ".")
+	if len(parts) < len(min) {
+		return false
+	}
+	for i, m := range min {
+		v, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return false
+		}
+		if v != m {
+			return v > m
+		}
+	}
+	return true
+}
+
+// probeSyntax returns the replication statements which the version requires.
+func probeSyntax(version string) replSyntax {
+	return replSyntax{
+		replica:        versionAtLeast(version, replicaMinVersion),
+		source:         versionAtLeast(version, sourceMinVersion),
+		binlogStatus:   versionAtLeast(version, binlogStatusMinVersion),
+		semiSyncSource: versionAtLeast(version, semiSyncSourceMinVersion),
+	}
+}
+
+// ProbeVersion used to probe the server version, the replica/source syntax is used from 8.0.22 on,
+// the deprecated slave/master statements are removed in the later releases.
+func (my *Mysql80) ProbeVersion(db *sql.DB) error {
+	version, err := my.GetVersion(db)
+	if err != nil {
+		return err
+	}
+	my.setSyntax(probeSyntax(version))
+	return nil
+}
+
+// InstallClonePlugin used to install the clone plugin if it's not installed.
+func (my *Mysql80) InstallClonePlugin(db *sql.DB) error {
+	query := "SELECT PLUGIN_STATUS FROM INFORMATION_SCHEMA.PLUGINS WHERE PLUGIN_NAME = 'clone'"
+	rows, err := QueryWithTimeout(db, my.queryTimeout, query)
+	if err != nil {
+		return err
+	}
+	if len(rows) > 0 {
+		if status := rows[0]["PLUGIN_STATUS"]; status != "ACTIVE" {
+			return errors.Errorf("mysql.clone.plugin.status[%v].is.not.active", status)
+		}
+		return nil
+	}
+	return my.executeWithoutBinlog(db, []string{"INSTALL PLUGIN clone SONAME 'mysql_clone.so'"})
+}
+
+// GrantCloneDonorPrivileges used to grant the BACKUP_ADMIN to the user which the recipient connects with.
+func (my *Mysql80) GrantCloneDonorPrivileges(db *sql.DB, user string) error {
+	query := fmt.Sprintf("GRANT BACKUP_ADMIN ON *.* TO `%s`", user)
+	return my.executeWithoutBinlog(db, []string{query})
+}
+
+// CloneInstance used to replace the local data with the donor's by the clone plugin.
+// The mysqld restarts after the clone, if it's not managed by a supervisor it just shuts down,
+// both are the success.
+func (my *Mysql80) CloneInstance(db *sql.DB, donor *model.Repl) error {
+	query := fmt.Sprintf("SET GLOBAL clone_valid_donor_list = '%s:%d'", donor.Master_Host, donor.Master_Port)
+	if err := ExecuteWithTimeout(db, my.queryTimeout, query); err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf("CLONE INSTANCE FROM '%s'@'%s':%d IDENTIFIED BY '%s'", donor.Repl_User, donor.Master_Host, donor.Master_Port, donor.Repl_Password)
+	if err := Execute(db, query); err != nil {
+		switch cause := errors.Cause(err).(type) {
+		case *mysqldriver.MySQLError:
+			if cause.Number == errRestartServerFailed {
+				return nil
+			}
+		default:
+			if cause == mysqldriver.ErrInvalidConn || cause == driver.ErrBadConn {
+				return nil
+			}
+		}
+		return err
+	}
+	return nil
+}
@@ -12,10 +12,12 @@ import (
 	"testing"
 
 	"config"
+	"model"
 	"xbase/xlog"
 
-	_ "github.com/go-sql-driver/mysql"
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
 )
 
 func TestMysql80Handler(t *testing.T) {
@@ -29,3 +31,266 @@ func TestMysql80Handler(t *testing.T) {
 	got := mysql.mysqlHandler
 	assert.Equal(t, want, got)
 }
+
+func TestMysql80CloneSupported(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{"8.0.17", true},
+		{"8.0.22-13", true},
+		{"8.0.18-log", true},
+		{"8.1.0", true},
+		{"8.0.16", false},
+		{"5.7.30-log", false},
+		{"8.0", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, CloneSupported(test.version), test.version)
+	}
+}
+
+func TestMysql80ProbeVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	mysql80 := new(Mysql80)
+	mysql80.SetQueryTimeout(10000)
+
+	tests := []struct {
+		version string
+		want    replSyntax
+	}{
+		{"8.0.21", replSyntax{}},
+		{"8.0.22-13", replSyntax{replica: true}},
+		{"8.0.23", replSyntax{replica: true, source: true}},
+		{"8.0.26", replSyntax{replica: true, source: true, semiSyncSource: true}},
+		{"8.2.0", replSyntax{replica: true, source: true, binlogStatus: true, semiSyncSource: true}},
+		{"8.4.2", replSyntax{replica: true, source: true, binlogStatus: true, semiSyncSource: true}},
+	}
+	for _, test := range tests {
+		mock.ExpectQuery("SELECT @@GLOBAL.VERSION").WillReturnRows(sqlmock.NewRows([]string{"@@GLOBAL.VERSION"}).AddRow(test.version))
+		assert.Nil(t, mysql80.ProbeVersion(db))
+		assert.Equal(t, test.want, mysql80.syntax, test.version)
+	}
+}
+
+func TestMysql80ReplicaSyntax(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	mysql80 := new(Mysql80)
+	mysql80.SetQueryTimeout(10000)
+	mysql80.syntax = probeSyntax("8.0.23")
+
+	// the replica columns are mapped to the slave ones
+	{
+		columns := []string{"Source_Log_File",
+			"Read_Source_Log_Pos",
+			"Relay_Source_Log_File",
+			"Retrieved_Gtid_Set",
+			"Executed_Gtid_Set",
+			"Replica_IO_Running",
+			"Replica_SQL_Running",
+			"Seconds_Behind_Source",
+			"Last_Error",
+			"Replica_SQL_Running_State",
+			"Replicate_Do_DB",
+			"Replicate_Ignore_Server_Ids",
+		}
+		rows := sqlmock.NewRows(columns).AddRow("mysql-bin.000001",
+			"147",
+			"mysql-bin.000001",
+			"84030605-66aa-11e6-9465-52540e7fd51c:154-160",
+			"84030605-66aa-11e6-9465-52540e7fd51c:1-159",
+			"Yes",
+			"Yes",
+			"11",
+			"",
+			"Replica has read all relay log; waiting for more updates",
+			"db1",
+			"2,3",
+		)
+		mock.ExpectQuery("SHOW REPLICA STATUS").WillReturnRows(rows)
+		status, err := mysql80.showSlaveStatus(db)
+		assert.Nil(t, err)
+		// the columns without a slave twin are kept
+		assert.Equal(t, "db1", status[0]["Replicate_Do_DB"])
+		assert.Equal(t, "2,3", status[0]["Replicate_Ignore_Server_Ids"])
+		assert.Equal(t, "11", status[0]["Seconds_Behind_Master"])
+
+		mock.ExpectQuery("SHOW REPLICA STATUS").WillReturnRows(sqlmock.NewRows(columns).AddRow("mysql-bin.000001",
+			"147",
+			"mysql-bin.000001",
+			"84030605-66aa-11e6-9465-52540e7fd51c:154-160",
+			"84030605-66aa-11e6-9465-52540e7fd51c:1-159",
+			"Yes",
+			"Yes",
+			"11",
+			"",
+			"Replica has read all relay log; waiting for more updates",
+			"db1",
+			"2,3",
+		))
+		got, err := mysql80.GetSlaveGTID(db)
+		assert.Nil(t, err)
+		want := &model.GTID{Master_Log_File: "mysql-bin.000001",
+			Read_Master_Log_Pos:     147,
+			Retrieved_GTID_Set:      "84030605-66aa-11e6-9465-52540e7fd51c:154-160",
+			Executed_GTID_Set:       "84030605-66aa-11e6-9465-52540e7fd51c:1-159",
+			Slave_IO_Running:        true,
+			Slave_IO_Running_Str:    "Yes",
+			Slave_SQL_Running:       true,
+			Slave_SQL_Running_Str:   "Yes",
+			Seconds_Behind_Master:   "11",
+			Slave_SQL_Running_State: "Replica has read all relay log; waiting for more updates",
+		}
+		assert.Equal(t, want, got)
+	}
+
+	// the replica/source statements
+	{
+		mock.ExpectExec("STOP REPLICA").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("RESET MASTER").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("RESET REPLICA ALL").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("SET GLOBAL gtid_purged='84030605-66aa-11e6-9465-52540e7fd51c:1-159'").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("CHANGE REPLICATION SOURCE TO SOURCE_HOST = 'localhost', SOURCE_PORT = 123, SOURCE_USER = 'repl', SOURCE_PASSWORD = 'password', SOURCE_AUTO_POSITION = 1").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("START REPLICA").WillReturnResult(sqlmock.NewResult(1, 1))
+		master := model.Repl{Master_Host: "localhost",
+			Master_Port:      123,
+			Repl_User:        "repl",
+			Repl_Password:    "password",
+			Repl_GTID_Purged: "84030605-66aa-11e6-9465-52540e7fd51c:1-159"}
+		assert.Nil(t, mysql80.ChangeMasterTo(db, &master))
+
+		mock.ExpectExec("START REPLICA IO_THREAD").WillReturnResult(sqlmock.NewResult(1, 1))
+		assert.Nil(t, mysql80.StartSlaveIOThread(db))
+
+		mock.ExpectExec("STOP REPLICA").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("RESET REPLICA ALL").WillReturnResult(sqlmock.NewResult(1, 1))
+		assert.Nil(t, mysql80.ChangeToMaster(db))
+	}
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMysql80InstallClonePlugin(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	mysql80 := new(Mysql80)
+	mysql80.SetQueryTimeout(10000)
+	query := "SELECT PLUGIN_STATUS FROM INFORMATION_SCHEMA.PLUGINS WHERE PLUGIN_NAME = 'clone'"
+	columns := []string{"PLUGIN_STATUS"}
+
+	// installed
+	{
+		mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows(columns).AddRow("ACTIVE"))
+		err := mysql80.InstallClonePlugin(db)
+		assert.Nil(t, err)
+	}
+
+	// not installed on the super_read_only follower
+	{
+		mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectQuery("SELECT @@GLOBAL.SUPER_READ_ONLY").WillReturnRows(sqlmock.NewRows([]string{"@@GLOBAL.SUPER_READ_ONLY"}).AddRow("1"))
+		mock.ExpectExec("SET GLOBAL super_read_only = 0").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("SET sql_log_bin=0").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSTALL PLUGIN clone SONAME 'mysql_clone.so'").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("SET sql_log_bin=1").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("SET GLOBAL super_read_only = 1").WillReturnResult(sqlmock.NewResult(1, 1))
+		err := mysql80.InstallClonePlugin(db)
+		assert.Nil(t, err)
+	}
+
+	// not active
+	{
+		mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows(columns).AddRow("DISABLED"))
+		err := mysql80.InstallClonePlugin(db)
+		assert.Equal(t, "mysql.clone.plugin.status[DISABLED].is.not.active", err.Error())
+	}
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMysql80GrantCloneDonorPrivileges(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	mysql80 := new(Mysql80)
+	mysql80.SetQueryTimeout(10000)
+
+	// the leader is not super_read_only
+	mock.ExpectQuery("SELECT @@GLOBAL.SUPER_READ_ONLY").WillReturnRows(sqlmock.NewRows([]string{"@@GLOBAL.SUPER_READ_ONLY"}).AddRow("0"))
+	mock.ExpectExec("SET sql_log_bin=0").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("GRANT BACKUP_ADMIN ON *.* TO `repl`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("SET sql_log_bin=1").WillReturnResult(sqlmock.NewResult(1, 1))
+	err = mysql80.GrantCloneDonorPrivileges(db, "repl")
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMysql80CloneInstance(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	mysql80 := new(Mysql80)
+	mysql80.SetQueryTimeout(10000)
+	donor := &model.Repl{
+		Master_Host:   "192.168.0.2",
+		Master_Port:   3306,
+		Repl_User:     "repl",
+		Repl_Password: "replpwd",
+	}
+	list := "SET GLOBAL clone_valid_donor_list = '192.168.0.2:3306'"
+	clone := "CLONE INSTANCE FROM 'repl'@'192.168.0.2':3306 IDENTIFIED BY 'replpwd'"
+
+	// restarted, the connection is lost
+	{
+		mock.ExpectExec(list).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(clone).WillReturnError(mysqldriver.ErrInvalidConn)
+		err := mysql80.CloneInstance(db, donor)
+		assert.Nil(t, err)
+	}
+
+	// shut down without the supervisor
+	{
+		mock.ExpectExec(list).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(clone).WillReturnError(&mysqldriver.MySQLError{Number: 3707, Message: "Restart server failed (mysqld is not managed by supervisor process)."})
+		err := mysql80.CloneInstance(db, donor)
+		assert.Nil(t, err)
+	}
+
+	// failed
+	{
+		mock.ExpectExec(list).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(clone).WillReturnError(&mysqldriver.MySQLError{Number: 3862, Message: "Clone Donor Error: 1227 : Access denied."})
+		err := mysql80.CloneInstance(db, donor)
+		assert.Equal(t, "Error 3862: Clone Donor Error: 1227 : Access denied.", err.Error())
+	}
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMysql80SyntaxConcurrent(t *testing.T) {
+	// every Mysql has its own handler
+	assert.True(t, getHandler("mysql80") != getHandler("mysql80"))
+
+	// the ping probes the syntax while the others build the statements
+	mysql80 := getHandler("mysql80").(*Mysql80)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			mysql80.setSyntax(probeSyntax("8.0.23"))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		mysql80.replicaStatement("START SLAVE")
+	}
+	<-done
+	assert.Equal(t, "START REPLICA", mysql80.replicaStatement("START SLAVE"))
+}
@@ -0,0 +1,27 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018-2019 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysql
+
+var (
+	_ MysqlHandler = &Mysql84{}
+)
+
+// Mysql84 tuple, the slave/master statements and the semi-sync master/slave plugins are removed in 8.4,
+// so the replica/source syntax is used even before the version is probed.
+type Mysql84 struct {
+	Mysql80
+}
+
+// NewMysql84 creates the new Mysql84.
+func NewMysql84() *Mysql84 {
+	my := &Mysql84{}
+	my.syntax = replSyntax{replica: true, source: true, binlogStatus: true, semiSyncSource: true}
+	my.semiSync = semiSyncPlugins{source: true, replica: true}
+	return my
+}
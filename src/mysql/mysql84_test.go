@@ -0,0 +1,78 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysql
+
+import (
+	"testing"
+
+	"config"
+	"model"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestMysql84Handler(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := config.DefaultMysqlConfig()
+	conf.Version = "mysql84"
+
+	mysql := NewMysql(conf, 10000, log)
+	want := NewMysql84()
+	want.SetQueryTimeout(10000)
+	got := mysql.mysqlHandler
+	assert.Equal(t, want, got)
+
+	assert.True(t, IsMysql80("mysql84"))
+	assert.True(t, IsMysql80(" mysql80"))
+	assert.False(t, IsMysql80("mysql57"))
+}
+
+func TestMysql84Statements(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	mysql84 := NewMysql84()
+	mysql84.SetQueryTimeout(10000)
+
+	mock.ExpectQuery("SHOW BINARY LOG STATUS").WillReturnRows(sqlmock.NewRows([]string{"File", "Position", "Executed_Gtid_Set"}).
+		AddRow("mysql-bin.000002", "154", "84030605-66aa-11e6-9465-52540e7fd51c:1-159"))
+	got, err := mysql84.GetMasterGTID(db)
+	assert.Nil(t, err)
+	want := &model.GTID{Master_Log_File: "mysql-bin.000002",
+		Read_Master_Log_Pos:   154,
+		Executed_GTID_Set:     "84030605-66aa-11e6-9465-52540e7fd51c:1-159",
+		Seconds_Behind_Master: "0",
+		Slave_IO_Running:      true,
+		Slave_SQL_Running:     true,
+	}
+	assert.Equal(t, want, got)
+
+	mock.ExpectExec("RESET BINARY LOGS AND GTIDS").WillReturnResult(sqlmock.NewResult(1, 1))
+	assert.Nil(t, mysql84.ResetMaster(db))
+
+	mock.ExpectExec("STOP REPLICA").WillReturnResult(sqlmock.NewResult(1, 1))
+	assert.Nil(t, mysql84.StopSlave(db))
+
+	// the source syntax
+	assert.Equal(t, "CHANGE REPLICATION SOURCE TO\n  SOURCE_HOST = 'localhost',\n  SOURCE_PORT = 3306,\n  SOURCE_USER = 'repl',\n  SOURCE_PASSWORD = 'repl',\n  SOURCE_AUTO_POSITION = 1",
+		mysql84.changeMasterToCommands(&model.Repl{Master_Host: "localhost", Master_Port: 3306, Repl_User: "repl", Repl_Password: "repl"})[0])
+	assert.Equal(t, "CHANGE REPLICATION SOURCE TO\n  SOURCE_HOST = 'localhost',\n  SOURCE_PORT = 3306,\n  SOURCE_USER = 'repl',\n  SOURCE_PASSWORD = 'repl',\n  SOURCE_AUTO_POSITION = 1,\n  SOURCE_DELAY = 60",
+		mysql84.changeMasterToCommands(&model.Repl{Master_Host: "localhost", Master_Port: 3306, Repl_User: "repl", Repl_Password: "repl", Master_Delay: 60})[0])
+
+	// the delayed catch-up
+	mock.ExpectExec("STOP REPLICA SQL_THREAD").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("CHANGE REPLICATION SOURCE TO SOURCE_DELAY = 0").WillReturnResult(sqlmock.NewResult(1, 1))
+	assert.Nil(t, mysql84.SetMasterDelay(db, 0))
+	mock.ExpectExec("START REPLICA SQL_THREAD UNTIL SQL_BEFORE_GTIDS = '84030605-66aa-11e6-9465-52540e7fd51c:160'").WillReturnResult(sqlmock.NewResult(1, 1))
+	assert.Nil(t, mysql84.StartSlaveUntilGTID(db, "84030605-66aa-11e6-9465-52540e7fd51c:160"))
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -11,12 +11,19 @@ package mysql
 import (
 	"database/sql"
 	"model"
+	"strings"
 )
 
 // MysqlHandler interface.
 type MysqlHandler interface {
 	SetQueryTimeout(int)
 
+	// set the replication channel which xenon manages, empty is the default channel
+	SetReplChannel(string)
+
+	// probe the server version at connect time, the statements follow it
+	ProbeVersion(*sql.DB) error
+
 	// check health and return log_bin_basename
 	Ping(*sql.DB) (*PingEntry, error)
 
@@ -26,9 +33,12 @@ type MysqlHandler interface {
 	// get GTID from traversal binlog folder and find the newest one
 	GetMasterGTID(*sql.DB) (*model.GTID, error)
 
-	// get GTID from SHOW SLAVE STATUS
+	// get GTID of the xenon channel from SHOW SLAVE STATUS
 	GetSlaveGTID(*sql.DB) (*model.GTID, error)
 
+	// get GTID of all the channels from SHOW SLAVE STATUS
+	GetSlaveChannels(*sql.DB) ([]model.GTID, error)
+
 	// start slave io_thread
 	StartSlaveIOThread(*sql.DB) error
 
@@ -44,6 +54,12 @@ type MysqlHandler interface {
 	// use the provided master as the new master
 	ChangeMasterTo(*sql.DB, *model.Repl) error
 
+	// stop the sql thread and set the MASTER_DELAY
+	SetMasterDelay(*sql.DB, int) error
+
+	// start the sql thread until the transaction before the GTID
+	StartSlaveUntilGTID(*sql.DB, string) error
+
 	// change a slave to master
 	ChangeToMaster(*sql.DB) error
 
@@ -68,15 +84,51 @@ type MysqlHandler interface {
 	// purge binglog to
 	PurgeBinlogsTo(*sql.DB, string) error
 
+	// get the binlog files from SHOW BINARY LOGS, the last one is the active
+	GetBinaryLogs(*sql.DB) ([]string, error)
+
+	// get the log_bin_basename
+	GetBinlogBasename(*sql.DB) (string, error)
+
+	// get the Previous_gtids of the binlog, which are the gtid_purged after purging to it
+	GetBinlogPreviousGTIDs(*sql.DB, string) (string, error)
+
+	// get the server version
+	GetVersion(*sql.DB) (string, error)
+
+	// probe the capabilities the HA depends on
+	Preflight(*sql.DB) ([]model.MysqlPreflightCheck, error)
+
+	// install the clone plugin if it's not installed
+	InstallClonePlugin(*sql.DB) error
+
+	// grant the clone donor privileges to the user
+	GrantCloneDonorPrivileges(*sql.DB, string) error
+
+	// clone the data from the donor and restart
+	CloneInstance(*sql.DB, *model.Repl) error
+
 	// enable master semi sync: wait slave ack
 	EnableSemiSyncMaster(db *sql.DB) error
 
 	// disable master semi sync: don't wait slave ack
 	DisableSemiSyncMaster(db *sql.DB) error
 
+	// disable slave semi sync: the read replica never acks
+	DisableSemiSyncSlave(db *sql.DB) error
+
 	// set semi-sync master-timeout
 	SetSemiSyncMasterTimeout(db *sql.DB, timeout uint64) error
 
+	// install the semi-sync master and slave plugins if they are missing
+	InstallSemiSyncPlugins(db *sql.DB) error
+
+	// get the semi-sync status and the wait point
+	GetSemiSyncStatus(db *sql.DB) (*model.SemiSyncStatus, error)
+
+	// set semi-sync master-wait-point
+	SetSemiSyncWaitPoint(db *sql.DB, point string) error
+
 	//set rpl_semi_master_wait_for_slave_count
 	SetSemiWaitSlaveCount(db *sql.DB, count int) error
 
@@ -94,13 +146,41 @@ type MysqlHandler interface {
 }
 
 var (
-	handlers = make(map[string]MysqlHandler)
+	// handlers creates a handler for each Mysql, the handler keeps the probed state of its server
+	handlers = make(map[string]func() MysqlHandler)
 )
 
 func init() {
-	handlers["mysql56"] = new(Mysql56)
-	handlers["mysql57"] = new(Mysql57)
-	handlers["mysql80"] = new(Mysql80)
+	handlers["mysql56"] = func() MysqlHandler { return new(Mysql56) }
+	handlers["mysql57"] = func() MysqlHandler { return new(Mysql57) }
+	handlers["mysql80"] = func() MysqlHandler { return new(Mysql80) }
+	handlers["mysql84"] = func() MysqlHandler { return NewMysql84() }
+	handlers["mariadb10"] = func() MysqlHandler { return new(MariaDB10) }
+}
+
+// IsMysql80 returns true if the handler name is mysql80 or the later ones, they share the 8.0 behaviors,
+// such as the clone plugin and the binlog index.
+func IsMysql80(name string) bool {
+	switch strings.TrimSpace(name) {
+	case "mysql80", "mysql84":
+		return true
+	}
+	return false
+}
+
+// IsMariaDB returns true if the version is the MariaDB, the GTID is the domain-server-seq position.
+func IsMariaDB(name string) bool {
+	return strings.TrimSpace(name) == "mariadb10"
+}
+
+// HasSuperReadOnly returns true if the version has the super_read_only, which comes with 5.7,
+// the mysql56 and the MariaDB have the read_only only.
+func HasSuperReadOnly(name string) bool {
+	switch strings.TrimSpace(name) {
+	case "mysql56", "mariadb10":
+		return false
+	}
+	return true
 }
 
 func getHandler(name string) MysqlHandler {
@@ -108,5 +188,5 @@ func getHandler(name string) MysqlHandler {
 	if !ok {
 		return new(Mysql57)
 	}
-	return handler
+	return handler()
 }
@@ -10,7 +10,10 @@ package mysql
 
 import (
 	"config"
+	"database/sql"
+	"fmt"
 	"model"
+	"sync/atomic"
 	"testing"
 	"time"
 	"xbase/common"
@@ -62,6 +65,39 @@ func TestCreateReplUser(t *testing.T) {
 	mysql.PingStop()
 }
 
+func TestMysqlProbeVersion(t *testing.T) {
+	// log
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+
+	// the version is probed once when the mysql is up
+	{
+		var probes int32
+		h := NewMockGTIDA()
+		h.ProbeVersionFn = func(db *sql.DB) error {
+			atomic.AddInt32(&probes, 1)
+			return nil
+		}
+		_, mysql, cleanup := MockMysql(log, port, h)
+		time.Sleep(time.Duration(config.DefaultMysqlConfig().PingTimeout*3) * time.Millisecond)
+		cleanup()
+		assert.Equal(t, model.MysqlAlive, mysql.GetState())
+		assert.Equal(t, int32(1), atomic.LoadInt32(&probes))
+	}
+
+	// the probe error is a ping error
+	{
+		h := NewMockGTIDA()
+		h.ProbeVersionFn = func(db *sql.DB) error {
+			return fmt.Errorf("mock.probe.version.error")
+		}
+		_, mysql, cleanup := MockMysql(log, port+1, h)
+		time.Sleep(time.Duration(config.DefaultMysqlConfig().PingTimeout*2) * time.Millisecond)
+		cleanup()
+		assert.Equal(t, model.MysqlDead, mysql.GetState())
+	}
+}
+
 /*
 // TEST EFFECTS:
 // test GTIDGreaterThan function
@@ -14,6 +14,7 @@ import (
 	"model"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
 )
@@ -53,25 +54,190 @@ const (
 type MysqlBase struct {
 	MysqlHandler
 	queryTimeout int
+
+	// mutex guards the replChannel, syntax and semiSync, they are changed by the ping and read by all the others
+	mutex sync.RWMutex
+
+	// the replication channel which xenon manages, the others are left alone
+	replChannel string
+
+	// the statements which the server version requires, probed at connect time
+	syntax replSyntax
+
+	// the semi-sync plugins which are loaded, see InstallSemiSyncPlugins
+	semiSync semiSyncPlugins
+}
+
+// replSyntax tuple, the zero value is the slave/master syntax of 5.6 and 5.7.
+type replSyntax struct {
+	// SHOW REPLICA STATUS, START/STOP/RESET REPLICA, since 8.0.22
+	replica bool
+
+	// CHANGE REPLICATION SOURCE TO, since 8.0.23
+	source bool
+
+	// SHOW BINARY LOG STATUS, RESET BINARY LOGS AND GTIDS, since 8.2.0
+	binlogStatus bool
+
+	// the rpl_semi_sync_source/replica plugins are installed if missing, since 8.0.26
+	semiSyncSource bool
 }
 
+var (
+	// the status columns renamed by the replica syntax are mapped back to the slave names,
+	// the others(such as Replicate_Do_DB) are kept as they are
+	replicaColumns = map[string]string{
+		"Replica_IO_State":              "Slave_IO_State",
+		"Source_Host":                   "Master_Host",
+		"Source_User":                   "Master_User",
+		"Source_Port":                   "Master_Port",
+		"Source_Log_File":               "Master_Log_File",
+		"Read_Source_Log_Pos":           "Read_Master_Log_Pos",
+		"Relay_Source_Log_File":         "Relay_Master_Log_File",
+		"Replica_IO_Running":            "Slave_IO_Running",
+		"Replica_SQL_Running":           "Slave_SQL_Running",
+		"Exec_Source_Log_Pos":           "Exec_Master_Log_Pos",
+		"Source_SSL_Allowed":            "Master_SSL_Allowed",
+		"Source_SSL_CA_File":            "Master_SSL_CA_File",
+		"Source_SSL_CA_Path":            "Master_SSL_CA_Path",
+		"Source_SSL_Cert":               "Master_SSL_Cert",
+		"Source_SSL_Cipher":             "Master_SSL_Cipher",
+		"Source_SSL_Key":                "Master_SSL_Key",
+		"Seconds_Behind_Source":         "Seconds_Behind_Master",
+		"Source_SSL_Verify_Server_Cert": "Master_SSL_Verify_Server_Cert",
+		"Source_Server_Id":              "Master_Server_Id",
+		"Source_UUID":                   "Master_UUID",
+		"Source_Info_File":              "Master_Info_File",
+		"Replica_SQL_Running_State":     "Slave_SQL_Running_State",
+		"Source_Retry_Count":            "Master_Retry_Count",
+		"Source_Bind":                   "Master_Bind",
+		"Source_SSL_Crl":                "Master_SSL_Crl",
+		"Source_SSL_Crlpath":            "Master_SSL_Crlpath",
+		"Source_TLS_Version":            "Master_TLS_Version",
+		"Source_public_key_path":        "Master_public_key_path",
+		"Get_Source_public_key":         "Get_master_public_key",
+	}
+)
+
 // SetQueryTimeout used to set parameter queryTimeout
 func (my *MysqlBase) SetQueryTimeout(timeout int) {
 	my.queryTimeout = timeout
 }
 
+// SetReplChannel used to set the replication channel which xenon manages.
+func (my *MysqlBase) SetReplChannel(channel string) {
+	my.mutex.Lock()
+	defer my.mutex.Unlock()
+	my.replChannel = channel
+}
+
+// getReplChannel returns the replication channel which xenon manages.
+func (my *MysqlBase) getReplChannel() string {
+	my.mutex.RLock()
+	defer my.mutex.RUnlock()
+	return my.replChannel
+}
+
+// getSyntax returns the statements syntax probed by ProbeVersion.
+func (my *MysqlBase) getSyntax() replSyntax {
+	my.mutex.RLock()
+	defer my.mutex.RUnlock()
+	return my.syntax
+}
+
+// setSyntax used to set the statements syntax, the ping goroutine probes it while the others build the statements.
+func (my *MysqlBase) setSyntax(syntax replSyntax) {
+	my.mutex.Lock()
+	defer my.mutex.Unlock()
+	my.syntax = syntax
+}
+
+// getSemiSync returns the semi-sync plugins which are loaded.
+func (my *MysqlBase) getSemiSync() semiSyncPlugins {
+	my.mutex.RLock()
+	defer my.mutex.RUnlock()
+	return my.semiSync
+}
+
+// setSemiSync used to set the semi-sync plugins which are loaded.
+func (my *MysqlBase) setSemiSync(plugins semiSyncPlugins) {
+	my.mutex.Lock()
+	defer my.mutex.Unlock()
+	my.semiSync = plugins
+}
+
+// ProbeVersion does nothing, 5.6 and 5.7 only have the slave/master syntax.
+func (my *MysqlBase) ProbeVersion(db *sql.DB) error {
+	return nil
+}
+
+// replicaStatement returns the slave statement in the syntax which the server requires,
+// such as START SLAVE to START REPLICA.
+func (my *MysqlBase) replicaStatement(stmt string) string {
+	if my.getSyntax().replica {
+		stmt = strings.Replace(stmt, "SLAVE", "REPLICA", 1)
+	}
+	return stmt
+}
+
+// resetMasterStatement returns the RESET MASTER in the syntax which the server requires.
+func (my *MysqlBase) resetMasterStatement() string {
+	if my.getSyntax().binlogStatus {
+		return "RESET BINARY LOGS AND GTIDS"
+	}
+	return "RESET MASTER"
+}
+
+// showSlaveStatus returns the rows of SHOW SLAVE STATUS, the columns of the replica syntax are renamed to the slave ones.
+func (my *MysqlBase) showSlaveStatus(db *sql.DB) ([]map[string]string, error) {
+	rows, err := QueryWithTimeout(db, my.queryTimeout, my.replicaStatement("SHOW SLAVE STATUS"))
+	if err != nil || !my.getSyntax().replica {
+		return rows, err
+	}
+	for i, row := range rows {
+		renamed := make(map[string]string, len(row))
+		for k, v := range row {
+			if name, ok := replicaColumns[k]; ok {
+				k = name
+			}
+			renamed[k] = v
+		}
+		rows[i] = renamed
+	}
+	return rows, nil
+}
+
+// forChannel returns the FOR CHANNEL clause, empty for the default channel.
+func (my *MysqlBase) forChannel() string {
+	if my.getReplChannel() == "" {
+		return ""
+	}
+	return fmt.Sprintf(" FOR CHANNEL '%s'", my.getReplChannel())
+}
+
+// channelRow returns the row of the xenon channel from SHOW SLAVE STATUS, nil if not found.
+// Before 5.7 there is no Channel_Name and the only row is the default channel.
+func (my *MysqlBase) channelRow(rows []map[string]string) map[string]string {
+	for _, row := range rows {
+		if row["Channel_Name"] == my.getReplChannel() {
+			return row
+		}
+	}
+	return nil
+}
+
 // Ping has 2 affects:
 // one for heath check
-// other for get master_binglog the slave is syncing
+// other for get master_binglog the slave is syncing and the Seconds_Behind_Master
 func (my *MysqlBase) Ping(db *sql.DB) (*PingEntry, error) {
 	pe := &PingEntry{}
-	query := "SHOW SLAVE STATUS"
-	rows, err := QueryWithTimeout(db, my.queryTimeout, query)
+	rows, err := my.showSlaveStatus(db)
 	if err != nil {
 		return nil, err
 	}
-	if len(rows) > 0 {
-		pe.Relay_Master_Log_File = rows[0]["Relay_Master_Log_File"]
+	if row := my.channelRow(rows); row != nil {
+		pe.Relay_Master_Log_File = row["Relay_Master_Log_File"]
+		pe.Seconds_Behind_Master = row["Seconds_Behind_Master"]
 	}
 	return pe, nil
 }
@@ -91,40 +257,58 @@ func (my *MysqlBase) SetReadOnly(db *sql.DB, readonly bool) error {
 	return ExecuteSuperQueryListWithTimeout(db, my.queryTimeout, cmds)
 }
 
-// GetSlaveGTID gets the gtid from the default channel.
-// Here, We just show the default slave channel.
+// GetSlaveGTID gets the gtid from the xenon channel, the other channels are ignored.
 func (my *MysqlBase) GetSlaveGTID(db *sql.DB) (*model.GTID, error) {
 	gtid := &model.GTID{}
 
-	query := "SHOW SLAVE STATUS"
-	rows, err := QueryWithTimeout(db, my.queryTimeout, query)
+	rows, err := my.showSlaveStatus(db)
 	if err != nil {
 		return gtid, err
 	}
-	if len(rows) > 0 {
-		row := rows[0]
-		gtid.Master_Log_File = row["Master_Log_File"]
-		gtid.Read_Master_Log_Pos, _ = strconv.ParseUint(row["Read_Master_Log_Pos"], 10, 64)
-		gtid.Retrieved_GTID_Set = row["Retrieved_Gtid_Set"]
-		gtid.Executed_GTID_Set = row["Executed_Gtid_Set"]
-		gtid.Slave_IO_Running = (row["Slave_IO_Running"] == "Yes")
-		gtid.Slave_IO_Running_Str = row["Slave_IO_Running"]
-		gtid.Slave_SQL_Running = (row["Slave_SQL_Running"] == "Yes")
-		gtid.Slave_SQL_Running_Str = row["Slave_SQL_Running"]
-		gtid.Seconds_Behind_Master = row["Seconds_Behind_Master"]
-		gtid.Last_Error = row["Last_Error"]
-		gtid.Last_IO_Error = row["Last_IO_Error"]
-		gtid.Last_SQL_Error = row["Last_SQL_Error"]
-		gtid.Slave_SQL_Running_State = row["Slave_SQL_Running_State"]
+	if row := my.channelRow(rows); row != nil {
+		slaveGTIDFromRow(gtid, row)
 	}
 	return gtid, nil
 }
 
+// GetSlaveChannels gets the gtid of all the channels, including the ones xenon doesn't manage.
+func (my *MysqlBase) GetSlaveChannels(db *sql.DB) ([]model.GTID, error) {
+	rows, err := my.showSlaveStatus(db)
+	if err != nil {
+		return nil, err
+	}
+	channels := make([]model.GTID, len(rows))
+	for i, row := range rows {
+		slaveGTIDFromRow(&channels[i], row)
+	}
+	return channels, nil
+}
+
+func slaveGTIDFromRow(gtid *model.GTID, row map[string]string) {
+	gtid.Channel_Name = row["Channel_Name"]
+	gtid.Master_Log_File = row["Master_Log_File"]
+	gtid.Read_Master_Log_Pos, _ = strconv.ParseUint(row["Read_Master_Log_Pos"], 10, 64)
+	gtid.Retrieved_GTID_Set = row["Retrieved_Gtid_Set"]
+	gtid.Executed_GTID_Set = row["Executed_Gtid_Set"]
+	gtid.Slave_IO_Running = (row["Slave_IO_Running"] == "Yes")
+	gtid.Slave_IO_Running_Str = row["Slave_IO_Running"]
+	gtid.Slave_SQL_Running = (row["Slave_SQL_Running"] == "Yes")
+	gtid.Slave_SQL_Running_Str = row["Slave_SQL_Running"]
+	gtid.Seconds_Behind_Master = row["Seconds_Behind_Master"]
+	gtid.Last_Error = row["Last_Error"]
+	gtid.Last_IO_Error = row["Last_IO_Error"]
+	gtid.Last_SQL_Error = row["Last_SQL_Error"]
+	gtid.Slave_SQL_Running_State = row["Slave_SQL_Running_State"]
+}
+
 // GetMasterGTID used to get binlog info from master.
 func (my *MysqlBase) GetMasterGTID(db *sql.DB) (*model.GTID, error) {
 	gtid := &model.GTID{}
 
 	query := "SHOW MASTER STATUS"
+	if my.getSyntax().binlogStatus {
+		query = "SHOW BINARY LOG STATUS"
+	}
 	rows, err := QueryWithTimeout(db, my.queryTimeout, query)
 	if err != nil {
 		return nil, err
@@ -159,25 +343,25 @@ func (my *MysqlBase) GetUUID(db *sql.DB) (string, error) {
 
 // StartSlaveIOThread used to start the io thread.
 func (my *MysqlBase) StartSlaveIOThread(db *sql.DB) error {
-	cmd := "START SLAVE IO_THREAD"
+	cmd := my.replicaStatement("START SLAVE IO_THREAD") + my.forChannel()
 	return ExecuteWithTimeout(db, my.queryTimeout, cmd)
 }
 
 // StopSlaveIOThread used to stop the op thread.
 func (my *MysqlBase) StopSlaveIOThread(db *sql.DB) error {
-	cmd := "STOP SLAVE IO_THREAD"
+	cmd := my.replicaStatement("STOP SLAVE IO_THREAD") + my.forChannel()
 	return ExecuteWithTimeout(db, my.queryTimeout, cmd)
 }
 
 // StartSlave used to start slave.
 func (my *MysqlBase) StartSlave(db *sql.DB) error {
-	cmd := "START SLAVE"
+	cmd := my.replicaStatement("START SLAVE") + my.forChannel()
 	return ExecuteWithTimeout(db, my.queryTimeout, cmd)
 }
 
 // StopSlave used to stop the slave.
 func (my *MysqlBase) StopSlave(db *sql.DB) error {
-	cmd := "STOP SLAVE"
+	cmd := my.replicaStatement("STOP SLAVE") + my.forChannel()
 	return ExecuteWithTimeout(db, my.queryTimeout, cmd)
 }
 
@@ -189,36 +373,89 @@ func (my *MysqlBase) changeMasterToCommands(master *model.Repl) []string {
 	args = append(args, fmt.Sprintf("MASTER_USER = '%s'", master.Repl_User))
 	args = append(args, fmt.Sprintf("MASTER_PASSWORD = '%s'", master.Repl_Password))
 	args = append(args, "MASTER_AUTO_POSITION = 1")
-	changeMasterTo := "CHANGE MASTER TO\n  " + strings.Join(args, ",\n  ")
+	if master.Master_Delay > 0 {
+		args = append(args, fmt.Sprintf("MASTER_DELAY = %d", master.Master_Delay))
+	}
+	changeMasterTo := "CHANGE MASTER TO\n  " + strings.Join(args, ",\n  ") + my.forChannel()
+	if my.getSyntax().source {
+		changeMasterTo = strings.Replace(changeMasterTo, "CHANGE MASTER TO", "CHANGE REPLICATION SOURCE TO", 1)
+		changeMasterTo = strings.Replace(changeMasterTo, "  MASTER_", "  SOURCE_", -1)
+	}
 	return []string{changeMasterTo}
 }
 
-// ChangeMasterTo stop for all channels and reset all replication filter to null.
+// SetMasterDelay stops the sql thread and sets the MASTER_DELAY, the io thread keeps running.
+func (my *MysqlBase) SetMasterDelay(db *sql.DB, delay int) error {
+	changeMasterTo := fmt.Sprintf("CHANGE MASTER TO MASTER_DELAY = %d", delay) + my.forChannel()
+	if my.getSyntax().source {
+		changeMasterTo = fmt.Sprintf("CHANGE REPLICATION SOURCE TO SOURCE_DELAY = %d", delay) + my.forChannel()
+	}
+	cmds := []string{my.replicaStatement("STOP SLAVE SQL_THREAD") + my.forChannel(), changeMasterTo}
+	return ExecuteSuperQueryListWithTimeout(db, my.queryTimeout, cmds)
+}
+
+// StartSlaveUntilGTID starts the sql thread until the transaction before the gtid, the transaction is not applied.
+func (my *MysqlBase) StartSlaveUntilGTID(db *sql.DB, gtid string) error {
+	cmd := my.replicaStatement("START SLAVE SQL_THREAD") + fmt.Sprintf(" UNTIL SQL_BEFORE_GTIDS = '%s'", gtid) + my.forChannel()
+	return ExecuteWithTimeout(db, my.queryTimeout, cmd)
+}
+
+// legacyChannelCommands returns the commands to drop the default channel if xenon set it up
+// before the named channel was configured, the default channel of the other sources is kept.
+func (my *MysqlBase) legacyChannelCommands(db *sql.DB, master *model.Repl) ([]string, error) {
+	if my.getReplChannel() == "" {
+		return nil, nil
+	}
+	rows, err := my.showSlaveStatus(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if name, ok := row["Channel_Name"]; ok && name == "" && row["Master_User"] == master.Repl_User {
+			return []string{my.replicaStatement("STOP SLAVE") + " FOR CHANNEL ''", my.replicaStatement("RESET SLAVE ALL") + " FOR CHANNEL ''"}, nil
+		}
+	}
+	return nil, nil
+}
+
+// ChangeMasterTo stop the xenon channel and reset all replication filter to null,
+// the other channels are left alone.
 // In Xenon, we never set replication filter.
 func (my *MysqlBase) ChangeMasterTo(db *sql.DB, master *model.Repl) error {
+	legacy, err := my.legacyChannelCommands(db, master)
+	if err != nil {
+		return err
+	}
 	cmds := []string{}
-	cmds = append(cmds, "STOP SLAVE")
+	cmds = append(cmds, legacy...)
+	cmds = append(cmds, my.replicaStatement("STOP SLAVE")+my.forChannel())
 	if master.Repl_GTID_Purged != "" {
-		cmds = append(cmds, "RESET MASTER")
-		cmds = append(cmds, "RESET SLAVE ALL")
+		cmds = append(cmds, my.resetMasterStatement())
+		cmds = append(cmds, my.replicaStatement("RESET SLAVE ALL")+my.forChannel())
 		cmds = append(cmds, fmt.Sprintf("SET GLOBAL gtid_purged='%s'", master.Repl_GTID_Purged))
 	}
 	cmds = append(cmds, my.changeMasterToCommands(master)...)
-	cmds = append(cmds, "START SLAVE")
+	cmds = append(cmds, my.replicaStatement("START SLAVE")+my.forChannel())
 	return ExecuteSuperQueryListWithTimeout(db, my.queryTimeout, cmds)
 }
 
 // ChangeToMaster changes a slave to be master.
 func (my *MysqlBase) ChangeToMaster(db *sql.DB) error {
-	cmds := []string{"STOP SLAVE",
-		"RESET SLAVE ALL"} //"ALL" makes it forget the master host:port
+	cmds := []string{my.replicaStatement("STOP SLAVE") + my.forChannel(),
+		my.replicaStatement("RESET SLAVE ALL") + my.forChannel()} //"ALL" makes it forget the master host:port
 	return ExecuteSuperQueryListWithTimeout(db, my.queryTimeout, cmds)
 }
 
 // WaitUntilAfterGTID used to do 'SELECT WAIT_UNTIL_SQL_THREAD_AFTER_GTIDS' command.
+// With the named channel, the other channels may have no sql thread running,
+// so WAIT_FOR_EXECUTED_GTID_SET is used which doesn't depend on any channel,
+// it's also used with the replica syntax since the former is removed in 8.3.
 // https://dev.mysql.com/doc/refman/5.7/en/gtid-functions.html
 func (my *MysqlBase) WaitUntilAfterGTID(db *sql.DB, targetGTID string) error {
 	query := fmt.Sprintf("SELECT WAIT_UNTIL_SQL_THREAD_AFTER_GTIDS('%s')", targetGTID)
+	if my.getReplChannel() != "" || my.getSyntax().replica {
+		query = fmt.Sprintf("SELECT WAIT_FOR_EXECUTED_GTID_SET('%s')", targetGTID)
+	}
 	return Execute(db, query)
 }
 
@@ -249,14 +486,14 @@ func (my *MysqlBase) SetGlobalSysVar(db *sql.DB, varsql string) error {
 
 // ResetMaster used to reset master.
 func (my *MysqlBase) ResetMaster(db *sql.DB) error {
-	cmds := "RESET MASTER"
+	cmds := my.resetMasterStatement()
 	return ExecuteWithTimeout(db, my.queryTimeout, cmds)
 }
 
 // ResetSlaveAll used to reset slave.
 func (my *MysqlBase) ResetSlaveAll(db *sql.DB) error {
-	cmds := []string{"STOP SLAVE",
-		"RESET SLAVE ALL"} //"ALL" makes it forget the master host:port
+	cmds := []string{my.replicaStatement("STOP SLAVE") + my.forChannel(),
+		my.replicaStatement("RESET SLAVE ALL") + my.forChannel()} //"ALL" makes it forget the master host:port
 	return ExecuteSuperQueryListWithTimeout(db, my.queryTimeout, cmds)
 }
 
@@ -266,27 +503,129 @@ func (my *MysqlBase) PurgeBinlogsTo(db *sql.DB, binlog string) error {
 	return ExecuteWithTimeout(db, my.queryTimeout, cmds)
 }
 
+// GetBinaryLogs used to get the binlog files, the last one is the active.
+func (my *MysqlBase) GetBinaryLogs(db *sql.DB) ([]string, error) {
+	var binlogs []string
+	query := "SHOW BINARY LOGS"
+	rows, err := QueryWithTimeout(db, my.queryTimeout, query)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		binlogs = append(binlogs, row["Log_name"])
+	}
+	return binlogs, nil
+}
+
+// binlogEventInfo returns the Info of the first event of the type in the binlog header, empty if it's not found.
+func (my *MysqlBase) binlogEventInfo(db *sql.DB, binlog string, eventType string) (string, error) {
+	query := fmt.Sprintf("SHOW BINLOG EVENTS IN '%s' LIMIT 3", binlog)
+	rows, err := QueryWithTimeout(db, my.queryTimeout, query)
+	if err != nil {
+		return "", err
+	}
+	for _, row := range rows {
+		if row["Event_type"] == eventType {
+			return strings.Replace(row["Info"], "\n", "", -1), nil
+		}
+	}
+	return "", nil
+}
+
+// GetBinlogPreviousGTIDs used to get the Previous_gtids of the binlog.
+func (my *MysqlBase) GetBinlogPreviousGTIDs(db *sql.DB, binlog string) (string, error) {
+	return my.binlogEventInfo(db, binlog, "Previous_gtids")
+}
+
+// GetBinlogBasename used to get the log_bin_basename.
+func (my *MysqlBase) GetBinlogBasename(db *sql.DB) (string, error) {
+	basename := ""
+	query := "SELECT @@GLOBAL.LOG_BIN_BASENAME"
+	rows, err := QueryWithTimeout(db, my.queryTimeout, query)
+	if err != nil {
+		return basename, err
+	}
+	if len(rows) > 0 {
+		basename = rows[0]["@@GLOBAL.LOG_BIN_BASENAME"]
+	}
+	return basename, nil
+}
+
+// GetVersion used to get the server version.
+func (my *MysqlBase) GetVersion(db *sql.DB) (string, error) {
+	version := ""
+	query := "SELECT @@GLOBAL.VERSION"
+	rows, err := QueryWithTimeout(db, my.queryTimeout, query)
+	if err != nil {
+		return version, err
+	}
+	if len(rows) > 0 {
+		version = rows[0]["@@GLOBAL.VERSION"]
+	}
+	return version, nil
+}
+
+// InstallClonePlugin is only supported by mysql80.
+func (my *MysqlBase) InstallClonePlugin(db *sql.DB) error {
+	return errors.New("mysql.clone.plugin.requires.mysql80")
+}
+
+// GrantCloneDonorPrivileges is only supported by mysql80.
+func (my *MysqlBase) GrantCloneDonorPrivileges(db *sql.DB, user string) error {
+	return errors.New("mysql.clone.plugin.requires.mysql80")
+}
+
+// CloneInstance is only supported by mysql80.
+func (my *MysqlBase) CloneInstance(db *sql.DB, donor *model.Repl) error {
+	return errors.New("mysql.clone.plugin.requires.mysql80")
+}
+
+// executeWithoutBinlog runs the queries without binlog,
+// the super_read_only is lifted during the run since the followers are prepared too, such as the clone donor.
+func (my *MysqlBase) executeWithoutBinlog(db *sql.DB, queryList []string) error {
+	rows, err := QueryWithTimeout(db, my.queryTimeout, "SELECT @@GLOBAL.SUPER_READ_ONLY")
+	if err != nil {
+		return err
+	}
+	if len(rows) > 0 && rows[0]["@@GLOBAL.SUPER_READ_ONLY"] == "1" {
+		if err := ExecuteWithTimeout(db, my.queryTimeout, "SET GLOBAL super_read_only = 0"); err != nil {
+			return err
+		}
+		defer ExecuteWithTimeout(db, my.queryTimeout, "SET GLOBAL super_read_only = 1")
+	}
+
+	queryList = append([]string{"SET sql_log_bin=0"}, queryList...)
+	queryList = append(queryList, "SET sql_log_bin=1")
+	return ExecuteSuperQueryListWithTimeout(db, my.queryTimeout, queryList)
+}
+
 // EnableSemiSyncMaster used to enable the semi-sync on master.
 func (my *MysqlBase) EnableSemiSyncMaster(db *sql.DB) error {
-	cmds := "SET GLOBAL rpl_semi_sync_master_enabled=ON"
+	cmds := fmt.Sprintf("SET GLOBAL %s=ON", my.semiSyncVar("rpl_semi_sync_master_enabled"))
 	return ExecuteWithTimeout(db, my.queryTimeout, cmds)
 }
 
 //SetSemiWaitSlaveCount used set rpl_semi_sync_master_wait_for_slave_count
 func (my *MysqlBase) SetSemiWaitSlaveCount(db *sql.DB, count int) error {
-	cmds := fmt.Sprintf("SET GLOBAL rpl_semi_sync_master_wait_for_slave_count = %d", count)
+	cmds := fmt.Sprintf("SET GLOBAL %s = %d", my.semiSyncVar("rpl_semi_sync_master_wait_for_slave_count"), count)
 	return ExecuteWithTimeout(db, my.queryTimeout, cmds)
 }
 
 // DisableSemiSyncMaster used to disable the semi-sync from master.
 func (my *MysqlBase) DisableSemiSyncMaster(db *sql.DB) error {
-	cmds := "SET GLOBAL rpl_semi_sync_master_enabled=OFF"
+	cmds := fmt.Sprintf("SET GLOBAL %s=OFF", my.semiSyncVar("rpl_semi_sync_master_enabled"))
+	return ExecuteWithTimeout(db, my.queryTimeout, cmds)
+}
+
+// DisableSemiSyncSlave used to disable the semi-sync on slave, it works after the IO thread restarts.
+func (my *MysqlBase) DisableSemiSyncSlave(db *sql.DB) error {
+	cmds := fmt.Sprintf("SET GLOBAL %s=OFF", my.semiSyncVar("rpl_semi_sync_slave_enabled"))
 	return ExecuteWithTimeout(db, my.queryTimeout, cmds)
 }
 
 // SetSemiSyncMasterTimeout used to set semi-sync master timeout
 func (my *MysqlBase) SetSemiSyncMasterTimeout(db *sql.DB, timeout uint64) error {
-	cmds := fmt.Sprintf("SET GLOBAL rpl_semi_sync_master_timeout=%d", timeout)
+	cmds := fmt.Sprintf("SET GLOBAL %s=%d", my.semiSyncVar("rpl_semi_sync_master_timeout"), timeout)
 	return ExecuteWithTimeout(db, my.queryTimeout, cmds)
 }
 
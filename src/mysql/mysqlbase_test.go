@@ -33,10 +33,12 @@ func TestMysqlBasePing(t *testing.T) {
 	columns := []string{"Master_Log_File",
 		"Read_Master_Log_Pos",
 		"Relay_Master_Log_File",
+		"Seconds_Behind_Master",
 	}
 	mockRows := sqlmock.NewRows(columns).AddRow("mysql-bin.000001",
 		"147",
 		"mysql-bin.000001",
+		"3",
 	)
 
 	mock.ExpectQuery(query).WillReturnRows(mockRows)
@@ -46,6 +48,7 @@ func TestMysqlBasePing(t *testing.T) {
 	want := "mysql-bin.000001"
 	got := pe.Relay_Master_Log_File
 	assert.Equal(t, want, got)
+	assert.Equal(t, "3", pe.Seconds_Behind_Master)
 }
 
 func TestMysqlBaseGetSlaveGTIDGotZeroRow(t *testing.T) {
@@ -201,6 +204,19 @@ func TestMysqlBaseChangeMasterToCommand(t *testing.T) {
 
 	got := mysqlbase.changeMasterToCommands(&master)
 	assert.Equal(t, want, got)
+
+	// the read replica with delay
+	want = []string{
+		`CHANGE MASTER TO
+  MASTER_HOST = 'localhost',
+  MASTER_PORT = 123,
+  MASTER_USER = 'username',
+  MASTER_PASSWORD = 'password',
+  MASTER_AUTO_POSITION = 1,
+  MASTER_DELAY = 3600`}
+	master.Master_Delay = 3600
+	got = mysqlbase.changeMasterToCommands(&master)
+	assert.Equal(t, want, got)
 }
 
 func TestMysqlBaseChangeMasterTo(t *testing.T) {
@@ -226,6 +242,109 @@ func TestMysqlBaseChangeMasterTo(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestMysqlBaseChannel(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	channelbase := &MysqlBase{queryTimeout: 10000}
+	channelbase.SetReplChannel("xenon")
+
+	columns := []string{"Channel_Name",
+		"Master_User",
+		"Relay_Master_Log_File",
+		"Executed_Gtid_Set",
+		"Slave_IO_Running",
+		"Slave_SQL_Running",
+	}
+	rows := func() sqlmock.Rows {
+		return sqlmock.NewRows(columns).
+			AddRow("etl", "etl", "mysql-bin.000009", "84030605-66aa-11e6-9465-52540e7fd51c:1-9", "Yes", "No").
+			AddRow("xenon", "repl", "mysql-bin.000001", "84030605-66aa-11e6-9465-52540e7fd51c:1-159", "Yes", "Yes")
+	}
+
+	// the xenon channel is picked, the others are ignored
+	{
+		mock.ExpectQuery("SHOW SLAVE STATUS").WillReturnRows(rows())
+		got, err := channelbase.GetSlaveGTID(db)
+		assert.Nil(t, err)
+		want := &model.GTID{Channel_Name: "xenon",
+			Executed_GTID_Set:     "84030605-66aa-11e6-9465-52540e7fd51c:1-159",
+			Slave_IO_Running:      true,
+			Slave_IO_Running_Str:  "Yes",
+			Slave_SQL_Running:     true,
+			Slave_SQL_Running_Str: "Yes",
+		}
+		assert.Equal(t, want, got)
+
+		mock.ExpectQuery("SHOW SLAVE STATUS").WillReturnRows(rows())
+		pe, err := channelbase.Ping(db)
+		assert.Nil(t, err)
+		assert.Equal(t, "mysql-bin.000001", pe.Relay_Master_Log_File)
+	}
+
+	// the xenon channel is not setup yet
+	{
+		mock.ExpectQuery("SHOW SLAVE STATUS").WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("etl", "etl", "mysql-bin.000009", "84030605-66aa-11e6-9465-52540e7fd51c:1-9", "Yes", "No"))
+		got, err := channelbase.GetSlaveGTID(db)
+		assert.Nil(t, err)
+		assert.Equal(t, &model.GTID{}, got)
+	}
+
+	// all the channels
+	{
+		mock.ExpectQuery("SHOW SLAVE STATUS").WillReturnRows(rows())
+		got, err := channelbase.GetSlaveChannels(db)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(got))
+		assert.Equal(t, "etl", got[0].Channel_Name)
+		assert.False(t, got[0].Slave_SQL_Running)
+		assert.Equal(t, "xenon", got[1].Channel_Name)
+	}
+
+	// the commands are for the xenon channel only
+	{
+		mock.ExpectExec("START SLAVE FOR CHANNEL 'xenon'").WillReturnResult(sqlmock.NewResult(1, 1))
+		assert.Nil(t, channelbase.StartSlave(db))
+		mock.ExpectExec("STOP SLAVE IO_THREAD FOR CHANNEL 'xenon'").WillReturnResult(sqlmock.NewResult(1, 1))
+		assert.Nil(t, channelbase.StopSlaveIOThread(db))
+
+		mock.ExpectExec("STOP SLAVE FOR CHANNEL 'xenon'").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("RESET SLAVE ALL FOR CHANNEL 'xenon'").WillReturnResult(sqlmock.NewResult(1, 1))
+		assert.Nil(t, channelbase.ChangeToMaster(db))
+	}
+
+	master := model.Repl{Master_Host: "localhost",
+		Master_Port:   123,
+		Repl_User:     "repl",
+		Repl_Password: "password"}
+	changeMasterTo := "CHANGE MASTER TO MASTER_HOST = 'localhost', MASTER_PORT = 123, MASTER_USER = 'repl', MASTER_PASSWORD = 'password', MASTER_AUTO_POSITION = 1 FOR CHANNEL 'xenon'"
+
+	// change master on the xenon channel
+	{
+		mock.ExpectQuery("SHOW SLAVE STATUS").WillReturnRows(rows())
+		mock.ExpectExec("STOP SLAVE FOR CHANNEL 'xenon'").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(changeMasterTo).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("START SLAVE FOR CHANNEL 'xenon'").WillReturnResult(sqlmock.NewResult(1, 1))
+		assert.Nil(t, channelbase.ChangeMasterTo(db, &master))
+	}
+
+	// the default channel which xenon setup before is dropped, the etl channel is kept
+	{
+		mock.ExpectQuery("SHOW SLAVE STATUS").WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("", "repl", "mysql-bin.000001", "", "Yes", "Yes").
+			AddRow("etl", "etl", "mysql-bin.000009", "", "Yes", "Yes"))
+		mock.ExpectExec("STOP SLAVE FOR CHANNEL ''").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("RESET SLAVE ALL FOR CHANNEL ''").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("STOP SLAVE FOR CHANNEL 'xenon'").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(changeMasterTo).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("START SLAVE FOR CHANNEL 'xenon'").WillReturnResult(sqlmock.NewResult(1, 1))
+		assert.Nil(t, channelbase.ChangeMasterTo(db, &master))
+	}
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
 func TestMysqlBaseChangeToMaster(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.Nil(t, err)
@@ -262,6 +381,29 @@ func TestMysqlBaseSlaveIOThread(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestMysqlBaseDelayedCatchup(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	mysqlbase.SetQueryTimeout(10000)
+	defer db.Close()
+
+	queryList := []string{
+		"STOP SLAVE SQL_THREAD",
+		"CHANGE MASTER TO MASTER_DELAY = 0",
+		"START SLAVE SQL_THREAD UNTIL SQL_BEFORE_GTIDS = '84030605-66aa-11e6-9465-52540e7fd51c:160'",
+	}
+
+	mock.ExpectExec(queryList[0]).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(queryList[1]).WillReturnResult(sqlmock.NewResult(1, 1))
+	err = mysqlbase.SetMasterDelay(db, 0)
+	assert.Nil(t, err)
+
+	mock.ExpectExec(queryList[2]).WillReturnResult(sqlmock.NewResult(1, 1))
+	err = mysqlbase.StartSlaveUntilGTID(db, "84030605-66aa-11e6-9465-52540e7fd51c:160")
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
 func TestMysqlBaseReadOnly(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.Nil(t, err)
@@ -342,6 +484,91 @@ func TestMysqlBasePurgeBinlogsTo(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestMysqlBaseGetBinaryLogs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	mysqlbase.SetQueryTimeout(10000)
+	defer db.Close()
+
+	query := "SHOW BINARY LOGS"
+	columns := []string{"Log_name", "File_size"}
+	mockRows := sqlmock.NewRows(columns).
+		AddRow("mysql-bin.000001", "1024").
+		AddRow("mysql-bin.000002", "154")
+	mock.ExpectQuery(query).WillReturnRows(mockRows)
+
+	got, err := mysqlbase.GetBinaryLogs(db)
+	assert.Nil(t, err)
+	want := []string{"mysql-bin.000001", "mysql-bin.000002"}
+	assert.Equal(t, want, got)
+}
+
+func TestMysqlBaseGetBinlogPreviousGTIDs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	mysqlbase.SetQueryTimeout(10000)
+	defer db.Close()
+
+	query := "SHOW BINLOG EVENTS IN 'mysql-bin.000002' LIMIT 3"
+	columns := []string{"Log_name", "Pos", "Event_type", "Server_id", "End_log_pos", "Info"}
+	mockRows := sqlmock.NewRows(columns).
+		AddRow("mysql-bin.000002", "4", "Format_desc", "1", "123", "Server ver: 5.7.34-log, Binlog ver: 4").
+		AddRow("mysql-bin.000002", "123", "Previous_gtids", "1", "194", "84030605-66aa-11e6-9465-52540e7fd51c:1-160,\n9a8b7c6d-66aa-11e6-9465-52540e7fd51c:1-5")
+	mock.ExpectQuery(query).WillReturnRows(mockRows)
+
+	got, err := mysqlbase.GetBinlogPreviousGTIDs(db, "mysql-bin.000002")
+	assert.Nil(t, err)
+	want := "84030605-66aa-11e6-9465-52540e7fd51c:1-160,9a8b7c6d-66aa-11e6-9465-52540e7fd51c:1-5"
+	assert.Equal(t, want, got)
+
+	// the first binlog without GTIDs
+	mockRows = sqlmock.NewRows(columns).
+		AddRow("mysql-bin.000001", "4", "Format_desc", "1", "123", "Server ver: 5.7.34-log, Binlog ver: 4").
+		AddRow("mysql-bin.000001", "123", "Previous_gtids", "1", "154", "")
+	mock.ExpectQuery("SHOW BINLOG EVENTS IN 'mysql-bin.000001' LIMIT 3").WillReturnRows(mockRows)
+	got, err = mysqlbase.GetBinlogPreviousGTIDs(db, "mysql-bin.000001")
+	assert.Nil(t, err)
+	assert.Equal(t, "", got)
+}
+
+func TestMysqlBaseGetBinlogBasename(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	mysqlbase.SetQueryTimeout(10000)
+	defer db.Close()
+
+	query := "SELECT @@GLOBAL.LOG_BIN_BASENAME"
+	columns := []string{"@@GLOBAL.LOG_BIN_BASENAME"}
+	mockRows := sqlmock.NewRows(columns).AddRow("/u01/mysql/data/mysql-bin")
+	mock.ExpectQuery(query).WillReturnRows(mockRows)
+
+	got, err := mysqlbase.GetBinlogBasename(db)
+	assert.Nil(t, err)
+	assert.Equal(t, "/u01/mysql/data/mysql-bin", got)
+}
+
+func TestMysqlBaseGetVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	mysqlbase.SetQueryTimeout(10000)
+	defer db.Close()
+
+	query := "SELECT @@GLOBAL.VERSION"
+	columns := []string{"@@GLOBAL.VERSION"}
+	mockRows := sqlmock.NewRows(columns).AddRow("5.7.30-log")
+	mock.ExpectQuery(query).WillReturnRows(mockRows)
+
+	got, err := mysqlbase.GetVersion(db)
+	assert.Nil(t, err)
+	assert.Equal(t, "5.7.30-log", got)
+
+	// the clone plugin is only supported by mysql80
+	err = mysqlbase.InstallClonePlugin(db)
+	assert.Equal(t, "mysql.clone.plugin.requires.mysql80", err.Error())
+	err = mysqlbase.CloneInstance(db, &model.Repl{})
+	assert.Equal(t, "mysql.clone.plugin.requires.mysql80", err.Error())
+}
+
 func TestMysqlBaseSemiMaster(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.Nil(t, err)
@@ -351,6 +578,7 @@ func TestMysqlBaseSemiMaster(t *testing.T) {
 	queryList := []string{
 		"SET GLOBAL rpl_semi_sync_master_enabled=ON",
 		"SET GLOBAL rpl_semi_sync_master_enabled=OFF",
+		"SET GLOBAL rpl_semi_sync_slave_enabled=OFF",
 	}
 
 	mock.ExpectExec(queryList[0]).WillReturnResult(sqlmock.NewResult(1, 1))
@@ -360,6 +588,10 @@ func TestMysqlBaseSemiMaster(t *testing.T) {
 	mock.ExpectExec(queryList[1]).WillReturnResult(sqlmock.NewResult(1, 1))
 	err = mysqlbase.DisableSemiSyncMaster(db)
 	assert.Nil(t, err)
+
+	mock.ExpectExec(queryList[2]).WillReturnResult(sqlmock.NewResult(1, 1))
+	err = mysqlbase.DisableSemiSyncSlave(db)
+	assert.Nil(t, err)
 }
 
 func TestMysqlBaseSemiMasterTimeout(t *testing.T) {
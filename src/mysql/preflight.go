@@ -0,0 +1,175 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018-2019 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"model"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// preflightVariables are the global variables the preflight checks,
+// the variable is missing if the plugin is not installed or the server doesn't have it.
+var preflightVariables = []string{
+	"log_bin",
+	"gtid_mode",
+	"log_slave_updates",
+	"log_replica_updates",
+	"wsrep_on",
+	"group_replication_group_name",
+	"rpl_semi_sync_master_enabled",
+	"rpl_semi_sync_source_enabled",
+	"rpl_semi_sync_slave_enabled",
+	"rpl_semi_sync_replica_enabled",
+}
+
+// preflightVars returns the preflight variables which the server has.
+func (my *MysqlBase) preflightVars(db *sql.DB) (map[string]string, error) {
+	query := fmt.Sprintf("SHOW GLOBAL VARIABLES WHERE Variable_name IN ('%s')", strings.Join(preflightVariables, "','"))
+	rows, err := QueryWithTimeout(db, my.queryTimeout, query)
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string)
+	for _, row := range rows {
+		vars[strings.ToLower(row["Variable_name"])] = row["Value"]
+	}
+	return vars, nil
+}
+
+// groupReplicationState returns the state of the group replication member, empty if it's not running.
+func (my *MysqlBase) groupReplicationState(db *sql.DB) (string, error) {
+	query := "SELECT MEMBER_STATE FROM performance_schema.replication_group_members"
+	rows, err := QueryWithTimeout(db, my.queryTimeout, query)
+	if err != nil {
+		return "", err
+	}
+	for _, row := range rows {
+		if state := row["MEMBER_STATE"]; state != "" && state != "OFFLINE" {
+			return state, nil
+		}
+	}
+	return "", nil
+}
+
+// preflightOn returns the first of the variables the server has, and whether it's ON.
+func preflightOn(vars map[string]string, names ...string) (string, bool, bool) {
+	for _, name := range names {
+		if value, ok := vars[name]; ok {
+			return value, true, strings.ToUpper(value) == "ON" || value == "1"
+		}
+	}
+	return "", false, false
+}
+
+// preflight runs the checks, the gtidBuiltin is for the server which always has the GTID.
+func (my *MysqlBase) preflight(db *sql.DB, gtidBuiltin bool) ([]model.MysqlPreflightCheck, error) {
+	vars, err := my.preflightVars(db)
+	if err != nil {
+		return nil, err
+	}
+	var checks []model.MysqlPreflightCheck
+	check := func(name string, passed bool, detail string) {
+		checks = append(checks, model.MysqlPreflightCheck{Name: name, Passed: passed, Detail: detail})
+	}
+
+	// group replication manages the topology itself, xenon must not reset the slave on it
+	state := ""
+	if _, ok := vars["group_replication_group_name"]; ok {
+		if state, err = my.groupReplicationState(db); err != nil {
+			return nil, err
+		}
+	}
+	if state != "" {
+		check("group_replication", false, fmt.Sprintf("group replication is running, member state is %s", state))
+	} else {
+		check("group_replication", true, "group replication is not running")
+	}
+
+	// galera, such as the Percona XtraDB Cluster and the MariaDB Galera Cluster
+	if _, _, on := preflightOn(vars, "wsrep_on"); on {
+		check("galera", false, "wsrep_on is ON, the node is in a galera cluster")
+	} else {
+		check("galera", true, "wsrep is off")
+	}
+
+	if value, _, on := preflightOn(vars, "log_bin"); on {
+		check("log_bin", true, "log_bin is ON")
+	} else {
+		check("log_bin", false, fmt.Sprintf("log_bin is %q, the binlog must be enabled", value))
+	}
+
+	if gtidBuiltin {
+		check("gtid_mode", true, "the GTID is always on")
+	} else if value, _, on := preflightOn(vars, "gtid_mode"); on {
+		check("gtid_mode", true, "gtid_mode is ON")
+	} else {
+		check("gtid_mode", false, fmt.Sprintf("gtid_mode is %q, it must be ON", value))
+	}
+
+	// the slave becomes the master, it must have all the transactions in the binlog
+	if value, _, on := preflightOn(vars, "log_slave_updates", "log_replica_updates"); on {
+		check("log_slave_updates", true, "log_slave_updates is ON")
+	} else {
+		check("log_slave_updates", false, fmt.Sprintf("log_slave_updates is %q, it must be ON", value))
+	}
+
+	if _, ok, _ := preflightOn(vars, "rpl_semi_sync_master_enabled", "rpl_semi_sync_source_enabled"); ok {
+		check("semi_sync_master", true, "the semi-sync master plugin is installed")
+	} else {
+		check("semi_sync_master", false, "the semi-sync master plugin is not installed")
+	}
+	if _, ok, _ := preflightOn(vars, "rpl_semi_sync_slave_enabled", "rpl_semi_sync_replica_enabled"); ok {
+		check("semi_sync_slave", true, "the semi-sync slave plugin is installed")
+	} else {
+		check("semi_sync_slave", false, "the semi-sync slave plugin is not installed")
+	}
+	return checks, nil
+}
+
+// Preflight used to probe the capabilities the HA depends on.
+func (my *MysqlBase) Preflight(db *sql.DB) ([]model.MysqlPreflightCheck, error) {
+	return my.preflight(db, false)
+}
+
+// Preflight used to probe the capabilities of the mysql the HA depends on.
+func (m *Mysql) Preflight() ([]model.MysqlPreflightCheck, error) {
+	db, err := m.getDB()
+	if err != nil {
+		return nil, err
+	}
+	return m.mysqlHandler.Preflight(db)
+}
+
+// PreflightRequired returns false if the check is advisory, the semi-sync plugins are required
+// only if the durability policy waits for the semi-sync ACKs.
+func PreflightRequired(name string, semiSync bool) bool {
+	switch name {
+	case "semi_sync_master", "semi_sync_slave":
+		return semiSync
+	}
+	return true
+}
+
+// PreflightError returns the error of the failed required checks, nil if all passed.
+func PreflightError(checks []model.MysqlPreflightCheck, semiSync bool) error {
+	var failed []string
+	for _, check := range checks {
+		if !check.Passed && PreflightRequired(check.Name, semiSync) {
+			failed = append(failed, fmt.Sprintf("%s: %s", check.Name, check.Detail))
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("mysql.preflight.failed[%s]", strings.Join(failed, "; "))
+	}
+	return nil
+}
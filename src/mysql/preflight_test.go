@@ -0,0 +1,130 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018-2019 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysql
+
+import (
+	"testing"
+
+	"model"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func preflightRows(vars ...string) sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"Variable_name", "Value"})
+	for i := 0; i+1 < len(vars); i += 2 {
+		rows.AddRow(vars[i], vars[i+1])
+	}
+	return rows
+}
+
+func preflightFailed(checks []model.MysqlPreflightCheck) []string {
+	var failed []string
+	for _, check := range checks {
+		if !check.Passed {
+			failed = append(failed, check.Name)
+		}
+	}
+	return failed
+}
+
+func TestMysqlBasePreflight(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	mysql57 := new(Mysql57)
+	mysql57.SetQueryTimeout(10000)
+	query := "SHOW GLOBAL VARIABLES WHERE Variable_name IN"
+
+	// all passed
+	{
+		mock.ExpectQuery(query).WillReturnRows(preflightRows("log_bin", "ON", "gtid_mode", "ON", "log_slave_updates", "ON",
+			"rpl_semi_sync_master_enabled", "OFF", "rpl_semi_sync_slave_enabled", "ON"))
+		checks, err := mysql57.Preflight(db)
+		assert.Nil(t, err)
+		assert.Equal(t, 7, len(checks))
+		assert.Nil(t, preflightFailed(checks))
+		assert.Nil(t, PreflightError(checks, true))
+	}
+
+	// the 8.0.26+ names
+	{
+		mock.ExpectQuery(query).WillReturnRows(preflightRows("log_bin", "ON", "gtid_mode", "ON", "log_replica_updates", "ON",
+			"rpl_semi_sync_source_enabled", "ON", "rpl_semi_sync_replica_enabled", "OFF"))
+		checks, err := mysql57.Preflight(db)
+		assert.Nil(t, err)
+		assert.Nil(t, preflightFailed(checks))
+	}
+
+	// the group replication is running
+	{
+		mock.ExpectQuery(query).WillReturnRows(preflightRows("log_bin", "ON", "gtid_mode", "ON", "log_slave_updates", "ON",
+			"group_replication_group_name", "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa",
+			"rpl_semi_sync_master_enabled", "OFF", "rpl_semi_sync_slave_enabled", "ON"))
+		mock.ExpectQuery("SELECT MEMBER_STATE FROM performance_schema.replication_group_members").
+			WillReturnRows(sqlmock.NewRows([]string{"MEMBER_STATE"}).AddRow("ONLINE"))
+		checks, err := mysql57.Preflight(db)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"group_replication"}, preflightFailed(checks))
+		assert.Equal(t, "mysql.preflight.failed[group_replication: group replication is running, member state is ONLINE]", PreflightError(checks, true).Error())
+	}
+
+	// the group replication plugin is installed but offline
+	{
+		mock.ExpectQuery(query).WillReturnRows(preflightRows("log_bin", "ON", "gtid_mode", "ON", "log_slave_updates", "ON",
+			"group_replication_group_name", "",
+			"rpl_semi_sync_master_enabled", "OFF", "rpl_semi_sync_slave_enabled", "ON"))
+		mock.ExpectQuery("SELECT MEMBER_STATE FROM performance_schema.replication_group_members").
+			WillReturnRows(sqlmock.NewRows([]string{"MEMBER_STATE"}).AddRow("OFFLINE"))
+		checks, err := mysql57.Preflight(db)
+		assert.Nil(t, err)
+		assert.Nil(t, preflightFailed(checks))
+	}
+
+	// galera, binlog disabled, gtid off, no log_slave_updates and no semi-sync plugins
+	{
+		mock.ExpectQuery(query).WillReturnRows(preflightRows("log_bin", "OFF", "gtid_mode", "OFF_PERMISSIVE", "log_slave_updates", "OFF",
+			"wsrep_on", "ON"))
+		checks, err := mysql57.Preflight(db)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"galera", "log_bin", "gtid_mode", "log_slave_updates", "semi_sync_master", "semi_sync_slave"}, preflightFailed(checks))
+		assert.Contains(t, PreflightError(checks, true).Error(), "gtid_mode: gtid_mode is \"OFF_PERMISSIVE\", it must be ON")
+		assert.Contains(t, PreflightError(checks, true).Error(), "semi_sync_master")
+
+		// the semi-sync checks are advisory with the async durability
+		assert.NotContains(t, PreflightError(checks, false).Error(), "semi_sync_master")
+		assert.NotContains(t, PreflightError(checks, false).Error(), "semi_sync_slave")
+	}
+
+	// only the semi-sync plugins are missing
+	{
+		mock.ExpectQuery(query).WillReturnRows(preflightRows("log_bin", "ON", "gtid_mode", "ON", "log_slave_updates", "ON"))
+		checks, err := mysql57.Preflight(db)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"semi_sync_master", "semi_sync_slave"}, preflightFailed(checks))
+		assert.NotNil(t, PreflightError(checks, true))
+		assert.Nil(t, PreflightError(checks, false))
+		assert.False(t, PreflightRequired("semi_sync_master", false))
+		assert.True(t, PreflightRequired("log_bin", false))
+	}
+
+	// the GTID is always on in MariaDB
+	{
+		mariadb := new(MariaDB10)
+		mariadb.SetQueryTimeout(10000)
+		mock.ExpectQuery(query).WillReturnRows(preflightRows("log_bin", "ON", "log_slave_updates", "ON", "wsrep_on", "OFF",
+			"rpl_semi_sync_master_enabled", "OFF", "rpl_semi_sync_slave_enabled", "OFF"))
+		checks, err := mariadb.Preflight(db)
+		assert.Nil(t, err)
+		assert.Nil(t, preflightFailed(checks))
+	}
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -104,6 +104,11 @@ func (m *MysqlRPC) Status(req *model.MysqlStatusRPCRequest, rsp *model.MysqlStat
 	rsp.Status = string(m.mysql.GetState())
 	rsp.Options = string(m.mysql.GetOption())
 	rsp.Stats = m.mysql.getStats()
+	rsp.Stats.SemiSync, _ = m.mysql.GetSemiSyncStatus()
+	if rsp.Channels, err = m.mysql.GetSlaveChannels(); err != nil {
+		rsp.RetCode = err.Error()
+		return nil
+	}
 	return nil
 }
 
@@ -119,6 +124,56 @@ func (m *MysqlRPC) GTIDSubtract(req *model.MysqlGTIDSubtractRPCRequest, rsp *mod
 	return nil
 }
 
+// Version returns the mysql server version.
+func (m *MysqlRPC) Version(req *model.MysqlRPCRequest, rsp *model.MysqlVersionRPCResponse) error {
+	var err error
+
+	rsp.RetCode = model.OK
+	if rsp.Version, err = m.mysql.GetVersion(); err != nil {
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	return nil
+}
+
+// PrepareCloneDonor used to prepare the mysql as the clone donor.
+func (m *MysqlRPC) PrepareCloneDonor(req *model.MysqlRPCRequest, rsp *model.MysqlCloneRPCResponse) error {
+	rsp.RetCode = model.OK
+	donor, err := m.mysql.PrepareCloneDonor()
+	if err != nil {
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	rsp.Donor = *donor
+	return nil
+}
+
+// CloneInstance used to clone the data from the donor.
+func (m *MysqlRPC) CloneInstance(req *model.MysqlCloneRPCRequest, rsp *model.MysqlRPCResponse) error {
+	rsp.RetCode = model.OK
+	if err := m.mysql.CloneInstance(&req.Donor); err != nil {
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	return nil
+}
+
+// DelayedCatchup used to roll the delayed replica forward to the transaction before the GTID, or resume the delay.
+func (m *MysqlRPC) DelayedCatchup(req *model.MysqlDelayedCatchupRPCRequest, rsp *model.MysqlRPCResponse) error {
+	rsp.RetCode = model.OK
+	if req.Resume {
+		if err := m.mysql.DelayedResume(req.Delay); err != nil {
+			rsp.RetCode = err.Error()
+		}
+		return nil
+	}
+	if err := m.mysql.DelayedCatchup(req.GTID, req.Delay); err != nil {
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	return nil
+}
+
 // SetState used to set the mysql state.
 func (m *MysqlRPC) SetState(req *model.MysqlSetStateRPCRequest, rsp *model.MysqlSetStateRPCResponse) error {
 	rsp.RetCode = model.OK
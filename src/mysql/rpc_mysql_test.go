@@ -9,11 +9,13 @@
 package mysql
 
 import (
+	"database/sql"
 	"model"
 	"testing"
 	"xbase/common"
 	"xbase/xlog"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -194,3 +196,75 @@ func TestMysqlRPCIsWorking(t *testing.T) {
 		assert.Equal(t, want, got)
 	}
 }
+
+func TestMysqlRPCClone(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	mock := NewMockGTIDB()
+	id, _, cleanup := MockMysql(log, port, mock)
+	defer cleanup()
+
+	c, cleanup := MockGetClient(t, id)
+	defer cleanup()
+
+	// version
+	{
+		method := model.RPCMysqlVersion
+		req := model.NewMysqlRPCRequest()
+		rsp := model.NewMysqlVersionRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.OK, rsp.RetCode)
+		assert.Equal(t, "8.0.18", rsp.Version)
+	}
+
+	// prepare donor
+	var donor model.Repl
+	{
+		var grantee string
+		mock.GrantCloneDonorPrivilegesFn = func(db *sql.DB, user string) error {
+			grantee = user
+			return nil
+		}
+		method := model.RPCMysqlPrepareCloneDonor
+		req := model.NewMysqlRPCRequest()
+		rsp := model.NewMysqlCloneRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.OK, rsp.RetCode)
+		assert.Equal(t, "repl", grantee)
+		want := model.Repl{Master_Host: "127.0.0.1", Master_Port: 3306, Repl_User: "repl", Repl_Password: "repl"}
+		assert.Equal(t, want, rsp.Donor)
+		donor = rsp.Donor
+	}
+
+	// clone
+	{
+		var got *model.Repl
+		mock.CloneInstanceFn = func(db *sql.DB, donor *model.Repl) error {
+			got = donor
+			return nil
+		}
+		method := model.RPCMysqlCloneInstance
+		req := model.NewMysqlCloneRPCRequest()
+		req.Donor = donor
+		rsp := model.NewMysqlRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.OK, rsp.RetCode)
+		assert.Equal(t, &donor, got)
+	}
+
+	// the plugin can't be installed
+	{
+		mock.InstallClonePluginFn = func(db *sql.DB) error {
+			return errors.New("mysql.clone.plugin.requires.mysql80")
+		}
+		method := model.RPCMysqlCloneInstance
+		req := model.NewMysqlCloneRPCRequest()
+		rsp := model.NewMysqlRPCResponse(model.OK)
+		err := c.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, "mysql.clone.plugin.requires.mysql80", rsp.RetCode)
+	}
+}
@@ -0,0 +1,172 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018-2019 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"model"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// the lossless semi-sync, the master waits for the ack before the commit
+	SemiSyncWaitPointAfterSync = "AFTER_SYNC"
+)
+
+var (
+	// the variables and the status of the source/replica plugins are mapped to the master/slave names
+	semiSyncSourceReplacer  = strings.NewReplacer("master", "source", "slave", "replica")
+	semiSyncReplicaReplacer = strings.NewReplacer("slave", "replica")
+	semiSyncMasterReplacer  = strings.NewReplacer("source", "master", "replica", "slave")
+)
+
+// semiSyncPlugins tuple, which names the loaded semi-sync plugins have.
+type semiSyncPlugins struct {
+	// rpl_semi_sync_source instead of rpl_semi_sync_master
+	source bool
+
+	// rpl_semi_sync_replica instead of rpl_semi_sync_slave
+	replica bool
+}
+
+// varName returns the name of the master/slave variable by the plugins,
+// such as rpl_semi_sync_source_timeout for rpl_semi_sync_master_timeout.
+func (p semiSyncPlugins) varName(name string) string {
+	switch {
+	case strings.Contains(name, "_master_") && p.source:
+		return semiSyncSourceReplacer.Replace(name)
+	case strings.Contains(name, "_slave_") && p.replica:
+		return semiSyncReplicaReplacer.Replace(name)
+	}
+	return name
+}
+
+// semiSyncVar returns the name of the master/slave variable by the loaded plugins.
+func (my *MysqlBase) semiSyncVar(name string) string {
+	return my.getSemiSync().varName(name)
+}
+
+// semiSyncInstallCommands detects the loaded semi-sync plugins and returns the commands to install the missing ones,
+// the source/replica plugins are installed if the version has them.
+// The plugins returned are loaded only after the commands succeed.
+func (my *MysqlBase) semiSyncInstallCommands(db *sql.DB) ([]string, semiSyncPlugins, error) {
+	var loaded semiSyncPlugins
+
+	query := "SELECT PLUGIN_NAME, PLUGIN_STATUS FROM INFORMATION_SCHEMA.PLUGINS WHERE PLUGIN_NAME LIKE 'rpl_semi_sync_%'"
+	rows, err := QueryWithTimeout(db, my.queryTimeout, query)
+	if err != nil {
+		return nil, loaded, err
+	}
+	plugins := make(map[string]string)
+	for _, row := range rows {
+		if status := row["PLUGIN_STATUS"]; status != "ACTIVE" {
+			return nil, loaded, errors.Errorf("mysql.semisync.plugin[%v].status[%v].is.not.active", row["PLUGIN_NAME"], status)
+		}
+		plugins[row["PLUGIN_NAME"]] = row["PLUGIN_STATUS"]
+	}
+
+	var cmds []string
+	syntax := my.getSyntax()
+	_, master := plugins["rpl_semi_sync_master"]
+	_, source := plugins["rpl_semi_sync_source"]
+	switch {
+	case source:
+		loaded.source = true
+	case master:
+		loaded.source = false
+	case syntax.semiSyncSource:
+		loaded.source = true
+		cmds = append(cmds, "INSTALL PLUGIN rpl_semi_sync_source SONAME 'semisync_source.so'")
+	default:
+		loaded.source = false
+		cmds = append(cmds, "INSTALL PLUGIN rpl_semi_sync_master SONAME 'semisync_master.so'")
+	}
+
+	_, slave := plugins["rpl_semi_sync_slave"]
+	_, replica := plugins["rpl_semi_sync_replica"]
+	switch {
+	case replica:
+		loaded.replica = true
+	case slave:
+		loaded.replica = false
+	case syntax.semiSyncSource:
+		loaded.replica = true
+		cmds = append(cmds, "INSTALL PLUGIN rpl_semi_sync_replica SONAME 'semisync_replica.so'")
+	default:
+		loaded.replica = false
+		cmds = append(cmds, "INSTALL PLUGIN rpl_semi_sync_slave SONAME 'semisync_slave.so'")
+	}
+	// the fresh slave plugin is off, the IO thread acks once it restarts
+	if !slave && !replica {
+		cmds = append(cmds, fmt.Sprintf("SET GLOBAL %s=ON", loaded.varName("rpl_semi_sync_slave_enabled")))
+	}
+	return cmds, loaded, nil
+}
+
+// InstallSemiSyncPlugins used to install the semi-sync master and slave plugins if they are missing,
+// the variable names follow the loaded plugins after that.
+func (my *MysqlBase) InstallSemiSyncPlugins(db *sql.DB) error {
+	cmds, loaded, err := my.semiSyncInstallCommands(db)
+	if err != nil {
+		return err
+	}
+	if len(cmds) > 0 {
+		if err := my.executeWithoutBinlog(db, cmds); err != nil {
+			return err
+		}
+	}
+	my.setSemiSync(loaded)
+	return nil
+}
+
+// GetSemiSyncStatus used to get the semi-sync health, the source/replica names are mapped to the master/slave ones.
+func (my *MysqlBase) GetSemiSyncStatus(db *sql.DB) (*model.SemiSyncStatus, error) {
+	status := &model.SemiSyncStatus{}
+	rows, err := QueryWithTimeout(db, my.queryTimeout, "SHOW GLOBAL STATUS LIKE 'Rpl_semi_sync_%'")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		value := row["Value"]
+		count, _ := strconv.ParseUint(value, 10, 64)
+		switch semiSyncMasterReplacer.Replace(row["Variable_name"]) {
+		case "Rpl_semi_sync_master_status":
+			status.MasterStatus = value
+		case "Rpl_semi_sync_slave_status":
+			status.SlaveStatus = value
+		case "Rpl_semi_sync_master_clients":
+			status.Clients = count
+		case "Rpl_semi_sync_master_tx_waits":
+			status.TxWaits = count
+		case "Rpl_semi_sync_master_no_tx":
+			status.NoTx = count
+		case "Rpl_semi_sync_master_yes_tx":
+			status.YesTx = count
+		}
+	}
+
+	rows, err = QueryWithTimeout(db, my.queryTimeout, "SHOW GLOBAL VARIABLES LIKE 'rpl_semi_sync_%_wait_point'")
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) > 0 {
+		status.WaitPoint = rows[0]["Value"]
+	}
+	return status, nil
+}
+
+// SetSemiSyncWaitPoint used to set the rpl_semi_sync_master_wait_point.
+func (my *MysqlBase) SetSemiSyncWaitPoint(db *sql.DB, point string) error {
+	cmds := fmt.Sprintf("SET GLOBAL %s=%s", my.semiSyncVar("rpl_semi_sync_master_wait_point"), point)
+	return ExecuteWithTimeout(db, my.queryTimeout, cmds)
+}
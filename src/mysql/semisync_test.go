@@ -0,0 +1,174 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018-2019 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysql
+
+import (
+	"database/sql"
+	"testing"
+
+	"config"
+	"model"
+	"xbase/xlog"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestInstallSemiSyncPlugins(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	query := "SELECT PLUGIN_NAME, PLUGIN_STATUS FROM INFORMATION_SCHEMA.PLUGINS WHERE PLUGIN_NAME LIKE"
+	columns := []string{"PLUGIN_NAME", "PLUGIN_STATUS"}
+
+	// 5.7 has none, the master/slave plugins are installed and the slave is enabled
+	{
+		mysql57 := new(Mysql57)
+		mysql57.SetQueryTimeout(10000)
+		mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectQuery("SELECT @@GLOBAL.SUPER_READ_ONLY").WillReturnRows(sqlmock.NewRows([]string{"@@GLOBAL.SUPER_READ_ONLY"}).AddRow("1"))
+		mock.ExpectExec("SET GLOBAL super_read_only = 0").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("SET sql_log_bin=0").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSTALL PLUGIN rpl_semi_sync_master SONAME 'semisync_master.so'").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSTALL PLUGIN rpl_semi_sync_slave SONAME 'semisync_slave.so'").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("SET GLOBAL rpl_semi_sync_slave_enabled=ON").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("SET sql_log_bin=1").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("SET GLOBAL super_read_only = 1").WillReturnResult(sqlmock.NewResult(1, 1))
+		assert.Nil(t, mysql57.InstallSemiSyncPlugins(db))
+		assert.Equal(t, semiSyncPlugins{}, mysql57.semiSync)
+
+		// installed
+		mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows(columns).AddRow("rpl_semi_sync_master", "ACTIVE").AddRow("rpl_semi_sync_slave", "ACTIVE"))
+		assert.Nil(t, mysql57.InstallSemiSyncPlugins(db))
+
+		// disabled
+		mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows(columns).AddRow("rpl_semi_sync_master", "DISABLED"))
+		err := mysql57.InstallSemiSyncPlugins(db)
+		assert.Equal(t, "mysql.semisync.plugin[rpl_semi_sync_master].status[DISABLED].is.not.active", err.Error())
+	}
+
+	// 8.0.26 installs the source/replica plugins, the variables follow them
+	{
+		mysql80 := new(Mysql80)
+		mysql80.SetQueryTimeout(10000)
+		mysql80.syntax = probeSyntax("8.0.26")
+		mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectQuery("SELECT @@GLOBAL.SUPER_READ_ONLY").WillReturnRows(sqlmock.NewRows([]string{"@@GLOBAL.SUPER_READ_ONLY"}).AddRow("0"))
+		mock.ExpectExec("SET sql_log_bin=0").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSTALL PLUGIN rpl_semi_sync_source SONAME 'semisync_source.so'").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSTALL PLUGIN rpl_semi_sync_replica SONAME 'semisync_replica.so'").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("SET GLOBAL rpl_semi_sync_replica_enabled=ON").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("SET sql_log_bin=1").WillReturnResult(sqlmock.NewResult(1, 1))
+		assert.Nil(t, mysql80.InstallSemiSyncPlugins(db))
+
+		mock.ExpectExec("SET GLOBAL rpl_semi_sync_source_enabled=ON").WillReturnResult(sqlmock.NewResult(1, 1))
+		assert.Nil(t, mysql80.EnableSemiSyncMaster(db))
+		mock.ExpectExec("SET GLOBAL rpl_semi_sync_source_timeout=1000").WillReturnResult(sqlmock.NewResult(1, 1))
+		assert.Nil(t, mysql80.SetSemiSyncMasterTimeout(db, 1000))
+		mock.ExpectExec("SET GLOBAL rpl_semi_sync_source_wait_for_replica_count = 2").WillReturnResult(sqlmock.NewResult(1, 1))
+		assert.Nil(t, mysql80.SetSemiWaitSlaveCount(db, 2))
+		mock.ExpectExec("SET GLOBAL rpl_semi_sync_source_wait_point=AFTER_SYNC").WillReturnResult(sqlmock.NewResult(1, 1))
+		assert.Nil(t, mysql80.SetSemiSyncWaitPoint(db, SemiSyncWaitPointAfterSync))
+
+		// the master/slave plugins are loaded before the upgrade, they are kept
+		mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows(columns).AddRow("rpl_semi_sync_master", "ACTIVE").AddRow("rpl_semi_sync_slave", "ACTIVE"))
+		assert.Nil(t, mysql80.InstallSemiSyncPlugins(db))
+		mock.ExpectExec("SET GLOBAL rpl_semi_sync_master_enabled=OFF").WillReturnResult(sqlmock.NewResult(1, 1))
+		assert.Nil(t, mysql80.DisableSemiSyncMaster(db))
+	}
+
+	// the source/replica plugins failed to install, the master/slave names are kept
+	{
+		mysql80 := new(Mysql80)
+		mysql80.SetQueryTimeout(10000)
+		mysql80.syntax = probeSyntax("8.0.26")
+		mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectQuery("SELECT @@GLOBAL.SUPER_READ_ONLY").WillReturnRows(sqlmock.NewRows([]string{"@@GLOBAL.SUPER_READ_ONLY"}).AddRow("0"))
+		mock.ExpectExec("SET sql_log_bin=0").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSTALL PLUGIN rpl_semi_sync_source SONAME 'semisync_source.so'").WillReturnError(errors.New("mock.install.error"))
+		err := mysql80.InstallSemiSyncPlugins(db)
+		assert.NotNil(t, err)
+		assert.Equal(t, semiSyncPlugins{}, mysql80.semiSync)
+		mock.ExpectExec("SET GLOBAL rpl_semi_sync_master_enabled=ON").WillReturnResult(sqlmock.NewResult(1, 1))
+		assert.Nil(t, mysql80.EnableSemiSyncMaster(db))
+	}
+
+	// the semi-sync is built in MariaDB
+	{
+		mariadb := new(MariaDB10)
+		assert.Nil(t, mariadb.InstallSemiSyncPlugins(db))
+	}
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSemiSyncStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	mysql84 := NewMysql84()
+	mysql84.SetQueryTimeout(10000)
+	columns := []string{"Variable_name", "Value"}
+	mock.ExpectQuery("SHOW GLOBAL STATUS LIKE 'Rpl_semi_sync_%'").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("Rpl_semi_sync_source_clients", "2").
+		AddRow("Rpl_semi_sync_source_no_tx", "3").
+		AddRow("Rpl_semi_sync_source_status", "ON").
+		AddRow("Rpl_semi_sync_source_tx_waits", "100").
+		AddRow("Rpl_semi_sync_source_yes_tx", "97").
+		AddRow("Rpl_semi_sync_replica_status", "OFF"))
+	mock.ExpectQuery("SHOW GLOBAL VARIABLES LIKE 'rpl_semi_sync_%_wait_point'").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("rpl_semi_sync_source_wait_point", "AFTER_SYNC"))
+	got, err := mysql84.GetSemiSyncStatus(db)
+	assert.Nil(t, err)
+	want := &model.SemiSyncStatus{
+		MasterStatus: "ON",
+		SlaveStatus:  "OFF",
+		Clients:      2,
+		TxWaits:      100,
+		NoTx:         3,
+		YesTx:        97,
+		WaitPoint:    "AFTER_SYNC",
+	}
+	assert.Equal(t, want, got)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestCheckSemiSyncWaitPoint(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := config.DefaultMysqlConfig()
+	mysql := NewMysql(conf, 10000, log)
+	mock := defaultMockGTID()
+	mysql.SetMysqlHandler(mock)
+
+	var point string
+	mock.SetSemiSyncWaitPointFn = func(db *sql.DB, p string) error {
+		point = p
+		return nil
+	}
+
+	// nothing is got
+	assert.Nil(t, mysql.CheckSemiSyncWaitPoint())
+	assert.Equal(t, "", point)
+
+	// AFTER_SYNC
+	mock.GetSemiSyncStatusFn = func(db *sql.DB) (*model.SemiSyncStatus, error) {
+		return &model.SemiSyncStatus{WaitPoint: "AFTER_SYNC"}, nil
+	}
+	assert.Nil(t, mysql.CheckSemiSyncWaitPoint())
+	assert.Equal(t, "", point)
+
+	// AFTER_COMMIT is set to AFTER_SYNC
+	mock.GetSemiSyncStatusFn = func(db *sql.DB) (*model.SemiSyncStatus, error) {
+		return &model.SemiSyncStatus{WaitPoint: "AFTER_COMMIT"}, nil
+	}
+	assert.Nil(t, mysql.CheckSemiSyncWaitPoint())
+	assert.Equal(t, "AFTER_SYNC", point)
+}
@@ -0,0 +1,501 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysqld
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"model"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	archiveFile     = "backup.xbstream.gz"
+	archiveRawFile  = "backup.xbstream"
+	manifestFile    = "manifest.json"
+	archiveIDLayout = "20060102150405"
+)
+
+var (
+	// binlog_pos = filename 'mysql-bin.000002', position '154', GTID of the last change 'uuid:1-10'
+	binlogPosRegexp = regexp.MustCompile(`(?s)filename '([^']*)', position '(\d+)'(?:, GTID of the last change '([^']*)')?`)
+)
+
+// localBackupCommands returns the backup command to the archive dir, the incremental
+// is based on the xtrabackup_checkpoints in the basedir or the LSN if the basedir is empty.
+func (b *Backup) localBackupCommands(dir string, basedir string, lsn uint64) []string {
+	var incremental string
+	if basedir != "" {
+		incremental = fmt.Sprintf(" --incremental-basedir=%s", basedir)
+	} else if lsn > 0 {
+		incremental = fmt.Sprintf(" --incremental-lsn=%d", lsn)
+	}
+
+	// the stream compressed by xtrabackup is not gzipped again
+	gzip := "gzip"
+	if b.conf.Compress != "" {
+		gzip = "cat"
+	}
+	arg := fmt.Sprintf("set -o pipefail; %s%s%s --extra-lsndir=%s --target-dir=%s | %s > %s",
+		b.xtrabackupCommand(b.conf.BackupIOPSLimits),
+		b.streamOptions(b.conf.Compress, b.conf.Encrypt, b.conf.EncryptKeyFile),
+		incremental,
+		dir,
+		dir,
+		gzip,
+		b.archivePath(dir))
+	return []string{
+		"-c",
+		arg,
+	}
+}
+
+// archivePath returns the archive file in the dir, it's gzipped if not compressed by xtrabackup.
+func (b *Backup) archivePath(dir string) string {
+	if b.conf.Compress != "" {
+		return filepath.Join(dir, archiveRawFile)
+	}
+	return filepath.Join(dir, archiveFile)
+}
+
+// incrementalBase returns the latest archive to take the incremental on,
+// nil if there is no archive or its compression and encryption are not the current.
+func (b *Backup) incrementalBase() (*model.BackupManifest, error) {
+	manifests, err := b.ListArchives()
+	if err != nil {
+		return nil, err
+	}
+	if len(manifests) == 0 {
+		return nil, nil
+	}
+	base := manifests[len(manifests)-1]
+	if base.Compress != b.conf.Compress || base.Encrypt != b.conf.Encrypt || base.EncryptKeyFile != b.conf.EncryptKeyFile {
+		b.log.Warning("local.backup.base[%v].compress[%v].encrypt[%v].is.not.the.current", base.ID, base.Compress, base.Encrypt)
+		return nil, nil
+	}
+	return base, nil
+}
+
+// LocalBackup used to take a compressed xbstream backup into the archive-dir,
+// the manifest is written after the archive is completed.
+// If incremental, the backup is based on the latest archive, or full if there is none.
+func (b *Backup) LocalBackup(incremental bool) (*model.BackupManifest, error) {
+	log := b.log
+
+	log.Info("local.backup.prepare.to.run")
+	if b.getStatus() == model.MYSQLD_BACKUPING ||
+		b.getStatus() == model.MYSQLD_APPLYLOGGING {
+		return nil, errors.New("local.backup.error[backup/applylog.already.running]")
+	}
+	if err := checkStreamOptions(b.conf.Compress, b.conf.Encrypt, b.conf.EncryptKeyFile); err != nil {
+		return nil, err
+	}
+
+	var base *model.BackupManifest
+	if incremental {
+		var err error
+		if base, err = b.incrementalBase(); err != nil {
+			return nil, err
+		}
+		if base == nil {
+			log.Warning("local.backup.no.archive.to.take.incremental.on.take.full")
+		}
+	}
+
+	begin := time.Now()
+	id := begin.Format(archiveIDLayout)
+	dir := filepath.Join(b.conf.ArchiveDir, id)
+	if _, err := os.Stat(dir); err == nil {
+		return nil, errors.Errorf("local.backup.error[archive[%v].already.exists]", id)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	b.start = begin
+	b.setStatus(model.MYSQLD_BACKUPING)
+	b.progress.reset(model.BACKUP_PHASE_NONE, b.conf.BackupDir, datadirSize(b.conf.BackupDir))
+
+	failed := func(err error) (*model.BackupManifest, error) {
+		b.setLastError(err.Error())
+		b.setStatus(model.MYSQLD_BACKUPNONE)
+		b.IncBackupErrs()
+		os.RemoveAll(dir)
+		log.Error("local.backup[%v].error[%+v]", id, err)
+		return nil, err
+	}
+
+	manifest := &model.BackupManifest{
+		ID:             id,
+		Type:           model.BACKUP_TYPE_FULL,
+		Archive:        b.archivePath(dir),
+		Begin:          begin.Unix(),
+		Compress:       b.conf.Compress,
+		Encrypt:        b.conf.Encrypt,
+		EncryptKeyFile: b.conf.EncryptKeyFile,
+	}
+
+	var args []string
+	if base != nil {
+		manifest.Type = model.BACKUP_TYPE_INCREMENTAL
+		manifest.BaseID = base.ID
+		basedir := filepath.Join(b.conf.ArchiveDir, base.ID)
+		if _, err := os.Stat(filepath.Join(basedir, "xtrabackup_checkpoints")); err != nil {
+			basedir = ""
+		}
+		args = b.localBackupCommands(dir, basedir, base.ToLSN)
+	} else {
+		args = b.localBackupCommands(dir, "", 0)
+	}
+	b.setLastCMD(strings.Join(args, " "))
+	log.Warning("local.backup.cmd[%s]", b.getLastCMD())
+	if err := b.cmd.Run(bash, args); err != nil {
+		return failed(err)
+	}
+	if err := b.cmd.ScanFunc(backupOk, backupOkCheckTimes, b.progress.parse); err != nil {
+		return failed(err)
+	}
+
+	if err := parseBackupInfo(dir, manifest); err != nil {
+		return failed(err)
+	}
+	if base != nil && manifest.FromLSN != base.ToLSN {
+		return failed(errors.Errorf("local.backup.incremental.from.lsn[%v].not.match.base[%v].to.lsn[%v]", manifest.FromLSN, base.ID, base.ToLSN))
+	}
+	size, checksum, err := fileChecksum(manifest.Archive)
+	if err != nil {
+		return failed(err)
+	}
+	manifest.Size = size
+	manifest.Checksum = checksum
+	manifest.End = time.Now().Unix()
+	if err := writeManifest(dir, manifest); err != nil {
+		return failed(err)
+	}
+
+	b.setStatus(model.MYSQLD_BACKUPNONE)
+	b.IncBackups()
+	log.Warning("local.backup[%v].%v.done.size[%v].gtid[%v]", id, manifest.Type, manifest.Size, manifest.GTID)
+	return manifest, nil
+}
+
+// parseBackupInfo parses the binlog position and LSNs from the xtrabackup_info
+// and xtrabackup_checkpoints which written by --extra-lsndir.
+func parseBackupInfo(dir string, manifest *model.BackupManifest) error {
+	info, err := parseKeyValueFile(filepath.Join(dir, "xtrabackup_info"))
+	if err != nil {
+		return err
+	}
+	if m := binlogPosRegexp.FindStringSubmatch(info["binlog_pos"]); m != nil {
+		manifest.BinlogFile = m[1]
+		manifest.BinlogPos, _ = strconv.ParseUint(m[2], 10, 64)
+		manifest.GTID = strings.Replace(m[3], "\n", "", -1)
+	}
+
+	checkpoints, err := parseKeyValueFile(filepath.Join(dir, "xtrabackup_checkpoints"))
+	if err != nil {
+		return err
+	}
+	manifest.FromLSN, _ = strconv.ParseUint(checkpoints["from_lsn"], 10, 64)
+	manifest.ToLSN, _ = strconv.ParseUint(checkpoints["to_lsn"], 10, 64)
+	return nil
+}
+
+// parseKeyValueFile parses the 'key = value' lines, the lines without '=' are
+// joined to the previous value(the GTID set may be multi-lines).
+func parseKeyValueFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+
+	var last string
+	kvs := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		idx := strings.Index(line, " = ")
+		if idx < 0 {
+			if last != "" {
+				kvs[last] += "\n" + line
+			}
+			continue
+		}
+		last = strings.TrimSpace(line[:idx])
+		kvs[last] = strings.TrimSpace(line[idx+3:])
+	}
+	return kvs, errors.WithStack(scanner.Err())
+}
+
+// fileChecksum returns the size and the sha256 of the file.
+func fileChecksum(path string) (uint64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", errors.WithStack(err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", errors.WithStack(err)
+	}
+	return uint64(n), hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func writeManifest(dir string, manifest *model.BackupManifest) error {
+	b, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(ioutil.WriteFile(filepath.Join(dir, manifestFile), b, 0644))
+}
+
+func readManifest(dir string) (*model.BackupManifest, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	manifest := &model.BackupManifest{}
+	if err := json.Unmarshal(b, manifest); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return manifest, nil
+}
+
+// checkArchiveID used to make sure the id is a dir name under the archive-dir.
+func checkArchiveID(id string) error {
+	if id == "" || id == "." || id == ".." || filepath.Base(id) != id {
+		return errors.Errorf("archive.id[%v].invalid", id)
+	}
+	return nil
+}
+
+// ListArchives returns the manifests of the archives sorted by id(the oldest first),
+// the dirs without manifest(running or broken) are skipped.
+func (b *Backup) ListArchives() ([]*model.BackupManifest, error) {
+	dirs, err := ioutil.ReadDir(b.conf.ArchiveDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+
+	var manifests []*model.BackupManifest
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		manifest, err := readManifest(filepath.Join(b.conf.ArchiveDir, dir.Name()))
+		if err != nil {
+			continue
+		}
+		manifests = append(manifests, manifest)
+	}
+	sort.Slice(manifests, func(i, j int) bool { return manifests[i].ID < manifests[j].ID })
+	return manifests, nil
+}
+
+// ShowArchive returns the manifest of the archive.
+func (b *Backup) ShowArchive(id string) (*model.BackupManifest, error) {
+	if err := checkArchiveID(id); err != nil {
+		return nil, err
+	}
+	manifest, err := readManifest(filepath.Join(b.conf.ArchiveDir, id))
+	if err != nil {
+		return nil, errors.Errorf("archive[%v].not.found", id)
+	}
+	return manifest, nil
+}
+
+// ArchiveChain returns the archives to restore the id in order: the full first and
+// the incrementals based on it. The chain must be complete and the LSNs continuous.
+func (b *Backup) ArchiveChain(id string) ([]*model.BackupManifest, error) {
+	var chain []*model.BackupManifest
+	for next := id; ; {
+		manifest, err := b.ShowArchive(next)
+		if err != nil {
+			if len(chain) > 0 {
+				return nil, errors.Errorf("archive[%v].chain.broken[base[%v].not.found]", id, next)
+			}
+			return nil, err
+		}
+		if len(chain) > 0 && manifest.ToLSN != chain[0].FromLSN {
+			return nil, errors.Errorf("archive[%v].chain.broken[%v.to.lsn[%v]!=%v.from.lsn[%v]]", id, manifest.ID, manifest.ToLSN, chain[0].ID, chain[0].FromLSN)
+		}
+		chain = append([]*model.BackupManifest{manifest}, chain...)
+
+		switch manifest.Type {
+		case model.BACKUP_TYPE_FULL:
+			return chain, nil
+		case model.BACKUP_TYPE_INCREMENTAL:
+			if manifest.BaseID == "" {
+				return nil, errors.Errorf("archive[%v].chain.broken[%v.has.no.base]", id, manifest.ID)
+			}
+			next = manifest.BaseID
+		default:
+			return nil, errors.Errorf("archive[%v].type[%v].unknown", manifest.ID, manifest.Type)
+		}
+	}
+}
+
+// DeleteArchive used to remove the archive from the archive-dir,
+// the archive which is the base of other incrementals can't be removed.
+func (b *Backup) DeleteArchive(id string) error {
+	if _, err := b.ShowArchive(id); err != nil {
+		return err
+	}
+	manifests, err := b.ListArchives()
+	if err != nil {
+		return err
+	}
+	for _, manifest := range manifests {
+		if manifest.BaseID == id {
+			return errors.Errorf("archive[%v].is.the.base.of.incremental[%v]", id, manifest.ID)
+		}
+	}
+	b.log.Warning("archive[%v].prepare.to.delete", id)
+	return errors.WithStack(os.RemoveAll(filepath.Join(b.conf.ArchiveDir, id)))
+}
+
+// archiveChains groups the archives into chains, each chain is a full and the incrementals on it,
+// the incrementals whose base is missing are grouped as a chain too.
+func archiveChains(manifests []*model.BackupManifest) [][]*model.BackupManifest {
+	var chains [][]*model.BackupManifest
+	idx := make(map[string]int)
+	for _, manifest := range manifests {
+		i, ok := idx[manifest.BaseID]
+		if manifest.Type != model.BACKUP_TYPE_INCREMENTAL || !ok {
+			i = len(chains)
+			chains = append(chains, nil)
+		}
+		chains[i] = append(chains[i], manifest)
+		idx[manifest.ID] = i
+	}
+	return chains
+}
+
+// PruneArchives used to remove the chains beyond the retention-count or whose
+// latest archive is older than the retention-days, returns the removed ids.
+func (b *Backup) PruneArchives() ([]string, error) {
+	manifests, err := b.ListArchives()
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []string
+	chains := archiveChains(manifests)
+	deadline := time.Now().AddDate(0, 0, -b.conf.RetentionDays).Unix()
+	for i, chain := range chains {
+		keep := len(chains) - i
+		latest := chain[len(chain)-1]
+		if (b.conf.RetentionCount > 0 && keep > b.conf.RetentionCount) ||
+			(b.conf.RetentionDays > 0 && latest.End < deadline) {
+			// remove the incrementals first, the chain is never left without base
+			for j := len(chain) - 1; j >= 0; j-- {
+				if err := b.DeleteArchive(chain[j].ID); err != nil {
+					return pruned, err
+				}
+				pruned = append(pruned, chain[j].ID)
+			}
+		}
+	}
+	return pruned, nil
+}
+
+func (b *Backup) extractCommands(archive string, dir string) []string {
+	cat := "cat"
+	if strings.HasSuffix(archive, ".gz") {
+		cat = "gunzip -c"
+	}
+	arg := fmt.Sprintf("set -o pipefail; mkdir -p %s && %s %s | %s/xbstream -x -C %s",
+		dir,
+		cat,
+		archive,
+		b.conf.XtrabackupBinDir,
+		dir)
+	return []string{
+		"-c",
+		arg,
+	}
+}
+
+// RestoreArchive used to restore the archive chain into the dir and prepare it,
+// the dir must be empty or not exist.
+func (b *Backup) RestoreArchive(id string, dir string) error {
+	log := b.log
+
+	if dir == "" {
+		return errors.New("restore.target.dir.is.empty")
+	}
+	if files, err := ioutil.ReadDir(dir); err == nil && len(files) > 0 {
+		return errors.Errorf("restore.target.dir[%v].is.not.empty", dir)
+	}
+	chain, err := b.ArchiveChain(id)
+	if err != nil {
+		return err
+	}
+	base := chain[0]
+	for _, manifest := range chain {
+		if manifest.Compress != base.Compress || manifest.Encrypt != base.Encrypt || manifest.EncryptKeyFile != base.EncryptKeyFile {
+			return errors.Errorf("archive[%v].compress.or.encrypt.mismatch.base[%v]", manifest.ID, base.ID)
+		}
+		_, checksum, err := fileChecksum(manifest.Archive)
+		if err != nil {
+			return err
+		}
+		if checksum != manifest.Checksum {
+			return errors.Errorf("archive[%v].checksum[%v].mismatch.manifest[%v]", manifest.ID, checksum, manifest.Checksum)
+		}
+	}
+
+	// the incrementals are extracted next to the dir and removed after applied
+	incdir := dir + "-incremental"
+	defer os.RemoveAll(incdir)
+
+	req := &model.BackupRPCRequest{
+		BackupDir:      dir,
+		Compress:       base.Compress,
+		Encrypt:        base.Encrypt,
+		EncryptKeyFile: base.EncryptKeyFile,
+	}
+	for i, manifest := range chain {
+		target := dir
+		if i > 0 {
+			target = filepath.Join(incdir, manifest.ID)
+			req.IncrementalDirs = append(req.IncrementalDirs, target)
+		}
+		args := b.extractCommands(manifest.Archive, target)
+		log.Warning("restore.archive[%v].extract.cmd[%s]", manifest.ID, strings.Join(args, " "))
+		if outs, err := b.cmd.RunCommand(bash, args); err != nil {
+			log.Error("restore.archive[%v].extract.error[%v:%+v]", manifest.ID, outs, err)
+			return err
+		}
+	}
+	if err := b.ApplyLog(req); err != nil {
+		return err
+	}
+	log.Warning("restore.archive[%v].to[%v].done", id, dir)
+	return nil
+}
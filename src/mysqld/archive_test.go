@@ -0,0 +1,449 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysqld
+
+import (
+	"config"
+	"fmt"
+	"io/ioutil"
+	"model"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const mockXtrabackup = `#!/bin/bash
+type=full-backuped
+from=0
+for arg in "$@"; do
+	case $arg in
+	--extra-lsndir=*) dir=${arg#*=};;
+	--incremental-lsn=*) type=incremental; from=${arg#*=};;
+	--incremental-basedir=*) type=incremental; from=$(awk '/^to_lsn/{print $3}' ${arg#*=}/xtrabackup_checkpoints);;
+	esac
+done
+to=$((from+1000))
+cat > $dir/xtrabackup_info <<EOF
+tool_name = xtrabackup
+binlog_pos = filename 'mysql-bin.000002', position '154', GTID of the last change '4b4b2a49-1b20-11e8-8c4d-525400a36c5e:1-10,
+4b4b2a49-1b20-11e8-8c4d-525400a36c5f:1-3'
+innodb_from_lsn = $from
+innodb_to_lsn = $to
+EOF
+cat > $dir/xtrabackup_checkpoints <<EOF
+backup_type = $type
+from_lsn = $from
+to_lsn = $to
+last_lsn = $to
+EOF
+echo "xbstream data"
+echo "200601 10:00:05 completed OK!" >&2
+`
+
+// mockArchiveBackup creates a backup with the xtrabackup replaced by a script.
+func mockArchiveBackup(t *testing.T) (*Backup, func()) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmp, err := ioutil.TempDir("", "xenon-archive")
+	assert.Nil(t, err)
+
+	bindir := filepath.Join(tmp, "bin")
+	os.MkdirAll(bindir, 0755)
+	err = ioutil.WriteFile(filepath.Join(bindir, "xtrabackup"), []byte(mockXtrabackup), 0755)
+	assert.Nil(t, err)
+
+	conf := config.DefaultBackupConfig()
+	conf.XtrabackupBinDir = bindir
+	conf.BackupDir = filepath.Join(tmp, "data")
+	conf.ArchiveDir = filepath.Join(tmp, "archive")
+	return NewBackup(conf, log), func() {
+		os.RemoveAll(tmp)
+	}
+}
+
+// mockArchive writes a fake archive with the manifest into the archive-dir.
+func mockArchive(t *testing.T, b *Backup, manifest *model.BackupManifest) {
+	dir := filepath.Join(b.conf.ArchiveDir, manifest.ID)
+	assert.Nil(t, os.MkdirAll(dir, 0755))
+	manifest.Archive = filepath.Join(dir, archiveFile)
+	assert.Nil(t, ioutil.WriteFile(manifest.Archive, []byte(manifest.ID), 0644))
+	size, checksum, err := fileChecksum(manifest.Archive)
+	assert.Nil(t, err)
+	manifest.Size = size
+	manifest.Checksum = checksum
+	assert.Nil(t, writeManifest(dir, manifest))
+}
+
+func mockManifest(t *testing.T, b *Backup, id string, end time.Time) {
+	mockArchive(t, b, &model.BackupManifest{ID: id, Type: model.BACKUP_TYPE_FULL, End: end.Unix()})
+}
+
+// mockChain writes a full and the incrementals on it, each one covers 100 LSNs.
+func mockChain(t *testing.T, b *Backup, ids ...string) {
+	for i, id := range ids {
+		manifest := &model.BackupManifest{
+			ID:      id,
+			Type:    model.BACKUP_TYPE_FULL,
+			End:     time.Now().Unix(),
+			FromLSN: uint64(i * 100),
+			ToLSN:   uint64((i + 1) * 100),
+		}
+		if i > 0 {
+			manifest.Type = model.BACKUP_TYPE_INCREMENTAL
+			manifest.BaseID = ids[i-1]
+		}
+		mockArchive(t, b, manifest)
+	}
+}
+
+func TestLocalBackupCommand(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := config.DefaultBackupConfig()
+	backup := NewBackup(conf, log)
+
+	// full
+	{
+		got := backup.localBackupCommands("/u01/backup_archive/20200601100000", "", 0)
+		want := []string{
+			"-c",
+			"set -o pipefail; ./xtrabackup --defaults-file=/etc/my3306.cnf --host=localhost --port=3306 --user=root --backup --throttle=100000 --parallel=2 --stream=xbstream --extra-lsndir=/u01/backup_archive/20200601100000 --target-dir=/u01/backup_archive/20200601100000 | gzip > /u01/backup_archive/20200601100000/backup.xbstream.gz",
+		}
+		assert.Equal(t, want, got)
+	}
+
+	// incremental on the basedir
+	{
+		got := backup.localBackupCommands("/u01/backup_archive/20200601110000", "/u01/backup_archive/20200601100000", 2617133)
+		want := []string{
+			"-c",
+			"set -o pipefail; ./xtrabackup --defaults-file=/etc/my3306.cnf --host=localhost --port=3306 --user=root --backup --throttle=100000 --parallel=2 --stream=xbstream --incremental-basedir=/u01/backup_archive/20200601100000 --extra-lsndir=/u01/backup_archive/20200601110000 --target-dir=/u01/backup_archive/20200601110000 | gzip > /u01/backup_archive/20200601110000/backup.xbstream.gz",
+		}
+		assert.Equal(t, want, got)
+	}
+
+	// incremental on the lsn
+	{
+		got := backup.localBackupCommands("/u01/backup_archive/20200601110000", "", 2617133)
+		want := []string{
+			"-c",
+			"set -o pipefail; ./xtrabackup --defaults-file=/etc/my3306.cnf --host=localhost --port=3306 --user=root --backup --throttle=100000 --parallel=2 --stream=xbstream --incremental-lsn=2617133 --extra-lsndir=/u01/backup_archive/20200601110000 --target-dir=/u01/backup_archive/20200601110000 | gzip > /u01/backup_archive/20200601110000/backup.xbstream.gz",
+		}
+		assert.Equal(t, want, got)
+	}
+}
+
+func TestLocalBackup(t *testing.T) {
+	backup, cleanup := mockArchiveBackup(t)
+	defer cleanup()
+
+	// incremental without any archive is full
+	manifest, err := backup.LocalBackup(true)
+	assert.Nil(t, err)
+	assert.Equal(t, model.BACKUP_TYPE_FULL, manifest.Type)
+	assert.Equal(t, "", manifest.BaseID)
+	assert.Equal(t, "mysql-bin.000002", manifest.BinlogFile)
+	assert.Equal(t, uint64(154), manifest.BinlogPos)
+	assert.Equal(t, "4b4b2a49-1b20-11e8-8c4d-525400a36c5e:1-10,4b4b2a49-1b20-11e8-8c4d-525400a36c5f:1-3", manifest.GTID)
+	assert.Equal(t, uint64(0), manifest.FromLSN)
+	assert.Equal(t, uint64(1000), manifest.ToLSN)
+	assert.Equal(t, model.MYSQLD_BACKUPNONE, backup.getStatus())
+	assert.Equal(t, uint64(1), backup.getStats().Backups)
+
+	// the checksum is the sha256 of the archive
+	size, checksum, err := fileChecksum(filepath.Join(backup.conf.ArchiveDir, manifest.ID, archiveFile))
+	assert.Nil(t, err)
+	assert.True(t, size > 0)
+	assert.Equal(t, size, manifest.Size)
+	assert.Equal(t, checksum, manifest.Checksum)
+
+	// list and show
+	{
+		manifests, err := backup.ListArchives()
+		assert.Nil(t, err)
+		assert.Equal(t, []*model.BackupManifest{manifest}, manifests)
+
+		got, err := backup.ShowArchive(manifest.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, manifest, got)
+	}
+
+	// delete
+	{
+		err := backup.DeleteArchive("../" + manifest.ID)
+		assert.NotNil(t, err)
+
+		err = backup.DeleteArchive(manifest.ID)
+		assert.Nil(t, err)
+		_, err = backup.ShowArchive(manifest.ID)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestLocalBackupError(t *testing.T) {
+	backup, cleanup := mockArchiveBackup(t)
+	defer cleanup()
+
+	err := ioutil.WriteFile(filepath.Join(backup.conf.XtrabackupBinDir, "xtrabackup"), []byte("#!/bin/bash\necho 'xtrabackup: error' >&2\nexit 1\n"), 0755)
+	assert.Nil(t, err)
+
+	_, err = backup.LocalBackup(false)
+	assert.NotNil(t, err)
+	assert.Equal(t, uint64(1), backup.getStats().BackupErrs)
+
+	// the broken archive is removed
+	dirs, _ := ioutil.ReadDir(backup.conf.ArchiveDir)
+	assert.Equal(t, 0, len(dirs))
+}
+
+func TestPruneArchives(t *testing.T) {
+	backup, cleanup := mockArchiveBackup(t)
+	defer cleanup()
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		mockManifest(t, backup, fmt.Sprintf("2020060110000%d", i), now.AddDate(0, 0, i-4))
+	}
+
+	// by count
+	{
+		backup.conf.RetentionCount = 3
+		backup.conf.RetentionDays = 0
+		pruned, err := backup.PruneArchives()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"20200601100000", "20200601100001"}, pruned)
+	}
+
+	// by age
+	{
+		backup.conf.RetentionCount = 0
+		backup.conf.RetentionDays = 1
+		pruned, err := backup.PruneArchives()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"20200601100002"}, pruned)
+
+		manifests, err := backup.ListArchives()
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(manifests))
+	}
+}
+
+func TestParseBackupInfoWithoutGTID(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "xenon-archive")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmp)
+
+	ioutil.WriteFile(filepath.Join(tmp, "xtrabackup_info"), []byte("binlog_pos = filename 'mysql-bin.000003', position '1024'\n"), 0644)
+	ioutil.WriteFile(filepath.Join(tmp, "xtrabackup_checkpoints"), []byte("from_lsn = 100\nto_lsn = 200\n"), 0644)
+
+	manifest := &model.BackupManifest{}
+	err = parseBackupInfo(tmp, manifest)
+	assert.Nil(t, err)
+	want := &model.BackupManifest{BinlogFile: "mysql-bin.000003", BinlogPos: 1024, FromLSN: 100, ToLSN: 200}
+	assert.Equal(t, want, manifest)
+}
+
+func TestLocalBackupIncremental(t *testing.T) {
+	backup, cleanup := mockArchiveBackup(t)
+	defer cleanup()
+
+	base := &model.BackupManifest{ID: "20200601100000", Type: model.BACKUP_TYPE_FULL, End: time.Now().Unix(), ToLSN: 2617133}
+	mockArchive(t, backup, base)
+
+	// on the lsn, the base has no checkpoints
+	{
+		manifest, err := backup.LocalBackup(true)
+		assert.Nil(t, err)
+		assert.Equal(t, model.BACKUP_TYPE_INCREMENTAL, manifest.Type)
+		assert.Equal(t, base.ID, manifest.BaseID)
+		assert.Equal(t, uint64(2617133), manifest.FromLSN)
+		assert.Equal(t, uint64(2618133), manifest.ToLSN)
+		assert.Contains(t, backup.getLastCMD(), "--incremental-lsn=2617133")
+
+		chain, err := backup.ArchiveChain(manifest.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{base.ID, manifest.ID}, []string{chain[0].ID, chain[1].ID})
+		assert.Nil(t, backup.DeleteArchive(manifest.ID))
+	}
+
+	// on the basedir checkpoints
+	{
+		checkpoints := "backup_type = full-backuped\nfrom_lsn = 0\nto_lsn = 2617133\n"
+		err := ioutil.WriteFile(filepath.Join(backup.conf.ArchiveDir, base.ID, "xtrabackup_checkpoints"), []byte(checkpoints), 0644)
+		assert.Nil(t, err)
+
+		manifest, err := backup.LocalBackup(true)
+		assert.Nil(t, err)
+		assert.Equal(t, model.BACKUP_TYPE_INCREMENTAL, manifest.Type)
+		assert.Equal(t, uint64(2617133), manifest.FromLSN)
+		assert.Contains(t, backup.getLastCMD(), "--incremental-basedir="+filepath.Join(backup.conf.ArchiveDir, base.ID))
+	}
+}
+
+func TestLocalBackupCompressEncrypt(t *testing.T) {
+	backup, cleanup := mockArchiveBackup(t)
+	defer cleanup()
+
+	base := &model.BackupManifest{ID: "20200601100000", Type: model.BACKUP_TYPE_FULL, End: time.Now().Unix(), ToLSN: 2617133}
+	mockArchive(t, backup, base)
+	backup.conf.Compress = model.BACKUP_COMPRESS_ZSTD
+	backup.conf.Encrypt = "AES256"
+	backup.conf.EncryptKeyFile = "/etc/xenon/backup.key"
+
+	// the base is not compressed, take a full
+	manifest, err := backup.LocalBackup(true)
+	assert.Nil(t, err)
+	assert.Equal(t, model.BACKUP_TYPE_FULL, manifest.Type)
+	assert.Equal(t, filepath.Join(backup.conf.ArchiveDir, manifest.ID, archiveRawFile), manifest.Archive)
+	assert.Equal(t, model.BACKUP_COMPRESS_ZSTD, manifest.Compress)
+	assert.Equal(t, "AES256", manifest.Encrypt)
+	assert.Equal(t, "/etc/xenon/backup.key", manifest.EncryptKeyFile)
+	assert.Contains(t, backup.getLastCMD(), "--compress=zstd --compress-threads=2 --encrypt=AES256 --encrypt-key-file=/etc/xenon/backup.key --encrypt-threads=2")
+	assert.Contains(t, backup.getLastCMD(), "| cat > "+manifest.Archive)
+
+	// restore with the options in the manifest
+	{
+		target := filepath.Join(filepath.Dir(backup.conf.ArchiveDir), "restore")
+		cmd := common.NewMockReplayCommand([]string{"completed OK!", "completed OK!", "completed OK!"})
+		backup.SetCMDHandler(cmd)
+		backup.conf.Compress = ""
+		backup.conf.Encrypt = ""
+
+		err := backup.RestoreArchive(manifest.ID, target)
+		assert.Nil(t, err)
+		calls := cmd.Calls()
+		assert.Equal(t, 2, len(calls))
+		assert.Equal(t, fmt.Sprintf("set -o pipefail; mkdir -p %s && cat %s | %s/xbstream -x -C %s", target, manifest.Archive, backup.conf.XtrabackupBinDir, target), calls[0][1])
+		assert.Contains(t, calls[1][1], "--decrypt=AES256 --encrypt-key-file=/etc/xenon/backup.key")
+		assert.Contains(t, calls[1][1], "--decompress")
+	}
+
+	// the key file is not set
+	{
+		backup.conf.Encrypt = "AES256"
+		backup.conf.EncryptKeyFile = ""
+		_, err := backup.LocalBackup(false)
+		assert.Equal(t, "backup.encrypt[AES256].key.file.is.empty", err.Error())
+	}
+}
+
+func TestArchiveChain(t *testing.T) {
+	backup, cleanup := mockArchiveBackup(t)
+	defer cleanup()
+
+	mockChain(t, backup, "20200601100000", "20200601110000", "20200601120000")
+
+	// complete
+	{
+		chain, err := backup.ArchiveChain("20200601120000")
+		assert.Nil(t, err)
+		var got []string
+		for _, manifest := range chain {
+			got = append(got, manifest.ID)
+		}
+		assert.Equal(t, []string{"20200601100000", "20200601110000", "20200601120000"}, got)
+	}
+
+	// the base can't be deleted
+	{
+		err := backup.DeleteArchive("20200601110000")
+		assert.Equal(t, "archive[20200601110000].is.the.base.of.incremental[20200601120000]", err.Error())
+	}
+
+	// the lsn is not continuous
+	{
+		mockArchive(t, backup, &model.BackupManifest{ID: "20200601130000", Type: model.BACKUP_TYPE_INCREMENTAL, BaseID: "20200601120000", FromLSN: 400, ToLSN: 500})
+		_, err := backup.ArchiveChain("20200601130000")
+		assert.Equal(t, "archive[20200601130000].chain.broken[20200601120000.to.lsn[300]!=20200601130000.from.lsn[400]]", err.Error())
+	}
+
+	// the base is missing
+	{
+		os.RemoveAll(filepath.Join(backup.conf.ArchiveDir, "20200601100000"))
+		_, err := backup.ArchiveChain("20200601120000")
+		assert.Equal(t, "archive[20200601120000].chain.broken[base[20200601100000].not.found]", err.Error())
+	}
+}
+
+func TestPruneArchivesChain(t *testing.T) {
+	backup, cleanup := mockArchiveBackup(t)
+	defer cleanup()
+
+	mockChain(t, backup, "20200601100000", "20200601110000", "20200601120000")
+	mockChain(t, backup, "20200602100000", "20200602110000")
+	mockChain(t, backup, "20200603100000")
+
+	backup.conf.RetentionCount = 2
+	backup.conf.RetentionDays = 0
+	pruned, err := backup.PruneArchives()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20200601120000", "20200601110000", "20200601100000"}, pruned)
+
+	manifests, err := backup.ListArchives()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(manifests))
+}
+
+func TestRestoreArchive(t *testing.T) {
+	backup, cleanup := mockArchiveBackup(t)
+	defer cleanup()
+
+	mockChain(t, backup, "20200601100000", "20200601110000", "20200601120000")
+	target := filepath.Join(filepath.Dir(backup.conf.ArchiveDir), "restore")
+	incdir := target + "-incremental"
+
+	// the recorded outputs of preparing the full and the 2 incrementals
+	outs := []string{
+		"xtrabackup: starting shutdown with innodb_fast_shutdown = 1",
+		"200601 10:00:01 completed OK!",
+		"xtrabackup: page size for ./ibdata1.delta is 16384 bytes",
+		"200601 10:00:02 completed OK!",
+		"InnoDB: Shutdown completed; log sequence number 300",
+		"200601 10:00:03 completed OK!",
+	}
+	cmd := common.NewMockReplayCommand(outs)
+	backup.SetCMDHandler(cmd)
+
+	err := backup.RestoreArchive("20200601120000", target)
+	assert.Nil(t, err)
+	calls := cmd.Calls()
+	assert.Equal(t, 4, len(calls))
+
+	want := []string{
+		fmt.Sprintf("set -o pipefail; mkdir -p %s && gunzip -c %s/20200601100000/backup.xbstream.gz | %s/xbstream -x -C %s", target, backup.conf.ArchiveDir, backup.conf.XtrabackupBinDir, target),
+		fmt.Sprintf("set -o pipefail; mkdir -p %s/20200601110000 && gunzip -c %s/20200601110000/backup.xbstream.gz | %s/xbstream -x -C %s/20200601110000", incdir, backup.conf.ArchiveDir, backup.conf.XtrabackupBinDir, incdir),
+		fmt.Sprintf("set -o pipefail; mkdir -p %s/20200601120000 && gunzip -c %s/20200601120000/backup.xbstream.gz | %s/xbstream -x -C %s/20200601120000", incdir, backup.conf.ArchiveDir, backup.conf.XtrabackupBinDir, incdir),
+	}
+	for i := range want {
+		assert.Equal(t, want[i], calls[i][1])
+	}
+	prepare := fmt.Sprintf("%s/xtrabackup --defaults-file=/etc/my3306.cnf --use-memory=2GB --prepare", backup.conf.XtrabackupBinDir)
+	applylog := fmt.Sprintf("%s --apply-log-only --target-dir=%s && %s --apply-log-only --target-dir=%s --incremental-dir=%s/20200601110000 && %s --target-dir=%s --incremental-dir=%s/20200601120000",
+		prepare, target, prepare, target, incdir, prepare, target, incdir)
+	assert.Equal(t, applylog, calls[3][1])
+	assert.Equal(t, model.BACKUP_PHASE_COMPLETED, backup.getStats().Progress.Phase)
+
+	// checksum mismatch
+	{
+		ioutil.WriteFile(filepath.Join(backup.conf.ArchiveDir, "20200601110000", archiveFile), []byte("broken"), 0644)
+		err := backup.RestoreArchive("20200601120000", filepath.Join(target, "x"))
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "archive[20200601110000].checksum")
+	}
+
+	// the target is not empty
+	{
+		os.MkdirAll(filepath.Join(target, "mysql"), 0755)
+		err := backup.RestoreArchive("20200601100000", target)
+		assert.Equal(t, fmt.Sprintf("restore.target.dir[%v].is.not.empty", target), err.Error())
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"fmt"
 	"model"
 	"strings"
+	"sync"
 	"time"
 	"xbase/common"
 	"xbase/xlog"
@@ -28,21 +29,28 @@ const (
 
 // Backup tuple.
 type Backup struct {
-	log    *xlog.Log
-	conf   *config.BackupConfig
-	cmd    common.Command
-	start  time.Time
-	status model.MYSQLD_STATUS
-	stats  model.BackupStats
+	log      *xlog.Log
+	conf     *config.BackupConfig
+	cmd      common.Command
+	start    time.Time
+	status   model.MYSQLD_STATUS
+	stats    model.BackupStats
+	progress *progress
+
+	// the streams prepared to receive by the xenon transport
+	streams     map[string]*streamJob
+	streamMutex sync.Mutex
 }
 
 // NewBackup creates new backup tuple.
 func NewBackup(conf *config.BackupConfig, log *xlog.Log) *Backup {
 	return &Backup{
-		conf:   conf,
-		log:    log,
-		cmd:    common.NewLinuxCommand(log),
-		status: model.MYSQLD_BACKUPNONE,
+		conf:     conf,
+		log:      log,
+		cmd:      common.NewLinuxCommand(log),
+		status:   model.MYSQLD_BACKUPNONE,
+		progress: newProgress(),
+		streams:  make(map[string]*streamJob),
 	}
 }
 
@@ -93,32 +101,63 @@ func (b *Backup) checkSSHTunnelWithKey(req *model.BackupRPCRequest) bool {
 	return true
 }
 
-func (b *Backup) backupCommands(iskey bool, req *model.BackupRPCRequest) []string {
-	var arg string
-	var backup string
-	var ssh string
-
+// xtrabackupCommand returns the xtrabackup command which streams the backup to stdout,
+// the caller appends the target options.
+func (b *Backup) xtrabackupCommand(iopsLimits int) string {
 	if b.conf.Passwd == "" {
-		backup = fmt.Sprintf("%s/xtrabackup --defaults-file=%s --host=%s --port=%d --user=%s --backup --throttle=%d --parallel=%d --stream=xbstream --target-dir=./",
+		return fmt.Sprintf("%s/xtrabackup --defaults-file=%s --host=%s --port=%d --user=%s --backup --throttle=%d --parallel=%d --stream=xbstream",
 			b.conf.XtrabackupBinDir,
 			b.conf.DefaultsFile,
 			b.conf.Host,
 			b.conf.Port,
 			b.conf.Admin,
-			req.IOPSLimits,
-			b.conf.Parallel)
-	} else {
-		backup = fmt.Sprintf("%s/xtrabackup --defaults-file=%s --host=%s --port=%d --user=%s --password=%s --backup --throttle=%d --parallel=%d --stream=xbstream --target-dir=./",
-			b.conf.XtrabackupBinDir,
-			b.conf.DefaultsFile,
-			b.conf.Host,
-			b.conf.Port,
-			b.conf.Admin,
-			b.conf.Passwd,
-			req.IOPSLimits,
+			iopsLimits,
 			b.conf.Parallel)
 	}
+	return fmt.Sprintf("%s/xtrabackup --defaults-file=%s --host=%s --port=%d --user=%s --password=%s --backup --throttle=%d --parallel=%d --stream=xbstream",
+		b.conf.XtrabackupBinDir,
+		b.conf.DefaultsFile,
+		b.conf.Host,
+		b.conf.Port,
+		b.conf.Admin,
+		b.conf.Passwd,
+		iopsLimits,
+		b.conf.Parallel)
+}
 
+// checkStreamOptions used to check the compression and encryption options.
+func checkStreamOptions(compress string, encrypt string, keyfile string) error {
+	switch compress {
+	case "", model.BACKUP_COMPRESS_ZSTD, model.BACKUP_COMPRESS_QPRESS:
+	default:
+		return errors.Errorf("backup.compress[%v].unsupported", compress)
+	}
+	if encrypt != "" && keyfile == "" {
+		return errors.Errorf("backup.encrypt[%v].key.file.is.empty", encrypt)
+	}
+	return nil
+}
+
+// streamOptions returns the xtrabackup options to compress and encrypt the stream.
+func (b *Backup) streamOptions(compress string, encrypt string, keyfile string) string {
+	var opts string
+	switch compress {
+	case model.BACKUP_COMPRESS_ZSTD:
+		opts += fmt.Sprintf(" --compress=zstd --compress-threads=%d", b.conf.Parallel)
+	case model.BACKUP_COMPRESS_QPRESS:
+		opts += fmt.Sprintf(" --compress=quicklz --compress-threads=%d", b.conf.Parallel)
+	}
+	if encrypt != "" {
+		opts += fmt.Sprintf(" --encrypt=%s --encrypt-key-file=%s --encrypt-threads=%d", encrypt, keyfile, b.conf.Parallel)
+	}
+	return opts
+}
+
+func (b *Backup) backupCommands(iskey bool, req *model.BackupRPCRequest) []string {
+	var arg string
+	var ssh string
+
+	backup := fmt.Sprintf("%s%s --target-dir=./", b.xtrabackupCommand(req.IOPSLimits), b.streamOptions(req.Compress, req.Encrypt, req.EncryptKeyFile))
 	if iskey {
 		ssh = fmt.Sprintf("ssh -o 'StrictHostKeyChecking=no' %s@%s -p %d \"%s/xbstream -x -C %s\"",
 			req.SSHUser,
@@ -151,6 +190,15 @@ func (b *Backup) Backup(req *model.BackupRPCRequest) error {
 	if b.getStatus() == model.MYSQLD_BACKUPING {
 		return errors.New("do.backup.error[backup.job.is.already.running]")
 	}
+	if err := checkBackupMethod(req); err != nil {
+		return err
+	}
+	if err := checkStreamOptions(req.Compress, req.Encrypt, req.EncryptKeyFile); err != nil {
+		return err
+	}
+	if req.Rate > 0 {
+		return errors.Errorf("backup.rate[%v].requires.the.xenon.transport", req.Rate)
+	}
 
 	// check ssh tunnel
 	var sshPasswdOK, sshKeyOK bool
@@ -167,13 +215,31 @@ func (b *Backup) Backup(req *model.BackupRPCRequest) error {
 	}
 	log.Warning("backup.check.ssh[%v].tunnel.done", sshKeyOK)
 
+	if isLogicalMethod(req.Method) {
+		return b.runBackup(b.dumpCommands(sshKeyOK, req), nil)
+	}
+	return b.runBackup(b.backupCommands(sshKeyOK, req), nil)
+}
+
+// runBackup runs the backup command and scans the outputs until completed,
+// the stdout is written to the stream if it is not nil.
+func (b *Backup) runBackup(args []string, stream *streamWriter) error {
+	log := b.log
+
 	b.start = time.Now()
 	b.setStatus(model.MYSQLD_BACKUPING)
 
-	args := b.backupCommands(sshKeyOK, req)
+	b.progress.reset(model.BACKUP_PHASE_NONE, b.conf.BackupDir, datadirSize(b.conf.BackupDir))
+
 	b.setLastCMD(strings.Join(args, " "))
 	log.Warning("backup.cmd[%s]", b.getLastCMD())
-	if err := b.cmd.Run(bash, args); err != nil {
+	var err error
+	if stream == nil {
+		err = b.cmd.Run(bash, args)
+	} else {
+		err = b.cmd.RunStream(bash, args, stream)
+	}
+	if err != nil {
 		b.setLastError(err.Error())
 		b.setStatus(model.MYSQLD_BACKUPNONE)
 		b.IncBackupErrs()
@@ -181,7 +247,11 @@ func (b *Backup) Backup(req *model.BackupRPCRequest) error {
 		return err
 	}
 
-	if err := b.cmd.Scan(backupOk, backupOkCheckTimes); err != nil {
+	err = b.cmd.ScanFunc(backupOk, backupOkCheckTimes, b.progress.parse)
+	if err == nil && stream != nil {
+		err = stream.finish()
+	}
+	if err != nil {
 		b.setLastError(err.Error())
 		b.setStatus(model.MYSQLD_BACKUPNONE)
 		b.IncBackupErrs()
@@ -203,12 +273,51 @@ func (b *Backup) Cancel() error {
 	return b.cmd.Kill()
 }
 
+// applylogCommands returns the prepare command of the backupdir, if there are incrementals,
+// the base and all the incrementals but the last are prepared with --apply-log-only.
+// The encrypted and compressed backups are decrypted and decompressed before prepared.
 func (b *Backup) applylogCommands(req *model.BackupRPCRequest) []string {
-	arg := fmt.Sprintf("%s/xtrabackup --defaults-file=%s --use-memory=%s --prepare --target-dir=%s", b.conf.XtrabackupBinDir, b.conf.DefaultsFile, b.conf.UseMemory, req.BackupDir)
+	var cmds []string
+	xtrabackup := fmt.Sprintf("%s/xtrabackup", b.conf.XtrabackupBinDir)
+	for _, dir := range append([]string{req.BackupDir}, req.IncrementalDirs...) {
+		if req.Encrypt != "" {
+			cmds = append(cmds, fmt.Sprintf("%s --decrypt=%s --encrypt-key-file=%s --parallel=%d --remove-original --target-dir=%s", xtrabackup, req.Encrypt, req.EncryptKeyFile, b.conf.Parallel, dir))
+		}
+		if req.Compress != "" {
+			cmds = append(cmds, fmt.Sprintf("%s --decompress --parallel=%d --remove-original --target-dir=%s", xtrabackup, b.conf.Parallel, dir))
+		}
+	}
+
+	prepare := fmt.Sprintf("%s --defaults-file=%s --use-memory=%s --prepare", xtrabackup, b.conf.DefaultsFile, b.conf.UseMemory)
+	if len(req.IncrementalDirs) == 0 {
+		cmds = append(cmds, fmt.Sprintf("%s --target-dir=%s", prepare, req.BackupDir))
+	} else {
+		cmds = append(cmds, fmt.Sprintf("%s --apply-log-only --target-dir=%s", prepare, req.BackupDir))
+		for i, dir := range req.IncrementalDirs {
+			if i < len(req.IncrementalDirs)-1 {
+				cmds = append(cmds, fmt.Sprintf("%s --apply-log-only --target-dir=%s --incremental-dir=%s", prepare, req.BackupDir, dir))
+			} else {
+				cmds = append(cmds, fmt.Sprintf("%s --target-dir=%s --incremental-dir=%s", prepare, req.BackupDir, dir))
+			}
+		}
+	}
 	return []string{
 		"-c",
-		arg,
+		strings.Join(cmds, " && "),
+	}
+}
+
+// applylogSteps returns the number of the xtrabackup runs in applylogCommands,
+// each one says completed OK! when it's done.
+func applylogSteps(req *model.BackupRPCRequest) int {
+	steps := 1
+	if req.Encrypt != "" {
+		steps++
 	}
+	if req.Compress != "" {
+		steps++
+	}
+	return steps * (len(req.IncrementalDirs) + 1)
 }
 
 // ApplyLog used to apply log from backupdir.
@@ -220,8 +329,12 @@ func (b *Backup) ApplyLog(req *model.BackupRPCRequest) error {
 		b.getStatus() == model.MYSQLD_APPLYLOGGING {
 		return errors.New("applylog.error[backup/applylog.already.running]")
 	}
+	if err := checkStreamOptions(req.Compress, req.Encrypt, req.EncryptKeyFile); err != nil {
+		return err
+	}
 
 	b.setStatus(model.MYSQLD_APPLYLOGGING)
+	b.progress.reset(model.BACKUP_PHASE_PREPARING, req.BackupDir, 0)
 
 	args := b.applylogCommands(req)
 	log.Warning("applylog.cmd[%s]", strings.Join(args, " "))
@@ -233,7 +346,18 @@ func (b *Backup) ApplyLog(req *model.BackupRPCRequest) error {
 		return err
 	}
 
-	if err := b.cmd.Scan(backupOk, backupOkCheckTimes); err != nil {
+	// each run says completed, only the last one completes the progress
+	steps := applylogSteps(req)
+	var completed int
+	parse := func(line string) {
+		if strings.Contains(line, backupOk) {
+			if completed++; completed < steps {
+				return
+			}
+		}
+		b.progress.parse(line)
+	}
+	if err := b.cmd.ScanFunc(backupOk, backupOkCheckTimes*steps, parse); err != nil {
 		b.setLastError(err.Error())
 		log.Error("applylog.cmd.scan.error[%+v]", err)
 		b.setStatus(model.MYSQLD_BACKUPNONE)
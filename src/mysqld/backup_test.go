@@ -120,6 +120,126 @@ func TestApplyLog(t *testing.T) {
 	}
 }
 
+func TestApplyLogIncremental(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := config.DefaultBackupConfig()
+	backup := NewBackup(conf, log)
+
+	req := model.NewBackupRPCRequest()
+	req.BackupDir = "/tmp/xtrabackup_test"
+	req.IncrementalDirs = []string{"/tmp/xtrabackup_inc1", "/tmp/xtrabackup_inc2"}
+	// test commands
+	{
+		got := backup.applylogCommands(req)
+		want := []string{
+			"-c",
+			"./xtrabackup --defaults-file=/etc/my3306.cnf --use-memory=2GB --prepare --apply-log-only --target-dir=/tmp/xtrabackup_test && " +
+				"./xtrabackup --defaults-file=/etc/my3306.cnf --use-memory=2GB --prepare --apply-log-only --target-dir=/tmp/xtrabackup_test --incremental-dir=/tmp/xtrabackup_inc1 && " +
+				"./xtrabackup --defaults-file=/etc/my3306.cnf --use-memory=2GB --prepare --target-dir=/tmp/xtrabackup_test --incremental-dir=/tmp/xtrabackup_inc2",
+		}
+		assert.Equal(t, want, got)
+	}
+
+	// the last incremental is not completed
+	{
+		outs := []string{
+			"200601 10:00:01 completed OK!",
+			"InnoDB: Progress in percent: 0 10 20",
+			"200601 10:00:02 completed OK!",
+			"InnoDB: Progress in percent: 0 10 20 30",
+			"xtrabackup: error: applying the delta failed",
+		}
+		backup.SetCMDHandler(common.NewMockReplayCommand(outs))
+		err := backup.ApplyLog(req)
+		assert.Equal(t, "cmd.outs.[completed OK!].found[2]!=expects[3]", err.Error())
+
+		progress := backup.getStats().Progress
+		assert.Equal(t, model.BACKUP_PHASE_PREPARING, progress.Phase)
+		assert.Equal(t, float64(30), progress.Percent)
+	}
+}
+
+func TestBackupCompressEncrypt(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := config.DefaultBackupConfig()
+	backup := NewBackup(conf, log)
+
+	req := model.NewBackupRPCRequest()
+	req.BackupDir = "/u01/backup"
+	req.XtrabackupBinDir = "/u01/xtrabackup_20161216"
+	req.SSHUser = "user"
+	req.SSHHost = "127.0.0.1"
+	req.SSHPort = 22
+	req.IOPSLimits = 100
+	req.Compress = model.BACKUP_COMPRESS_ZSTD
+	req.Encrypt = "AES256"
+	req.EncryptKeyFile = "/etc/xenon/backup.key"
+
+	// test xtrabackup commands
+	{
+		got := backup.backupCommands(true, req)
+		want := []string{
+			"-c",
+			"./xtrabackup --defaults-file=/etc/my3306.cnf --host=localhost --port=3306 --user=root --backup --throttle=100 --parallel=2 --stream=xbstream --compress=zstd --compress-threads=2 --encrypt=AES256 --encrypt-key-file=/etc/xenon/backup.key --encrypt-threads=2 --target-dir=./ | ssh -o 'StrictHostKeyChecking=no' user@127.0.0.1 -p 22 \"/u01/xtrabackup_20161216/xbstream -x -C /u01/backup\"",
+		}
+		assert.Equal(t, want, got)
+
+		req.Compress = model.BACKUP_COMPRESS_QPRESS
+		req.Encrypt = ""
+		got = backup.backupCommands(true, req)
+		want = []string{
+			"-c",
+			"./xtrabackup --defaults-file=/etc/my3306.cnf --host=localhost --port=3306 --user=root --backup --throttle=100 --parallel=2 --stream=xbstream --compress=quicklz --compress-threads=2 --target-dir=./ | ssh -o 'StrictHostKeyChecking=no' user@127.0.0.1 -p 22 \"/u01/xtrabackup_20161216/xbstream -x -C /u01/backup\"",
+		}
+		assert.Equal(t, want, got)
+	}
+
+	// test applylog commands
+	{
+		req.Compress = model.BACKUP_COMPRESS_ZSTD
+		req.Encrypt = "AES256"
+		req.IncrementalDirs = []string{"/u01/inc1"}
+		got := backup.applylogCommands(req)
+		want := []string{
+			"-c",
+			"./xtrabackup --decrypt=AES256 --encrypt-key-file=/etc/xenon/backup.key --parallel=2 --remove-original --target-dir=/u01/backup && " +
+				"./xtrabackup --decompress --parallel=2 --remove-original --target-dir=/u01/backup && " +
+				"./xtrabackup --decrypt=AES256 --encrypt-key-file=/etc/xenon/backup.key --parallel=2 --remove-original --target-dir=/u01/inc1 && " +
+				"./xtrabackup --decompress --parallel=2 --remove-original --target-dir=/u01/inc1 && " +
+				"./xtrabackup --defaults-file=/etc/my3306.cnf --use-memory=2GB --prepare --apply-log-only --target-dir=/u01/backup && " +
+				"./xtrabackup --defaults-file=/etc/my3306.cnf --use-memory=2GB --prepare --target-dir=/u01/backup --incremental-dir=/u01/inc1",
+		}
+		assert.Equal(t, want, got)
+		assert.Equal(t, 6, applylogSteps(req))
+	}
+
+	// each run says completed
+	{
+		req.IncrementalDirs = nil
+		outs := []string{
+			"200601 10:00:01 completed OK!",
+			"200601 10:00:02 completed OK!",
+			"200601 10:00:03 completed OK!",
+		}
+		backup.SetCMDHandler(common.NewMockReplayCommand(outs))
+		err := backup.ApplyLog(req)
+		assert.Nil(t, err)
+		assert.Equal(t, model.BACKUP_PHASE_COMPLETED, backup.getStats().Progress.Phase)
+	}
+
+	// bad options
+	{
+		req.Compress = "lz4"
+		err := backup.ApplyLog(req)
+		assert.Equal(t, "backup.compress[lz4].unsupported", err.Error())
+
+		req.Compress = ""
+		req.EncryptKeyFile = ""
+		err = backup.Backup(req)
+		assert.Equal(t, "backup.encrypt[AES256].key.file.is.empty", err.Error())
+	}
+}
+
 func TestCheckSSH(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
 	conf := config.DefaultBackupConfig()
@@ -0,0 +1,405 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysqld
+
+import (
+	"config"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	mysqlbinlog = "bin/mysqlbinlog"
+	mysqlclient = "bin/mysql"
+
+	replayDatetimeLayout = "2006-01-02 15:04:05"
+
+	// the max GNO of a GTID in mysql
+	maxGNO = 9223372036854775806
+)
+
+// BinlogLister used to get the binlogs of the local mysql.
+type BinlogLister interface {
+	GetBinlogBasename() (string, error)
+	GetBinaryLogs() ([]string, error)
+}
+
+// BinlogArchiver used to copy the closed binlogs into the binlog-archive-dir,
+// so that they are kept for the point-in-time restore after the leader purged them.
+type BinlogArchiver struct {
+	log     *xlog.Log
+	conf    *config.BackupConfig
+	lister  BinlogLister
+	mutex   sync.Mutex
+	ticker  *time.Ticker
+	running bool
+}
+
+// NewBinlogArchiver creates the new BinlogArchiver.
+func NewBinlogArchiver(conf *config.BackupConfig, log *xlog.Log) *BinlogArchiver {
+	return &BinlogArchiver{
+		log:  log,
+		conf: conf,
+	}
+}
+
+// Start used to archive the closed binlogs on the binlog-archive-interval,
+// nothing to do if the binlog-archive-dir is empty.
+func (a *BinlogArchiver) Start() {
+	if a.conf.BinlogArchiveDir == "" {
+		a.log.Info("binlog.archiver.disabled")
+		return
+	}
+	if a.running {
+		return
+	}
+
+	a.ticker = common.NormalTicker(a.conf.BinlogArchiveInterval)
+	go func(ticker *time.Ticker) {
+		for range ticker.C {
+			if _, err := a.Archive(); err != nil {
+				a.log.Error("binlog.archiver.archive.error[%v]", err)
+			}
+		}
+	}(a.ticker)
+	a.running = true
+	a.log.Info("binlog.archiver[%v].start[%vms]...", a.conf.BinlogArchiveDir, a.conf.BinlogArchiveInterval)
+}
+
+// Stop used to stop the archive ticker.
+func (a *BinlogArchiver) Stop() {
+	if !a.running {
+		return
+	}
+	a.ticker.Stop()
+	a.running = false
+	a.log.Info("binlog.archiver.stop...")
+}
+
+// binlogs returns the binlog dir and the binlogs of the local mysql, the last one is the active.
+func (a *BinlogArchiver) binlogs() (string, []string, error) {
+	if a.lister == nil {
+		return "", nil, errors.New("binlog.archiver.lister.is.nil")
+	}
+	basename, err := a.lister.GetBinlogBasename()
+	if err != nil {
+		return "", nil, err
+	}
+	if basename == "" {
+		return "", nil, errors.New("binlog.archiver.log_bin_basename.is.empty")
+	}
+	binlogs, err := a.lister.GetBinaryLogs()
+	if err != nil {
+		return "", nil, err
+	}
+	return filepath.Dir(basename), binlogs, nil
+}
+
+// archived returns true if the binlog has been copied into the archive dir completely.
+func (a *BinlogArchiver) archived(dir string, name string) (bool, error) {
+	dst, err := os.Stat(filepath.Join(a.conf.BinlogArchiveDir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, errors.WithStack(err)
+	}
+	src, err := os.Stat(filepath.Join(dir, name))
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	if src.Size() != dst.Size() {
+		return false, errors.Errorf("binlog[%v].archived.size[%v].mismatch.source[%v]", name, dst.Size(), src.Size())
+	}
+	return true, nil
+}
+
+// Archive used to copy the closed binlogs which are not archived into the binlog-archive-dir,
+// returns the binlogs archived in this run.
+func (a *BinlogArchiver) Archive() ([]string, error) {
+	log := a.log
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.conf.BinlogArchiveDir == "" {
+		return nil, nil
+	}
+	dir, binlogs, err := a.binlogs()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(a.conf.BinlogArchiveDir, 0755); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var names []string
+	// The last one is the active binlog which is still being written.
+	for i := 0; i < len(binlogs)-1; i++ {
+		name := binlogs[i]
+		ok, err := a.archived(dir, name)
+		if err != nil {
+			return names, err
+		}
+		if ok {
+			continue
+		}
+		if err := copyBinlog(filepath.Join(dir, name), filepath.Join(a.conf.BinlogArchiveDir, name)); err != nil {
+			return names, err
+		}
+		names = append(names, name)
+		log.Info("binlog.archiver.archived[%v]", name)
+	}
+	return names, nil
+}
+
+// PurgeGuard returns the binlog which is safe to purge to, it never passes the first binlog which is not archived,
+// empty means nothing can be purged. It only reads the archive dir, the copying is left to the archive ticker.
+// The check is true for the purge plan, the limit isn't logged then.
+func (a *BinlogArchiver) PurgeGuard(next string, check bool) string {
+	log := a.log
+
+	if a.conf.BinlogArchiveDir == "" {
+		return next
+	}
+
+	dir, binlogs, err := a.binlogs()
+	if err != nil {
+		log.Error("binlog.archiver.purge.guard.error[%v]", err)
+		return ""
+	}
+	for _, name := range binlogs {
+		if strings.Compare(name, next) >= 0 {
+			break
+		}
+		if ok, err := a.archived(dir, name); err != nil || !ok {
+			if !check {
+				log.Warning("binlog.archiver.purge.to[%v].limited.to[%v].not.archived.error[%v]", next, name, err)
+			}
+			return name
+		}
+	}
+	return next
+}
+
+// copyBinlog copies the binlog to a temporary file and renames it,
+// so that a binlog in the archive dir is always completed.
+func copyBinlog(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return errors.WithStack(err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return errors.WithStack(err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(tmp, dst))
+}
+
+// binlogSequence splits the binlog name into the prefix and the sequence number,
+// such as mysql-bin.000002 to mysql-bin and 2.
+func binlogSequence(name string) (string, int, bool) {
+	idx := strings.LastIndex(name, ".")
+	if idx < 0 {
+		return "", 0, false
+	}
+	seq, err := strconv.Atoi(name[idx+1:])
+	if err != nil {
+		return "", 0, false
+	}
+	return name[:idx], seq, true
+}
+
+// archivedBinlogs returns the archived binlogs from the first one,
+// the binlogs must be continuous.
+func archivedBinlogs(dir string, first string) ([]string, error) {
+	prefix, firstSeq, ok := binlogSequence(first)
+	if !ok {
+		return nil, errors.Errorf("binlog[%v].invalid", first)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var seqs []int
+	for _, file := range files {
+		p, seq, ok := binlogSequence(file.Name())
+		if !ok || p != prefix || seq < firstSeq {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+	if len(seqs) == 0 || seqs[0] != firstSeq {
+		return nil, errors.Errorf("binlog[%v].is.not.archived.in[%v]", first, dir)
+	}
+
+	width := len(first) - len(prefix) - 1
+	var binlogs []string
+	for i, seq := range seqs {
+		if i > 0 && seq != seqs[i-1]+1 {
+			return nil, errors.Errorf("binlog.archive.is.not.continuous[%v.%0*d->%v.%0*d]", prefix, width, seqs[i-1], prefix, width, seq)
+		}
+		binlogs = append(binlogs, filepath.Join(dir, fmt.Sprintf("%s.%0*d", prefix, width, seq)))
+	}
+	return binlogs, nil
+}
+
+// gtidsAfter returns the GTIDs after the last one of each UUID in the GTID set,
+// such as "uuid:1-100:150" to "uuid:151-9223372036854775806".
+func gtidsAfter(set string) (string, error) {
+	var after []string
+	for _, uuidSet := range strings.Split(set, ",") {
+		parts := strings.Split(strings.TrimSpace(uuidSet), ":")
+		if len(parts) < 2 || parts[0] == "" {
+			return "", errors.Errorf("gtid.set[%v].invalid", set)
+		}
+		var last uint64
+		for _, interval := range parts[1:] {
+			for _, bound := range strings.SplitN(interval, "-", 2) {
+				gno, err := strconv.ParseUint(bound, 10, 64)
+				if err != nil || gno == 0 || gno > maxGNO {
+					return "", errors.Errorf("gtid.set[%v].invalid", set)
+				}
+				if gno > last {
+					last = gno
+				}
+			}
+		}
+		if last < maxGNO {
+			after = append(after, fmt.Sprintf("%s:%d-%d", parts[0], last+1, maxGNO))
+		}
+	}
+	return strings.Join(after, ","), nil
+}
+
+// replayCommands returns the mysqlbinlog command which replays the binlogs into the local mysql,
+// the start position only applies to the first binlog.
+// The transactions in excludeGTID are skipped, they are the ones after the stop GTID set.
+func (b *Backup) replayCommands(binlogs []string, pos uint64, stopDatetime string, excludeGTID string) []string {
+	var stop string
+	if stopDatetime != "" {
+		stop += fmt.Sprintf(" --stop-datetime='%s'", stopDatetime)
+	}
+	if excludeGTID != "" {
+		stop += fmt.Sprintf(" --exclude-gtids='%s'", excludeGTID)
+	}
+
+	client := fmt.Sprintf("%s --host=%s --port=%d --user=%s",
+		filepath.Join(b.conf.Basedir, mysqlclient),
+		b.conf.Host,
+		b.conf.Port,
+		b.conf.Admin)
+	if b.conf.Passwd != "" {
+		client += fmt.Sprintf(" --password=%s", b.conf.Passwd)
+	}
+
+	arg := fmt.Sprintf("set -o pipefail; %s --start-position=%d%s %s | %s",
+		filepath.Join(b.conf.Basedir, mysqlbinlog),
+		pos,
+		stop,
+		strings.Join(binlogs, " "),
+		client)
+	return []string{
+		"-c",
+		arg,
+	}
+}
+
+// CheckReplayStop used to check the stop datetime and the stop GTID set of the replay,
+// so the restore job fails before the datadir is touched.
+func CheckReplayStop(stopDatetime string, stopGTID string) error {
+	if stopDatetime == "" && stopGTID == "" {
+		return errors.New("replay.stop.datetime.and.gtid.are.both.empty")
+	}
+	if stopDatetime != "" {
+		if _, err := time.ParseInLocation(replayDatetimeLayout, stopDatetime, time.Local); err != nil {
+			return errors.Errorf("replay.stop.datetime[%v].invalid.want.format[%v]", stopDatetime, replayDatetimeLayout)
+		}
+	}
+	if stopGTID != "" {
+		if _, err := gtidsAfter(stopGTID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplayBinlogs used to replay the archived binlogs from the binlog position of the archive
+// into the local mysql, until the stop datetime or the stop GTID set is reached.
+// All the transactions up to the stop GTID set are replayed, whatever the UUIDs are,
+// the ones of its UUIDs after it are excluded.
+func (b *Backup) ReplayBinlogs(id string, stopDatetime string, stopGTID string) error {
+	log := b.log
+
+	if err := CheckReplayStop(stopDatetime, stopGTID); err != nil {
+		return err
+	}
+	var excludeGTID string
+	if stopGTID != "" {
+		var err error
+		if excludeGTID, err = gtidsAfter(stopGTID); err != nil {
+			return err
+		}
+	}
+	if b.conf.BinlogArchiveDir == "" {
+		return errors.New("binlog.archive.dir.is.empty")
+	}
+
+	manifest, err := b.ShowArchive(id)
+	if err != nil {
+		return err
+	}
+	if manifest.BinlogFile == "" {
+		return errors.Errorf("archive[%v].has.no.binlog.position", id)
+	}
+	binlogs, err := archivedBinlogs(b.conf.BinlogArchiveDir, manifest.BinlogFile)
+	if err != nil {
+		return err
+	}
+
+	args := b.replayCommands(binlogs, manifest.BinlogPos, stopDatetime, excludeGTID)
+	log.Warning("replay.binlogs.of.archive[%v].from[%v:%v].cmd[%s]", id, manifest.BinlogFile, manifest.BinlogPos, strings.Join(args, " "))
+	if outs, err := b.cmd.RunCommand(bash, args); err != nil {
+		log.Error("replay.binlogs.of.archive[%v].error[%v:%+v]", id, outs, err)
+		return err
+	}
+	log.Warning("replay.binlogs.of.archive[%v].to[datetime:%v, gtid:%v].done", id, stopDatetime, stopGTID)
+	return nil
+}
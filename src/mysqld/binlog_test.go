@@ -0,0 +1,210 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysqld
+
+import (
+	"config"
+	"fmt"
+	"io/ioutil"
+	"model"
+	"os"
+	"path/filepath"
+	"testing"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockBinlogLister struct {
+	basename string
+	binlogs  []string
+}
+
+func (l *mockBinlogLister) GetBinlogBasename() (string, error) {
+	return l.basename, nil
+}
+
+func (l *mockBinlogLister) GetBinaryLogs() ([]string, error) {
+	return l.binlogs, nil
+}
+
+// mockBinlogArchiver creates an archiver on the fake binlogs mysql-bin.000001~n.
+func mockBinlogArchiver(t *testing.T, n int) (*BinlogArchiver, *mockBinlogLister, func()) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tmp, err := ioutil.TempDir("", "xenon-binlog")
+	assert.Nil(t, err)
+
+	datadir := filepath.Join(tmp, "data")
+	assert.Nil(t, os.MkdirAll(datadir, 0755))
+	lister := &mockBinlogLister{basename: filepath.Join(datadir, "mysql-bin")}
+	for i := 1; i <= n; i++ {
+		name := fmt.Sprintf("mysql-bin.%06d", i)
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(datadir, name), []byte(name), 0644))
+		lister.binlogs = append(lister.binlogs, name)
+	}
+
+	conf := config.DefaultBackupConfig()
+	conf.BinlogArchiveDir = filepath.Join(tmp, "binlog")
+	archiver := NewBinlogArchiver(conf, log)
+	archiver.lister = lister
+	return archiver, lister, func() {
+		os.RemoveAll(tmp)
+	}
+}
+
+func TestBinlogArchiverArchive(t *testing.T) {
+	archiver, lister, cleanup := mockBinlogArchiver(t, 3)
+	defer cleanup()
+
+	// the active binlog is not archived
+	archived, err := archiver.Archive()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"mysql-bin.000001", "mysql-bin.000002"}, archived)
+	b, err := ioutil.ReadFile(filepath.Join(archiver.conf.BinlogArchiveDir, "mysql-bin.000002"))
+	assert.Nil(t, err)
+	assert.Equal(t, "mysql-bin.000002", string(b))
+
+	// nothing new
+	archived, err = archiver.Archive()
+	assert.Nil(t, err)
+	assert.Nil(t, archived)
+
+	// rotated
+	datadir := filepath.Dir(lister.basename)
+	ioutil.WriteFile(filepath.Join(datadir, "mysql-bin.000004"), []byte("mysql-bin.000004"), 0644)
+	lister.binlogs = append(lister.binlogs, "mysql-bin.000004")
+	archived, err = archiver.Archive()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"mysql-bin.000003"}, archived)
+
+	// the archived one is different from the source
+	ioutil.WriteFile(filepath.Join(archiver.conf.BinlogArchiveDir, "mysql-bin.000001"), []byte("x"), 0644)
+	_, err = archiver.Archive()
+	assert.Equal(t, "binlog[mysql-bin.000001].archived.size[1].mismatch.source[16]", err.Error())
+
+	// disabled
+	archiver.conf.BinlogArchiveDir = ""
+	archived, err = archiver.Archive()
+	assert.Nil(t, err)
+	assert.Nil(t, archived)
+}
+
+func TestBinlogArchiverPurgeGuard(t *testing.T) {
+	archiver, _, cleanup := mockBinlogArchiver(t, 4)
+	defer cleanup()
+
+	// nothing is archived, the guard never archives
+	assert.Equal(t, "mysql-bin.000001", archiver.PurgeGuard("mysql-bin.000003", false))
+	_, err := os.Stat(filepath.Join(archiver.conf.BinlogArchiveDir, "mysql-bin.000001"))
+	assert.True(t, os.IsNotExist(err))
+
+	// the closed binlogs are archived
+	_, err = archiver.Archive()
+	assert.Nil(t, err)
+	assert.Equal(t, "mysql-bin.000003", archiver.PurgeGuard("mysql-bin.000003", false))
+
+	// the unarchived binlog is never purged
+	ioutil.WriteFile(filepath.Join(archiver.conf.BinlogArchiveDir, "mysql-bin.000002"), []byte("x"), 0644)
+	assert.Equal(t, "mysql-bin.000002", archiver.PurgeGuard("mysql-bin.000004", false))
+
+	// the lister is not ready
+	archiver.lister = nil
+	assert.Equal(t, "", archiver.PurgeGuard("mysql-bin.000004", false))
+
+	// disabled
+	archiver.conf.BinlogArchiveDir = ""
+	assert.Equal(t, "mysql-bin.000004", archiver.PurgeGuard("mysql-bin.000004", false))
+}
+
+func TestArchivedBinlogs(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "xenon-binlog")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmp)
+
+	for _, name := range []string{"mysql-bin.000001", "mysql-bin.000002", "mysql-bin.000003", "mysql-bin.000004.tmp", "other-bin.000003"} {
+		ioutil.WriteFile(filepath.Join(tmp, name), []byte(name), 0644)
+	}
+
+	binlogs, err := archivedBinlogs(tmp, "mysql-bin.000002")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(tmp, "mysql-bin.000002"), filepath.Join(tmp, "mysql-bin.000003")}, binlogs)
+
+	_, err = archivedBinlogs(tmp, "mysql-bin.000005")
+	assert.Equal(t, fmt.Sprintf("binlog[mysql-bin.000005].is.not.archived.in[%v]", tmp), err.Error())
+
+	os.Remove(filepath.Join(tmp, "mysql-bin.000002"))
+	_, err = archivedBinlogs(tmp, "mysql-bin.000001")
+	assert.Equal(t, "binlog.archive.is.not.continuous[mysql-bin.000001->mysql-bin.000003]", err.Error())
+}
+
+func TestReplayBinlogs(t *testing.T) {
+	backup, cleanup := mockArchiveBackup(t)
+	defer cleanup()
+
+	backup.conf.Basedir = "/u01/mysql"
+	backup.conf.BinlogArchiveDir = filepath.Join(filepath.Dir(backup.conf.ArchiveDir), "binlog")
+	os.MkdirAll(backup.conf.BinlogArchiveDir, 0755)
+	for _, name := range []string{"mysql-bin.000001", "mysql-bin.000002", "mysql-bin.000003"} {
+		ioutil.WriteFile(filepath.Join(backup.conf.BinlogArchiveDir, name), []byte(name), 0644)
+	}
+	mockArchive(t, backup, &model.BackupManifest{ID: "20200601100000", Type: model.BACKUP_TYPE_FULL, BinlogFile: "mysql-bin.000002", BinlogPos: 154})
+
+	cmd := common.NewMockReplayCommand(nil)
+	backup.SetCMDHandler(cmd)
+	binlogs := fmt.Sprintf("%s/mysql-bin.000002 %s/mysql-bin.000003", backup.conf.BinlogArchiveDir, backup.conf.BinlogArchiveDir)
+	client := "/u01/mysql/bin/mysql --host=localhost --port=3306 --user=root"
+
+	// to time
+	{
+		err := backup.ReplayBinlogs("20200601100000", "2020-06-01 12:00:00", "")
+		assert.Nil(t, err)
+		want := fmt.Sprintf("set -o pipefail; /u01/mysql/bin/mysqlbinlog --start-position=154 --stop-datetime='2020-06-01 12:00:00' %s | %s", binlogs, client)
+		calls := cmd.Calls()
+		assert.Equal(t, want, calls[len(calls)-1][1])
+	}
+
+	// to gtid
+	{
+		err := backup.ReplayBinlogs("20200601100000", "", "4b4b2a49-1b20-11e8-8c4d-525400a36c5e:1-20")
+		assert.Nil(t, err)
+		want := fmt.Sprintf("set -o pipefail; /u01/mysql/bin/mysqlbinlog --start-position=154 --exclude-gtids='4b4b2a49-1b20-11e8-8c4d-525400a36c5e:21-9223372036854775806' %s | %s", binlogs, client)
+		calls := cmd.Calls()
+		assert.Equal(t, want, calls[len(calls)-1][1])
+	}
+
+	// to the gtid set of the UUIDs, the transactions of the other UUIDs are replayed
+	{
+		err := backup.ReplayBinlogs("20200601100000", "", "4b4b2a49-1b20-11e8-8c4d-525400a36c5e:1-20,\n84030605-66aa-11e6-9465-52540e7fd51c:1-5:8")
+		assert.Nil(t, err)
+		want := fmt.Sprintf("set -o pipefail; /u01/mysql/bin/mysqlbinlog --start-position=154 --exclude-gtids='4b4b2a49-1b20-11e8-8c4d-525400a36c5e:21-9223372036854775806,84030605-66aa-11e6-9465-52540e7fd51c:9-9223372036854775806' %s | %s", binlogs, client)
+		calls := cmd.Calls()
+		assert.Equal(t, want, calls[len(calls)-1][1])
+	}
+
+	// errors
+	{
+		err := backup.ReplayBinlogs("20200601100000", "", "")
+		assert.Equal(t, "replay.stop.datetime.and.gtid.are.both.empty", err.Error())
+
+		err = backup.ReplayBinlogs("20200601100000", "2020/06/01", "")
+		assert.Equal(t, "replay.stop.datetime[2020/06/01].invalid.want.format[2006-01-02 15:04:05]", err.Error())
+
+		err = backup.ReplayBinlogs("20200601100000", "", "4b4b2a49-1b20-11e8-8c4d-525400a36c5e:1-x")
+		assert.Equal(t, "gtid.set[4b4b2a49-1b20-11e8-8c4d-525400a36c5e:1-x].invalid", err.Error())
+
+		mockArchive(t, backup, &model.BackupManifest{ID: "20200601110000", Type: model.BACKUP_TYPE_FULL})
+		err = backup.ReplayBinlogs("20200601110000", "2020-06-01 12:00:00", "")
+		assert.Equal(t, "archive[20200601110000].has.no.binlog.position", err.Error())
+
+		backup.conf.BinlogArchiveDir = ""
+		err = backup.ReplayBinlogs("20200601100000", "2020-06-01 12:00:00", "")
+		assert.Equal(t, "binlog.archive.dir.is.empty", err.Error())
+	}
+}
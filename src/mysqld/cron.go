@@ -0,0 +1,150 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysqld
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	cronDescriptors = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+
+	// the bounds of minute, hour, day of month, month and day of week(0 and 7 are sunday)
+	cronBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+)
+
+// cronSchedule is a parsed 5-field cron expression:
+// minute hour day-of-month month day-of-week
+type cronSchedule struct {
+	fields [5]map[int]bool
+	domAll bool
+	dowAll bool
+}
+
+// parseCron parses the cron expression, the fields support '*', '*/n', 'a-b', 'a-b/n' and lists.
+func parseCron(spec string) (*cronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if desc, ok := cronDescriptors[spec]; ok {
+		spec = desc
+	}
+
+	items := strings.Fields(spec)
+	if len(items) != 5 {
+		return nil, errors.Errorf("cron.spec[%v].must.have.5.fields", spec)
+	}
+
+	s := &cronSchedule{}
+	for i, item := range items {
+		field, err := parseCronField(item, cronBounds[i][0], cronBounds[i][1])
+		if err != nil {
+			return nil, errors.Errorf("cron.spec[%v].field[%v].invalid[%v]", spec, item, err)
+		}
+		s.fields[i] = field
+	}
+	if s.fields[4][7] {
+		s.fields[4][0] = true
+	}
+	s.domAll = strings.HasPrefix(items[2], "*")
+	s.dowAll = strings.HasPrefix(items[4], "*")
+	return s, nil
+}
+
+func parseCronField(item string, min int, max int) (map[int]bool, error) {
+	field := make(map[int]bool)
+	for _, part := range strings.Split(item, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			n, err := strconv.Atoi(part[idx+1:])
+			if err != nil || n <= 0 {
+				return nil, errors.Errorf("step[%v].invalid", part)
+			}
+			step = n
+			part = part[:idx]
+		}
+
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, errors.Errorf("range[%v].invalid", part)
+			}
+			if hi, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, errors.Errorf("range[%v].invalid", part)
+			}
+		default:
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, errors.Errorf("value[%v].invalid", part)
+			}
+			lo, hi = n, n
+			if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, errors.Errorf("value[%v].out.of.range[%v-%v]", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			field[v] = true
+		}
+	}
+	return field, nil
+}
+
+func (s *cronSchedule) matchDay(t time.Time) bool {
+	dom := s.fields[2][t.Day()]
+	dow := s.fields[4][int(t.Weekday())]
+	// same as vixie cron: if both the day fields are restricted, either matches
+	if !s.domAll && !s.dowAll {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// next returns the first time after t which matches the schedule,
+// the zero time is returned if nothing matches in 5 years.
+func (s *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(5, 0, 0)
+	for t.Before(end) {
+		if !s.fields[3][int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.fields[1][t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.fields[0][t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
@@ -0,0 +1,64 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysqld
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCronNext(t *testing.T) {
+	// 2021-03-10 is a wednesday.
+	now := time.Date(2021, 3, 10, 10, 30, 15, 0, time.Local)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2021, 3, 10, 10, 31, 0, 0, time.Local)},
+		{"*/15 * * * *", time.Date(2021, 3, 10, 10, 45, 0, 0, time.Local)},
+		{"0 2 * * *", time.Date(2021, 3, 11, 2, 0, 0, 0, time.Local)},
+		{"@daily", time.Date(2021, 3, 11, 0, 0, 0, 0, time.Local)},
+		{"@hourly", time.Date(2021, 3, 10, 11, 0, 0, 0, time.Local)},
+		{"30 1 * * 0", time.Date(2021, 3, 14, 1, 30, 0, 0, time.Local)},
+		{"30 1 * * 7", time.Date(2021, 3, 14, 1, 30, 0, 0, time.Local)},
+		{"0 3 1 * *", time.Date(2021, 4, 1, 3, 0, 0, 0, time.Local)},
+		{"0 0 1 1 *", time.Date(2022, 1, 1, 0, 0, 0, 0, time.Local)},
+		{"0 9-17/4 * * 1-5", time.Date(2021, 3, 10, 13, 0, 0, 0, time.Local)},
+		{"0,45 10 * * *", time.Date(2021, 3, 10, 10, 45, 0, 0, time.Local)},
+		// dom or dow when both are restricted.
+		{"0 0 15 * 5", time.Date(2021, 3, 12, 0, 0, 0, 0, time.Local)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, test := range tests {
+		s, err := parseCron(test.spec)
+		assert.Nil(t, err, test.spec)
+		assert.Equal(t, test.want, s.next(now), test.spec)
+	}
+}
+
+func TestCronParseError(t *testing.T) {
+	specs := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"a * * * *",
+		"5-1 * * * *",
+	}
+	for _, spec := range specs {
+		_, err := parseCron(spec)
+		assert.NotNil(t, err, spec)
+	}
+}
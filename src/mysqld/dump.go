@@ -0,0 +1,265 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysqld
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"model"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	mysqldump = "bin/mysqldump"
+
+	// the mysqldump outputs in the dump dir
+	dumpFile = "dump.sql"
+
+	// the mydumper file which records the binlog position and the GTID of the dump
+	mydumperMetadata = "metadata"
+
+	// mysqldump/mydumper say nothing when they are done, it's echoed after the dump is sent
+	dumpOk = "dump " + backupOk
+
+	// the statement of the mysqldump --set-gtid-purged=ON header
+	dumpGTIDPurged = "SET @@GLOBAL.GTID_PURGED="
+)
+
+var (
+	// the 8.0 mysqldump appends the GTID_PURGED by /*!80000 '+'*/
+	dumpGTIDPurgedAppend = regexp.MustCompile(`/\*![0-9]+ '\+'\*/`)
+)
+
+// isLogicalMethod returns true if the backup method is mysqldump or mydumper.
+func isLogicalMethod(method string) bool {
+	return method == model.BACKUP_METHOD_MYSQLDUMP || method == model.BACKUP_METHOD_MYDUMPER
+}
+
+// checkBackupMethod used to check the backup method, the logical dump has no compression,
+// encryption or incrementals.
+func checkBackupMethod(req *model.BackupRPCRequest) error {
+	switch req.Method {
+	case "", model.BACKUP_METHOD_XTRABACKUP:
+		return nil
+	case model.BACKUP_METHOD_MYSQLDUMP, model.BACKUP_METHOD_MYDUMPER:
+		if req.Compress != "" || req.Encrypt != "" || len(req.IncrementalDirs) > 0 {
+			return errors.Errorf("backup.method[%v].does.not.support.compress.encrypt.or.incremental", req.Method)
+		}
+		return nil
+	}
+	return errors.Errorf("backup.method[%v].unsupported", req.Method)
+}
+
+// connectOptions returns the options of the mysql tools to connect the local mysql.
+func (b *Backup) connectOptions() string {
+	opts := fmt.Sprintf("--host=%s --port=%d --user=%s", b.conf.Host, b.conf.Port, b.conf.Admin)
+	if b.conf.Passwd != "" {
+		opts += fmt.Sprintf(" --password=%s", b.conf.Passwd)
+	}
+	return opts
+}
+
+// dumpCommand returns the command which writes the consistent logical dump to stdout,
+// the mydumper outputs are written into a temporary dir under the dump-dir and sent by tar.
+func (b *Backup) dumpCommand(method string) string {
+	if method == model.BACKUP_METHOD_MYDUMPER {
+		return fmt.Sprintf("(mkdir -p %s && dir=$(mktemp -d %s/mydumper.XXXXXX) && %s/mydumper %s --trx-consistency-only --threads=%d --outputdir=$dir && tar -C $dir -cf - .; rc=$?; rm -rf $dir; exit $rc)",
+			b.conf.DumpDir,
+			b.conf.DumpDir,
+			b.conf.MydumperBinDir,
+			b.connectOptions(),
+			b.conf.Parallel)
+	}
+	return fmt.Sprintf("%s %s --single-transaction --set-gtid-purged=ON --all-databases --triggers --routines --events --hex-blob",
+		filepath.Join(b.conf.Basedir, mysqldump),
+		b.connectOptions())
+}
+
+// dumpSink returns the command which writes the dump from stdin into the dir on the receiver.
+func dumpSink(method string, dir string) string {
+	if method == model.BACKUP_METHOD_MYDUMPER {
+		return fmt.Sprintf("mkdir -p %s && tar -xf - -C %s", dir, dir)
+	}
+	return fmt.Sprintf("mkdir -p %s && cat > %s/%s", dir, dir, dumpFile)
+}
+
+// dumpCommands returns the command which sends the dump to the receiver by ssh.
+func (b *Backup) dumpCommands(iskey bool, req *model.BackupRPCRequest) []string {
+	var ssh string
+
+	sink := dumpSink(req.Method, req.BackupDir)
+	if iskey {
+		ssh = fmt.Sprintf("ssh -o 'StrictHostKeyChecking=no' %s@%s -p %d \"%s\"",
+			req.SSHUser,
+			req.SSHHost,
+			req.SSHPort,
+			sink)
+	} else {
+		ssh = fmt.Sprintf("sshpass -p %s ssh -o 'StrictHostKeyChecking=no' %s@%s -p %d \"%s\"",
+			req.SSHPasswd,
+			req.SSHUser,
+			req.SSHHost,
+			req.SSHPort,
+			sink)
+	}
+	return []string{
+		"-c",
+		fmt.Sprintf("set -o pipefail; %s | %s && echo '%s' >&2", b.dumpCommand(req.Method), ssh, dumpOk),
+	}
+}
+
+// streamDumpCommands returns the command which writes the dump to the xenon stream.
+func (b *Backup) streamDumpCommands(method string) []string {
+	return []string{
+		"-c",
+		fmt.Sprintf("set -o pipefail; %s && echo '%s' >&2", b.dumpCommand(method), dumpOk),
+	}
+}
+
+// loadCommands returns the command which loads the dump in the dir into the local mysql.
+// The replication is stopped and the user databases are dropped first,
+// the GTID_PURGED statement of the mysqldump is skipped, the caller sets it after RESET MASTER.
+func (b *Backup) loadCommands(dir string, method string) []string {
+	client := fmt.Sprintf("%s %s", filepath.Join(b.conf.Basedir, mysqlclient), b.connectOptions())
+	prepare := fmt.Sprintf("%s -e \"STOP SLAVE; SET GLOBAL super_read_only = 0\"", client)
+	drop := fmt.Sprintf("for db in $(%s -N -e \"SELECT SCHEMA_NAME FROM information_schema.SCHEMATA WHERE SCHEMA_NAME NOT IN ('mysql','sys','information_schema','performance_schema')\"); do %s -e \"SET sql_log_bin = 0; DROP DATABASE \\`$db\\`\" || exit 1; done",
+		client,
+		client)
+
+	var load string
+	if method == model.BACKUP_METHOD_MYDUMPER {
+		load = fmt.Sprintf("%s/myloader %s --directory=%s --overwrite-tables --threads=%d", b.conf.MydumperBinDir, b.connectOptions(), dir, b.conf.Parallel)
+	} else {
+		load = fmt.Sprintf("awk '/^SET @@GLOBAL.GTID_PURGED=/{skip=1} !skip{print} skip&&/;$/{skip=0}' %s/%s | %s", dir, dumpFile, client)
+	}
+	return []string{
+		"-c",
+		fmt.Sprintf("set -o pipefail; %s && %s && %s", prepare, drop, load),
+	}
+}
+
+// LoadDump used to load the logical dump in req.BackupDir into the local mysql,
+// the user databases are replaced by the dump.
+func (b *Backup) LoadDump(req *model.BackupRPCRequest) error {
+	log := b.log
+
+	log.Info("load.dump.prepare.to.run")
+	if !isLogicalMethod(req.Method) {
+		return errors.Errorf("load.dump.method[%v].is.not.logical", req.Method)
+	}
+	if b.getStatus() == model.MYSQLD_BACKUPING ||
+		b.getStatus() == model.MYSQLD_APPLYLOGGING {
+		return errors.New("load.dump.error[backup/applylog.already.running]")
+	}
+
+	b.setStatus(model.MYSQLD_APPLYLOGGING)
+	defer b.setStatus(model.MYSQLD_BACKUPNONE)
+
+	args := b.loadCommands(req.BackupDir, req.Method)
+	log.Warning("load.dump.cmd[%s]", strings.Join(args, " "))
+	if outs, err := b.cmd.RunCommand(bash, args); err != nil {
+		log.Error("load.dump.cmd.error[%v:%+v]", outs, err)
+		b.setLastError(err.Error())
+		return err
+	}
+	log.Warning("load.dump[%v].done", req.BackupDir)
+	return nil
+}
+
+// DumpGTIDPurged returns the GTID set of the logical dump in the dir,
+// which is the GTID_PURGED in the mysqldump header or the GTID in the mydumper metadata.
+func DumpGTIDPurged(dir string, method string) (string, error) {
+	switch method {
+	case model.BACKUP_METHOD_MYSQLDUMP:
+		return mysqldumpGTIDPurged(filepath.Join(dir, dumpFile))
+	case model.BACKUP_METHOD_MYDUMPER:
+		return mydumperGTIDPurged(filepath.Join(dir, mydumperMetadata))
+	}
+	return "", errors.Errorf("dump.method[%v].is.not.logical", method)
+}
+
+// readGTIDSet reads the GTID set which may be split into lines after the commas.
+func readGTIDSet(reader *bufio.Reader, first string) string {
+	set := first
+	for strings.HasSuffix(strings.TrimSpace(set), ",") {
+		line, err := reader.ReadString('\n')
+		set += strings.TrimSpace(line)
+		if err != nil {
+			break
+		}
+	}
+	return strings.Join(strings.Fields(set), "")
+}
+
+// mysqldumpGTIDPurged parses the GTID_PURGED statement in the header of the dump file, such as:
+// SET @@GLOBAL.GTID_PURGED=/*!80000 '+'*/ 'uuid1:1-10,
+// uuid2:1-5';
+func mysqldumpGTIDPurged(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		// the header ends before the first database
+		if strings.HasPrefix(line, "CREATE ") || strings.HasPrefix(line, "USE ") {
+			break
+		}
+		if strings.HasPrefix(line, dumpGTIDPurged) {
+			value := dumpGTIDPurgedAppend.ReplaceAllString(strings.TrimPrefix(line, dumpGTIDPurged), "")
+			value = strings.TrimSuffix(readGTIDSet(reader, strings.TrimSuffix(value, ";")), ";")
+			return strings.Trim(value, "'"), nil
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+	}
+	return "", errors.Errorf("dump[%v].has.no.gtid_purged", path)
+}
+
+// mydumperGTIDPurged parses the GTID in the mydumper metadata,
+// it's "GTID:set" in the old versions and "Executed_Gtid_Set = set" in the new ones.
+func mydumperGTIDPurged(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		for _, key := range []string{"GTID:", "Executed_Gtid_Set ="} {
+			if strings.HasPrefix(line, key) {
+				return readGTIDSet(reader, strings.TrimSpace(strings.TrimPrefix(line, key))), nil
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+	}
+	return "", errors.Errorf("dump.metadata[%v].has.no.gtid", path)
+}
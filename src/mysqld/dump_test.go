@@ -0,0 +1,229 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysqld
+
+import (
+	"config"
+	"io/ioutil"
+	"model"
+	"os"
+	"path/filepath"
+	"testing"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDumpCommands(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := config.DefaultBackupConfig()
+	conf.Basedir = "/u01/mysql"
+	conf.DumpDir = "/u01/backup_dump"
+	backup := NewBackup(conf, log)
+
+	req := model.NewBackupRPCRequest()
+	req.BackupDir = "/u01/backup_dump"
+	req.SSHPasswd = "sshpasswd"
+	req.SSHUser = "user"
+	req.SSHHost = "127.0.0.1"
+	req.SSHPort = 22
+
+	// mysqldump
+	{
+		req.Method = model.BACKUP_METHOD_MYSQLDUMP
+		got := backup.dumpCommands(true, req)
+		want := []string{
+			"-c",
+			"set -o pipefail; /u01/mysql/bin/mysqldump --host=localhost --port=3306 --user=root --single-transaction --set-gtid-purged=ON --all-databases --triggers --routines --events --hex-blob | ssh -o 'StrictHostKeyChecking=no' user@127.0.0.1 -p 22 \"mkdir -p /u01/backup_dump && cat > /u01/backup_dump/dump.sql\" && echo 'dump completed OK!' >&2",
+		}
+		assert.Equal(t, want, got)
+	}
+
+	// mydumper with ssh password
+	{
+		req.Method = model.BACKUP_METHOD_MYDUMPER
+		got := backup.dumpCommands(false, req)
+		want := []string{
+			"-c",
+			"set -o pipefail; (mkdir -p /u01/backup_dump && dir=$(mktemp -d /u01/backup_dump/mydumper.XXXXXX) && /usr/bin/mydumper --host=localhost --port=3306 --user=root --trx-consistency-only --threads=2 --outputdir=$dir && tar -C $dir -cf - .; rc=$?; rm -rf $dir; exit $rc) | sshpass -p sshpasswd ssh -o 'StrictHostKeyChecking=no' user@127.0.0.1 -p 22 \"mkdir -p /u01/backup_dump && tar -xf - -C /u01/backup_dump\" && echo 'dump completed OK!' >&2",
+		}
+		assert.Equal(t, want, got)
+	}
+
+	// the logical dump has no compression
+	{
+		req.Compress = model.BACKUP_COMPRESS_ZSTD
+		err := backup.Backup(req)
+		assert.Equal(t, "backup.method[mydumper].does.not.support.compress.encrypt.or.incremental", err.Error())
+		req.Compress = ""
+
+		req.Method = "mysqlpump"
+		err = backup.Backup(req)
+		assert.Equal(t, "backup.method[mysqlpump].unsupported", err.Error())
+	}
+}
+
+func TestLoadDump(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := config.DefaultBackupConfig()
+	conf.Basedir = "/u01/mysql"
+	backup := NewBackup(conf, log)
+	cmd := common.NewMockReplayCommand(nil)
+	backup.SetCMDHandler(cmd)
+
+	client := "/u01/mysql/bin/mysql --host=localhost --port=3306 --user=root"
+	prepare := "set -o pipefail; " + client + " -e \"STOP SLAVE; SET GLOBAL super_read_only = 0\" && for db in $(" + client + " -N -e \"SELECT SCHEMA_NAME FROM information_schema.SCHEMATA WHERE SCHEMA_NAME NOT IN ('mysql','sys','information_schema','performance_schema')\"); do " + client + " -e \"SET sql_log_bin = 0; DROP DATABASE \\`$db\\`\" || exit 1; done && "
+
+	req := model.NewBackupRPCRequest()
+	req.BackupDir = "/u01/backup_dump"
+
+	// mysqldump
+	{
+		req.Method = model.BACKUP_METHOD_MYSQLDUMP
+		err := backup.LoadDump(req)
+		assert.Nil(t, err)
+		want := prepare + "awk '/^SET @@GLOBAL.GTID_PURGED=/{skip=1} !skip{print} skip&&/;$/{skip=0}' /u01/backup_dump/dump.sql | " + client
+		calls := cmd.Calls()
+		assert.Equal(t, want, calls[len(calls)-1][1])
+	}
+
+	// mydumper
+	{
+		req.Method = model.BACKUP_METHOD_MYDUMPER
+		err := backup.LoadDump(req)
+		assert.Nil(t, err)
+		want := prepare + "/usr/bin/myloader --host=localhost --port=3306 --user=root --directory=/u01/backup_dump --overwrite-tables --threads=2"
+		calls := cmd.Calls()
+		assert.Equal(t, want, calls[len(calls)-1][1])
+	}
+
+	// not logical
+	{
+		req.Method = model.BACKUP_METHOD_XTRABACKUP
+		err := backup.LoadDump(req)
+		assert.Equal(t, "load.dump.method[xtrabackup].is.not.logical", err.Error())
+	}
+}
+
+func TestDumpGTIDPurged(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "xenon-dump")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmp)
+
+	write := func(name string, data string) {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(tmp, name), []byte(data), 0644))
+	}
+
+	// mysqldump of 5.7
+	{
+		write(dumpFile, `-- MySQL dump 10.13
+SET @MYSQLDUMP_TEMP_LOG_BIN = @@SESSION.SQL_LOG_BIN;
+SET @@SESSION.SQL_LOG_BIN= 0;
+
+--
+-- GTID state at the beginning of the backup
+--
+
+SET @@GLOBAL.GTID_PURGED='4b4b2a49-1b20-11e8-8c4d-525400a36c5e:1-20';
+
+CREATE DATABASE /*!32312 IF NOT EXISTS*/ db1;
+`)
+		gtid, err := DumpGTIDPurged(tmp, model.BACKUP_METHOD_MYSQLDUMP)
+		assert.Nil(t, err)
+		assert.Equal(t, "4b4b2a49-1b20-11e8-8c4d-525400a36c5e:1-20", gtid)
+	}
+
+	// mysqldump of 8.0 with the multi-line set
+	{
+		write(dumpFile, `-- MySQL dump 10.13
+SET @@GLOBAL.GTID_PURGED=/*!80000 '+'*/ '4b4b2a49-1b20-11e8-8c4d-525400a36c5e:1-20,
+5c5c2a49-1b20-11e8-8c4d-525400a36c5e:1-5';
+
+CREATE DATABASE db1;
+`)
+		gtid, err := DumpGTIDPurged(tmp, model.BACKUP_METHOD_MYSQLDUMP)
+		assert.Nil(t, err)
+		assert.Equal(t, "4b4b2a49-1b20-11e8-8c4d-525400a36c5e:1-20,5c5c2a49-1b20-11e8-8c4d-525400a36c5e:1-5", gtid)
+	}
+
+	// the GTID_PURGED in the data is not the header
+	{
+		write(dumpFile, `-- MySQL dump 10.13
+CREATE DATABASE db1;
+SET @@GLOBAL.GTID_PURGED='4b4b2a49-1b20-11e8-8c4d-525400a36c5e:1-20';
+`)
+		_, err := DumpGTIDPurged(tmp, model.BACKUP_METHOD_MYSQLDUMP)
+		assert.NotNil(t, err)
+	}
+
+	// old mydumper metadata
+	{
+		write(mydumperMetadata, `Started dump at: 2020-06-01 10:00:00
+SHOW MASTER STATUS:
+	Log: mysql-bin.000002
+	Pos: 154
+	GTID:4b4b2a49-1b20-11e8-8c4d-525400a36c5e:1-20,
+5c5c2a49-1b20-11e8-8c4d-525400a36c5e:1-5
+
+Finished dump at: 2020-06-01 10:00:01
+`)
+		gtid, err := DumpGTIDPurged(tmp, model.BACKUP_METHOD_MYDUMPER)
+		assert.Nil(t, err)
+		assert.Equal(t, "4b4b2a49-1b20-11e8-8c4d-525400a36c5e:1-20,5c5c2a49-1b20-11e8-8c4d-525400a36c5e:1-5", gtid)
+	}
+
+	// new mydumper metadata
+	{
+		write(mydumperMetadata, `# Started dump at: 2023-06-01 10:00:00
+[master]
+# Channel_Name = '' # It can be use to setup replication FOR CHANNEL
+File = mysql-bin.000002
+Position = 154
+Executed_Gtid_Set = 4b4b2a49-1b20-11e8-8c4d-525400a36c5e:1-20
+`)
+		gtid, err := DumpGTIDPurged(tmp, model.BACKUP_METHOD_MYDUMPER)
+		assert.Nil(t, err)
+		assert.Equal(t, "4b4b2a49-1b20-11e8-8c4d-525400a36c5e:1-20", gtid)
+	}
+
+	// not logical
+	{
+		_, err := DumpGTIDPurged(tmp, model.BACKUP_METHOD_XTRABACKUP)
+		assert.Equal(t, "dump.method[xtrabackup].is.not.logical", err.Error())
+	}
+}
+
+func TestStreamDump(t *testing.T) {
+	endpoint, mysqld, donor, backupdir, cleanup := mockStream(t)
+	defer cleanup()
+
+	// the mysqldump is replaced by the script
+	donor.conf.Basedir = filepath.Dir(donor.conf.XtrabackupBinDir)
+	ioutil.WriteFile(filepath.Join(donor.conf.Basedir, mysqldump), []byte("#!/bin/bash\necho \"SET @@GLOBAL.GTID_PURGED='4b4b2a49-1b20-11e8-8c4d-525400a36c5e:1-20';\"\n"), 0755)
+
+	token, err := mysqld.backup.PrepareStream(backupdir, model.BACKUP_METHOD_MYSQLDUMP)
+	assert.Nil(t, err)
+	req := model.NewBackupRPCRequest()
+	req.Transport = model.BACKUP_TRANSPORT_XENON
+	req.StreamAddr = endpoint
+	req.StreamToken = token
+	req.Method = model.BACKUP_METHOD_MYSQLDUMP
+	sent, sentChecksum, err := donor.StreamBackup(req)
+	assert.Nil(t, err)
+
+	received, receivedChecksum, err := mysqld.backup.WaitStream(token, false)
+	assert.Nil(t, err)
+	assert.Equal(t, sent, received)
+	assert.Equal(t, sentChecksum, receivedChecksum)
+
+	gtid, err := DumpGTIDPurged(backupdir, model.BACKUP_METHOD_MYSQLDUMP)
+	assert.Nil(t, err)
+	assert.Equal(t, "4b4b2a49-1b20-11e8-8c4d-525400a36c5e:1-20", gtid)
+}
@@ -31,6 +31,7 @@ func setupRPC(rpc *xrpc.Service, mysqld *Mysqld) {
 	if err := rpc.RegisterService(mysqld.GetMysqldRPC()); err != nil {
 		mysqld.log.Panic("server.rpc.RegisterService.GetMysqldRPC.error[%v]", err)
 	}
+	rpc.RegisterStreamHandler(mysqld.HandleStream)
 }
 
 // MockMysqld used to mock a mysqld.
@@ -11,6 +11,7 @@ package mysqld
 import (
 	"config"
 	"model"
+	"net"
 	"strconv"
 	"strings"
 	"sync"
@@ -25,6 +26,8 @@ type Mysqld struct {
 	log            *xlog.Log
 	cmd            common.Command
 	backup         *Backup
+	scheduler      *Scheduler
+	archiver       *BinlogArchiver
 	monitorTicker  *time.Ticker
 	monitorRunning bool
 	mutex          sync.RWMutex
@@ -35,11 +38,14 @@ type Mysqld struct {
 
 // NewMysqld creates the new Mysqld.
 func NewMysqld(conf *config.BackupConfig, log *xlog.Log) *Mysqld {
+	backup := NewBackup(conf, log)
 	return &Mysqld{
 		conf:        conf,
 		log:         log,
 		cmd:         common.NewLinuxCommand(log),
-		backup:      NewBackup(conf, log),
+		backup:      backup,
+		scheduler:   NewScheduler(conf, log, backup),
+		archiver:    NewBinlogArchiver(conf, log),
 		status:      model.MYSQLD_NOTRUNNING,
 		argsHandler: NewLinuxArgs(conf),
 	}
@@ -171,6 +177,57 @@ func (m *Mysqld) MonitorStop() {
 	m.log.Info("mysqld[%v].monitor.stop...", m.conf.DefaultsFile)
 }
 
+// SetBackupChooser used to set the chooser of the scheduled backups.
+func (m *Mysqld) SetBackupChooser(chooser BackupChooser) {
+	m.scheduler.chooser = chooser
+}
+
+// ScheduledBackup used to take the scheduled backup dispatched by the leader in background.
+func (m *Mysqld) ScheduledBackup(incremental bool) {
+	go m.scheduler.take(incremental)
+}
+
+// ScheduleStart used to start the scheduled local backups.
+func (m *Mysqld) ScheduleStart() error {
+	return m.scheduler.Start()
+}
+
+// ScheduleStop used to stop the scheduled local backups.
+func (m *Mysqld) ScheduleStop() {
+	m.scheduler.Stop()
+}
+
+// SetBinlogLister used to set the lister of the local binlogs to archive.
+func (m *Mysqld) SetBinlogLister(lister BinlogLister) {
+	m.archiver.lister = lister
+}
+
+// BinlogArchiveStart used to start archiving the closed binlogs.
+func (m *Mysqld) BinlogArchiveStart() {
+	m.archiver.Start()
+}
+
+// BinlogArchiveStop used to stop archiving the closed binlogs.
+func (m *Mysqld) BinlogArchiveStop() {
+	m.archiver.Stop()
+}
+
+// PurgeBinlogGuard returns the binlog which is safe to purge to, the binlogs
+// which are not archived are never purged.
+func (m *Mysqld) PurgeBinlogGuard(next string, check bool) string {
+	return m.archiver.PurgeGuard(next, check)
+}
+
+// BackupRunning returns true if a backup is running, the leader doesn't purge binlogs meanwhile.
+func (m *Mysqld) BackupRunning() bool {
+	return m.backup.getStatus() == model.MYSQLD_BACKUPING
+}
+
+// HandleStream used to receive the backup stream from the donor.
+func (m *Mysqld) HandleStream(conn net.Conn) {
+	m.backup.HandleStream(conn)
+}
+
 func (m *Mysqld) setStatus(s model.MYSQLD_STATUS) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
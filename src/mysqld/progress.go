@@ -0,0 +1,156 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysqld
+
+import (
+	"model"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// [01] Copying ./ibdata1 to <STDOUT>
+	// [02] Streaming ./mysql/user.frm to <STDOUT>
+	progressCopyingRegexp = regexp.MustCompile(`\[(\d+)\] (?:Copying|Streaming) (\S+) `)
+
+	// [01]        ...done
+	progressDoneRegexp = regexp.MustCompile(`\[(\d+)\]\s+\.\.\.done`)
+
+	// >> log scanned up to (2616853)
+	// Transaction log of lsn (2616844) to (2616853) was copied.
+	// InnoDB: Doing recovery: scanned up to log sequence number 2617133
+	// InnoDB: Shutdown completed; log sequence number 2617142
+	progressLSNRegexp = regexp.MustCompile(`(?:scanned up to \(|to \(|log sequence number )(\d+)`)
+
+	// InnoDB: Progress in percent: 0 1 2 3 4
+	progressPercentRegexp = regexp.MustCompile(`Progress in percent: ([\d ]+)`)
+)
+
+// progress used to parse the outputs of xtrabackup into model.BackupProgress.
+type progress struct {
+	mutex   sync.RWMutex
+	datadir string
+	threads map[string]string
+	p       model.BackupProgress
+}
+
+func newProgress() *progress {
+	return &progress{
+		threads: make(map[string]string),
+		p:       model.BackupProgress{Phase: model.BACKUP_PHASE_NONE},
+	}
+}
+
+// reset used to begin a new job, the copied files are relative to datadir.
+func (p *progress) reset(phase model.BACKUP_PHASE, datadir string, total uint64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now().Unix()
+	p.datadir = datadir
+	p.threads = make(map[string]string)
+	p.p = model.BackupProgress{
+		Phase:      phase,
+		TotalBytes: total,
+		Begin:      now,
+		Update:     now,
+	}
+}
+
+// parse used to parse one line of xtrabackup outputs.
+func (p *progress) parse(line string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	pg := &p.p
+	pg.Update = time.Now().Unix()
+	switch {
+	case strings.Contains(line, backupOk):
+		pg.Phase = model.BACKUP_PHASE_COMPLETED
+		pg.Percent = 100
+		return
+	case strings.Contains(line, "Executing FLUSH TABLES WITH READ LOCK"),
+		strings.Contains(line, "Executing LOCK TABLES FOR BACKUP"),
+		strings.Contains(line, "Executing LOCK INSTANCE FOR BACKUP"):
+		pg.Phase = model.BACKUP_PHASE_LOCKING
+	case strings.Contains(line, "Starting to backup non-InnoDB tables and files"):
+		pg.Phase = model.BACKUP_PHASE_COPYING_NON_INNODB
+	case strings.Contains(line, "Finished backing up non-InnoDB tables and files"),
+		strings.Contains(line, "Executing UNLOCK TABLES"):
+		pg.Phase = model.BACKUP_PHASE_FINISHING
+	}
+
+	if m := progressCopyingRegexp.FindStringSubmatch(line); m != nil {
+		p.threads[m[1]] = m[2]
+		if pg.Phase == model.BACKUP_PHASE_NONE {
+			pg.Phase = model.BACKUP_PHASE_COPYING_INNODB
+		}
+	} else if m := progressDoneRegexp.FindStringSubmatch(line); m != nil {
+		if file, ok := p.threads[m[1]]; ok {
+			delete(p.threads, m[1])
+			pg.CopiedFiles++
+			if info, err := os.Stat(filepath.Join(p.datadir, file)); err == nil {
+				pg.CopiedBytes += uint64(info.Size())
+			}
+			if pg.TotalBytes > 0 {
+				percent := float64(pg.CopiedBytes) * 100 / float64(pg.TotalBytes)
+				// never be 100 until xtrabackup says completed
+				if percent > 99 {
+					percent = 99
+				}
+				pg.Percent = percent
+			}
+		}
+	} else if m := progressPercentRegexp.FindStringSubmatch(line); m != nil {
+		fields := strings.Fields(m[1])
+		if percent, err := strconv.Atoi(fields[len(fields)-1]); err == nil {
+			pg.Percent = float64(percent)
+		}
+	}
+
+	if m := progressLSNRegexp.FindStringSubmatch(line); m != nil {
+		if lsn, err := strconv.ParseUint(m[1], 10, 64); err == nil {
+			pg.LSN = lsn
+		}
+	}
+}
+
+func (p *progress) get() model.BackupProgress {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.p
+}
+
+// datadirSize returns the bytes which xtrabackup will copy from the datadir approximately,
+// the binlogs, relay logs and redo logs are skipped.
+func datadirSize(datadir string) uint64 {
+	var size uint64
+	filepath.Walk(datadir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		name := info.Name()
+		if strings.HasPrefix(name, "ib_logfile") ||
+			strings.Contains(name, "-bin.") ||
+			strings.Contains(name, "relay") {
+			return nil
+		}
+		size += uint64(info.Size())
+		return nil
+	})
+	return size
+}
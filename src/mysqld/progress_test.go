@@ -0,0 +1,115 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysqld
+
+import (
+	"io/ioutil"
+	"model"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressBackup(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "xenon-progress")
+	assert.Nil(t, err)
+	defer os.RemoveAll(datadir)
+
+	err = os.Mkdir(filepath.Join(datadir, "db1"), 0755)
+	assert.Nil(t, err)
+	files := map[string]int{
+		"ibdata1":          4096,
+		"db1/t1.ibd":       2048,
+		"db1/t1.frm":       1024,
+		"mysql-bin.000001": 8192,
+		"ib_logfile0":      8192,
+	}
+	for name, size := range files {
+		err := ioutil.WriteFile(filepath.Join(datadir, name), make([]byte, size), 0644)
+		assert.Nil(t, err)
+	}
+	total := datadirSize(datadir)
+	assert.Equal(t, uint64(4096+2048+1024), total)
+
+	p := newProgress()
+	assert.Equal(t, model.BACKUP_PHASE_NONE, p.get().Phase)
+	p.reset(model.BACKUP_PHASE_NONE, datadir, total)
+
+	lines := []struct {
+		line    string
+		phase   model.BACKUP_PHASE
+		files   uint64
+		bytes   uint64
+		lsn     uint64
+		percent float64
+	}{
+		{"xtrabackup version 2.4.20 based on MySQL server 5.7.26 Linux (x86_64)", model.BACKUP_PHASE_NONE, 0, 0, 0, 0},
+		{">> log scanned up to (2616853)", model.BACKUP_PHASE_NONE, 0, 0, 2616853, 0},
+		{"200331 10:41:21 [01] Copying ./ibdata1 to <STDOUT>", model.BACKUP_PHASE_COPYING_INNODB, 0, 0, 2616853, 0},
+		{"200331 10:41:21 [02] Copying ./db1/t1.ibd to <STDOUT>", model.BACKUP_PHASE_COPYING_INNODB, 0, 0, 2616853, 0},
+		{"200331 10:41:21 [02]        ...done", model.BACKUP_PHASE_COPYING_INNODB, 1, 2048, 2616853, 2048 * 100 / float64(total)},
+		{"200331 10:41:22 [01]        ...done", model.BACKUP_PHASE_COPYING_INNODB, 2, 6144, 2616853, 6144 * 100 / float64(total)},
+		{">> log scanned up to (2616900)", model.BACKUP_PHASE_COPYING_INNODB, 2, 6144, 2616900, 6144 * 100 / float64(total)},
+		{"200331 10:41:22 Executing FLUSH NO_WRITE_TO_BINLOG TABLES...", model.BACKUP_PHASE_COPYING_INNODB, 2, 6144, 2616900, 6144 * 100 / float64(total)},
+		{"200331 10:41:22 Executing FLUSH TABLES WITH READ LOCK...", model.BACKUP_PHASE_LOCKING, 2, 6144, 2616900, 6144 * 100 / float64(total)},
+		{"200331 10:41:22 Starting to backup non-InnoDB tables and files", model.BACKUP_PHASE_COPYING_NON_INNODB, 2, 6144, 2616900, 6144 * 100 / float64(total)},
+		{"200331 10:41:22 [01] Streaming ./db1/t1.frm to <STDOUT>", model.BACKUP_PHASE_COPYING_NON_INNODB, 2, 6144, 2616900, 6144 * 100 / float64(total)},
+		{"200331 10:41:22 [01]        ...done", model.BACKUP_PHASE_COPYING_NON_INNODB, 3, 7168, 2616900, 99},
+		{"200331 10:41:22 Finished backing up non-InnoDB tables and files", model.BACKUP_PHASE_FINISHING, 3, 7168, 2616900, 99},
+		{"xtrabackup: Transaction log of lsn (2616844) to (2616953) was copied.", model.BACKUP_PHASE_FINISHING, 3, 7168, 2616953, 99},
+		{"200331 10:41:23 completed OK!", model.BACKUP_PHASE_COMPLETED, 3, 7168, 2616953, 100},
+	}
+	for _, l := range lines {
+		p.parse(l.line)
+		got := p.get()
+		assert.Equal(t, l.phase, got.Phase, l.line)
+		assert.Equal(t, l.files, got.CopiedFiles, l.line)
+		assert.Equal(t, l.bytes, got.CopiedBytes, l.line)
+		assert.Equal(t, l.lsn, got.LSN, l.line)
+		assert.Equal(t, l.percent, got.Percent, l.line)
+	}
+	got := p.get()
+	assert.Equal(t, uint64(7168), got.TotalBytes)
+	assert.Equal(t, int64(0), int64(got.ETA()))
+}
+
+func TestProgressApplyLog(t *testing.T) {
+	p := newProgress()
+	p.reset(model.BACKUP_PHASE_PREPARING, "/tmp/xenon-progress-none", 0)
+
+	p.parse("InnoDB: Doing recovery: scanned up to log sequence number 2617133 (0%)")
+	got := p.get()
+	assert.Equal(t, model.BACKUP_PHASE_PREPARING, got.Phase)
+	assert.Equal(t, uint64(2617133), got.LSN)
+	assert.Equal(t, float64(0), got.Percent)
+	assert.Equal(t, int64(-1), int64(got.ETA()))
+
+	p.parse("InnoDB: Progress in percent: 0 1 2 3 4 5 6 7 8 9 10 11 12")
+	got = p.get()
+	assert.Equal(t, float64(12), got.Percent)
+
+	p.parse("InnoDB: Shutdown completed; log sequence number 2617142")
+	p.parse("200331 10:45:01 completed OK!")
+	got = p.get()
+	assert.Equal(t, model.BACKUP_PHASE_COMPLETED, got.Phase)
+	assert.Equal(t, uint64(2617142), got.LSN)
+	assert.Equal(t, float64(100), got.Percent)
+}
+
+func TestProgressETA(t *testing.T) {
+	p := model.BackupProgress{
+		Phase:   model.BACKUP_PHASE_COPYING_INNODB,
+		Percent: 25,
+		Begin:   100,
+		Update:  160,
+	}
+	assert.Equal(t, "3m0s", p.ETA().String())
+}
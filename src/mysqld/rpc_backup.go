@@ -23,9 +23,15 @@ func (m *Mysqld) GetBackupRPC() *BackupRPC {
 }
 
 // DoBackup used to execute the xtrabackup command.
+// With the xenon transport, the backup is streamed to req.StreamAddr and the size/checksum are returned.
 func (b *BackupRPC) DoBackup(req *model.BackupRPCRequest, rsp *model.BackupRPCResponse) error {
+	var err error
 	rsp.RetCode = model.OK
-	err := b.mysqld.backup.Backup(req)
+	if req.Transport == model.BACKUP_TRANSPORT_XENON {
+		rsp.Size, rsp.Checksum, err = b.mysqld.backup.StreamBackup(req)
+	} else {
+		err = b.mysqld.backup.Backup(req)
+	}
 	if err != nil {
 		rsp.RetCode = err.Error()
 		return nil
@@ -44,6 +50,16 @@ func (b *BackupRPC) DoApplyLog(req *model.BackupRPCRequest, rsp *model.BackupRPC
 	return nil
 }
 
+// LoadDump used to load the logical dump in req.BackupDir into the local mysql.
+func (b *BackupRPC) LoadDump(req *model.BackupRPCRequest, rsp *model.BackupRPCResponse) error {
+	rsp.RetCode = model.OK
+	if err := b.mysqld.backup.LoadDump(req); err != nil {
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	return nil
+}
+
 // CancelBackup used to cancel the job of backup.
 func (b *BackupRPC) CancelBackup(req *model.BackupRPCRequest, rsp *model.BackupRPCResponse) error {
 	rsp.RetCode = model.OK
@@ -54,3 +70,121 @@ func (b *BackupRPC) CancelBackup(req *model.BackupRPCRequest, rsp *model.BackupR
 	}
 	return nil
 }
+
+// GetBackupStatus returns the backup status and the progress.
+func (b *BackupRPC) GetBackupStatus(req *model.BackupRPCRequest, rsp *model.BackupStatusRPCResponse) error {
+	rsp.RetCode = model.OK
+	backup := b.mysqld.backup
+	rsp.Status = backup.getStatus()
+	rsp.Stats = backup.getStats()
+	rsp.Stats.LastError = backup.getLastError()
+	rsp.Stats.LastCMD = backup.getLastCMD()
+	return nil
+}
+
+// ListArchives returns the manifests of the local backup archives.
+func (b *BackupRPC) ListArchives(req *model.BackupArchiveRPCRequest, rsp *model.BackupArchiveRPCResponse) error {
+	rsp.RetCode = model.OK
+	manifests, err := b.mysqld.backup.ListArchives()
+	if err != nil {
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	rsp.Manifests = manifests
+	return nil
+}
+
+// ShowArchive returns the manifest of the archive req.ID.
+func (b *BackupRPC) ShowArchive(req *model.BackupArchiveRPCRequest, rsp *model.BackupArchiveRPCResponse) error {
+	rsp.RetCode = model.OK
+	manifest, err := b.mysqld.backup.ShowArchive(req.ID)
+	if err != nil {
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	rsp.Manifests = []*model.BackupManifest{manifest}
+	return nil
+}
+
+// DeleteArchive used to remove the archive req.ID.
+func (b *BackupRPC) DeleteArchive(req *model.BackupArchiveRPCRequest, rsp *model.BackupArchiveRPCResponse) error {
+	rsp.RetCode = model.OK
+	if err := b.mysqld.backup.DeleteArchive(req.ID); err != nil {
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	return nil
+}
+
+// RestoreArchive used to restore the archive chain of req.ID into req.TargetDir and prepare it.
+func (b *BackupRPC) RestoreArchive(req *model.BackupArchiveRPCRequest, rsp *model.BackupArchiveRPCResponse) error {
+	rsp.RetCode = model.OK
+	if err := b.mysqld.backup.RestoreArchive(req.ID, req.TargetDir); err != nil {
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	return nil
+}
+
+// VerifyArchive used to verify the archive req.ID by restoring it into a scratch mysqld,
+// returns the manifest with the verification.
+func (b *BackupRPC) VerifyArchive(req *model.BackupArchiveRPCRequest, rsp *model.BackupArchiveRPCResponse) error {
+	rsp.RetCode = model.OK
+	if _, err := b.mysqld.backup.VerifyArchive(req.ID); err != nil {
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	manifest, err := b.mysqld.backup.ShowArchive(req.ID)
+	if err != nil {
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	rsp.Manifests = []*model.BackupManifest{manifest}
+	return nil
+}
+
+// ReplayBinlogs used to replay the archived binlogs from the binlog position of req.ID
+// until req.StopDatetime or req.StopGTID.
+func (b *BackupRPC) ReplayBinlogs(req *model.BackupArchiveRPCRequest, rsp *model.BackupArchiveRPCResponse) error {
+	rsp.RetCode = model.OK
+	if err := b.mysqld.backup.ReplayBinlogs(req.ID, req.StopDatetime, req.StopGTID); err != nil {
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	return nil
+}
+
+// ScheduledBackup used to take the scheduled backup dispatched by the leader,
+// it returns at once and the backup runs in background.
+func (b *BackupRPC) ScheduledBackup(req *model.BackupArchiveRPCRequest, rsp *model.BackupArchiveRPCResponse) error {
+	rsp.RetCode = model.OK
+	b.mysqld.ScheduledBackup(req.Incremental)
+	return nil
+}
+
+// PrepareStream used to prepare a stream to receive the backup into req.BackupDir,
+// returns the token for the donor.
+func (b *BackupRPC) PrepareStream(req *model.BackupStreamRPCRequest, rsp *model.BackupStreamRPCResponse) error {
+	rsp.RetCode = model.OK
+	token, err := b.mysqld.backup.PrepareStream(req.BackupDir, req.Method)
+	if err != nil {
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	rsp.Token = token
+	return nil
+}
+
+// WaitStream used to wait for the stream req.Token to be received,
+// returns the size and the checksum of the received stream.
+func (b *BackupRPC) WaitStream(req *model.BackupStreamRPCRequest, rsp *model.BackupStreamRPCResponse) error {
+	rsp.RetCode = model.OK
+	size, checksum, err := b.mysqld.backup.WaitStream(req.Token, req.Abort)
+	if err != nil {
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	rsp.Size = size
+	rsp.Checksum = checksum
+	return nil
+}
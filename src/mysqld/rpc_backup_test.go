@@ -9,8 +9,11 @@
 package mysqld
 
 import (
+	"io/ioutil"
 	"model"
+	"os"
 	"testing"
+	"time"
 	"xbase/common"
 	"xbase/xlog"
 
@@ -61,6 +64,58 @@ func TestBackupRPCBackupDo(t *testing.T) {
 	}
 }
 
+func TestBackupRPCStream(t *testing.T) {
+	endpoint, mysqld, donor, backupdir, cleanup := mockStream(t)
+	defer cleanup()
+	// the donor is the same node
+	donor.conf = mysqld.backup.conf
+	mysqld.backup.cmd = common.NewLinuxCommand(mysqld.log)
+	c, _ := MockGetClient(t, endpoint)
+
+	// prepare
+	token := func() string {
+		req := model.NewBackupStreamRPCRequest()
+		req.BackupDir = backupdir
+		rsp := model.NewBackupStreamRPCResponse(model.OK)
+		err := c.Call(model.RPCBackupPrepareStream, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.OK, rsp.RetCode)
+		return rsp.Token
+	}()
+
+	// do backup with the xenon transport
+	req := model.NewBackupRPCRequest()
+	req.Transport = model.BACKUP_TRANSPORT_XENON
+	req.StreamAddr = endpoint
+	req.StreamToken = token
+	rsp := model.NewBackupRPCResponse(model.OK)
+	err := c.Call(model.RPCBackupDo, req, rsp)
+	assert.Nil(t, err)
+	assert.Equal(t, model.OK, rsp.RetCode)
+
+	// wait
+	{
+		req := model.NewBackupStreamRPCRequest()
+		req.Token = token
+		wait := model.NewBackupStreamRPCResponse(model.OK)
+		err := c.Call(model.RPCBackupWaitStream, req, wait)
+		assert.Nil(t, err)
+		assert.Equal(t, model.OK, wait.RetCode)
+		assert.Equal(t, rsp.Size, wait.Size)
+		assert.Equal(t, rsp.Checksum, wait.Checksum)
+	}
+
+	// the stream is removed after waited
+	{
+		wait := model.NewBackupStreamRPCResponse(model.OK)
+		req := model.NewBackupStreamRPCRequest()
+		req.Token = token
+		err := c.Call(model.RPCBackupWaitStream, req, wait)
+		assert.Nil(t, err)
+		assert.Equal(t, "stream["+token+"].not.found", wait.RetCode)
+	}
+}
+
 func TestBackupRPCApplyLog(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
 	port := common.RandomPort(8000, 9000)
@@ -99,3 +154,133 @@ func TestBackupRPCApplyLog(t *testing.T) {
 		}
 	}
 }
+
+func TestBackupRPCGetBackupStatus(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	endpoint, mysqld, cleanup := MockMysqld(log, port)
+	defer cleanup()
+	mysqld.backup.progress.parse(">> log scanned up to (2616853)")
+
+	c, _ := MockGetClient(t, endpoint)
+	method := model.RPCBackupStatus
+	req := model.NewBackupRPCRequest()
+	rsp := model.NewBackupStatusRPCResponse(model.OK)
+	err := c.Call(method, req, rsp)
+	assert.Nil(t, err)
+	assert.Equal(t, model.OK, rsp.RetCode)
+	assert.Equal(t, model.MYSQLD_BACKUPNONE, rsp.Status)
+	assert.Equal(t, uint64(2616853), rsp.Stats.Progress.LSN)
+}
+
+func TestBackupRPCArchives(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	endpoint, mysqld, cleanup := MockMysqld(log, port)
+	defer cleanup()
+
+	tmp, err := ioutil.TempDir("", "xenon-archive")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmp)
+	mysqld.conf.ArchiveDir = tmp
+	mockManifest(t, mysqld.backup, "20200601100000", time.Now())
+
+	c, _ := MockGetClient(t, endpoint)
+
+	// list
+	{
+		req := model.NewBackupArchiveRPCRequest()
+		rsp := model.NewBackupArchiveRPCResponse(model.OK)
+		err := c.Call(model.RPCBackupList, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.OK, rsp.RetCode)
+		assert.Equal(t, 1, len(rsp.Manifests))
+		assert.Equal(t, "20200601100000", rsp.Manifests[0].ID)
+	}
+
+	// show
+	{
+		req := model.NewBackupArchiveRPCRequest()
+		req.ID = "20200601100000"
+		rsp := model.NewBackupArchiveRPCResponse(model.OK)
+		err := c.Call(model.RPCBackupShow, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.OK, rsp.RetCode)
+		assert.Equal(t, "full", rsp.Manifests[0].Type)
+	}
+
+	// delete
+	{
+		req := model.NewBackupArchiveRPCRequest()
+		req.ID = "20200601100000"
+		rsp := model.NewBackupArchiveRPCResponse(model.OK)
+		err := c.Call(model.RPCBackupDelete, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.OK, rsp.RetCode)
+
+		err = c.Call(model.RPCBackupShow, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, "archive[20200601100000].not.found", rsp.RetCode)
+	}
+}
+
+func TestBackupRPCRestoreArchive(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	endpoint, mysqld, cleanup := MockMysqld(log, port)
+	defer cleanup()
+
+	tmp, err := ioutil.TempDir("", "xenon-archive")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmp)
+	mysqld.conf.ArchiveDir = tmp
+	mysqld.backup.SetCMDHandler(common.NewMockReplayCommand([]string{"completed OK!", "completed OK!"}))
+	mockChain(t, mysqld.backup, "20200601100000", "20200601110000")
+
+	c, _ := MockGetClient(t, endpoint)
+	req := model.NewBackupArchiveRPCRequest()
+	req.ID = "20200601110000"
+	req.TargetDir = tmp + "/restore"
+	rsp := model.NewBackupArchiveRPCResponse(model.OK)
+	err = c.Call(model.RPCBackupRestore, req, rsp)
+	assert.Nil(t, err)
+	assert.Equal(t, model.OK, rsp.RetCode)
+
+	// the base is deleted
+	os.RemoveAll(tmp + "/20200601100000")
+	req.TargetDir = tmp + "/restore1"
+	err = c.Call(model.RPCBackupRestore, req, rsp)
+	assert.Nil(t, err)
+	assert.Equal(t, "archive[20200601110000].chain.broken[base[20200601100000].not.found]", rsp.RetCode)
+}
+
+func TestBackupRPCReplayBinlogs(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	endpoint, mysqld, cleanup := MockMysqld(log, port)
+	defer cleanup()
+
+	tmp, err := ioutil.TempDir("", "xenon-archive")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmp)
+	mysqld.conf.ArchiveDir = tmp
+	mysqld.conf.BinlogArchiveDir = tmp + "/binlog"
+	os.MkdirAll(mysqld.conf.BinlogArchiveDir, 0755)
+	ioutil.WriteFile(mysqld.conf.BinlogArchiveDir+"/mysql-bin.000002", []byte("binlog"), 0644)
+	mysqld.backup.SetCMDHandler(common.NewMockReplayCommand(nil))
+	mockArchive(t, mysqld.backup, &model.BackupManifest{ID: "20200601100000", Type: model.BACKUP_TYPE_FULL, BinlogFile: "mysql-bin.000002", BinlogPos: 154})
+
+	c, _ := MockGetClient(t, endpoint)
+	req := model.NewBackupArchiveRPCRequest()
+	req.ID = "20200601100000"
+	req.StopDatetime = "2020-06-01 12:00:00"
+	rsp := model.NewBackupArchiveRPCResponse(model.OK)
+	err = c.Call(model.RPCBackupReplay, req, rsp)
+	assert.Nil(t, err)
+	assert.Equal(t, model.OK, rsp.RetCode)
+
+	req.ID = "20200601110000"
+	err = c.Call(model.RPCBackupReplay, req, rsp)
+	assert.Nil(t, err)
+	assert.Equal(t, "archive[20200601110000].not.found", rsp.RetCode)
+}
@@ -0,0 +1,215 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysqld
+
+import (
+	"config"
+	"model"
+	"sync"
+	"time"
+	"xbase/xlog"
+)
+
+// BackupChooser decides whether this node takes the scheduled backup,
+// the chooser may dispatch the backup to another node instead.
+type BackupChooser func(incremental bool) bool
+
+// Scheduler used to take the local backups and verify the latest one on the cron-like schedules.
+type Scheduler struct {
+	log     *xlog.Log
+	conf    *config.BackupConfig
+	backup  *Backup
+	chooser BackupChooser
+	mutex   sync.Mutex
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewScheduler creates the new Scheduler.
+func NewScheduler(conf *config.BackupConfig, log *xlog.Log, backup *Backup) *Scheduler {
+	return &Scheduler{
+		log:    log,
+		conf:   conf,
+		backup: backup,
+	}
+}
+
+// Start used to start the schedule loops, nothing to do if all the schedules are empty.
+func (s *Scheduler) Start() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.conf.Schedule == "" && s.conf.IncrementalSchedule == "" && s.conf.VerifySchedule == "" {
+		s.log.Info("backup.scheduler.disabled")
+		return nil
+	}
+	if s.stop != nil {
+		return nil
+	}
+
+	var full, incremental, verify *cronSchedule
+	var err error
+	if s.conf.Schedule != "" {
+		if full, err = parseCron(s.conf.Schedule); err != nil {
+			return err
+		}
+	}
+	if s.conf.IncrementalSchedule != "" {
+		if incremental, err = parseCron(s.conf.IncrementalSchedule); err != nil {
+			return err
+		}
+	}
+	if s.conf.VerifySchedule != "" {
+		if verify, err = parseCron(s.conf.VerifySchedule); err != nil {
+			return err
+		}
+	}
+
+	s.stop = make(chan struct{})
+	if full != nil || incremental != nil {
+		s.wg.Add(1)
+		go func(stop chan struct{}) {
+			defer s.wg.Done()
+			s.loop(full, incremental, stop)
+		}(s.stop)
+	}
+	if verify != nil {
+		s.wg.Add(1)
+		go func(stop chan struct{}) {
+			defer s.wg.Done()
+			s.verifyLoop(verify, stop)
+		}(s.stop)
+	}
+	s.log.Info("backup.scheduler[full:%v, incremental:%v, verify:%v].start...", s.conf.Schedule, s.conf.IncrementalSchedule, s.conf.VerifySchedule)
+	return nil
+}
+
+// Stop used to stop the schedule loop and wait for it exits.
+func (s *Scheduler) Stop() {
+	s.mutex.Lock()
+	if s.stop == nil {
+		s.mutex.Unlock()
+		return
+	}
+	close(s.stop)
+	s.stop = nil
+	s.mutex.Unlock()
+
+	s.wg.Wait()
+	s.log.Info("backup.scheduler.stop...")
+}
+
+// nextBackup returns the next backup time and whether it is incremental,
+// the full wins if both are at the same time.
+func nextBackup(full *cronSchedule, incremental *cronSchedule, now time.Time) (time.Time, bool) {
+	var next, nextIncremental time.Time
+	if full != nil {
+		next = full.next(now)
+	}
+	if incremental != nil {
+		nextIncremental = incremental.next(now)
+	}
+	if !nextIncremental.IsZero() && (next.IsZero() || nextIncremental.Before(next)) {
+		return nextIncremental, true
+	}
+	return next, false
+}
+
+func (s *Scheduler) loop(full *cronSchedule, incremental *cronSchedule, stop chan struct{}) {
+	for {
+		next, isIncremental := nextBackup(full, incremental, time.Now())
+		if next.IsZero() {
+			s.log.Error("backup.scheduler[full:%v, incremental:%v].has.no.next.time", s.conf.Schedule, s.conf.IncrementalSchedule)
+			return
+		}
+
+		s.log.Info("backup.scheduler.next.backup.at[%v].incremental[%v]", next, isIncremental)
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+			s.fire(isIncremental)
+		}
+	}
+}
+
+func (s *Scheduler) fire(incremental bool) {
+	log := s.log
+
+	if s.chooser != nil && !s.chooser(incremental) {
+		log.Info("backup.scheduler.skip[not.the.chosen.node]")
+		return
+	}
+	s.take(incremental)
+}
+
+// take used to take the local backup and prune the archives.
+func (s *Scheduler) take(incremental bool) {
+	log := s.log
+
+	if _, err := s.backup.LocalBackup(incremental); err != nil {
+		log.Error("backup.scheduler.local.backup.error[%v]", err)
+		return
+	}
+
+	pruned, err := s.backup.PruneArchives()
+	if err != nil {
+		log.Error("backup.scheduler.prune.archives.error[%v]", err)
+	}
+	if len(pruned) > 0 {
+		log.Warning("backup.scheduler.pruned.archives%v", pruned)
+	}
+}
+
+func (s *Scheduler) verifyLoop(verify *cronSchedule, stop chan struct{}) {
+	for {
+		next := verify.next(time.Now())
+		if next.IsZero() {
+			s.log.Error("backup.scheduler[verify:%v].has.no.next.time", s.conf.VerifySchedule)
+			return
+		}
+
+		s.log.Info("backup.scheduler.next.verify.at[%v]", next)
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+			s.fireVerify()
+		}
+	}
+}
+
+// fireVerify verifies the latest archive on this node, the archives are local so the chooser is not asked.
+func (s *Scheduler) fireVerify() {
+	log := s.log
+
+	manifests, err := s.backup.ListArchives()
+	if err != nil {
+		log.Error("backup.scheduler.list.archives.error[%v]", err)
+		return
+	}
+	if len(manifests) == 0 {
+		log.Info("backup.scheduler.skip.verify[no.archives]")
+		return
+	}
+
+	latest := manifests[len(manifests)-1]
+	verification, err := s.backup.VerifyArchive(latest.ID)
+	if err != nil {
+		log.Error("backup.scheduler.verify.archive[%v].error[%v]", latest.ID, err)
+		return
+	}
+	if verification.Result != model.BACKUP_VERIFY_PASSED {
+		log.Error("backup.scheduler.verify.archive[%v].failed[%v]", latest.ID, verification.Error)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysqld
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedulerStartStop(t *testing.T) {
+	backup, cleanup := mockArchiveBackup(t)
+	defer cleanup()
+	scheduler := NewScheduler(backup.conf, backup.log, backup)
+
+	// disabled
+	{
+		err := scheduler.Start()
+		assert.Nil(t, err)
+		scheduler.Stop()
+	}
+
+	// invalid
+	{
+		backup.conf.Schedule = "0 2 * *"
+		err := scheduler.Start()
+		assert.NotNil(t, err)
+	}
+
+	{
+		backup.conf.Schedule = "@daily"
+		err := scheduler.Start()
+		assert.Nil(t, err)
+		err = scheduler.Start()
+		assert.Nil(t, err)
+		scheduler.Stop()
+		scheduler.Stop()
+	}
+
+	// verify only
+	{
+		backup.conf.Schedule = ""
+		backup.conf.VerifySchedule = "@weekly"
+		err := scheduler.Start()
+		assert.Nil(t, err)
+		scheduler.Stop()
+	}
+}
+
+func TestSchedulerFire(t *testing.T) {
+	backup, cleanup := mockArchiveBackup(t)
+	defer cleanup()
+	scheduler := NewScheduler(backup.conf, backup.log, backup)
+	backup.conf.RetentionCount = 1
+
+	// not chosen
+	{
+		scheduler.chooser = func(incremental bool) bool { return false }
+		scheduler.fire(false)
+		manifests, err := backup.ListArchives()
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(manifests))
+	}
+
+	// chosen, the old archive is pruned
+	{
+		mockManifest(t, backup, "20200601100000", time.Now())
+		scheduler.chooser = func(incremental bool) bool { return true }
+		scheduler.fire(false)
+		manifests, err := backup.ListArchives()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(manifests))
+		assert.NotEqual(t, "20200601100000", manifests[0].ID)
+	}
+}
+
+func TestSchedulerNextBackup(t *testing.T) {
+	full, err := parseCron("0 2 * * 0")
+	assert.Nil(t, err)
+	incremental, err := parseCron("0 2 * * *")
+	assert.Nil(t, err)
+
+	// both at sunday 02:00, the full wins
+	now := time.Date(2020, 6, 6, 10, 0, 0, 0, time.Local)
+	next, isIncremental := nextBackup(full, incremental, now)
+	assert.Equal(t, time.Date(2020, 6, 7, 2, 0, 0, 0, time.Local), next)
+	assert.False(t, isIncremental)
+
+	// monday 02:00 is incremental only
+	now = time.Date(2020, 6, 7, 10, 0, 0, 0, time.Local)
+	next, isIncremental = nextBackup(full, incremental, now)
+	assert.Equal(t, time.Date(2020, 6, 8, 2, 0, 0, 0, time.Local), next)
+	assert.True(t, isIncremental)
+
+	// only incremental
+	next, isIncremental = nextBackup(nil, incremental, now)
+	assert.Equal(t, time.Date(2020, 6, 8, 2, 0, 0, 0, time.Local), next)
+	assert.True(t, isIncremental)
+}
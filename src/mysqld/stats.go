@@ -45,6 +45,7 @@ func (s *Backup) getStats() *model.BackupStats {
 		AppLogs:    atomic.LoadUint64(&s.stats.AppLogs),
 		AppLogErrs: atomic.LoadUint64(&s.stats.AppLogErrs),
 		Cancels:    atomic.LoadUint64(&s.stats.Cancels),
+		Progress:   s.progress.get(),
 	}
 }
 
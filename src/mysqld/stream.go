@@ -0,0 +1,748 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysqld
+
+import (
+	"bufio"
+	"bytes"
+	"config"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"model"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"xbase/xlog"
+	"xbase/xrpc"
+
+	"github.com/pkg/errors"
+)
+
+// The stream protocol:
+// donor    --> "<token>\n"
+// receiver --> "<offset>\n", the bytes which have been received, the donor resends from it
+// donor    --> frames of [4 bytes length][data], a zero length frame ends the stream
+// receiver --> the 8 bytes offset after each frame is piped into xbstream, streamEndAck after the end
+const (
+	// streamDialTimeout is the timeout(ms) to dial the receiver
+	streamDialTimeout = 1000 * 5
+
+	// streamConnectTimeout is the time to wait for the donor to connect the prepared stream
+	streamConnectTimeout = time.Second * 30
+
+	// streamResumeTimeout is the time to wait for the donor to resume an interrupted stream
+	streamResumeTimeout = time.Second * 30
+
+	// streamIOTimeout is the timeout of writing a frame or the offset
+	streamIOTimeout = time.Second * 60
+
+	// streamFrameSize is the max data size of a frame
+	streamFrameSize = 1024 * 64
+
+	// streamWindowSize is the max bytes which are sent but not acked,
+	// the donor keeps them to resend after the stream is resumed
+	streamWindowSize = 1024 * 1024 * 64
+
+	// streamEndAck is the ack of the end frame
+	streamEndAck = ^uint64(0)
+)
+
+var (
+	// streamResumeRetries is the times to reconnect the receiver, it waits retry*streamResumeInterval before each one
+	streamResumeRetries  = 5
+	streamResumeInterval = time.Second
+)
+
+// streamDigest counts the bytes and the sha256 of the stream.
+type streamDigest struct {
+	hash hash.Hash
+	size uint64
+}
+
+func newStreamDigest() *streamDigest {
+	return &streamDigest{hash: sha256.New()}
+}
+
+func (d *streamDigest) Write(p []byte) (int, error) {
+	d.hash.Write(p)
+	d.size += uint64(len(p))
+	return len(p), nil
+}
+
+func (d *streamDigest) checksum() string {
+	return hex.EncodeToString(d.hash.Sum(nil))
+}
+
+// rateLimiter limits the stream to rate bytes per second, 0 means no limit.
+type rateLimiter struct {
+	rate  int64
+	start time.Time
+	bytes int64
+}
+
+func newRateLimiter(rate int64) *rateLimiter {
+	return &rateLimiter{rate: rate, start: time.Now()}
+}
+
+// wait sleeps until the n bytes are allowed, the stall longer than a second is not made up.
+func (l *rateLimiter) wait(n int) {
+	if l.rate <= 0 {
+		return
+	}
+	l.bytes += int64(n)
+	expected := time.Duration(float64(l.bytes) / float64(l.rate) * float64(time.Second))
+	d := expected - time.Since(l.start)
+	switch {
+	case d > 0:
+		time.Sleep(d)
+	case d < -time.Second:
+		l.start = time.Now()
+		l.bytes = 0
+	}
+}
+
+// writeStreamFrames writes the data as frames, the zero length data is the end frame.
+func writeStreamFrames(conn net.Conn, data []byte) error {
+	header := make([]byte, 4)
+	for {
+		n := len(data)
+		if n > streamFrameSize {
+			n = streamFrameSize
+		}
+		binary.BigEndian.PutUint32(header, uint32(n))
+		conn.SetWriteDeadline(time.Now().Add(streamIOTimeout))
+		if _, err := conn.Write(header); err != nil {
+			return errors.WithStack(err)
+		}
+		if _, err := conn.Write(data[:n]); err != nil {
+			return errors.WithStack(err)
+		}
+		if data = data[n:]; len(data) == 0 {
+			return nil
+		}
+	}
+}
+
+// streamWriter writes the xtrabackup outputs to the receiver as frames and digests them.
+// The frames which are not acked are kept in the window, if the conn is broken,
+// it reconnects and resends them from the offset which the receiver has.
+type streamWriter struct {
+	log     *xlog.Log
+	backup  *Backup
+	req     *model.BackupRPCRequest
+	digest  *streamDigest
+	limiter *rateLimiter
+
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	conn   net.Conn
+	broken bool
+	window []byte
+	base   uint64
+	sent   uint64
+	ended  bool
+
+	resumes int
+	err     error
+}
+
+func newStreamWriter(b *Backup, req *model.BackupRPCRequest) *streamWriter {
+	w := &streamWriter{
+		log:     b.log,
+		backup:  b,
+		req:     req,
+		digest:  newStreamDigest(),
+		limiter: newRateLimiter(req.Rate),
+	}
+	w.cond = sync.NewCond(&w.mutex)
+	return w
+}
+
+// connect dials the receiver and returns the offset which it has.
+func (w *streamWriter) connect() (net.Conn, *bufio.Reader, uint64, error) {
+	conn, err := w.backup.dialStream(w.req)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	reader := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(streamIOTimeout))
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, nil, 0, errors.WithStack(err)
+	}
+	conn.SetReadDeadline(time.Time{})
+	offset, err := strconv.ParseUint(strings.TrimSpace(line), 10, 64)
+	if err != nil {
+		conn.Close()
+		return nil, nil, 0, errors.WithStack(err)
+	}
+	return conn, reader, offset, nil
+}
+
+// start connects the receiver for the first time.
+func (w *streamWriter) start() error {
+	conn, reader, offset, err := w.connect()
+	if err != nil {
+		return err
+	}
+	if offset != 0 {
+		conn.Close()
+		return errors.Errorf("backup.stream[%v].is.not.new.offset[%v]", w.req.StreamToken, offset)
+	}
+	w.conn = conn
+	go w.readAcks(conn, reader)
+	return nil
+}
+
+// readAcks moves the window forward on the acks of the receiver.
+func (w *streamWriter) readAcks(conn net.Conn, reader *bufio.Reader) {
+	buf := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			w.setBroken(conn)
+			return
+		}
+		offset := binary.BigEndian.Uint64(buf)
+
+		w.mutex.Lock()
+		if w.conn == conn {
+			if offset == streamEndAck {
+				w.ended = true
+			} else if offset > w.base && offset <= w.sent {
+				w.window = w.window[offset-w.base:]
+				w.base = offset
+			}
+		}
+		w.cond.Broadcast()
+		w.mutex.Unlock()
+	}
+}
+
+func (w *streamWriter) setBroken(conn net.Conn) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.conn == conn && !w.broken {
+		w.broken = true
+		conn.Close()
+	}
+	w.cond.Broadcast()
+}
+
+// resume reconnects the receiver and resends the window from the offset which it has.
+func (w *streamWriter) resume() error {
+	log := w.log
+
+	for retry := 1; retry <= streamResumeRetries; retry++ {
+		time.Sleep(time.Duration(retry) * streamResumeInterval)
+		conn, reader, offset, err := w.connect()
+		if err != nil {
+			log.Error("backup.stream.to[%v].resume.retry[%v].error[%v]", w.req.StreamAddr, retry, err)
+			continue
+		}
+
+		w.mutex.Lock()
+		if offset < w.base || offset > w.sent {
+			w.mutex.Unlock()
+			conn.Close()
+			return errors.Errorf("backup.stream.resume.offset[%v].out.of.window[%v-%v]", offset, w.base, w.sent)
+		}
+		w.window = w.window[offset-w.base:]
+		w.base = offset
+		pending := append([]byte(nil), w.window...)
+		w.conn = conn
+		w.broken = false
+		w.mutex.Unlock()
+
+		go w.readAcks(conn, reader)
+		if len(pending) > 0 {
+			if err := writeStreamFrames(conn, pending); err != nil {
+				log.Error("backup.stream.to[%v].resend.retry[%v].error[%v]", w.req.StreamAddr, retry, err)
+				w.setBroken(conn)
+				continue
+			}
+		}
+		w.resumes++
+		log.Warning("backup.stream.to[%v].resumed.from.checkpoint[%v].resent[%v].bytes", w.req.StreamAddr, offset, len(pending))
+		return nil
+	}
+	return errors.Errorf("backup.stream.to[%v].resume.failed.after[%v].retries", w.req.StreamAddr, streamResumeRetries)
+}
+
+// send sends the chunk and keeps it in the window until it's acked.
+func (w *streamWriter) send(chunk []byte) error {
+	w.mutex.Lock()
+	for len(w.window)+len(chunk) > streamWindowSize && !w.broken {
+		w.cond.Wait()
+	}
+	w.window = append(w.window, chunk...)
+	w.sent += uint64(len(chunk))
+	conn, broken := w.conn, w.broken
+	w.mutex.Unlock()
+
+	if !broken {
+		err := writeStreamFrames(conn, chunk)
+		if err == nil {
+			return nil
+		}
+		w.log.Error("backup.stream.to[%v].write.error[%v].prepare.to.resume", w.req.StreamAddr, err)
+		w.setBroken(conn)
+	}
+	return w.resume()
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.digest.Write(p)
+	for data := p; len(data) > 0; {
+		n := len(data)
+		if n > streamFrameSize {
+			n = streamFrameSize
+		}
+		w.limiter.wait(n)
+		if err := w.send(data[:n]); err != nil {
+			w.err = err
+			return 0, err
+		}
+		data = data[n:]
+	}
+	return len(p), nil
+}
+
+// finish sends the end frame and waits for the receiver acks it.
+func (w *streamWriter) finish() error {
+	if w.err != nil {
+		return w.err
+	}
+	for {
+		w.mutex.Lock()
+		conn, broken := w.conn, w.broken
+		w.mutex.Unlock()
+
+		if !broken {
+			if err := writeStreamFrames(conn, nil); err != nil {
+				w.setBroken(conn)
+			} else {
+				w.mutex.Lock()
+				for !w.ended && !w.broken {
+					w.cond.Wait()
+				}
+				ended := w.ended
+				w.mutex.Unlock()
+				if ended {
+					conn.Close()
+					return nil
+				}
+			}
+		}
+		if err := w.resume(); err != nil {
+			w.err = err
+			return err
+		}
+	}
+}
+
+// close closes the conn if the backup failed.
+func (w *streamWriter) close() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.conn != nil {
+		w.conn.Close()
+	}
+}
+
+// streamJob is a prepared stream on the receiver.
+type streamJob struct {
+	token  string
+	dir    string
+	method string
+
+	// xbstream is started on the first connection
+	started bool
+
+	// a donor connection is streaming
+	active bool
+
+	// the end frame is received or the job is aborted
+	ended bool
+
+	// the connections, the resume timer checks it
+	conns int
+
+	// the checkpoint: the bytes which have been piped into xbstream
+	offset uint64
+
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	outs   bytes.Buffer
+	digest *streamDigest
+	done   chan struct{}
+
+	size     uint64
+	checksum string
+	err      error
+}
+
+// streamTLSConfig returns the TLS config of the xenon stream, nil means the plain TCP.
+func streamTLSConfig(conf *config.BackupConfig, server bool) (*tls.Config, error) {
+	if conf.StreamTLSCertFile == "" || conf.StreamTLSKeyFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(conf.StreamTLSCertFile, conf.StreamTLSKeyFile)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	tlsConf := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	if conf.StreamTLSCAFile == "" {
+		tlsConf.InsecureSkipVerify = true
+		return tlsConf, nil
+	}
+	ca, err := ioutil.ReadFile(conf.StreamTLSCAFile)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.Errorf("stream.tls.ca[%v].invalid", conf.StreamTLSCAFile)
+	}
+	if server {
+		tlsConf.ClientCAs = pool
+		tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
+	} else {
+		tlsConf.RootCAs = pool
+	}
+	return tlsConf, nil
+}
+
+// PrepareStream registers a stream which will be extracted into the dir,
+// the logical dump is written by the dump sink instead of xbstream,
+// returns the token which the donor sends first on the stream.
+func (b *Backup) PrepareStream(dir string, method string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.WithStack(err)
+	}
+	token := hex.EncodeToString(buf)
+
+	b.streamMutex.Lock()
+	defer b.streamMutex.Unlock()
+	b.streams[token] = &streamJob{token: token, dir: dir, method: method, digest: newStreamDigest(), done: make(chan struct{})}
+	b.log.Warning("stream[%v].prepared.to[%v]", token, dir)
+	return token, nil
+}
+
+// connectStream marks the stream active, only one connection streams at a time,
+// xbstream is started on the first connection.
+func (b *Backup) connectStream(token string) (*streamJob, uint64, error) {
+	b.streamMutex.Lock()
+	defer b.streamMutex.Unlock()
+	job, ok := b.streams[token]
+	if !ok {
+		return nil, 0, errors.Errorf("stream[%v].not.found", token)
+	}
+	if job.active {
+		return nil, 0, errors.Errorf("stream[%v].already.connected", token)
+	}
+	if job.ended {
+		return nil, 0, errors.Errorf("stream[%v].already.ended", token)
+	}
+	if !job.started {
+		if err := b.startXbstream(job); err != nil {
+			return nil, 0, err
+		}
+		job.started = true
+	}
+	job.active = true
+	job.conns++
+	return job, job.offset, nil
+}
+
+// startXbstream starts the xbstream which extracts the stream into the job dir.
+func (b *Backup) startXbstream(job *streamJob) error {
+	xbstream := fmt.Sprintf("%s/xbstream", b.conf.XtrabackupBinDir)
+	cmd := exec.Command(xbstream, "-x", "-C", job.dir)
+	if isLogicalMethod(job.method) {
+		cmd = exec.Command(bash, "-c", dumpSink(job.method, job.dir))
+	}
+	cmd.Stdout = &job.outs
+	cmd.Stderr = &job.outs
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := cmd.Start(); err != nil {
+		return errors.WithStack(err)
+	}
+	job.cmd = cmd
+	job.stdin = stdin
+	b.log.Warning("stream[%v].cmd[%v].started", job.token, strings.Join(cmd.Args, " "))
+
+	go func() {
+		err := cmd.Wait()
+		b.streamMutex.Lock()
+		defer b.streamMutex.Unlock()
+		if err != nil && job.err == nil {
+			job.err = errors.Errorf("stream[%v].xbstream.error[%v].outs[%s]", job.token, err, strings.TrimSpace(job.outs.String()))
+		}
+		if job.err == nil && !job.ended {
+			job.err = errors.Errorf("stream[%v].xbstream.exited.before.the.end", job.token)
+		}
+		job.size = job.digest.size
+		job.checksum = job.digest.checksum()
+		close(job.done)
+	}()
+	return nil
+}
+
+// disconnectStream marks the stream inactive, the job is aborted if the donor
+// does not resume it in streamResumeTimeout.
+func (b *Backup) disconnectStream(job *streamJob) {
+	b.streamMutex.Lock()
+	defer b.streamMutex.Unlock()
+	job.active = false
+	if job.ended {
+		return
+	}
+
+	conns := job.conns
+	b.log.Warning("stream[%v].interrupted.at.checkpoint[%v].wait.for.resume", job.token, job.offset)
+	time.AfterFunc(streamResumeTimeout, func() {
+		b.streamMutex.Lock()
+		defer b.streamMutex.Unlock()
+		if job.conns == conns && !job.active && !job.ended {
+			b.abortStream(job, errors.Errorf("stream[%v].interrupted.at[%v].not.resumed.in[%v]", job.token, job.offset, streamResumeTimeout))
+		}
+	})
+}
+
+// abortStream kills the xbstream, the streamMutex must be held.
+func (b *Backup) abortStream(job *streamJob, err error) {
+	b.log.Error("stream[%v].abort[%v]", job.token, err)
+	if job.err == nil {
+		job.err = err
+	}
+	job.ended = true
+	job.stdin.Close()
+	job.cmd.Process.Kill()
+}
+
+// HandleStream used to receive the stream from the donor and pipe it into xbstream,
+// the donor reconnects and resumes from the checkpoint if the conn is broken.
+func (b *Backup) HandleStream(conn net.Conn) {
+	log := b.log
+	defer conn.Close()
+
+	tlsConf, err := streamTLSConfig(b.conf, true)
+	if err != nil {
+		log.Error("stream.tls.config.error[%+v]", err)
+		return
+	}
+	if tlsConf != nil {
+		conn = tls.Server(conn, tlsConf)
+	}
+
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		log.Error("stream.from[%v].read.token.error[%v]", conn.RemoteAddr(), err)
+		return
+	}
+	token := strings.TrimSpace(line)
+	job, offset, err := b.connectStream(token)
+	if err != nil {
+		log.Error("stream.from[%v].error[%v]", conn.RemoteAddr(), err)
+		return
+	}
+	defer b.disconnectStream(job)
+
+	log.Warning("stream[%v].from[%v].connected.at.checkpoint[%v]", token, conn.RemoteAddr(), offset)
+	conn.SetWriteDeadline(time.Now().Add(streamIOTimeout))
+	if _, err := fmt.Fprintf(conn, "%d\n", offset); err != nil {
+		log.Error("stream[%v].write.offset.error[%v]", token, err)
+		return
+	}
+
+	header := make([]byte, 4)
+	ack := make([]byte, 8)
+	buf := make([]byte, streamFrameSize)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			log.Error("stream[%v].read.frame.error[%v]", token, err)
+			return
+		}
+		n := binary.BigEndian.Uint32(header)
+		if n > streamFrameSize {
+			log.Error("stream[%v].frame.size[%v].too.large", token, n)
+			return
+		}
+
+		// the end of the stream
+		if n == 0 {
+			b.streamMutex.Lock()
+			job.ended = true
+			job.stdin.Close()
+			b.streamMutex.Unlock()
+			<-job.done
+
+			binary.BigEndian.PutUint64(ack, streamEndAck)
+			conn.SetWriteDeadline(time.Now().Add(streamIOTimeout))
+			conn.Write(ack)
+			log.Warning("stream[%v].received[%v].bytes.checksum[%v].error[%v]", token, job.size, job.checksum, job.err)
+			return
+		}
+
+		if _, err := io.ReadFull(reader, buf[:n]); err != nil {
+			log.Error("stream[%v].read.frame.error[%v]", token, err)
+			return
+		}
+		if _, err := job.stdin.Write(buf[:n]); err != nil {
+			b.streamMutex.Lock()
+			b.abortStream(job, errors.Errorf("stream[%v].write.xbstream.error[%v]", token, err))
+			b.streamMutex.Unlock()
+			return
+		}
+		b.streamMutex.Lock()
+		job.digest.Write(buf[:n])
+		job.offset += uint64(n)
+		offset = job.offset
+		b.streamMutex.Unlock()
+
+		binary.BigEndian.PutUint64(ack, offset)
+		conn.SetWriteDeadline(time.Now().Add(streamIOTimeout))
+		if _, err := conn.Write(ack); err != nil {
+			log.Error("stream[%v].write.ack.error[%v]", token, err)
+			return
+		}
+	}
+}
+
+// WaitStream waits for the stream to be received and returns the size and the checksum,
+// abort kills the stream which is not ended.
+func (b *Backup) WaitStream(token string, abort bool) (uint64, string, error) {
+	b.streamMutex.Lock()
+	job, ok := b.streams[token]
+	b.streamMutex.Unlock()
+	if !ok {
+		return 0, "", errors.Errorf("stream[%v].not.found", token)
+	}
+
+	// removes the job if it's not started, otherwise aborts it with the err if it's not nil
+	removed := func(err error) bool {
+		b.streamMutex.Lock()
+		defer b.streamMutex.Unlock()
+		if !job.started {
+			delete(b.streams, token)
+			return true
+		}
+		if err != nil && !job.ended {
+			b.abortStream(job, err)
+		}
+		return false
+	}
+	if abort {
+		err := errors.Errorf("stream[%v].aborted", token)
+		if removed(err) {
+			return 0, "", err
+		}
+	}
+
+	select {
+	case <-job.done:
+	case <-time.After(streamConnectTimeout):
+		if removed(nil) {
+			return 0, "", errors.Errorf("stream[%v].not.connected.in[%v]", token, streamConnectTimeout)
+		}
+		<-job.done
+	}
+
+	b.streamMutex.Lock()
+	delete(b.streams, token)
+	b.streamMutex.Unlock()
+	return job.size, job.checksum, job.err
+}
+
+// dialStream dials the receiver and sends the token line.
+func (b *Backup) dialStream(req *model.BackupRPCRequest) (net.Conn, error) {
+	tlsConf, err := streamTLSConfig(b.conf, false)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := xrpc.DialStream(req.StreamAddr, streamDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConf != nil {
+		if tlsConf.RootCAs != nil {
+			host, _, _ := net.SplitHostPort(req.StreamAddr)
+			tlsConf.ServerName = host
+		}
+		conn = tls.Client(conn, tlsConf)
+	}
+	if _, err := fmt.Fprintf(conn, "%s\n", req.StreamToken); err != nil {
+		conn.Close()
+		return nil, errors.WithStack(err)
+	}
+	return conn, nil
+}
+
+// StreamBackup used to stream the xtrabackup outputs to the receiver through its xenon port,
+// limited to req.Rate bytes per second, returns the size and the sha256 checksum of the stream.
+func (b *Backup) StreamBackup(req *model.BackupRPCRequest) (uint64, string, error) {
+	log := b.log
+
+	log.Info("backup.stream.prepare.to.run")
+	if b.getStatus() == model.MYSQLD_BACKUPING {
+		return 0, "", errors.New("do.backup.error[backup.job.is.already.running]")
+	}
+	if err := checkBackupMethod(req); err != nil {
+		return 0, "", err
+	}
+	if err := checkStreamOptions(req.Compress, req.Encrypt, req.EncryptKeyFile); err != nil {
+		return 0, "", err
+	}
+	if req.StreamAddr == "" || req.StreamToken == "" {
+		return 0, "", errors.New("backup.stream.addr.or.token.is.empty")
+	}
+
+	w := newStreamWriter(b, req)
+	if err := w.start(); err != nil {
+		log.Error("backup.stream.connect[%v].error[%+v]", req.StreamAddr, err)
+		b.setLastError(err.Error())
+		return 0, "", err
+	}
+	log.Warning("backup.stream.to[%v].connected.rate[%v]", req.StreamAddr, req.Rate)
+
+	args := []string{
+		"-c",
+		fmt.Sprintf("%s%s --target-dir=./", b.xtrabackupCommand(req.IOPSLimits), b.streamOptions(req.Compress, req.Encrypt, req.EncryptKeyFile)),
+	}
+	if isLogicalMethod(req.Method) {
+		args = b.streamDumpCommands(req.Method)
+	}
+	if err := b.runBackup(args, w); err != nil {
+		w.close()
+		return 0, "", err
+	}
+	log.Warning("backup.stream.sent[%v].bytes.checksum[%v].resumes[%v]", w.digest.size, w.digest.checksum(), w.resumes)
+	return w.digest.size, w.digest.checksum(), nil
+}
@@ -0,0 +1,286 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysqld
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"model"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const mockStreamXtrabackup = `#!/bin/bash
+echo "xbstream data"
+echo "200601 10:00:05 completed OK!" >&2
+`
+
+// xbstream -x -C dir
+const mockXbstream = `#!/bin/bash
+cat > $3/xbstream.out
+`
+
+// mockStream creates a receiver mysqld with the rpc server and a donor backup,
+// the xtrabackup and xbstream are replaced by the scripts.
+func mockStream(t *testing.T) (string, *Mysqld, *Backup, string, func()) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	endpoint, mysqld, cleanup := MockMysqld(log, port)
+
+	tmp, err := ioutil.TempDir("", "xenon-stream")
+	assert.Nil(t, err)
+	bindir := filepath.Join(tmp, "bin")
+	os.MkdirAll(bindir, 0755)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(bindir, "xtrabackup"), []byte(mockStreamXtrabackup), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(bindir, "xbstream"), []byte(mockXbstream), 0755))
+	mysqld.backup.conf.XtrabackupBinDir = bindir
+
+	conf := *mysqld.backup.conf
+	conf.BackupDir = filepath.Join(tmp, "donor")
+	donor := NewBackup(&conf, log)
+
+	backupdir := filepath.Join(tmp, "backup")
+	os.MkdirAll(backupdir, 0755)
+	return endpoint, mysqld, donor, backupdir, func() {
+		cleanup()
+		os.RemoveAll(tmp)
+	}
+}
+
+// mockStreamCert writes a self-signed cert for 127.0.0.1 which is also the CA.
+func mockStreamCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "xenon"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	certFile := filepath.Join(dir, "stream.crt")
+	keyFile := filepath.Join(dir, "stream.key")
+	assert.Nil(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	assert.Nil(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certFile, keyFile
+}
+
+func TestStreamBackup(t *testing.T) {
+	endpoint, mysqld, donor, backupdir, cleanup := mockStream(t)
+	defer cleanup()
+
+	sum := sha256.Sum256([]byte("xbstream data\n"))
+	checksum := hex.EncodeToString(sum[:])
+	stream := func() (uint64, string, uint64, string, error) {
+		token, err := mysqld.backup.PrepareStream(backupdir, "")
+		assert.Nil(t, err)
+
+		req := model.NewBackupRPCRequest()
+		req.Transport = model.BACKUP_TRANSPORT_XENON
+		req.StreamAddr = endpoint
+		req.StreamToken = token
+		sent, sentChecksum, err := donor.StreamBackup(req)
+		assert.Nil(t, err)
+
+		received, receivedChecksum, err := mysqld.backup.WaitStream(token, false)
+		return sent, sentChecksum, received, receivedChecksum, err
+	}
+
+	// plain tcp
+	{
+		sent, sentChecksum, received, receivedChecksum, err := stream()
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(14), sent)
+		assert.Equal(t, checksum, sentChecksum)
+		assert.Equal(t, sent, received)
+		assert.Equal(t, sentChecksum, receivedChecksum)
+		b, err := ioutil.ReadFile(filepath.Join(backupdir, "xbstream.out"))
+		assert.Nil(t, err)
+		assert.Equal(t, "xbstream data\n", string(b))
+	}
+
+	// tls with the peers verified by the CA
+	{
+		certFile, keyFile := mockStreamCert(t, backupdir)
+		for _, conf := range []*Backup{mysqld.backup, donor} {
+			conf.conf.StreamTLSCertFile = certFile
+			conf.conf.StreamTLSKeyFile = keyFile
+			conf.conf.StreamTLSCAFile = certFile
+		}
+		sent, sentChecksum, received, receivedChecksum, err := stream()
+		assert.Nil(t, err)
+		assert.Equal(t, checksum, sentChecksum)
+		assert.Equal(t, sent, received)
+		assert.Equal(t, sentChecksum, receivedChecksum)
+		for _, conf := range []*Backup{mysqld.backup, donor} {
+			conf.conf.StreamTLSCertFile = ""
+			conf.conf.StreamTLSKeyFile = ""
+			conf.conf.StreamTLSCAFile = ""
+		}
+	}
+
+	// xbstream failed
+	{
+		xbstream := filepath.Join(mysqld.backup.conf.XtrabackupBinDir, "xbstream")
+		ioutil.WriteFile(xbstream, []byte("#!/bin/bash\ncat > /dev/null\necho broken >&2\nexit 1\n"), 0755)
+		_, _, _, _, err := stream()
+		assert.True(t, strings.Contains(err.Error(), "xbstream.error[exit status 1].outs[broken]"))
+	}
+}
+
+func TestWaitStream(t *testing.T) {
+	_, mysqld, _, backupdir, cleanup := mockStream(t)
+	defer cleanup()
+
+	// not found
+	{
+		_, _, err := mysqld.backup.WaitStream("xx", false)
+		assert.Equal(t, "stream[xx].not.found", err.Error())
+	}
+
+	// abort the stream which is not connected
+	{
+		token, err := mysqld.backup.PrepareStream(backupdir, "")
+		assert.Nil(t, err)
+		_, _, err = mysqld.backup.WaitStream(token, true)
+		assert.Equal(t, "stream["+token+"].aborted", err.Error())
+		_, _, err = mysqld.backup.connectStream(token)
+		assert.Equal(t, "stream["+token+"].not.found", err.Error())
+	}
+
+	// connect one at a time, abort the started stream
+	{
+		token, err := mysqld.backup.PrepareStream(backupdir, "")
+		assert.Nil(t, err)
+		_, _, err = mysqld.backup.connectStream(token)
+		assert.Nil(t, err)
+		_, _, err = mysqld.backup.connectStream(token)
+		assert.Equal(t, "stream["+token+"].already.connected", err.Error())
+
+		_, _, err = mysqld.backup.WaitStream(token, true)
+		assert.Equal(t, "stream["+token+"].aborted", err.Error())
+	}
+}
+
+// mockStreamProxy forwards the conns to the endpoint, the first conn is cut after n bytes from the donor.
+func mockStreamProxy(t *testing.T, endpoint string, n int64) (string, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go func() {
+		first := true
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			upstream, err := net.Dial("tcp", endpoint)
+			if err != nil {
+				conn.Close()
+				return
+			}
+			go func() {
+				io.Copy(conn, upstream)
+				conn.Close()
+			}()
+			go func(cut bool) {
+				if cut {
+					io.CopyN(upstream, conn, n)
+				} else {
+					io.Copy(upstream, conn)
+				}
+				upstream.Close()
+				conn.Close()
+			}(first)
+			first = false
+		}
+	}()
+	return ln.Addr().String(), func() {
+		ln.Close()
+	}
+}
+
+func TestStreamBackupResume(t *testing.T) {
+	endpoint, mysqld, donor, backupdir, cleanup := mockStream(t)
+	defer cleanup()
+
+	streamResumeInterval = time.Millisecond * 10
+	defer func() { streamResumeInterval = time.Second }()
+
+	// 1MB stream, cut at 300KB
+	xtrabackup := filepath.Join(donor.conf.XtrabackupBinDir, "xtrabackup")
+	ioutil.WriteFile(xtrabackup, []byte("#!/bin/bash\nhead -c 1048576 /dev/urandom\necho \"completed OK!\" >&2\n"), 0755)
+	proxy, stop := mockStreamProxy(t, endpoint, 300*1024)
+	defer stop()
+
+	token, err := mysqld.backup.PrepareStream(backupdir, "")
+	assert.Nil(t, err)
+	req := model.NewBackupRPCRequest()
+	req.StreamAddr = proxy
+	req.StreamToken = token
+	sent, sentChecksum, err := donor.StreamBackup(req)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1048576), sent)
+
+	received, receivedChecksum, err := mysqld.backup.WaitStream(token, false)
+	assert.Nil(t, err)
+	assert.Equal(t, sent, received)
+	assert.Equal(t, sentChecksum, receivedChecksum)
+
+	// the extracted is the same as the sent
+	b, err := ioutil.ReadFile(filepath.Join(backupdir, "xbstream.out"))
+	assert.Nil(t, err)
+	sum := sha256.Sum256(b)
+	assert.Equal(t, sentChecksum, hex.EncodeToString(sum[:]))
+}
+
+func TestRateLimiter(t *testing.T) {
+	// no limit
+	{
+		limiter := newRateLimiter(0)
+		start := time.Now()
+		limiter.wait(1024 * 1024 * 1024)
+		assert.True(t, time.Since(start) < time.Millisecond*100)
+	}
+
+	// 1MB/s
+	{
+		limiter := newRateLimiter(1024 * 1024)
+		start := time.Now()
+		for i := 0; i < 8; i++ {
+			limiter.wait(64 * 1024)
+		}
+		assert.True(t, time.Since(start) >= time.Millisecond*450)
+	}
+}
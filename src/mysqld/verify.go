@@ -0,0 +1,312 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysqld
+
+import (
+	"fmt"
+	"io/ioutil"
+	"model"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// the files of the scratch mysqld under verify-dir/id
+	verifyCnfFile       = "verify.cnf"
+	verifyClientCnfFile = "verify-client.cnf"
+	localClientCnfFile  = "local-client.cnf"
+	verifySocketFile    = "mysql.sock"
+	verifyDataDir       = "data"
+)
+
+var (
+	// the scratch mysqld must accept the connections in time, including the crash recovery
+	verifyStartTimeout = time.Minute * 5
+	verifyPingInterval = time.Second
+)
+
+// verifyCnf returns the option file of the scratch mysqld, it includes the defaults-file
+// to keep the innodb options which the data depends on, and moves all the files into the dir.
+// The replication, the semi-sync and the events are off, nothing leaves the scratch mysqld.
+func (b *Backup) verifyCnf(dir string, port int) string {
+	datadir := filepath.Join(dir, verifyDataDir)
+	lines := []string{
+		fmt.Sprintf("!include %s", b.conf.DefaultsFile),
+		"",
+		"[mysqld]",
+		fmt.Sprintf("datadir=%s", datadir),
+		fmt.Sprintf("port=%d", port),
+		fmt.Sprintf("socket=%s", filepath.Join(dir, verifySocketFile)),
+		fmt.Sprintf("pid-file=%s", filepath.Join(dir, "mysqld.pid")),
+		fmt.Sprintf("log-error=%s", filepath.Join(dir, "mysqld.err")),
+		fmt.Sprintf("log-bin=%s", filepath.Join(dir, "mysql-bin")),
+		fmt.Sprintf("log-bin-index=%s", filepath.Join(dir, "mysql-bin.index")),
+		fmt.Sprintf("relay-log=%s", filepath.Join(dir, "relay-bin")),
+		fmt.Sprintf("relay-log-index=%s", filepath.Join(dir, "relay-bin.index")),
+		fmt.Sprintf("innodb_data_home_dir=%s", datadir),
+		fmt.Sprintf("innodb_log_group_home_dir=%s", datadir),
+		fmt.Sprintf("innodb_undo_directory=%s", datadir),
+		fmt.Sprintf("general_log_file=%s", filepath.Join(dir, "general.log")),
+		fmt.Sprintf("slow_query_log_file=%s", filepath.Join(dir, "slow.log")),
+		"skip-slave-start",
+		"event_scheduler=OFF",
+		"loose-mysqlx=OFF",
+		"loose-rpl_semi_sync_master_enabled=OFF",
+		"loose-rpl_semi_sync_slave_enabled=OFF",
+		"",
+		"[mysqld_safe]",
+		fmt.Sprintf("pid-file=%s", filepath.Join(dir, "mysqld.pid")),
+		fmt.Sprintf("log-error=%s", filepath.Join(dir, "mysqld.err")),
+		"",
+	}
+	return strings.Join(lines, "\n")
+}
+
+// verifyPort returns the verify-port, or a spare one if it's 0.
+func (b *Backup) verifyPort() (int, error) {
+	if b.conf.VerifyPort > 0 {
+		return b.conf.VerifyPort, nil
+	}
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
+
+// verifyClientCnf returns the client option file which carries the admin credentials,
+// the scratch mysqld is connected by the socket, the local mysql by the host and port if the socket is empty.
+// The password never goes into the command line, which is echoed in the errors.
+func (b *Backup) verifyClientCnf(socket string) string {
+	lines := []string{
+		"[client]",
+		fmt.Sprintf("user=%s", b.conf.Admin),
+	}
+	if b.conf.Passwd != "" {
+		lines = append(lines, fmt.Sprintf("password=%s", b.conf.Passwd))
+	}
+	if socket != "" {
+		lines = append(lines, fmt.Sprintf("socket=%s", socket))
+	} else {
+		lines = append(lines, fmt.Sprintf("host=%s", b.conf.Host), fmt.Sprintf("port=%d", b.conf.Port))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// verifyQuery runs the query by the mysql client with the client option file cnf.
+func (b *Backup) verifyQuery(cnf string, query string) (string, error) {
+	client := fmt.Sprintf("%s --defaults-extra-file=%s", filepath.Join(b.conf.Basedir, mysqlclient), cnf)
+	args := []string{
+		"-c",
+		fmt.Sprintf("%s -N -e \"%s\"", client, query),
+	}
+	outs, err := b.cmd.RunCommand(bash, args)
+	if err != nil {
+		// the outs leads with the command line, only the client errors are kept
+		outs = strings.TrimPrefix(outs, strings.Join(append([]string{bash}, args...), " "))
+		return "", errors.Errorf("verify.query[%s].error[%v].outs[%s]", query, err, strings.TrimSpace(outs))
+	}
+	return strings.TrimSpace(outs), nil
+}
+
+// verifyCount runs the COUNT query.
+func (b *Backup) verifyCount(cnf string, query string) (int, error) {
+	outs, err := b.verifyQuery(cnf, query)
+	if err != nil {
+		return 0, err
+	}
+	count, err := strconv.Atoi(outs)
+	if err != nil {
+		return 0, errors.Errorf("verify.query[%s].count[%s].invalid", query, outs)
+	}
+	return count, nil
+}
+
+// verifyChecksums returns the CHECKSUM TABLE results, the outputs are 'db.table checksum' per line.
+func (b *Backup) verifyChecksums(cnf string, tables []string) (map[string]string, error) {
+	outs, err := b.verifyQuery(cnf, fmt.Sprintf("CHECKSUM TABLE %s", strings.Join(tables, ", ")))
+	if err != nil {
+		return nil, err
+	}
+	checksums := make(map[string]string)
+	for _, line := range strings.Split(outs, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			checksums[fields[0]] = fields[1]
+		}
+	}
+	return checksums, nil
+}
+
+// startVerifyMysqld starts the scratch mysqld by the mysqld_safe with the option file,
+// and waits until it accepts the connections.
+func (b *Backup) startVerifyMysqld(args ArgsHandler, cnf string) error {
+	if _, err := b.cmd.RunCommandWithTimeout(3000, bash, args.Start()); err != nil {
+		return err
+	}
+
+	var err error
+	deadline := time.Now().Add(verifyStartTimeout)
+	for time.Now().Before(deadline) {
+		if _, err = b.verifyQuery(cnf, "SELECT 1"); err == nil {
+			return nil
+		}
+		time.Sleep(verifyPingInterval)
+	}
+	return errors.Errorf("verify.mysqld.start.timeout[%v].last.error[%v]", verifyStartTimeout, err)
+}
+
+// stopVerifyMysqld kills the scratch mysqld and waits for it exits, the data is thrown away.
+func (b *Backup) stopVerifyMysqld(args ArgsHandler) {
+	log := b.log
+
+	if _, err := b.cmd.RunCommandWithTimeout(3000, bash, args.Kill()); err != nil {
+		log.Warning("verify.mysqld.kill.error[%v]", err)
+	}
+	for i := 0; i < 10; i++ {
+		outs, err := b.cmd.RunCommand(bash, args.IsRunning())
+		if running, perr := strconv.Atoi(strings.TrimSpace(outs)); err != nil || perr != nil || running == 0 {
+			return
+		}
+		time.Sleep(verifyPingInterval)
+	}
+	log.Error("verify.mysqld.is.still.running.after.killed")
+}
+
+// verifyMysql runs the sanity checks on the scratch mysqld:
+// the mysql schema loads, the user tables count, and the checksums against the local mysql.
+// The checksums are compared at the verify time, so only the static tables are checked.
+// The scratch and local are the client option files to connect them.
+func (b *Backup) verifyMysql(scratch string, local string, verification *model.BackupVerification) error {
+	users, err := b.verifyCount(scratch, "SELECT COUNT(*) FROM mysql.user")
+	if err != nil {
+		return err
+	}
+	if users == 0 {
+		return errors.New("verify.mysql.user.is.empty")
+	}
+
+	tables, err := b.verifyCount(scratch, "SELECT COUNT(*) FROM information_schema.TABLES WHERE TABLE_TYPE = 'BASE TABLE' AND TABLE_SCHEMA NOT IN ('mysql','sys','information_schema','performance_schema')")
+	if err != nil {
+		return err
+	}
+	verification.Tables = tables
+
+	if b.conf.VerifyChecksumTables == "" {
+		return nil
+	}
+	var names []string
+	for _, name := range strings.Split(b.conf.VerifyChecksumTables, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	restored, err := b.verifyChecksums(scratch, names)
+	if err != nil {
+		return err
+	}
+	current, err := b.verifyChecksums(local, names)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if restored[name] == "" || restored[name] != current[name] {
+			return errors.Errorf("verify.checksum.table[%v].mismatch[%v:%v]", name, restored[name], current[name])
+		}
+	}
+	verification.Checksums = restored
+	return nil
+}
+
+// VerifyArchive used to restore the archive id into a scratch dir, start a throwaway mysqld on it
+// and run the sanity checks, the result is recorded in the manifest and the dir is removed.
+// The error is returned only if the archive can't be verified at all.
+func (b *Backup) VerifyArchive(id string) (*model.BackupVerification, error) {
+	log := b.log
+
+	manifest, err := b.ShowArchive(id)
+	if err != nil {
+		return nil, err
+	}
+	if b.conf.VerifyDir == "" {
+		return nil, errors.New("verify.dir.is.empty")
+	}
+	if b.getStatus() == model.MYSQLD_BACKUPING || b.getStatus() == model.MYSQLD_APPLYLOGGING {
+		return nil, errors.New("verify.error[backup/applylog.already.running]")
+	}
+
+	verification := &model.BackupVerification{Begin: time.Now().Unix()}
+	if err := b.verifyArchive(id, verification); err != nil {
+		log.Error("verify.archive[%v].failed[%v]", id, err)
+		verification.Result = model.BACKUP_VERIFY_FAILED
+		verification.Error = err.Error()
+	} else {
+		verification.Result = model.BACKUP_VERIFY_PASSED
+	}
+	verification.End = time.Now().Unix()
+
+	manifest.Verification = verification
+	if err := writeManifest(filepath.Join(b.conf.ArchiveDir, id), manifest); err != nil {
+		return nil, err
+	}
+	log.Warning("verify.archive[%v].%v.tables[%v]", id, verification.Result, verification.Tables)
+	return verification, nil
+}
+
+func (b *Backup) verifyArchive(id string, verification *model.BackupVerification) error {
+	log := b.log
+
+	dir := filepath.Join(b.conf.VerifyDir, id)
+	if err := os.RemoveAll(dir); err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := b.RestoreArchive(id, filepath.Join(dir, verifyDataDir)); err != nil {
+		return err
+	}
+
+	port, err := b.verifyPort()
+	if err != nil {
+		return err
+	}
+	cnf := filepath.Join(dir, verifyCnfFile)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := ioutil.WriteFile(cnf, []byte(b.verifyCnf(dir, port)), 0644); err != nil {
+		return errors.WithStack(err)
+	}
+
+	conf := *b.conf
+	conf.DefaultsFile = cnf
+	args := NewLinuxArgs(&conf)
+	scratch := filepath.Join(dir, verifyClientCnfFile)
+	if err := ioutil.WriteFile(scratch, []byte(b.verifyClientCnf(filepath.Join(dir, verifySocketFile))), 0600); err != nil {
+		return errors.WithStack(err)
+	}
+	local := filepath.Join(dir, localClientCnfFile)
+	if err := ioutil.WriteFile(local, []byte(b.verifyClientCnf("")), 0600); err != nil {
+		return errors.WithStack(err)
+	}
+
+	log.Warning("verify.archive[%v].start.mysqld.on.port[%v]", id, port)
+	defer b.stopVerifyMysqld(args)
+	if err := b.startVerifyMysqld(args, scratch); err != nil {
+		return err
+	}
+	return b.verifyMysql(scratch, local, verification)
+}
@@ -0,0 +1,178 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package mysqld
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"model"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"xbase/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockVerifyCommand returns the outputs of the commands which contain the keys,
+// the fails are returned with the command line ahead as the common.RunCommand does.
+type mockVerifyCommand struct {
+	*common.MockReplayCommand
+	outs  map[string]string
+	fails map[string]string
+}
+
+func (c *mockVerifyCommand) RunCommand(cmds string, args []string) (string, error) {
+	c.MockReplayCommand.RunCommand(cmds, args)
+	for key, out := range c.fails {
+		if strings.Contains(args[1], key) {
+			return strings.Join(append([]string{cmds}, args...), " ") + out, errors.New("exit status 1")
+		}
+	}
+	for key, out := range c.outs {
+		if strings.Contains(args[1], key) {
+			return out, nil
+		}
+	}
+	return "", nil
+}
+
+func (c *mockVerifyCommand) RunCommandWithTimeout(to int, cmds string, args []string) (string, error) {
+	return c.RunCommand(cmds, args)
+}
+
+func TestVerifyArchive(t *testing.T) {
+	backup, cleanup := mockArchiveBackup(t)
+	defer cleanup()
+
+	verifyPingInterval = time.Millisecond
+	defer func() { verifyPingInterval = time.Second }()
+
+	backup.conf.Basedir = "/u01/mysql"
+	backup.conf.VerifyDir = filepath.Join(filepath.Dir(backup.conf.ArchiveDir), "verify")
+	backup.conf.VerifyPort = 3406
+	backup.conf.VerifyChecksumTables = "db1.t1, db1.t2"
+	backup.conf.Passwd = "secret"
+	mockChain(t, backup, "20200601100000")
+	dir := filepath.Join(backup.conf.VerifyDir, "20200601100000")
+	scratch := filepath.Join(dir, verifyClientCnfFile)
+	local := filepath.Join(dir, localClientCnfFile)
+
+	cmd := &mockVerifyCommand{
+		MockReplayCommand: common.NewMockReplayCommand([]string{"200601 10:00:01 completed OK!"}),
+		outs: map[string]string{
+			"SELECT 1":                                 "1",
+			"FROM mysql.user":                          "3",
+			"FROM information_schema.TABLES":           "12",
+			"verify-client.cnf -N -e \"CHECKSUM TABLE": "db1.t1\t100\ndb1.t2\t200\n",
+			"local-client.cnf -N -e \"CHECKSUM TABLE":  "db1.t1\t100\ndb1.t2\t200\n",
+			"ps aux | grep '[m]ysqld_safe":             "0",
+			"kill -9 $(ps aux | grep '[-]-def":         "",
+		},
+		fails: map[string]string{},
+	}
+	backup.SetCMDHandler(cmd)
+
+	// passed
+	{
+		verification, err := backup.VerifyArchive("20200601100000")
+		assert.Nil(t, err)
+		assert.Equal(t, model.BACKUP_VERIFY_PASSED, verification.Result)
+		assert.Equal(t, 12, verification.Tables)
+		assert.Equal(t, map[string]string{"db1.t1": "100", "db1.t2": "200"}, verification.Checksums)
+
+		// recorded in the manifest
+		manifest, err := backup.ShowArchive("20200601100000")
+		assert.Nil(t, err)
+		assert.Equal(t, verification, manifest.Verification)
+
+		// the scratch mysqld is started by the mysqld_safe with the option file and killed
+		var started, killed, scratched, locals bool
+		for _, call := range cmd.Calls() {
+			switch {
+			case call[1] == fmt.Sprintf("/u01/mysql/bin/mysqld_safe --defaults-file=%s/verify.cnf > /dev/null&", dir):
+				started = true
+			case strings.HasPrefix(call[1], fmt.Sprintf("kill -9 $(ps aux | grep '[-]-defaults-file=%s/verify.cnf'", dir)):
+				killed = true
+			case call[1] == fmt.Sprintf("/u01/mysql/bin/mysql --defaults-extra-file=%s -N -e \"CHECKSUM TABLE db1.t1, db1.t2\"", scratch):
+				scratched = true
+			case call[1] == fmt.Sprintf("/u01/mysql/bin/mysql --defaults-extra-file=%s -N -e \"CHECKSUM TABLE db1.t1, db1.t2\"", local):
+				locals = true
+			}
+			// the password is kept in the client option files
+			assert.NotContains(t, call[1], "secret")
+		}
+		assert.True(t, started)
+		assert.True(t, killed)
+		assert.True(t, scratched)
+		assert.True(t, locals)
+
+		// the scratch dir is removed
+		_, err = os.Stat(dir)
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	// the checksum mismatches the local mysql
+	{
+		cmd.outs["local-client.cnf -N -e \"CHECKSUM TABLE"] = "db1.t1\t100\ndb1.t2\t201\n"
+		verification, err := backup.VerifyArchive("20200601100000")
+		assert.Nil(t, err)
+		assert.Equal(t, model.BACKUP_VERIFY_FAILED, verification.Result)
+		assert.Equal(t, "verify.checksum.table[db1.t2].mismatch[200:201]", verification.Error)
+
+		manifest, err := backup.ShowArchive("20200601100000")
+		assert.Nil(t, err)
+		assert.Equal(t, model.BACKUP_VERIFY_FAILED, manifest.Verification.Result)
+	}
+
+	// the client fails, the command line isn't recorded in the manifest
+	{
+		cmd.outs["local-client.cnf -N -e \"CHECKSUM TABLE"] = "db1.t1\t100\ndb1.t2\t200\n"
+		cmd.fails["local-client.cnf -N -e \"CHECKSUM TABLE"] = "ERROR 1045 (28000): Access denied for user 'root'@'localhost'"
+		verification, err := backup.VerifyArchive("20200601100000")
+		assert.Nil(t, err)
+		assert.Equal(t, model.BACKUP_VERIFY_FAILED, verification.Result)
+		assert.Equal(t, "verify.query[CHECKSUM TABLE db1.t1, db1.t2].error[exit status 1].outs[ERROR 1045 (28000): Access denied for user 'root'@'localhost']", verification.Error)
+
+		manifest, err := ioutil.ReadFile(filepath.Join(backup.conf.ArchiveDir, "20200601100000", manifestFile))
+		assert.Nil(t, err)
+		assert.NotContains(t, string(manifest), "secret")
+	}
+
+	// not found
+	{
+		_, err := backup.VerifyArchive("20200601110000")
+		assert.NotNil(t, err)
+	}
+}
+
+func TestVerifyCnf(t *testing.T) {
+	backup, cleanup := mockArchiveBackup(t)
+	defer cleanup()
+
+	// the client option files
+	backup.conf.Passwd = "secret"
+	assert.Equal(t, "[client]\nuser=root\npassword=secret\nsocket=/u01/backup_verify/20200601100000/mysql.sock\n", backup.verifyClientCnf("/u01/backup_verify/20200601100000/mysql.sock"))
+	assert.Equal(t, "[client]\nuser=root\npassword=secret\nhost=localhost\nport=3306\n", backup.verifyClientCnf(""))
+
+	cnf := backup.verifyCnf("/u01/backup_verify/20200601100000", 3406)
+	assert.True(t, strings.HasPrefix(cnf, "!include /etc/my3306.cnf\n\n[mysqld]\ndatadir=/u01/backup_verify/20200601100000/data\nport=3406\nsocket=/u01/backup_verify/20200601100000/mysql.sock\n"))
+	assert.Contains(t, cnf, "log-bin=/u01/backup_verify/20200601100000/mysql-bin\n")
+	assert.Contains(t, cnf, "skip-slave-start\n")
+	assert.Contains(t, cnf, "[mysqld_safe]\npid-file=/u01/backup_verify/20200601100000/mysqld.pid\n")
+
+	// a spare port
+	backup.conf.VerifyPort = 0
+	port, err := backup.verifyPort()
+	assert.Nil(t, err)
+	assert.True(t, port > 0)
+}
@@ -23,6 +23,11 @@ func (r *Raft) AddPeer(connStr string) error {
 		return nil
 	}
 
+	if r.readReplicas[connStr] != nil {
+		r.WARNING("peer[%v].already.exists.in.replicas[%+v].can't.add.repeatedly", connStr, r.readReplicas)
+		return nil
+	}
+
 	// we can't add ourself
 	if r.getID() != connStr {
 		p := NewPeer(r, connStr, r.conf.RequestTimeout, r.conf.HeartbeatTimeout)
@@ -54,6 +59,11 @@ func (r *Raft) AddIdlePeer(connStr string) error {
 		return nil
 	}
 
+	if r.readReplicas[connStr] != nil {
+		r.WARNING("peer[%v].already.exists.in.replicas[%+v].can't.add.repeatedly", connStr, r.readReplicas)
+		return nil
+	}
+
 	// we can't add ourself
 	if r.getID() != connStr {
 		p := NewPeer(r, connStr, r.conf.RequestTimeout, r.conf.HeartbeatTimeout)
@@ -70,6 +80,42 @@ func (r *Raft) AddIdlePeer(connStr string) error {
 	return nil
 }
 
+// AddReplicaPeer used to add a read replica to replicas.
+func (r *Raft) AddReplicaPeer(connStr string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.peers[connStr] != nil {
+		r.WARNING("peer[%v].already.exists.in.peers[%+v].can't.add.repeatedly", connStr, r.peers)
+		return nil
+	}
+
+	if r.idlePeers[connStr] != nil {
+		r.WARNING("peer[%v].already.exists.in.idlePeers[%+v].can't.add.repeatedly", connStr, r.idlePeers)
+		return nil
+	}
+
+	if r.readReplicas[connStr] != nil {
+		r.WARNING("peer[%v].already.exists.in.replicas[%+v].can't.add.repeatedly", connStr, r.readReplicas)
+		return nil
+	}
+
+	// we can't add ourself
+	if r.getID() != connStr {
+		p := NewPeer(r, connStr, r.conf.RequestTimeout, r.conf.HeartbeatTimeout)
+		r.readReplicas[connStr] = p
+
+		// append peer to conf.Raft.Replicas
+		r.meta.Replicas = append(r.meta.Replicas, connStr)
+
+		// write configure to file
+		r.incEpochID()
+		r.writePeersJSON()
+	}
+	r.WARNING("add.peer[%v].to.replicas[%+v]", connStr, r.readReplicas)
+	return nil
+}
+
 // RemovePeer used to remove a peer from peers.
 func (r *Raft) RemovePeer(connStr string) error {
 	r.mutex.Lock()
@@ -128,6 +174,35 @@ func (r *Raft) RemoveIdlePeer(connStr string) error {
 	return nil
 }
 
+// RemoveReplicaPeer used to remove a read replica from replicas.
+func (r *Raft) RemoveReplicaPeer(connStr string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// we can't remove ourself
+	if connStr != r.getID() {
+		if _, ok := r.readReplicas[connStr]; !ok {
+			r.WARNING("peer[%v].not.exists.in.replicas[%+v]", connStr, r.readReplicas)
+			return nil
+		}
+		delete(r.readReplicas, connStr)
+
+		// remove peer from conf.Raft.Replicas
+		for i, v := range r.meta.Replicas {
+			if v == connStr {
+				r.meta.Replicas = append(r.meta.Replicas[:i], r.meta.Replicas[i+1:]...)
+				break
+			}
+		}
+
+		// write configure to file
+		r.incEpochID()
+		r.writePeersJSON()
+	}
+	r.WARNING("removed.peer[%v].from.replicas[%+v]", connStr, r.readReplicas)
+	return nil
+}
+
 // GetLeader returns leader.
 func (r *Raft) GetLeader() string {
 	return r.leader
@@ -143,6 +218,11 @@ func (r *Raft) GetIdlePeers() []string {
 	return r.getIdlePeers()
 }
 
+// GetReplicas returns read replicas string.
+func (r *Raft) GetReplicas() []string {
+	return r.getReplicas()
+}
+
 // GetAllPeers returns all peers string.
 func (r *Raft) GetAllPeers() []string {
 	return r.getAllPeers()
@@ -44,6 +44,10 @@ const (
 	// LEARNER state.
 	LEARNER
 
+	// REPLICA state.
+	// the read replica follows the leader by heartbeats, it never votes or promotes
+	REPLICA
+
 	// STOPPED state.
 	STOPPED
 
@@ -66,6 +70,8 @@ func (s State) String() string {
 	case 1 << 5:
 		return "LEARNER"
 	case 1 << 6:
+		return "REPLICA"
+	case 1 << 7:
 		return "STOPPED"
 	}
 	return "UNKNOW"
@@ -97,6 +103,7 @@ func (r *Raft) getState() State {
 
 func (r *Raft) setState(state State) {
 	r.setLeader(noLeader)
+	r.setUpstream("")
 	r.state = state
 }
 
@@ -118,6 +125,11 @@ func (r *Raft) getAllMembers() int {
 	return len(r.meta.Peers) + len(r.meta.IdlePeers)
 }
 
+// the delayed replica applies the binlogs late on purpose, it's never promoted
+func (r *Raft) isDelayed() bool {
+	return r.conf.ReplicationDelaySeconds > 0
+}
+
 func (r *Raft) getPeers() []string {
 	return r.meta.Peers
 }
@@ -126,6 +138,10 @@ func (r *Raft) getIdlePeers() []string {
 	return r.meta.IdlePeers
 }
 
+func (r *Raft) getReplicas() []string {
+	return r.meta.Replicas
+}
+
 func (r *Raft) getAllPeers() []string {
 	allPeers := r.meta.Peers
 	allPeers = append(allPeers, r.meta.IdlePeers...)
@@ -164,6 +180,30 @@ func (r *Raft) getLeader() string {
 	return r.leader
 }
 
+// setLeader wakes up the leader watchers if the leader changed.
 func (r *Raft) setLeader(leader string) {
+	r.leaderChangedMu.Lock()
+	defer r.leaderChangedMu.Unlock()
+	if r.leader == leader {
+		return
+	}
 	r.leader = leader
+	close(r.leaderChanged)
+	r.leaderChanged = make(chan struct{})
+}
+
+func (r *Raft) getUpstream() string {
+	return r.upstream
+}
+
+func (r *Raft) setUpstream(upstream string) {
+	r.upstream = upstream
+}
+
+func (r *Raft) getSemiSyncDegraded() bool {
+	return r.semiSyncDegraded
+}
+
+func (r *Raft) setSemiSyncDegraded(degraded bool) {
+	r.semiSyncDegraded = degraded
 }
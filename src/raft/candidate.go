@@ -273,12 +273,12 @@ func (r *Candidate) sendRequestVote(respChan chan *model.RaftRPCResponse) {
 	r.wg.Wait()
 }
 
-// Votes who comes from IDLE machine will be filitered out.
+// Votes who comes from IDLE or REPLICA machine will be filitered out.
 func (r *Candidate) processRequestVoteResponse(voteGranted *int, rsp *model.RaftRPCResponse, switchMaster *bool) {
 	r.WARNING("get.vote.response.from[N:%+v, R:%v].rsp.gtid[%v].retcode[%v]", rsp.GetFrom(), rsp.Raft.State, rsp.GetGTID(), rsp.RetCode)
 	switch rsp.RetCode {
 	case model.OK:
-		if rsp.Raft.State == IDLE.String() {
+		if rsp.Raft.State == IDLE.String() || rsp.Raft.State == REPLICA.String() {
 			return
 		}
 		*voteGranted++
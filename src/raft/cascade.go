@@ -0,0 +1,159 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018-2019 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"fmt"
+	"model"
+	"strconv"
+	"time"
+)
+
+// The cascading replication:
+// a follower(or a read replica) with replication-upstream replicates from the upstream follower instead of the leader,
+// it tells the leader its upstream by the heartbeat response, the leader routes the Repl of its heartbeats
+// to the upstream if it's healthy, otherwise to the leader itself.
+// The upstream must replicate from the leader, so there is no loop.
+//
+// The Relay_Master_Log_File of a cascaded replica is the upstream's binlog, the leader can't compare it with its own,
+// so the leader checks the executed GTID set of the cascaded replica before it purges its binlogs.
+
+// setCascadeResponse tells the leader my upstream and my mysql endpoint for my downstreams,
+// and my executed GTID set for the purge if I want to replicate from an upstream.
+func (r *Raft) setCascadeResponse(rsp *model.RaftRPCResponse) {
+	repl := r.mysql.GetRepl()
+	rsp.Repl = model.Repl{Master_Host: repl.Master_Host, Master_Port: repl.Master_Port}
+	rsp.Upstream = r.conf.ReplicationUpstream
+	if rsp.Upstream != "" {
+		gtid, err := r.mysql.GetGTID()
+		if err != nil {
+			r.ERROR("mysql.get.gtid.for.the.cascading.replication.error[%v]", err)
+			return
+		}
+		rsp.GTID = gtid
+	}
+}
+
+// healthyUpstream returns true if the upstream responded in an election timeout with its replication running.
+func (r *Leader) healthyUpstream(state replicaState) bool {
+	expired := time.Duration(r.getElectionTimeout()) * time.Millisecond
+	if time.Since(state.updated) > expired || state.repl.Master_Host == "" {
+		return false
+	}
+	_, err := strconv.Atoi(state.secondsBehindMaster)
+	return err == nil
+}
+
+// routeUpstream returns the upstream of the replica and the Repl to it, false if the replica should replicate from me.
+func (r *Leader) routeUpstream(id string) (string, model.Repl, bool) {
+	r.replicasMu.Lock()
+	defer r.replicasMu.Unlock()
+
+	state, ok := r.replicas[id]
+	if !ok || state.upstream == "" || state.upstream == id || state.upstream == r.getID() {
+		return "", model.Repl{}, false
+	}
+	upstream, ok := r.replicas[state.upstream]
+	if !ok || upstream.upstream != "" || !r.healthyUpstream(upstream) {
+		return "", model.Repl{}, false
+	}
+
+	repl := r.mysql.GetRepl()
+	repl.Master_Host = upstream.repl.Master_Host
+	repl.Master_Port = upstream.repl.Master_Port
+	return state.upstream, repl, true
+}
+
+// cascadedReplicas returns the members which replicate from their upstreams.
+func (r *Leader) cascadedReplicas() []string {
+	var cascaded []string
+	for _, ids := range [][]string{r.getPeers(), r.getIdlePeers()} {
+		for _, id := range ids {
+			if id == r.getID() {
+				continue
+			}
+			if _, _, ok := r.routeUpstream(id); ok {
+				cascaded = append(cascaded, id)
+			}
+		}
+	}
+	return cascaded
+}
+
+// isCascading returns true if the replica wants to replicate from an upstream, its binlog position isn't mine.
+func (r *Leader) isCascading(id string) bool {
+	state, ok := r.getReplica(id)
+	return ok && state.upstream != ""
+}
+
+// cascadeGuard returns the binlog which is safe to purge to for the replicas which want to replicate from
+// their upstreams, they fall back to me when the upstreams are down.
+func (r *Leader) cascadeGuard(next string, check bool) (string, string) {
+	var executed []string
+	for _, ids := range [][]string{r.getPeers(), r.getIdlePeers(), r.getReplicas()} {
+		for _, id := range ids {
+			if id == r.getID() || !r.isCascading(id) {
+				continue
+			}
+			state, _ := r.getReplica(id)
+			if state.executedGTID == "" {
+				return "", fmt.Sprintf("cascading.replica[%v].executed.gtid.is.unknown", id)
+			}
+			executed = append(executed, state.executedGTID)
+		}
+	}
+	if binlog := r.executedTo(next, executed); binlog != next {
+		return binlog, "cascading.replicas.need.the.gtids.of.the.binlogs"
+	}
+	return next, ""
+}
+
+// executedTo returns the newest binlog up to next whose Previous_gtids are in every executed GTID set,
+// purging to a binlog purges its Previous_gtids. Empty means nothing can be purged.
+func (r *Leader) executedTo(next string, executed []string) string {
+	if len(executed) == 0 || next == "" {
+		return next
+	}
+
+	binlogs, err := r.mysql.GetBinaryLogs()
+	if err != nil {
+		r.ERROR("purge.guard.get.binary.logs.error[%v]", err)
+		return ""
+	}
+	// purging to the first one purges nothing
+	for i := len(binlogs) - 1; i > 0; i-- {
+		if binlogs[i] > next {
+			continue
+		}
+		if r.executedBefore(binlogs[i], executed) {
+			return binlogs[i]
+		}
+	}
+	return ""
+}
+
+// executedBefore returns true if all the GTIDs before the binlog are in every executed GTID set.
+func (r *Leader) executedBefore(binlog string, executed []string) bool {
+	previous, err := r.mysql.GetBinlogPreviousGTIDs(binlog)
+	if err != nil {
+		r.ERROR("purge.guard.get.binlog[%v].previous.gtids.error[%v]", binlog, err)
+		return false
+	}
+	for _, gtid := range executed {
+		missing, err := r.mysql.GetGTIDSubtract(previous, gtid)
+		if err != nil {
+			r.ERROR("purge.guard.gtid.subtract[%v, %v].error[%v]", previous, gtid, err)
+			return false
+		}
+		if missing != "" {
+			return false
+		}
+	}
+	return true
+}
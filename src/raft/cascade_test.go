@@ -0,0 +1,241 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018-2019 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"config"
+	"database/sql"
+	"model"
+	"mysql"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockCascadeResponse(from string, lag string, upstream string, host string) *model.RaftRPCResponse {
+	rsp := mockReplicaResponse(from, lag)
+	rsp.Upstream = upstream
+	rsp.Repl = model.Repl{Master_Host: host, Master_Port: 3306}
+	return rsp
+}
+
+func TestRaftCascadeRoute(t *testing.T) {
+	conf := config.DefaultRaftConfig()
+	conf.ElectionTimeout = 300
+	leader, ids, cleanup := mockDurabilityLeader(t, conf)
+	defer cleanup()
+
+	// no responses
+	_, _, ok := leader.routeUpstream(ids[2])
+	assert.False(t, ok)
+
+	// ids[2] replicates from ids[1]
+	{
+		leader.updateReplica(mockCascadeResponse(ids[1], "0", "", "192.168.0.2"))
+		leader.updateReplica(mockCascadeResponse(ids[2], "0", ids[1], "192.168.0.3"))
+		upstream, repl, ok := leader.routeUpstream(ids[2])
+		assert.True(t, ok)
+		assert.Equal(t, ids[1], upstream)
+		assert.Equal(t, "192.168.0.2", repl.Master_Host)
+		assert.Equal(t, 3306, repl.Master_Port)
+		assert.Equal(t, leader.mysql.GetRepl().Repl_User, repl.Repl_User)
+		assert.Equal(t, []string{ids[2]}, leader.cascadedReplicas())
+
+		_, _, ok = leader.routeUpstream(ids[1])
+		assert.False(t, ok)
+	}
+
+	// the replication of the upstream is broken
+	{
+		leader.updateReplica(mockCascadeResponse(ids[1], "NULL", "", "192.168.0.2"))
+		_, _, ok := leader.routeUpstream(ids[2])
+		assert.False(t, ok)
+		assert.Nil(t, leader.cascadedReplicas())
+	}
+
+	// the upstream replicates from an upstream too
+	{
+		leader.updateReplica(mockCascadeResponse(ids[1], "0", ids[2], "192.168.0.2"))
+		_, _, ok := leader.routeUpstream(ids[2])
+		assert.False(t, ok)
+		_, _, ok = leader.routeUpstream(ids[1])
+		assert.False(t, ok)
+	}
+
+	// the upstream is me or itself
+	{
+		leader.updateReplica(mockCascadeResponse(ids[1], "0", "", "192.168.0.2"))
+		leader.updateReplica(mockCascadeResponse(ids[2], "0", ids[0], "192.168.0.3"))
+		_, _, ok := leader.routeUpstream(ids[2])
+		assert.False(t, ok)
+		leader.updateReplica(mockCascadeResponse(ids[2], "0", ids[2], "192.168.0.3"))
+		_, _, ok = leader.routeUpstream(ids[2])
+		assert.False(t, ok)
+	}
+
+	// the upstream response expired
+	{
+		leader.updateReplica(mockCascadeResponse(ids[2], "0", ids[1], "192.168.0.3"))
+		_, _, ok := leader.routeUpstream(ids[2])
+		assert.True(t, ok)
+
+		time.Sleep(time.Millisecond * time.Duration(leader.getElectionTimeout()*2))
+		leader.updateReplica(mockCascadeResponse(ids[2], "0", ids[1], "192.168.0.3"))
+		_, _, ok = leader.routeUpstream(ids[2])
+		assert.False(t, ok)
+	}
+}
+
+func TestRaftCascadeDurability(t *testing.T) {
+	conf := config.DefaultRaftConfig()
+	conf.Durability = DurabilityAckN
+	conf.DurabilityAcks = 2
+	leader, ids, cleanup := mockDurabilityLeader(t, conf)
+	defer cleanup()
+
+	want := durabilitySettings{semiSync: true, waitCount: 2, timeout: semisyncTimeout}
+	assert.Equal(t, want, leader.durabilitySettings())
+
+	// ids[2] acks ids[1] instead of me
+	leader.updateReplica(mockCascadeResponse(ids[1], "0", "", "192.168.0.2"))
+	leader.updateReplica(mockCascadeResponse(ids[2], "0", ids[1], "192.168.0.3"))
+	want = durabilitySettings{semiSync: true, waitCount: 1, timeout: semisyncTimeout,
+		note: "cascaded.replicas.never.ack.wait.for.the.direct.ones"}
+	assert.Equal(t, want, leader.durabilitySettings())
+}
+
+// mockGTIDSubtract subtracts the GTIDs like "uuid:1-N" of one uuid.
+func mockGTIDSubtract(db *sql.DB, subsetGTID string, setGTID string) (string, error) {
+	last := func(gtid string) int {
+		n, _ := strconv.Atoi(gtid[strings.LastIndex(gtid, "-")+1:])
+		return n
+	}
+	if subsetGTID == "" || last(subsetGTID) <= last(setGTID) {
+		return "", nil
+	}
+	return subsetGTID, nil
+}
+
+func cascadeTarget(leader *Leader, next string) string {
+	binlog, _ := leader.cascadeGuard(next, false)
+	return binlog
+}
+
+func TestRaftCascadePurgeGuard(t *testing.T) {
+	conf := config.DefaultRaftConfig()
+	leader, ids, cleanup := mockDurabilityLeader(t, conf)
+	defer cleanup()
+
+	uuid := "84030605-66aa-11e6-9465-52540e7fd51c"
+	mock := mysql.NewMockGTIDA()
+	mock.GetBinaryLogsFn = func(db *sql.DB) ([]string, error) {
+		return []string{"mysql-bin.000001", "mysql-bin.000002", "mysql-bin.000003", "mysql-bin.000004"}, nil
+	}
+	mock.GetBinlogPreviousGTIDsFn = func(db *sql.DB, binlog string) (string, error) {
+		previous := map[string]string{
+			"mysql-bin.000001": "",
+			"mysql-bin.000002": uuid + ":1-50",
+			"mysql-bin.000003": uuid + ":1-120",
+			"mysql-bin.000004": uuid + ":1-200",
+		}
+		return previous[binlog], nil
+	}
+	mock.GetGTIDSubtractFn = mockGTIDSubtract
+	leader.mysql.SetMysqlHandler(mock)
+
+	// no cascading replicas
+	assert.Equal(t, "mysql-bin.000004", cascadeTarget(leader, "mysql-bin.000004"))
+
+	// the executed GTID of the cascading replica is unknown
+	leader.updateReplica(mockCascadeResponse(ids[2], "0", ids[1], "192.168.0.3"))
+	assert.Equal(t, "", cascadeTarget(leader, "mysql-bin.000004"))
+
+	// ids[2] executed 1-100, it needs the binlogs from mysql-bin.000002
+	rsp := mockCascadeResponse(ids[2], "0", ids[1], "192.168.0.3")
+	rsp.GTID.Executed_GTID_Set = uuid + ":1-100"
+	leader.updateReplica(rsp)
+	assert.Equal(t, "mysql-bin.000002", cascadeTarget(leader, "mysql-bin.000004"))
+	assert.Equal(t, "mysql-bin.000002", cascadeTarget(leader, "mysql-bin.000003"))
+
+	// ids[2] executed 1-40, nothing can be purged
+	rsp.GTID.Executed_GTID_Set = uuid + ":1-40"
+	leader.updateReplica(rsp)
+	assert.Equal(t, "", cascadeTarget(leader, "mysql-bin.000004"))
+
+	// ids[2] caught up
+	rsp.GTID.Executed_GTID_Set = uuid + ":1-200"
+	leader.updateReplica(rsp)
+	assert.Equal(t, "mysql-bin.000004", cascadeTarget(leader, "mysql-bin.000004"))
+}
+
+// TEST EFFECTS:
+// test the follower replicates from its upstream and falls back to the leader when the upstream is down
+//
+// TEST PROCESSES:
+//  1. rafts[2] replicates from rafts[1]
+//  2. Start 3 rafts state as FOLLOWER
+//  3. wait rafts[0] elected as leader, rafts[2] replicates from rafts[1]
+//  4. stop rafts[1]
+//  5. rafts[2] falls back to the leader
+func TestRaftCascade(t *testing.T) {
+	conf := config.DefaultRaftConfig()
+	conf.MetaDatadir = "/tmp/"
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	ids, rafts, cleanup := MockRaftsWithConfig(log, conf, port, 3, -1)
+	defer cleanup()
+
+	// 1. rafts[2] replicates from rafts[1]
+	{
+		c := *conf
+		c.ReplicationUpstream = ids[1]
+		rafts[2].conf = &c
+
+		upstream := mysql.NewMockGTIDX3()
+		upstream.PingFn = func(db *sql.DB) (*mysql.PingEntry, error) {
+			return &mysql.PingEntry{Seconds_Behind_Master: "0"}, nil
+		}
+		rafts[0].mysql.SetMysqlHandler(mysql.NewMockGTIDX5())
+		rafts[1].mysql.SetMysqlHandler(upstream)
+		rafts[2].mysql.SetMysqlHandler(mysql.NewMockGTIDX3())
+	}
+
+	// 2. Start 3 rafts state as FOLLOWER
+	for _, raft := range rafts {
+		raft.Start()
+	}
+
+	// 3. rafts[2] replicates from rafts[1]
+	{
+		MockWaitLeaderEggs(rafts, 1)
+		assert.Equal(t, LEADER, rafts[0].getState())
+		MockWaitLeaderEggs(rafts, 0)
+
+		assert.Equal(t, ids[1], rafts[2].getUpstream())
+		assert.Equal(t, "", rafts[1].getUpstream())
+		assert.Equal(t, []string{ids[2]}, rafts[0].L.cascadedReplicas())
+	}
+
+	// 4. stop rafts[1]
+	rafts[1].Stop()
+
+	// 5. rafts[2] falls back to the leader
+	{
+		MockWaitLeaderEggs(rafts, 0)
+		assert.Equal(t, LEADER, rafts[0].getState())
+		assert.Equal(t, "", rafts[2].getUpstream())
+		assert.Equal(t, rafts[0].getID(), rafts[2].GetLeader())
+	}
+}
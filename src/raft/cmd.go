@@ -43,3 +43,22 @@ func (r *Raft) leaderStopShellCommand() error {
 	r.WARNING("leaderStopShellCommand[%v].done", args)
 	return nil
 }
+
+// semiSyncDegradeShellCommand executes the shell commands
+// when the leader semi-sync falls back to async or recovers, such as the alerts
+func (r *Raft) semiSyncDegradeShellCommand(event string) error {
+	if r.conf.SemiSyncDegradeCommand == "" {
+		return nil
+	}
+	args := []string{
+		"-c",
+		r.conf.SemiSyncDegradeCommand + " " + event,
+	}
+
+	if out, err := r.cmd.RunCommand(bash, args); err != nil {
+		r.ERROR("semiSyncDegradeShellCommand[%v].out[%v].error[%+v]", args, out, err)
+		return err
+	}
+	r.WARNING("semiSyncDegradeShellCommand[%v].done", args)
+	return nil
+}
@@ -0,0 +1,208 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018-2019 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"fmt"
+	"model"
+	"strconv"
+	"time"
+)
+
+const (
+	// DurabilityQuorum waits for the ACK from (n-1)/2 replicas, 2 nodes fall back to async after semi-sync-timeout-for-two-nodes.
+	DurabilityQuorum = "quorum"
+
+	// DurabilityAckN waits for the ACK from durability-acks replicas.
+	DurabilityAckN = "ack-n"
+
+	// DurabilityCrossZone waits for the ACK from at least one replica in another zone.
+	DurabilityCrossZone = "cross-zone"
+
+	// DurabilityAsync disables the semi-sync, the replicas lag at most durability-max-lag seconds.
+	DurabilityAsync = "async"
+)
+
+const (
+	semisyncTimeout = 1000000000000000000 // for 3 or more nodes
+)
+
+// replicaState is what the leader got from the heartbeat response of a replica,
+// the zone comes from the peer labels.
+type replicaState struct {
+	secondsBehindMaster string
+	updated             time.Time
+
+	// for the cascading replication
+	upstream     string
+	repl         model.Repl
+	executedGTID string
+
+	// for the purge guards
+	backuping bool
+}
+
+// durabilitySettings is the semi-sync settings the leader applies,
+// the note tells why the policy can't be applied as it's configured.
+type durabilitySettings struct {
+	semiSync  bool
+	waitCount int
+	timeout   uint64
+	note      string
+}
+
+func (d durabilitySettings) String() string {
+	if !d.semiSync {
+		return "async"
+	}
+	if d.note != "" {
+		return fmt.Sprintf("semi-sync[wait_count:%v, timeout:%v, note:%v]", d.waitCount, d.timeout, d.note)
+	}
+	return fmt.Sprintf("semi-sync[wait_count:%v, timeout:%v]", d.waitCount, d.timeout)
+}
+
+// IsSemiSync returns true if the durability policy waits for the semi-sync ACKs.
+func IsSemiSync(durability string) bool {
+	return durability != DurabilityAsync
+}
+
+// checkDurability returns error if the durability policy is unknown, empty is the quorum.
+func checkDurability(durability string) error {
+	switch durability {
+	case "", DurabilityQuorum, DurabilityAckN, DurabilityCrossZone, DurabilityAsync:
+		return nil
+	}
+	return fmt.Errorf("raft.durability[%v].unsupported", durability)
+}
+
+// updateReplica saves the lag and the cascading replication of the replica from its heartbeat response.
+func (r *Leader) updateReplica(rsp *model.RaftRPCResponse) {
+	r.replicasMu.Lock()
+	defer r.replicasMu.Unlock()
+	r.replicas[rsp.GetFrom()] = replicaState{
+		secondsBehindMaster: rsp.Seconds_Behind_Master,
+		updated:             time.Now(),
+		upstream:            rsp.Upstream,
+		repl:                rsp.Repl,
+		executedGTID:        rsp.GTID.Executed_GTID_Set,
+		backuping:           rsp.Backuping,
+	}
+}
+
+// getReplica returns the state of the replica, false if it never responded to the heartbeat.
+func (r *Leader) getReplica(id string) (replicaState, bool) {
+	r.replicasMu.Lock()
+	defer r.replicasMu.Unlock()
+	state, ok := r.replicas[id]
+	return state, ok
+}
+
+func (r *Leader) resetReplicas() {
+	r.replicasMu.Lock()
+	defer r.replicasMu.Unlock()
+	r.replicas = make(map[string]replicaState)
+}
+
+// durabilitySettings computes the semi-sync settings of the durability policy for the current membership,
+// the cascaded replicas ack their upstreams instead of the master, so it waits for the direct ones at most.
+func (r *Leader) durabilitySettings() durabilitySettings {
+	settings := r.policySettings()
+	if cascaded := len(r.cascadedReplicas()); cascaded > 0 && settings.semiSync {
+		direct := r.getAllMembers() - 1 - cascaded
+		if direct > 0 && settings.waitCount > direct {
+			settings.waitCount = direct
+			settings.note = "cascaded.replicas.never.ack.wait.for.the.direct.ones"
+		}
+	}
+	return settings
+}
+
+// policySettings computes the semi-sync settings of the durability policy.
+func (r *Leader) policySettings() durabilitySettings {
+	members := r.getMembers()
+	replicas := r.getAllMembers() - 1
+
+	// the 2 nodes can't wait forever, the master falls back to async when the other is down
+	timeout := uint64(semisyncTimeout)
+	if members < 3 {
+		timeout = r.semiSyncTimeoutFor2Nodes
+	}
+
+	quorum := durabilitySettings{semiSync: true, waitCount: maxInt((members-1)/2, 1), timeout: timeout}
+	switch r.conf.Durability {
+	case DurabilityAsync:
+		return durabilitySettings{}
+	case DurabilityAckN:
+		acks := maxInt(r.conf.DurabilityAcks, 1)
+		if acks > replicas && replicas > 0 {
+			return durabilitySettings{semiSync: true, waitCount: replicas, timeout: timeout,
+				note: fmt.Sprintf("acks.%v.more.than.the.replicas.wait.for.all", acks)}
+		}
+		return durabilitySettings{semiSync: true, waitCount: acks, timeout: timeout}
+	case DurabilityCrossZone:
+		zone := r.getZone()
+		if zone == "" {
+			quorum.note = "my.zone.is.empty.use.the.quorum"
+			return quorum
+		}
+		// wait for one more ACK than the replicas in my zone, at least one ACK comes from another zone
+		// the replicas whose zones are unknown are counted in my zone
+		var same, other int
+		for _, ids := range [][]string{r.getPeers(), r.getIdlePeers()} {
+			for _, id := range ids {
+				if id == r.getID() {
+					continue
+				}
+				if peerZone := r.getPeerZone(id); peerZone != "" && peerZone != zone {
+					other++
+				} else {
+					same++
+				}
+			}
+		}
+		if other == 0 {
+			quorum.note = "no.replica.in.other.zones.use.the.quorum"
+			return quorum
+		}
+		return durabilitySettings{semiSync: true, waitCount: same + 1, timeout: timeout}
+	}
+	return quorum
+}
+
+// laggingReplicas returns the members which lag more than durability-max-lag, the ones which didn't respond
+// in an election timeout or whose replication is broken are lagging too.
+func (r *Leader) laggingReplicas() []string {
+	var lagging []string
+	if r.conf.DurabilityMaxLag <= 0 {
+		return lagging
+	}
+
+	expired := time.Duration(r.getElectionTimeout()) * time.Millisecond
+	for _, id := range r.getPeers() {
+		if id == r.getID() {
+			continue
+		}
+		state, ok := r.getReplica(id)
+		if !ok || time.Since(state.updated) > expired {
+			lagging = append(lagging, id)
+			continue
+		}
+		if lag, err := strconv.Atoi(state.secondsBehindMaster); err != nil || lag > r.conf.DurabilityMaxLag {
+			lagging = append(lagging, id)
+		}
+	}
+	return lagging
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,206 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018-2019 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"config"
+	"database/sql"
+	"io/ioutil"
+	"model"
+	"mysql"
+	"os"
+	"sync"
+	"testing"
+	"time"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockReplicaResponse(from string, lag string) *model.RaftRPCResponse {
+	rsp := model.NewRaftRPCResponse(model.OK)
+	rsp.Raft.From = from
+	rsp.Seconds_Behind_Master = lag
+	return rsp
+}
+
+// mockDurabilityLeader returns the leader of a raft which is not started, with the peers of ids.
+func mockDurabilityLeader(t *testing.T, conf *config.RaftConfig) (*Leader, []string, func()) {
+	dir, err := ioutil.TempDir("", "xenon-durability")
+	assert.Nil(t, err)
+	conf.MetaDatadir = dir
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	ids := []string{"192.168.0.1:8801", "192.168.0.2:8801", "192.168.0.3:8801"}
+	mysql57 := mysql.NewMysql(config.DefaultMysqlConfig(), 10000, log)
+	raft := NewRaft(ids[0], conf, 10000, log, mysql57, FOLLOWER)
+	for _, id := range ids {
+		raft.AddPeer(id)
+	}
+	return raft.L, ids, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestRaftDurabilitySettings(t *testing.T) {
+	conf := config.DefaultRaftConfig()
+	leader, ids, cleanup := mockDurabilityLeader(t, conf)
+	defer cleanup()
+
+	// quorum
+	{
+		want := durabilitySettings{semiSync: true, waitCount: 1, timeout: semisyncTimeout}
+		assert.Equal(t, want, leader.durabilitySettings())
+
+		leader.AddPeer("192.168.0.4:8801")
+		leader.AddPeer("192.168.0.5:8801")
+		want = durabilitySettings{semiSync: true, waitCount: 2, timeout: semisyncTimeout}
+		assert.Equal(t, want, leader.durabilitySettings())
+		leader.RemovePeer("192.168.0.4:8801")
+		leader.RemovePeer("192.168.0.5:8801")
+	}
+
+	// ack-n
+	{
+		conf.Durability = DurabilityAckN
+		conf.DurabilityAcks = 2
+		want := durabilitySettings{semiSync: true, waitCount: 2, timeout: semisyncTimeout}
+		assert.Equal(t, want, leader.durabilitySettings())
+
+		conf.DurabilityAcks = 3
+		want = durabilitySettings{semiSync: true, waitCount: 2, timeout: semisyncTimeout, note: "acks.3.more.than.the.replicas.wait.for.all"}
+		assert.Equal(t, want, leader.durabilitySettings())
+	}
+
+	// cross-zone
+	{
+		conf.Durability = DurabilityCrossZone
+		want := durabilitySettings{semiSync: true, waitCount: 1, timeout: semisyncTimeout, note: "my.zone.is.empty.use.the.quorum"}
+		assert.Equal(t, want, leader.durabilitySettings())
+
+		// the zones are unknown
+		conf.Zone = "zone-a"
+		want = durabilitySettings{semiSync: true, waitCount: 1, timeout: semisyncTimeout, note: "no.replica.in.other.zones.use.the.quorum"}
+		assert.Equal(t, want, leader.durabilitySettings())
+
+		// one replica in my zone, one in zone-b
+		leader.updatePeerLabels(ids[1], map[string]string{LabelZone: "zone-a"})
+		leader.updatePeerLabels(ids[2], map[string]string{LabelZone: "zone-b"})
+		want = durabilitySettings{semiSync: true, waitCount: 2, timeout: semisyncTimeout}
+		assert.Equal(t, want, leader.durabilitySettings())
+
+		// all the replicas in other zones
+		leader.updatePeerLabels(ids[1], map[string]string{LabelZone: "zone-c"})
+		want = durabilitySettings{semiSync: true, waitCount: 1, timeout: semisyncTimeout}
+		assert.Equal(t, want, leader.durabilitySettings())
+	}
+
+	// async
+	{
+		conf.Durability = DurabilityAsync
+		assert.Equal(t, durabilitySettings{}, leader.durabilitySettings())
+	}
+
+	// 2 nodes
+	{
+		conf.Durability = DurabilityQuorum
+		leader.RemovePeer(ids[2])
+		want := durabilitySettings{semiSync: true, waitCount: 1, timeout: leader.semiSyncTimeoutFor2Nodes}
+		assert.Equal(t, want, leader.durabilitySettings())
+	}
+}
+
+func TestRaftDurabilityLaggingReplicas(t *testing.T) {
+	conf := config.DefaultRaftConfig()
+	conf.Durability = DurabilityAsync
+	conf.ElectionTimeout = 300
+	leader, ids, cleanup := mockDurabilityLeader(t, conf)
+	defer cleanup()
+
+	// no bound
+	assert.Nil(t, leader.laggingReplicas())
+
+	// no responses
+	conf.DurabilityMaxLag = 10
+	assert.Equal(t, []string{ids[1], ids[2]}, leader.laggingReplicas())
+
+	leader.updateReplica(mockReplicaResponse(ids[1], "3"))
+	leader.updateReplica(mockReplicaResponse(ids[2], "NULL"))
+	assert.Equal(t, []string{ids[2]}, leader.laggingReplicas())
+
+	leader.updateReplica(mockReplicaResponse(ids[2], "30"))
+	assert.Equal(t, []string{ids[2]}, leader.laggingReplicas())
+
+	leader.updateReplica(mockReplicaResponse(ids[2], "10"))
+	assert.Nil(t, leader.laggingReplicas())
+
+	// the response expired
+	time.Sleep(time.Millisecond * time.Duration(leader.getElectionTimeout()*2))
+	leader.updateReplica(mockReplicaResponse(ids[2], "0"))
+	assert.Equal(t, []string{ids[1]}, leader.laggingReplicas())
+}
+
+// TEST EFFECTS:
+// test the leader applies the durability settings
+//
+// TEST PROCESSES:
+//  1. set rafts GTID
+//     1.0 rafts[0]  with MockGTID_X1{Master_Log_File = "mysql-bin.000001", Read_Master_Log_Pos = 123}
+//     1.1 rafts[1]  with MockGTID_X3{Master_Log_File = "mysql-bin.000003", Read_Master_Log_Pos = 123}
+//     1.2 rafts[2]  with MockGTID_X5{Master_Log_File = "mysql-bin.000005", Read_Master_Log_Pos = 123}
+//  2. Start 3 rafts state as FOLLOWER
+//  3. wait rafts[2] elected as leader
+//  4. check the wait count of the cross-zone durability
+func TestRaftLeaderDurability(t *testing.T) {
+	conf := config.DefaultRaftConfig()
+	conf.MetaDatadir = "/tmp/"
+	conf.Durability = DurabilityCrossZone
+	conf.Zone = "zone-a"
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, cleanup := MockRaftsWithConfig(log, conf, port, 3, -1)
+	defer cleanup()
+
+	var mu sync.Mutex
+	var waitCount int
+	mock := mysql.NewMockGTIDX5()
+	mock.SetSemiWaitSlaveCountFn = func(db *sql.DB, count int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		waitCount = count
+		return nil
+	}
+
+	// 1. set rafts GTID
+	{
+		rafts[0].mysql.SetMysqlHandler(mysql.NewMockGTIDX1())
+		rafts[1].mysql.SetMysqlHandler(mysql.NewMockGTIDX3())
+		rafts[2].mysql.SetMysqlHandler(mock)
+	}
+
+	// 2. Start 3 rafts state as FOLLOWER
+	for _, raft := range rafts {
+		raft.Start()
+	}
+
+	// 3. leader
+	MockWaitLeaderEggs(rafts, 1)
+	assert.Equal(t, LEADER, rafts[2].getState())
+
+	// 4. all in zone-a, there is no replica in other zones
+	{
+		MockWaitLeaderEggs(rafts, 0)
+		mu.Lock()
+		assert.Equal(t, 1, waitCount)
+		mu.Unlock()
+		assert.Equal(t, "no.replica.in.other.zones.use.the.quorum", rafts[2].L.durability.note)
+	}
+}
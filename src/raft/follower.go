@@ -9,6 +9,7 @@
 package raft
 
 import (
+	"fmt"
 	"model"
 	"strings"
 	"sync"
@@ -23,6 +24,12 @@ type Follower struct {
 	// Used to wait for the async job done.
 	wg sync.WaitGroup
 
+	// The election timeouts we have waited for the leader in the preferred zone.
+	preferredZoneWaits int
+
+	// The leader and viewid the failover has been refused for, it's counted once until the leader is back.
+	refusedView string
+
 	// follower process heartbeat request handler
 	processHeartbeatRequestHandler func(*model.RaftRPCRequest) *model.RaftRPCResponse
 
@@ -62,7 +69,9 @@ func (r *Follower) Loop() {
 			// promotable cases:
 			// 1. MySQL is MYSQL_ALIVE
 			// 2. Slave_SQL_RNNNING is OK
-			if !r.isBrainSplit && r.mysql.Promotable() {
+			// 3. not waiting for the leader in the preferred zone
+			// 4. not a delayed replica
+			if !r.isBrainSplit && r.mysql.Promotable() && !r.refuseFailover() && !r.waitForPreferredZone() && !r.isDelayed() {
 				r.WARNING("timeout.and.ping.almost.node.successed.promote.to.candidate")
 				r.upgradeToCandidate()
 			}
@@ -78,6 +87,10 @@ func (r *Follower) Loop() {
 
 				if rsp.RetCode != model.OK {
 					r.WARNING("process.heartbeat.request.RetCode.not.OK:%+v", rsp.RetCode)
+				} else {
+					r.preferredZoneWaits = 0
+					r.refusedView = ""
+					r.updateLastLeader(req.GetFrom())
 				}
 				// reset timeout
 				r.resetElectionTimeout()
@@ -120,6 +133,9 @@ func (r *Follower) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Raf
 	rsp.Raft.EpochID = r.getEpochID()
 	rsp.Raft.State = r.state.String()
 	rsp.Relay_Master_Log_File = r.mysql.RelayMasterLogFile()
+	rsp.Seconds_Behind_Master = r.mysql.SecondsBehindMaster()
+	rsp.Backuping = r.isBackupRunning()
+	r.setCascadeResponse(rsp)
 
 	r.DEBUG("get.heartbeat.from[N:%v, V:%v, E:%v]...", req.GetFrom(), req.GetViewID(), req.GetEpochID())
 	if !r.checkRequest(req) {
@@ -136,6 +152,8 @@ func (r *Follower) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Raf
 		rsp.RetCode = model.ErrorInvalidViewID
 
 	case viewdiff <= 0:
+		r.setSemiSyncDegraded(req.SemiSyncDegraded)
+
 		// MySQL1: disable master semi-sync because I am a slave
 		if err := r.mysql.DisableSemiSyncMaster(); err != nil {
 			r.ERROR("mysql.DisableSemiSyncMaster.error[%v]", err)
@@ -151,8 +169,8 @@ func (r *Follower) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Raf
 			r.ERROR("mysql.StartSlave.error[%v]", err)
 		}
 
-		// MySQL4: change master
-		if r.getLeader() != req.GetFrom() {
+		// MySQL4: change master, the leader or my upstream changes
+		if r.getLeader() != req.GetFrom() || r.getUpstream() != req.Upstream {
 			gtid, err := r.mysql.GetGTID()
 			if err == nil {
 				r.WARNING("get.heartbeat.my.gtid.is:%v", gtid)
@@ -163,8 +181,9 @@ func (r *Follower) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Raf
 				r.degradeToInvalid(&gtid, &req.GTID)
 			}
 
-			r.WARNING("get.heartbeat.from[N:%v, V:%v, E:%v].change.mysql.master", req.GetFrom(), req.GetViewID(), req.GetEpochID())
+			r.WARNING("get.heartbeat.from[N:%v, V:%v, E:%v].change.mysql.master.upstream[%v].delay[%v]", req.GetFrom(), req.GetViewID(), req.GetEpochID(), req.Upstream, r.conf.ReplicationDelaySeconds)
 			req.Repl.Repl_GTID_Purged = r.Raft.mysql.GetReplGtidPurged()
+			req.Repl.Master_Delay = r.conf.ReplicationDelaySeconds
 			if err := r.mysql.ChangeMasterTo(&req.Repl); err != nil {
 				r.ERROR("change.master.to[FROM:%v, GTID:%v].error[%v]", req.GetFrom(), req.GetRepl(), err)
 				// ChangeToMasterError is true, means we can't promotable to CANDIDATE.
@@ -176,7 +195,8 @@ func (r *Follower) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Raf
 			}
 
 			r.ChangeToMasterError = false
-			r.leader = req.GetFrom()
+			r.setLeader(req.GetFrom())
+			r.setUpstream(req.Upstream)
 			r.WARNING("get.heartbeat.change.to.the.new.master[%v].successed", req.GetFrom())
 		}
 
@@ -189,8 +209,11 @@ func (r *Follower) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Raf
 		// epoch change
 		if epochdiff != 0 {
 			r.WARNING("get.heartbeat.from[N:%v, V:%v, E:%v].update.epoch", req.GetFrom(), req.GetViewID(), req.GetEpochID())
-			r.updateEpoch(req.GetEpochID(), req.GetPeers(), req.GetIdlePeers())
+			r.updateEpoch(req.GetEpochID(), req.GetPeers(), req.GetIdlePeers(), req.GetReplicas())
 		}
+
+		// the external binlog consumers of the leader
+		r.syncPurgeConsumers(req.PurgeConsumers)
 	}
 	return rsp
 }
@@ -247,8 +270,8 @@ func (r *Follower) processRequestVoteRequest(req *model.RaftRPCRequest) *model.R
 
 		if greater {
 			// reject cases:
-			// 1. I am promotable: I am alive and GTID greater than you
-			if r.mysql.Promotable() {
+			// 1. I am promotable: I am alive and GTID greater than you, the delayed replica never promotes
+			if r.mysql.Promotable() && !r.isDelayed() {
 				r.WARNING("get.requestvote.from[N:%v, V:%v, E:%v].stale.ret.ErrorInvalidGTID", req.GetFrom(), req.GetViewID(), req.GetEpochID())
 				rsp.RetCode = model.ErrorInvalidGTID
 				return rsp
@@ -348,6 +371,42 @@ func (r *Follower) sendClusterPing(respChan chan *model.RaftRPCResponse) {
 	}
 }
 
+// refuseFailover returns true if the policy refuses the automatic failover and the leader semi-sync has fallen back to async,
+// the slaves may miss the transactions committed without ACK, promote one by hand with 'xenoncli raft trytoleader'.
+// It's counted once per leader and viewid, the repeats on the later election timeouts are only warned.
+func (r *Follower) refuseFailover() bool {
+	if r.conf.SemiSyncDegradePolicy != SemiSyncDegradeRefuseFailover || !r.getSemiSyncDegraded() {
+		r.refusedView = ""
+		return false
+	}
+	view := fmt.Sprintf("%v@%v", r.getLeader(), r.getViewID())
+	if r.refusedView == view {
+		r.WARNING("timeout.but.the.leader[%v].semi-sync.was.async.still.refuse.to.promote[policy:%v]", r.getLeader(), r.conf.SemiSyncDegradePolicy)
+		return true
+	}
+	r.refusedView = view
+	r.IncFollowerRefusedFailovers()
+	r.ERROR("timeout.but.the.leader[%v].semi-sync.was.async.refuse.to.promote[policy:%v]", r.getLeader(), r.conf.SemiSyncDegradePolicy)
+	return true
+}
+
+// waitForPreferredZone returns true if we aren't in the preferred zone and it has a quorum alive,
+// we give up waiting after preferredZoneMaxWaits election timeouts without a leader.
+func (r *Follower) waitForPreferredZone() bool {
+	ok, alive, members := r.preferredZoneHasQuorum()
+	if !ok {
+		r.preferredZoneWaits = 0
+		return false
+	}
+	if r.preferredZoneWaits >= preferredZoneMaxWaits {
+		r.ERROR("timeout.and.the.preferred.zone[%v].has.quorum[%v/%v].but.no.leader.in[%v].election.timeouts.promote", r.conf.PreferredZone, alive, members, r.preferredZoneWaits)
+		return false
+	}
+	r.preferredZoneWaits++
+	r.WARNING("timeout.but.the.preferred.zone[%v].has.quorum[%v/%v].alive.wait.for.the.leader.there[%v/%v]", r.conf.PreferredZone, alive, members, r.preferredZoneWaits, preferredZoneMaxWaits)
+	return true
+}
+
 func (r *Follower) upgradeToCandidate() {
 	// only you
 	if len(r.peers) == 0 {
@@ -423,6 +482,7 @@ func (r *Follower) setMySQLAsync() {
 func (r *Follower) stateInit() {
 	r.WARNING("state.init")
 	r.updateStateBegin()
+	r.preferredZoneWaits = 0
 	// 1. stop vip
 	if err := r.leaderStopShellCommand(); err != nil {
 		// TODO(array): what todo?
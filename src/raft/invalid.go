@@ -94,6 +94,8 @@ func (r *Invalid) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Raft
 	rsp.Raft.EpochID = r.getEpochID()
 	rsp.Raft.State = r.state.String()
 	rsp.Relay_Master_Log_File = r.mysql.RelayMasterLogFile()
+	rsp.Seconds_Behind_Master = r.mysql.SecondsBehindMaster()
+	rsp.Backuping = r.isBackupRunning()
 
 	if !r.checkRequest(req) {
 		rsp.RetCode = model.ErrorInvalidRequest
@@ -121,14 +123,15 @@ func (r *Invalid) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Raft
 
 		// MySQL4: change master
 		if r.getLeader() != req.GetFrom() {
-			r.WARNING("get.heartbeat.from[N:%v, V:%v, E:%v].change.mysql.master[%+v]", req.GetFrom(), req.GetViewID(), req.GetEpochID(), req.GetGTID())
+			r.WARNING("get.heartbeat.from[N:%v, V:%v, E:%v].change.mysql.master[%+v].delay[%v]", req.GetFrom(), req.GetViewID(), req.GetEpochID(), req.GetGTID(), r.conf.ReplicationDelaySeconds)
 
+			req.Repl.Master_Delay = r.conf.ReplicationDelaySeconds
 			if err := r.mysql.ChangeMasterTo(&req.Repl); err != nil {
 				r.ERROR("change.master.to[FROM:%v, GTID:%v].error[%v]", req.GetFrom(), req.GetRepl(), err)
 				rsp.RetCode = model.ErrorChangeMaster
 				return rsp
 			}
-			r.leader = req.GetFrom()
+			r.setLeader(req.GetFrom())
 		}
 
 		// view change
@@ -140,8 +143,11 @@ func (r *Invalid) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Raft
 		// epoch change
 		if epochdiff != 0 {
 			r.WARNING("get.heartbeat.from[N:%v, V:%v, E:%v].update.epoch", req.GetFrom(), req.GetViewID(), req.GetEpochID())
-			r.updateEpoch(req.GetEpochID(), req.GetPeers(), req.GetIdlePeers())
+			r.updateEpoch(req.GetEpochID(), req.GetPeers(), req.GetIdlePeers(), req.GetReplicas())
 		}
+
+		// the external binlog consumers of the leader
+		r.syncPurgeConsumers(req.PurgeConsumers)
 	}
 	return rsp
 }
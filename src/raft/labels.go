@@ -0,0 +1,126 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018-2019 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"time"
+)
+
+const (
+	// the election timeouts a node waits for the leader in the preferred zone,
+	// the preferred zone may not elect one if this node has the newest GTID.
+	preferredZoneMaxWaits = 10
+)
+
+const (
+	// LabelZone is the label key of the zone.
+	LabelZone = "zone"
+
+	// LabelRegion is the label key of the region.
+	LabelRegion = "region"
+)
+
+// peerLabels is what we got from the last rpc with the peer.
+type peerLabels struct {
+	labels map[string]string
+	seen   time.Time
+}
+
+// GetLabels returns the labels of this node, the zone and region in config override the ones in labels.
+func (r *Raft) GetLabels() map[string]string {
+	labels := make(map[string]string, len(r.conf.Labels)+2)
+	for k, v := range r.conf.Labels {
+		labels[k] = v
+	}
+	if r.conf.Zone != "" {
+		labels[LabelZone] = r.conf.Zone
+	}
+	if r.conf.Region != "" {
+		labels[LabelRegion] = r.conf.Region
+	}
+	return labels
+}
+
+func (r *Raft) getZone() string {
+	return r.GetLabels()[LabelZone]
+}
+
+// peerHeartbeat is the leader and the time we got its heartbeat.
+type peerHeartbeat struct {
+	id   string
+	seen time.Time
+}
+
+// updateLastLeader saves the leader whose heartbeat we got.
+func (r *Raft) updateLastLeader(id string) {
+	r.peerLabelsMu.Lock()
+	defer r.peerLabelsMu.Unlock()
+	r.lastLeader = peerHeartbeat{id: id, seen: time.Now()}
+}
+
+// updatePeerLabels saves the labels of the peer and the time we heard from it.
+func (r *Raft) updatePeerLabels(id string, labels map[string]string) {
+	if id == "" || id == r.getID() {
+		return
+	}
+	r.peerLabelsMu.Lock()
+	defer r.peerLabelsMu.Unlock()
+	r.peerLabels[id] = peerLabels{labels: labels, seen: time.Now()}
+}
+
+// getPeerLabels returns the labels of the peer, false if we never heard from it.
+func (r *Raft) getPeerLabels(id string) (peerLabels, bool) {
+	r.peerLabelsMu.Lock()
+	defer r.peerLabelsMu.Unlock()
+	labels, ok := r.peerLabels[id]
+	return labels, ok
+}
+
+func (r *Raft) getPeerZone(id string) string {
+	if id == r.getID() {
+		return r.getZone()
+	}
+	labels, _ := r.getPeerLabels(id)
+	return labels.labels[LabelZone]
+}
+
+// preferredZoneHasQuorum returns true if this node isn't in the preferred zone and the preferred zone
+// has a quorum of its members alive, the leader should be elected there.
+// It also returns the alive and the members of the preferred zone, the members whose zones are unknown are not counted,
+// they are alive if we heard from them in an election timeout.
+// It's called once the heartbeats stopped, so the last leader is dead unless we heard from it after its last heartbeat.
+func (r *Raft) preferredZoneHasQuorum() (bool, int, int) {
+	preferred := r.conf.PreferredZone
+	if preferred == "" || r.getZone() == preferred {
+		return false, 0, 0
+	}
+
+	var members, alive int
+	expired := time.Duration(r.getElectionTimeout()) * time.Millisecond
+	r.peerLabelsMu.Lock()
+	lastLeader := r.lastLeader
+	r.peerLabelsMu.Unlock()
+	for _, id := range r.getPeers() {
+		if id == r.getID() {
+			continue
+		}
+		labels, ok := r.getPeerLabels(id)
+		if !ok || labels.labels[LabelZone] != preferred {
+			continue
+		}
+		members++
+		if id == lastLeader.id && !labels.seen.After(lastLeader.seen) {
+			continue
+		}
+		if time.Since(labels.seen) <= expired {
+			alive++
+		}
+	}
+	return members > 0 && alive >= members/2+1, alive, members
+}
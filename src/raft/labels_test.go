@@ -0,0 +1,170 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018-2019 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"config"
+	"mysql"
+	"testing"
+	"time"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRaftLabels(t *testing.T) {
+	conf := config.DefaultRaftConfig()
+	conf.Labels = map[string]string{"rack": "r1", LabelZone: "zone-x"}
+	leader, _, cleanup := mockDurabilityLeader(t, conf)
+	defer cleanup()
+
+	want := map[string]string{"rack": "r1", LabelZone: "zone-x"}
+	assert.Equal(t, want, leader.GetLabels())
+	assert.Equal(t, "zone-x", leader.getZone())
+
+	// the zone and region in config override the labels
+	conf.Zone = "zone-a"
+	conf.Region = "region-a"
+	want = map[string]string{"rack": "r1", LabelZone: "zone-a", LabelRegion: "region-a"}
+	assert.Equal(t, want, leader.GetLabels())
+	assert.Equal(t, "zone-a", leader.getZone())
+}
+
+func TestRaftPreferredZoneHasQuorum(t *testing.T) {
+	conf := config.DefaultRaftConfig()
+	conf.ElectionTimeout = 300
+	conf.Zone = "zone-dr"
+	leader, ids, cleanup := mockDurabilityLeader(t, conf)
+	defer cleanup()
+	leader.AddPeer("192.168.0.4:8801")
+	ids = append(ids, "192.168.0.4:8801")
+
+	// no preferred zone
+	ok, _, _ := leader.preferredZoneHasQuorum()
+	assert.False(t, ok)
+
+	// the peers are unknown
+	conf.PreferredZone = "zone-a"
+	ok, alive, members := leader.preferredZoneHasQuorum()
+	assert.False(t, ok)
+	assert.Equal(t, 0, alive)
+	assert.Equal(t, 0, members)
+
+	// 2 of 3 alive in the preferred zone
+	for _, id := range ids[1:] {
+		leader.updatePeerLabels(id, map[string]string{LabelZone: "zone-a"})
+	}
+	time.Sleep(time.Millisecond * time.Duration(leader.getElectionTimeout()*2))
+	leader.updatePeerLabels(ids[1], map[string]string{LabelZone: "zone-a"})
+	leader.updatePeerLabels(ids[2], map[string]string{LabelZone: "zone-a"})
+	ok, alive, members = leader.preferredZoneHasQuorum()
+	assert.True(t, ok)
+	assert.Equal(t, 2, alive)
+	assert.Equal(t, 3, members)
+
+	// 1 of 3 alive in the preferred zone
+	time.Sleep(time.Millisecond * time.Duration(leader.getElectionTimeout()*2))
+	leader.updatePeerLabels(ids[1], map[string]string{LabelZone: "zone-a"})
+	ok, _, _ = leader.preferredZoneHasQuorum()
+	assert.False(t, ok)
+
+	// the leader in the preferred zone died, it isn't alive though we heard from it in an election timeout
+	time.Sleep(time.Millisecond * time.Duration(leader.getElectionTimeout()*2))
+	leader.updatePeerLabels(ids[1], map[string]string{LabelZone: "zone-a"})
+	leader.updatePeerLabels(ids[2], map[string]string{LabelZone: "zone-a"})
+	leader.updateLastLeader(ids[1])
+	ok, alive, members = leader.preferredZoneHasQuorum()
+	assert.False(t, ok)
+	assert.Equal(t, 1, alive)
+	assert.Equal(t, 3, members)
+
+	// we heard from the last leader after its heartbeat, it's alive again
+	time.Sleep(time.Millisecond * 10)
+	leader.updatePeerLabels(ids[1], map[string]string{LabelZone: "zone-a"})
+	ok, alive, _ = leader.preferredZoneHasQuorum()
+	assert.True(t, ok)
+	assert.Equal(t, 2, alive)
+
+	// the follower waits at most preferredZoneMaxWaits election timeouts
+	leader.updatePeerLabels(ids[2], map[string]string{LabelZone: "zone-a"})
+	for i := 0; i < preferredZoneMaxWaits; i++ {
+		assert.True(t, leader.F.waitForPreferredZone())
+	}
+	assert.False(t, leader.F.waitForPreferredZone())
+
+	// I am in the preferred zone
+	conf.Zone = "zone-a"
+	ok, _, _ = leader.preferredZoneHasQuorum()
+	assert.False(t, ok)
+	assert.False(t, leader.F.waitForPreferredZone())
+	assert.Equal(t, 0, leader.F.preferredZoneWaits)
+}
+
+// TEST EFFECTS:
+// test the leader is elected in the preferred zone
+//
+// TEST PROCESSES:
+//  1. set rafts GTID
+//     1.0 rafts[0]  with MockGTID_X5{Master_Log_File = "mysql-bin.000005", Read_Master_Log_Pos = 123}
+//     1.1 rafts[1]  with MockGTID_X3{Master_Log_File = "mysql-bin.000003", Read_Master_Log_Pos = 123}
+//     1.2 rafts[2]  with MockGTID_X5{Master_Log_File = "mysql-bin.000005", Read_Master_Log_Pos = 123}
+//  2. rafts[0] and rafts[1] in zone-a, rafts[2] in zone-dr, the preferred zone is zone-a
+//  3. Start 3 rafts state as FOLLOWER
+//  4. wait rafts[0] elected as leader, rafts[2] waits for the preferred zone
+//  5. stop rafts[0], the preferred zone has no quorum
+//  6. wait rafts[2] elected as leader
+func TestRaftLeaderInPreferredZone(t *testing.T) {
+	conf := config.DefaultRaftConfig()
+	conf.MetaDatadir = "/tmp/"
+	conf.PreferredZone = "zone-a"
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, cleanup := MockRaftsWithConfig(log, conf, port, 3, -1)
+	defer cleanup()
+
+	// 1. set rafts GTID
+	{
+		rafts[0].mysql.SetMysqlHandler(mysql.NewMockGTIDX5())
+		rafts[1].mysql.SetMysqlHandler(mysql.NewMockGTIDX3())
+		rafts[2].mysql.SetMysqlHandler(mysql.NewMockGTIDX5())
+	}
+
+	// 2. zones
+	for i, zone := range []string{"zone-a", "zone-a", "zone-dr"} {
+		c := *conf
+		c.Zone = zone
+		rafts[i].conf = &c
+	}
+
+	// 3. Start 3 rafts state as FOLLOWER
+	for _, raft := range rafts {
+		raft.Start()
+	}
+
+	// 4. the leader is in the preferred zone
+	{
+		MockWaitLeaderEggs(rafts, 1)
+		assert.Equal(t, LEADER, rafts[0].getState())
+		assert.Equal(t, map[string]string{LabelZone: "zone-a"}, rafts[0].GetLabels())
+		labels, ok := rafts[0].getPeerLabels(rafts[2].getID())
+		assert.True(t, ok)
+		assert.Equal(t, map[string]string{LabelZone: "zone-dr"}, labels.labels)
+	}
+
+	// 5. stop the leader
+	rafts[0].Stop()
+
+	// 6. the preferred zone has no quorum
+	{
+		MockWaitLeaderEggs(rafts, 1)
+		assert.Equal(t, LEADER, rafts[2].getState())
+	}
+}
@@ -9,7 +9,9 @@
 package raft
 
 import (
+	"fmt"
 	"model"
+	"mysql"
 	"strings"
 	"sync"
 	"time"
@@ -31,10 +33,20 @@ type Leader struct {
 	// the binlog which we should purge to
 	nextPuregeBinlog string
 
+	// the members responded to the heartbeats of this round, include the idle nodes and the read replicas
+	purgeResponses int
+
 	purgeBinlogTick   *time.Ticker
 	checkSemiSyncTick *time.Ticker
 	checkGTIDTick     *time.Ticker
 
+	// the replicas got from the heartbeat responses, for the durability
+	replicas   map[string]replicaState
+	replicasMu sync.Mutex
+
+	// the semi-sync settings applied at last
+	durability durabilitySettings
+
 	// leader process heartbeat request handler
 	processHeartbeatRequestHandler func(*model.RaftRPCRequest) *model.RaftRPCResponse
 
@@ -51,14 +63,11 @@ type Leader struct {
 	processPingRequestHandler func(*model.RaftRPCRequest) *model.RaftRPCResponse
 }
 
-const (
-	semisyncTimeout = 1000000000000000000 // for 3 or more nodes
-)
-
 // NewLeader creates new Leader.
 func NewLeader(r *Raft) *Leader {
 	L := &Leader{
-		Raft: r,
+		Raft:     r,
+		replicas: make(map[string]replicaState),
 	}
 	L.initHandlers()
 	return L
@@ -78,12 +87,13 @@ func (r *Leader) Loop() {
 	incViewID := false
 	mysqlDown := false
 	ackGranted := 1
+	r.purgeResponses = 0
 
 	lessHtAcks := 0
 	maxLessHtAcks := r.Raft.conf.AdmitDefeatHtCnt
 
 	// send heartbeat
-	respChan := make(chan *model.RaftRPCResponse, r.getAllMembers())
+	respChan := make(chan *model.RaftRPCResponse, r.getAllMembers()+len(r.getReplicas()))
 	r.sendHeartbeatHandler(&mysqlDown, respChan)
 	r.resetHeartbeatTimeout()
 
@@ -126,7 +136,8 @@ func (r *Leader) Loop() {
 			}
 
 			ackGranted = 1
-			respChan = make(chan *model.RaftRPCResponse, r.getAllMembers())
+			r.purgeResponses = 0
+			respChan = make(chan *model.RaftRPCResponse, r.getAllMembers()+len(r.getReplicas()))
 			r.sendHeartbeatHandler(&mysqlDown, respChan)
 			r.resetHeartbeatTimeout()
 		case rsp := <-respChan:
@@ -306,6 +317,15 @@ func (r *Leader) sendHeartbeat(mysqlDown *bool, c chan *model.RaftRPCResponse) {
 			peer.sendHeartbeat(c)
 		}(peer)
 	}
+
+	// the read replicas follow me by heartbeats too
+	for _, peer := range r.readReplicas {
+		r.wg.Add(1)
+		go func(peer *Peer) {
+			defer r.wg.Done()
+			peer.sendHeartbeat(c)
+		}(peer)
+	}
 }
 
 // leaderProcessHeartbeatResponseHandler
@@ -320,24 +340,33 @@ func (r *Leader) processHeartbeatResponse(ackGranted *int, rsp *model.RaftRPCRes
 			r.degradeToFollower()
 		}
 	} else {
-		if rsp.Raft.State != IDLE.String() {
+		if rsp.Raft.State != IDLE.String() && rsp.Raft.State != REPLICA.String() {
 			*ackGranted++
 		}
+		r.purgeResponses++
+		r.updateReplica(rsp)
 		// find the smallest binlog
-		if r.relayMasterLogFile == "" {
-			r.relayMasterLogFile = rsp.Relay_Master_Log_File
-		} else if strings.Compare(r.relayMasterLogFile, rsp.Relay_Master_Log_File) > 0 {
-			r.relayMasterLogFile = rsp.Relay_Master_Log_File
+		// the binlog of the cascading replica is its upstream's, it's guarded by the GTIDs when purging
+		if rsp.Upstream == "" {
+			r.mutex.Lock()
+			if r.relayMasterLogFile == "" {
+				r.relayMasterLogFile = rsp.Relay_Master_Log_File
+			} else if strings.Compare(r.relayMasterLogFile, rsp.Relay_Master_Log_File) > 0 {
+				r.relayMasterLogFile = rsp.Relay_Master_Log_File
+			}
+			r.mutex.Unlock()
 		}
 
 		// to reset nextPuregeBinlog:
-		// we must get all responses from the follower(s) and idle(s)
+		// we must get all responses from the follower(s), idle(s) and read replica(s)
 		// imagine that:
 		// Master is doing backup for Slave2 restore
 		// Master purged to Slave1-Relay_Master_Log_File
 		// Slave2 starts up and can't find the binlog which she is want
-		if *ackGranted == r.getMembers() {
+		if r.purgeResponses == r.getAllMembers()-1+len(r.getReplicas()) {
+			r.mutex.Lock()
 			r.nextPuregeBinlog = r.relayMasterLogFile
+			r.mutex.Unlock()
 		}
 	}
 }
@@ -405,11 +434,20 @@ func (r *Leader) prepareSettingsAsync() {
 		r.WARNING("mysql.ChangeToMaster.done")
 
 		// MySQL3. enable semi-sync on master
-		// wait slave ack
+		// wait slave ack, the plugins are installed if they are missing and the wait point must be AFTER_SYNC
+		// if it still fails, the leader serves without the semi-sync rather than no leader, it shows in the semi-sync stats
+		// the async durability has no semi-sync
 		r.WARNING("3. mysql.EnableSemiSyncMaster.prepare")
-		if err := r.mysql.EnableSemiSyncMaster(); err != nil {
-			// WTF, what can we do?
-			r.ERROR("mysql.EnableSemiSyncMaster.error[%v]", err)
+		if r.conf.Durability != DurabilityAsync {
+			if err := r.mysql.InstallSemiSyncPlugins(); err != nil {
+				r.ERROR("mysql.InstallSemiSyncPlugins.error[%v]", err)
+			}
+			if err := r.mysql.CheckSemiSyncWaitPoint(); err != nil {
+				r.ERROR("mysql.CheckSemiSyncWaitPoint.error[%v]", err)
+			}
+			if err := r.mysql.EnableSemiSyncMaster(); err != nil {
+				r.ERROR("mysql.EnableSemiSyncMaster.error[%v]", err)
+			}
 		}
 		r.WARNING("mysql.EnableSemiSyncMaster.done")
 
@@ -451,13 +489,18 @@ func (r *Leader) purgeBinlogStart() {
 }
 
 func (r *Leader) purgeBinlogStop() {
+	r.mutex.Lock()
 	r.relayMasterLogFile = ""
 	r.nextPuregeBinlog = ""
+	r.mutex.Unlock()
 	r.purgeBinlogTick.Stop()
 }
 
 func (r *Leader) purgeBinlog() {
-	if r.skipPurgeBinlog {
+	r.mutex.RLock()
+	skipPurgeBinlog := r.skipPurgeBinlog
+	r.mutex.RUnlock()
+	if skipPurgeBinlog {
 		r.WARNING("purge.binlog.skipped[skipPurgeBinlog is true]")
 		return
 	}
@@ -467,14 +510,29 @@ func (r *Leader) purgeBinlog() {
 		return
 	}
 
-	if r.nextPuregeBinlog != "" {
-		if err := r.mysql.PurgeBinlogsTo(r.nextPuregeBinlog); err != nil {
-			r.ERROR("purge.binlogs.to[%v].error[%v]", r.nextPuregeBinlog, err)
+	if r.getNextPurgeBinlog() != "" {
+		plan := r.purgePlan(false)
+		next := plan.Target
+		if next == "" {
+			r.WARNING("purge.binlogs.to[%v].skipped[blocked.by.the.guards].steps[%+v]", plan.Next, plan.Steps)
+			return
+		}
+		if next != plan.Next {
+			r.WARNING("purge.binlogs.to[%v].limited.to[%v].by.the.guards.steps[%+v]", plan.Next, next, plan.Steps)
+		}
+
+		if err := r.mysql.PurgeBinlogsTo(next); err != nil {
+			r.ERROR("purge.binlogs.to[%v].error[%v]", next, err)
 			r.IncLeaderPurgeBinlogFails()
 		} else {
-			r.WARNING("purged.binlogs.to[%v]...", r.nextPuregeBinlog)
-			r.relayMasterLogFile = ""
-			r.nextPuregeBinlog = ""
+			r.WARNING("purged.binlogs.to[%v]...", next)
+			// Keep the nextPuregeBinlog if the guards limited it, purge the rest on the next tick.
+			r.mutex.Lock()
+			if next == r.nextPuregeBinlog {
+				r.relayMasterLogFile = ""
+				r.nextPuregeBinlog = ""
+			}
+			r.mutex.Unlock()
 			r.IncLeaderPurgeBinlogs()
 		}
 	}
@@ -486,6 +544,7 @@ func (r *Leader) checkSemiSyncStart() {
 	go func(leader *Leader) {
 		for range leader.checkSemiSyncTick.C {
 			leader.checkSemiSync()
+			leader.checkSemiSyncStatus()
 		}
 	}(r)
 	r.INFO("check.semi-sync.thread.start[%vms]...", interval)
@@ -496,29 +555,78 @@ func (r *Leader) checkSemiSyncStop() {
 	r.INFO("check.semi-sync.thread.stop...")
 }
 
-// Disable the semi-sync if the nodes number less than 3.
+// Apply the semi-sync settings of the durability policy as the membership changes.
 func (r *Leader) checkSemiSync() {
 	if r.skipCheckSemiSync {
 		r.WARNING("check.semi-sync.skipped[skipCheckSemiSync is true]")
 		return
 	}
 
-	min := 3
-	cur := r.getMembers()
-	if cur < min {
-		if err := r.mysql.SetSemiSyncMasterTimeout(r.semiSyncTimeoutFor2Nodes); err != nil {
-			r.ERROR("mysql.set.semi-sync.master.timeout.to.default.error[%v]", err)
+	settings := r.durabilitySettings()
+	if settings != r.durability {
+		r.WARNING("durability[%v].settings.change.from[%v].to[%v]", r.conf.Durability, r.durability, settings)
+		r.durability = settings
+	}
+
+	if !settings.semiSync {
+		if err := r.mysql.DisableSemiSyncMaster(); err != nil {
+			r.ERROR("mysql.disable.semi-sync.error[%v]", err)
 		}
+		return
+	}
+	if err := r.mysql.EnableSemiSyncMaster(); err != nil {
+		r.ERROR("mysql.enable.semi-sync.error[%v]", err)
+	}
+	if err := r.mysql.SetSemiWaitSlaveCount(settings.waitCount); err != nil {
+		r.ERROR("mysql.set.semi.wait.slave.count.error[%v]", err)
+	}
+	if err := r.mysql.SetSemiSyncMasterTimeout(settings.timeout); err != nil {
+		r.ERROR("mysql.set.semi.sync.master.timeout.error[%v]", err)
+	}
+}
+
+// checkSemiSyncStatus polls the semi-sync status once the master is read/write,
+// the master falls back to async silently when the ACK times out and a failover then loses the transactions no slave got.
+// The async durability has no semi-sync, it's degraded when the replicas lag more than durability-max-lag.
+func (r *Leader) checkSemiSyncStatus() {
+	if r.mysql.GetOption() != mysql.MysqlReadwrite {
+		return
+	}
+
+	var degraded bool
+	var detail string
+	if r.conf.Durability == DurabilityAsync {
+		lagging := r.laggingReplicas()
+		degraded = (len(lagging) > 0)
+		detail = fmt.Sprintf("lagging:%v", lagging)
 	} else {
-		if err := r.mysql.EnableSemiSyncMaster(); err != nil {
-			r.ERROR("mysql.enable.semi-sync.error[%v]", err)
-		}
-		if err := r.mysql.SetSemiWaitSlaveCount((cur - 1) / 2); err != nil {
-			r.ERROR("mysql.set.semi.wait.slave.count.error[%v]", err)
+		status, err := r.mysql.GetSemiSyncStatus()
+		if err != nil {
+			r.ERROR("mysql.get.semi-sync.status.error[%v]", err)
+			return
 		}
-		if err := r.mysql.SetSemiSyncMasterTimeout(semisyncTimeout); err != nil {
-			r.ERROR("mysql.set.semi.sync.master.timeout.to.infinite.error[%v]", err)
+		if status == nil || status.MasterStatus == "" {
+			return
 		}
+		degraded = (status.MasterStatus != "ON")
+		detail = fmt.Sprintf("clients:%v, no_tx:%v", status.Clients, status.NoTx)
+	}
+
+	if degraded == r.getSemiSyncDegraded() {
+		return
+	}
+	r.setSemiSyncDegraded(degraded)
+
+	event := "recovered"
+	if degraded {
+		event = "degraded"
+		r.IncLeaderSemiSyncFallbacks()
+		r.ERROR("durability[%v].degraded.to.async[%v].policy[%v]", r.conf.Durability, detail, r.conf.SemiSyncDegradePolicy)
+	} else {
+		r.WARNING("durability[%v].recovered[%v]", r.conf.Durability, detail)
+	}
+	if err := r.semiSyncDegradeShellCommand(event); err != nil {
+		r.ERROR("semi-sync.%v.shell.command.error[%v]", event, err)
 	}
 }
 
@@ -550,6 +658,9 @@ func (r *Leader) checkGTID() {
 func (r *Leader) stateInit() {
 	r.WARNING("state.init")
 	r.updateStateBegin()
+	r.setSemiSyncDegraded(false)
+	r.resetReplicas()
+	r.durability = durabilitySettings{}
 	r.purgeBinlogStart()
 	r.checkSemiSyncStart()
 	r.checkGTIDStart()
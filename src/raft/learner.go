@@ -96,6 +96,8 @@ func (r *Learner) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Raft
 	rsp.Raft.EpochID = r.getEpochID()
 	rsp.Raft.State = r.state.String()
 	rsp.Relay_Master_Log_File = r.mysql.RelayMasterLogFile()
+	rsp.Seconds_Behind_Master = r.mysql.SecondsBehindMaster()
+	rsp.Backuping = r.isBackupRunning()
 
 	if !r.checkRequest(req) {
 		rsp.RetCode = model.ErrorInvalidRequest
@@ -125,8 +127,11 @@ func (r *Learner) processHeartbeatRequest(req *model.RaftRPCRequest) *model.Raft
 		// epoch change
 		if epochdiff != 0 {
 			r.WARNING("get.heartbeat.from[N:%v, V:%v, E:%v].update.epoch", req.GetFrom(), req.GetViewID(), req.GetEpochID())
-			r.updateEpoch(req.GetEpochID(), req.GetPeers(), req.GetIdlePeers())
+			r.updateEpoch(req.GetEpochID(), req.GetPeers(), req.GetIdlePeers(), req.GetReplicas())
 		}
+
+		// the external binlog consumers of the leader
+		r.syncPurgeConsumers(req.PurgeConsumers)
 	}
 	return rsp
 }
@@ -46,8 +46,18 @@ func (p *Peer) sendHeartbeat(c chan *model.RaftRPCResponse) {
 	req.Raft.Leader = p.raft.getLeader()
 	req.Peers = p.raft.getPeers()
 	req.IdlePeers = p.raft.getIdlePeers()
+	req.Replicas = p.raft.getReplicas()
 	req.GTID = p.raft.getGTID()
 	req.Repl = p.raft.mysql.GetRepl()
+	req.Mysql = model.Repl{Master_Host: req.Repl.Master_Host, Master_Port: req.Repl.Master_Port}
+	// the cascading replication, the peer replicates from its upstream if it's healthy
+	if upstream, repl, ok := p.raft.L.routeUpstream(p.getID()); ok {
+		req.Upstream = upstream
+		req.Repl = repl
+	}
+	req.SemiSyncDegraded = p.raft.getSemiSyncDegraded()
+	req.PurgeConsumers = p.raft.GetPurgeConsumers()
+	req.Raft.Labels = p.raft.GetLabels()
 	client, cleanup, err := p.NewClient()
 	if err != nil {
 		p.raft.ERROR("send.heartbeat.to.peer[%v].new.client.error[%v]", p.getID(), err)
@@ -66,6 +76,7 @@ func (p *Peer) sendHeartbeat(c chan *model.RaftRPCResponse) {
 		return
 	}
 	p.raft.DEBUG("send.heartbeat.to.peer[%v].client.call.ok.rsp[%v].my.gtid.is[%v]", p.getID(), rsp, req.GTID)
+	p.raft.updatePeerLabels(p.getID(), rsp.Raft.Labels)
 	c <- rsp
 }
 
@@ -84,6 +95,7 @@ func (p *Peer) sendRequestVote(c chan *model.RaftRPCResponse) {
 	req.Raft.From = p.raft.getID()
 	req.Raft.To = p.connectionStr
 	req.Raft.Leader = p.raft.getLeader()
+	req.Raft.Labels = p.raft.GetLabels()
 	req.GTID, err = p.raft.mysql.GetGTID()
 	if err != nil {
 		p.raft.ERROR("send.requestvote.to.peer[%v].get.gtid.error[%v]", p.getID(), err)
@@ -110,6 +122,7 @@ func (p *Peer) sendRequestVote(c chan *model.RaftRPCResponse) {
 		c <- rsp
 		return
 	}
+	p.raft.updatePeerLabels(p.getID(), rsp.Raft.Labels)
 	c <- rsp
 }
 
@@ -120,6 +133,8 @@ func (p *Peer) SendPing(c chan *model.RaftRPCResponse) {
 
 	// request body
 	req := model.NewRaftRPCRequest()
+	req.Raft.From = p.raft.getID()
+	req.Raft.Labels = p.raft.GetLabels()
 
 	client, cleanup, err := p.NewClient()
 	if err != nil {
@@ -139,6 +154,7 @@ func (p *Peer) SendPing(c chan *model.RaftRPCResponse) {
 		return
 	}
 	p.raft.DEBUG("send.ping.to.peer[%v].client.call.ok.rsp[%v]", p.getID(), rsp)
+	p.raft.updatePeerLabels(p.getID(), rsp.Raft.Labels)
 	c <- rsp
 }
 
@@ -15,11 +15,12 @@ import (
 	"github.com/pkg/errors"
 )
 
-func writePeersJSON(path string, peers []string, idlePeers []string) error {
+func writePeersJSON(path string, peers []string, idlePeers []string, replicas []string) error {
 	allPeers := make(map[string][]string)
 
 	allPeers["peers"] = peers
 	allPeers["idlepeers"] = idlePeers
+	allPeers["replicas"] = replicas
 
 	jsonStr, err := json.Marshal(allPeers)
 	if err != nil {
@@ -32,19 +33,19 @@ func writePeersJSON(path string, peers []string, idlePeers []string) error {
 	return nil
 }
 
-func readPeersJSON(path string) ([]string, []string, error) {
+func readPeersJSON(path string) ([]string, []string, []string, error) {
 	//var peers []string
 	//var idlePeers []string
 	allPeers := make(map[string][]string)
 
 	buf, err := ioutil.ReadFile(path)
 	if err != nil {
-		return []string{}, []string{}, errors.WithStack(err)
+		return []string{}, []string{}, []string{}, errors.WithStack(err)
 	}
 
 	err = json.Unmarshal(buf, &allPeers)
 	if err != nil {
-		return []string{}, []string{}, errors.WithStack(err)
+		return []string{}, []string{}, []string{}, errors.WithStack(err)
 	}
-	return allPeers["peers"], allPeers["idlepeers"], nil
+	return allPeers["peers"], allPeers["idlepeers"], allPeers["replicas"], nil
 }
@@ -20,16 +20,17 @@ func TestPeersJson(t *testing.T) {
 	path := "/tmp/test.peersjson"
 	peers := []string{":0101", ":0202"}
 	idlePeers := []string{":0303", ":0404"}
+	replicas := []string{":0505"}
 
 	{
-		err := writePeersJSON(path, peers, idlePeers)
+		err := writePeersJSON(path, peers, idlePeers, replicas)
 		assert.Nil(t, err)
 		os.Remove(path)
 	}
 
 	// read error
 	{
-		_, _, err := readPeersJSON(path)
+		_, _, _, err := readPeersJSON(path)
 		want := fmt.Sprintf("open %s: no such file or directory", path)
 		got := err.Error()
 		assert.Equal(t, want, got)
@@ -37,16 +38,17 @@ func TestPeersJson(t *testing.T) {
 
 	// write json
 	{
-		err := writePeersJSON(path, peers, idlePeers)
+		err := writePeersJSON(path, peers, idlePeers, replicas)
 		assert.Nil(t, err)
 	}
 
 	// read json OK
 	{
-		ps, ips, err := readPeersJSON(path)
+		ps, ips, rs, err := readPeersJSON(path)
 		assert.Nil(t, err)
 		assert.Equal(t, peers, ps)
 		assert.Equal(t, idlePeers, ips)
+		assert.Equal(t, replicas, rs)
 	}
 
 	// json broken
@@ -61,7 +63,7 @@ func TestPeersJson(t *testing.T) {
 
 	// read error
 	{
-		_, _, err := readPeersJSON(path)
+		_, _, _, err := readPeersJSON(path)
 		want := "invalid character 'i' looking for beginning of value"
 		got := err.Error()
 		assert.Equal(t, want, got)
@@ -0,0 +1,376 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018-2019 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"model"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// purgeConsumersFile is the file for storing the external binlog consumers
+	purgeConsumersFile = "purge-consumers.json"
+)
+
+// The purge guards:
+// the leader purges to the smallest Relay_Master_Log_File of all the members, then the guards hold it back
+// one by one, the backup, the retention floors, the external consumers, the cascading replicas and
+// the guards added by AddPurgeBinlogGuard. The purge plan shows the binlog each guard allows and why.
+
+// purgeGuard returns the binlog which is safe to purge to and the reason if it holds the next back,
+// the check is true for the purge plan, see PurgeBinlogGuard.
+type purgeGuard struct {
+	name  string
+	guard func(next string, check bool) (string, string)
+}
+
+// newPurgeGuard wraps the PurgeBinlogGuard with the reasons.
+func newPurgeGuard(name string, guard PurgeBinlogGuard) purgeGuard {
+	return purgeGuard{name: name, guard: func(next string, check bool) (string, string) {
+		binlog := guard(next, check)
+		switch {
+		case binlog == "":
+			return "", "blocked.by.the.guard"
+		case binlog != next:
+			return binlog, "limited.by.the.guard"
+		}
+		return binlog, ""
+	}}
+}
+
+// The external consumers are set on the leader, it carries them to all the members and read replicas
+// in the heartbeats, so the next leader keeps their binlogs too and the members which were down catch up.
+// Only the positions are persisted, the heartbeat times of the consumers are kept in memory.
+
+// initPurgeConsumers loads the external consumers from the meta dir,
+// they have a stale period to heartbeat again since the times are not kept.
+func (r *Raft) initPurgeConsumers() {
+	path := filepath.Join(r.conf.MetaDatadir, purgeConsumersFile)
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			r.ERROR("read.purge.consumers[%v].error[%v]", path, err)
+		}
+		return
+	}
+
+	var consumers []model.PurgeConsumer
+	if err := json.Unmarshal(buf, &consumers); err != nil {
+		r.ERROR("unmarshal.purge.consumers[%v].error[%v]", path, err)
+		return
+	}
+	now := time.Now()
+	for _, consumer := range consumers {
+		consumer.Updated = now
+		r.purgeConsumers[consumer.Name] = consumer
+	}
+	r.INFO("purge.consumers.loaded[%+v]", consumers)
+}
+
+// writePurgeConsumers writes the consumers to a temporary file and renames it in the meta dir,
+// the caller must hold purgeConsumersMu.
+func (r *Raft) writePurgeConsumers() error {
+	consumers := make([]model.PurgeConsumer, 0, len(r.purgeConsumers))
+	for _, consumer := range r.purgeConsumers {
+		consumer.Updated = time.Time{}
+		consumers = append(consumers, consumer)
+	}
+	sort.Slice(consumers, func(i, j int) bool { return consumers[i].Name < consumers[j].Name })
+	buf, err := json.Marshal(consumers)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	path := filepath.Join(r.conf.MetaDatadir, purgeConsumersFile)
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf, 0644); err != nil {
+		os.Remove(tmp)
+		return errors.WithStack(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// samePurgePosition returns true if the consumers have the same persisted positions.
+func samePurgePosition(a model.PurgeConsumer, b model.PurgeConsumer) bool {
+	return a.Name == b.Name && a.Binlog == b.Binlog && a.GTID == b.GTID
+}
+
+// checkPurgeConsumerLeader returns error if this node isn't the leader, the others follow its consumers.
+func (r *Raft) checkPurgeConsumerLeader() error {
+	if r.getState() != LEADER {
+		return errors.Errorf("purge.consumer.is.only.set.on.the.leader[%v]", r.getLeader())
+	}
+	return nil
+}
+
+// SetPurgeConsumer used to add or update an external binlog consumer on the leader, the leader keeps the binlogs it needs.
+// The heartbeat of the consumer which doesn't move only updates the time in memory.
+func (r *Raft) SetPurgeConsumer(consumer model.PurgeConsumer) error {
+	if err := r.checkPurgeConsumerLeader(); err != nil {
+		return err
+	}
+	if consumer.Name == "" {
+		return errors.New("purge.consumer.name.is.empty")
+	}
+	if consumer.Binlog == "" && consumer.GTID == "" {
+		return errors.Errorf("purge.consumer[%v].binlog.and.gtid.are.both.empty", consumer.Name)
+	}
+
+	consumer.Updated = time.Now()
+	r.purgeConsumersMu.Lock()
+	defer r.purgeConsumersMu.Unlock()
+	old, ok := r.purgeConsumers[consumer.Name]
+	r.purgeConsumers[consumer.Name] = consumer
+	if ok && samePurgePosition(old, consumer) {
+		return nil
+	}
+	if err := r.writePurgeConsumers(); err != nil {
+		if ok {
+			r.purgeConsumers[consumer.Name] = old
+		} else {
+			delete(r.purgeConsumers, consumer.Name)
+		}
+		return err
+	}
+	r.WARNING("purge.consumer.set[%+v]", consumer)
+	return nil
+}
+
+// RemovePurgeConsumer used to remove an external binlog consumer on the leader.
+func (r *Raft) RemovePurgeConsumer(name string) error {
+	if err := r.checkPurgeConsumerLeader(); err != nil {
+		return err
+	}
+	r.purgeConsumersMu.Lock()
+	defer r.purgeConsumersMu.Unlock()
+	old, ok := r.purgeConsumers[name]
+	if !ok {
+		return errors.Errorf("purge.consumer[%v].does.not.exist", name)
+	}
+	delete(r.purgeConsumers, name)
+	if err := r.writePurgeConsumers(); err != nil {
+		r.purgeConsumers[name] = old
+		return err
+	}
+	r.WARNING("purge.consumer.removed[%v]", name)
+	return nil
+}
+
+// syncPurgeConsumers used to follow the consumers which the leader carries in the heartbeat,
+// the file is written only if the positions changed.
+func (r *Raft) syncPurgeConsumers(consumers []model.PurgeConsumer) {
+	r.purgeConsumersMu.Lock()
+	defer r.purgeConsumersMu.Unlock()
+
+	changed := len(consumers) != len(r.purgeConsumers)
+	synced := make(map[string]model.PurgeConsumer, len(consumers))
+	for _, consumer := range consumers {
+		if old, ok := r.purgeConsumers[consumer.Name]; !ok || !samePurgePosition(old, consumer) {
+			changed = true
+		}
+		synced[consumer.Name] = consumer
+	}
+	old := r.purgeConsumers
+	r.purgeConsumers = synced
+	if !changed {
+		return
+	}
+	if err := r.writePurgeConsumers(); err != nil {
+		r.purgeConsumers = old
+		r.ERROR("sync.purge.consumers[%+v].error[%v]", consumers, err)
+		return
+	}
+	r.WARNING("purge.consumers.synced.from.the.leader[%+v]", consumers)
+}
+
+// GetPurgeConsumers returns the external binlog consumers sorted by name.
+func (r *Raft) GetPurgeConsumers() []model.PurgeConsumer {
+	r.purgeConsumersMu.Lock()
+	defer r.purgeConsumersMu.Unlock()
+	consumers := make([]model.PurgeConsumer, 0, len(r.purgeConsumers))
+	for _, consumer := range r.purgeConsumers {
+		consumers = append(consumers, consumer)
+	}
+	sort.Slice(consumers, func(i, j int) bool { return consumers[i].Name < consumers[j].Name })
+	return consumers
+}
+
+// backupGuard blocks the purge while any member is running a backup,
+// the backup copies the binlogs and the restored node replicates from its position.
+func (r *Leader) backupGuard(next string, check bool) (string, string) {
+	if r.isBackupRunning() {
+		return "", fmt.Sprintf("backup.is.running.on[%v]", r.getID())
+	}
+	for _, ids := range [][]string{r.getPeers(), r.getIdlePeers(), r.getReplicas()} {
+		for _, id := range ids {
+			if state, ok := r.getReplica(id); ok && id != r.getID() && state.backuping {
+				return "", fmt.Sprintf("backup.is.running.on[%v]", id)
+			}
+		}
+	}
+	return next, ""
+}
+
+// retentionGuard keeps the binlogs closed in the last purge-binlog-retention-seconds,
+// and at least purge-binlog-retention-mb binlogs.
+func (r *Leader) retentionGuard(next string, check bool) (string, string) {
+	seconds := r.conf.PurgeBinlogRetentionSeconds
+	floor := int64(r.conf.PurgeBinlogRetentionMB) * 1024 * 1024
+	if seconds <= 0 && floor <= 0 {
+		return next, ""
+	}
+
+	basename, err := r.mysql.GetBinlogBasename()
+	if err != nil {
+		return "", fmt.Sprintf("get.binlog.basename.error[%v]", err)
+	}
+	binlogs, err := r.mysql.GetBinaryLogs()
+	if err != nil {
+		return "", fmt.Sprintf("get.binary.logs.error[%v]", err)
+	}
+	if len(binlogs) == 0 {
+		return "", "no.binary.logs"
+	}
+
+	var kept int64
+	infos := make([]os.FileInfo, len(binlogs))
+	for i, binlog := range binlogs {
+		info, err := os.Stat(filepath.Join(filepath.Dir(basename), binlog))
+		if err != nil {
+			return "", fmt.Sprintf("stat.binlog[%v].error[%v]", binlog, err)
+		}
+		infos[i] = info
+		kept += info.Size()
+	}
+
+	// purging to the first one purges nothing
+	target, reason := binlogs[0], ""
+	for i := 0; i+1 < len(binlogs) && binlogs[i+1] <= next; i++ {
+		if seconds > 0 && time.Since(infos[i].ModTime()) < time.Duration(seconds)*time.Second {
+			reason = fmt.Sprintf("binlog[%v].closed.in.the.last[%vs]", binlogs[i], seconds)
+			break
+		}
+		if floor > 0 && kept-infos[i].Size() < floor {
+			reason = fmt.Sprintf("binlogs.left.less.than[%vMB]", r.conf.PurgeBinlogRetentionMB)
+			break
+		}
+		kept -= infos[i].Size()
+		target = binlogs[i+1]
+	}
+	if target == binlogs[0] {
+		return "", reason
+	}
+	return target, reason
+}
+
+// isStaleConsumer returns true if the consumer didn't heartbeat in purge-consumer-stale-seconds.
+func (r *Raft) isStaleConsumer(consumer model.PurgeConsumer) bool {
+	stale := time.Duration(r.conf.PurgeConsumerStaleSeconds) * time.Second
+	return stale > 0 && time.Since(consumer.Updated) > stale
+}
+
+// consumersGuard keeps the binlogs which the external consumers haven't consumed, the stale ones are skipped.
+func (r *Leader) consumersGuard(next string, check bool) (string, string) {
+	var reason string
+	var executed []string
+	for _, consumer := range r.GetPurgeConsumers() {
+		if r.isStaleConsumer(consumer) {
+			if !check {
+				r.WARNING("purge.consumer[%v].is.stale.since[%v].skipped", consumer.Name, consumer.Updated)
+			}
+			continue
+		}
+		if consumer.Binlog != "" && consumer.Binlog < next {
+			next = consumer.Binlog
+			reason = fmt.Sprintf("consumer[%v].is.reading[%v]", consumer.Name, consumer.Binlog)
+		}
+		if consumer.GTID != "" {
+			executed = append(executed, consumer.GTID)
+		}
+	}
+	if binlog := r.executedTo(next, executed); binlog != next {
+		return binlog, "consumers.need.the.gtids.of.the.binlogs"
+	}
+	return next, reason
+}
+
+// getNextPurgeBinlog returns the smallest Relay_Master_Log_File of all the members, empty if it's not known yet.
+func (r *Leader) getNextPurgeBinlog() string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.nextPuregeBinlog
+}
+
+// purgePlan returns the binlog which the leader would purge to and why,
+// the check is true if it's only shown to the user, the guards don't change anything then.
+func (r *Leader) purgePlan(check bool) *model.PurgePlan {
+	// the plan is also made by the rpc, the fields are snapshotted with the lock
+	r.mutex.RLock()
+	plan := &model.PurgePlan{State: r.getState().String(), Next: r.nextPuregeBinlog}
+	skipPurgeBinlog := r.skipPurgeBinlog
+	guards := []purgeGuard{
+		{name: "backup", guard: r.backupGuard},
+		{name: "retention", guard: r.retentionGuard},
+		{name: "consumers", guard: r.consumersGuard},
+		{name: "cascade", guard: r.cascadeGuard},
+	}
+	guards = append(guards, r.purgeBinlogGuards...)
+	r.mutex.RUnlock()
+
+	switch {
+	case plan.State != LEADER.String():
+		plan.Note = "only.the.leader.purges.binlogs"
+		return plan
+	case skipPurgeBinlog:
+		plan.Note = "skipPurgeBinlog.is.true"
+		return plan
+	case r.conf.PurgeBinlogDisabled:
+		plan.Note = "conf.PurgeBinlogDisabled.is.true"
+		return plan
+	case plan.Next == "":
+		plan.Note = "waiting.for.the.responses.of.all.members"
+		return plan
+	}
+
+	target := plan.Next
+	for _, g := range guards {
+		binlog, reason := g.guard(target, check)
+		plan.Steps = append(plan.Steps, model.PurgePlanStep{Guard: g.name, Binlog: binlog, Reason: reason})
+		if target = binlog; target == "" {
+			break
+		}
+	}
+	plan.Target = target
+	if target == "" {
+		return plan
+	}
+
+	binlogs, err := r.mysql.GetBinaryLogs()
+	if err != nil {
+		r.ERROR("purge.plan.get.binary.logs.error[%v]", err)
+		return plan
+	}
+	for _, binlog := range binlogs {
+		if binlog < target {
+			plan.Purged = append(plan.Purged, binlog)
+		}
+	}
+	return plan
+}
@@ -0,0 +1,343 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018-2019 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"config"
+	"database/sql"
+	"io/ioutil"
+	"model"
+	"mysql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var purgeTestBinlogs = []string{"mysql-bin.000001", "mysql-bin.000002", "mysql-bin.000003", "mysql-bin.000004"}
+
+// mockPurgeLeader returns a leader whose binlogs are the files of 1MB in the meta dir,
+// the mysql-bin.00000N closed N hours ago, the last one is active.
+func mockPurgeLeader(t *testing.T, conf *config.RaftConfig) (*Leader, []string, func()) {
+	leader, ids, cleanup := mockDurabilityLeader(t, conf)
+	for i, binlog := range purgeTestBinlogs {
+		path := filepath.Join(conf.MetaDatadir, binlog)
+		assert.Nil(t, ioutil.WriteFile(path, make([]byte, 1024*1024), 0644))
+		mtime := time.Now().Add(-time.Duration(len(purgeTestBinlogs)-i) * time.Hour)
+		if i == len(purgeTestBinlogs)-1 {
+			mtime = time.Now()
+		}
+		assert.Nil(t, os.Chtimes(path, mtime, mtime))
+	}
+
+	mock := mysql.NewMockGTIDA()
+	mock.GetBinaryLogsFn = func(db *sql.DB) ([]string, error) {
+		return purgeTestBinlogs, nil
+	}
+	mock.GetBinlogBasenameFn = func(db *sql.DB) (string, error) {
+		return filepath.Join(conf.MetaDatadir, "mysql-bin"), nil
+	}
+	leader.mysql.SetMysqlHandler(mock)
+	leader.setState(LEADER)
+	leader.nextPuregeBinlog = "mysql-bin.000004"
+	return leader, ids, cleanup
+}
+
+func TestRaftPurgeRetentionGuard(t *testing.T) {
+	conf := config.DefaultRaftConfig()
+	leader, _, cleanup := mockPurgeLeader(t, conf)
+	defer cleanup()
+
+	tests := []struct {
+		seconds int
+		mb      int
+		next    string
+		want    string
+	}{
+		{0, 0, "mysql-bin.000004", "mysql-bin.000004"},
+		// mysql-bin.000002 closed 3 hours ago, mysql-bin.000003 closed 2 hours ago
+		{3600 * 5 / 2, 0, "mysql-bin.000004", "mysql-bin.000003"},
+		{3600 * 5, 0, "mysql-bin.000004", ""},
+		{0, 2, "mysql-bin.000004", "mysql-bin.000003"},
+		{0, 3, "mysql-bin.000003", "mysql-bin.000002"},
+		{0, 4, "mysql-bin.000004", ""},
+		{3600 * 5 / 2, 3, "mysql-bin.000004", "mysql-bin.000002"},
+		{3600, 1, "mysql-bin.000002", "mysql-bin.000002"},
+	}
+	for _, test := range tests {
+		conf.PurgeBinlogRetentionSeconds = test.seconds
+		conf.PurgeBinlogRetentionMB = test.mb
+		got, _ := leader.retentionGuard(test.next, false)
+		assert.Equal(t, test.want, got, "%+v", test)
+	}
+}
+
+func TestRaftPurgeConsumers(t *testing.T) {
+	conf := config.DefaultRaftConfig()
+	leader, _, cleanup := mockPurgeLeader(t, conf)
+	defer cleanup()
+
+	uuid := "84030605-66aa-11e6-9465-52540e7fd51c"
+	mock := mysql.NewMockGTIDA()
+	mock.GetBinaryLogsFn = func(db *sql.DB) ([]string, error) {
+		return purgeTestBinlogs, nil
+	}
+	mock.GetBinlogPreviousGTIDsFn = func(db *sql.DB, binlog string) (string, error) {
+		previous := map[string]string{
+			"mysql-bin.000001": "",
+			"mysql-bin.000002": uuid + ":1-50",
+			"mysql-bin.000003": uuid + ":1-120",
+			"mysql-bin.000004": uuid + ":1-200",
+		}
+		return previous[binlog], nil
+	}
+	mock.GetGTIDSubtractFn = mockGTIDSubtract
+	leader.mysql.SetMysqlHandler(mock)
+
+	// invalid consumers
+	{
+		assert.NotNil(t, leader.SetPurgeConsumer(model.PurgeConsumer{Binlog: "mysql-bin.000002"}))
+		assert.NotNil(t, leader.SetPurgeConsumer(model.PurgeConsumer{Name: "canal"}))
+		assert.NotNil(t, leader.RemovePurgeConsumer("canal"))
+	}
+
+	// the consumers hold the binlogs back
+	{
+		assert.Nil(t, leader.SetPurgeConsumer(model.PurgeConsumer{Name: "canal", Binlog: "mysql-bin.000003"}))
+		got, _ := leader.consumersGuard("mysql-bin.000004", false)
+		assert.Equal(t, "mysql-bin.000003", got)
+
+		assert.Nil(t, leader.SetPurgeConsumer(model.PurgeConsumer{Name: "debezium", GTID: uuid + ":1-100"}))
+		got, _ = leader.consumersGuard("mysql-bin.000004", false)
+		assert.Equal(t, "mysql-bin.000002", got)
+
+		want := []model.PurgeConsumer{
+			{Name: "canal", Binlog: "mysql-bin.000003"},
+			{Name: "debezium", GTID: uuid + ":1-100"},
+		}
+		assert.Equal(t, want, positions(leader.GetPurgeConsumers()))
+	}
+
+	// the consumers are loaded after restart
+	{
+		raft := NewRaft(leader.getID(), conf, 10000, leader.log, leader.mysql, FOLLOWER)
+		assert.Equal(t, positions(leader.GetPurgeConsumers()), positions(raft.GetPurgeConsumers()))
+
+		// only the leader sets the consumers
+		assert.NotNil(t, raft.SetPurgeConsumer(model.PurgeConsumer{Name: "canal", Binlog: "mysql-bin.000004"}))
+		assert.NotNil(t, raft.RemovePurgeConsumer("canal"))
+	}
+
+	// the heartbeat of the consumer doesn't write the file
+	{
+		path := filepath.Join(conf.MetaDatadir, purgeConsumersFile)
+		assert.Nil(t, os.Remove(path))
+		assert.Nil(t, leader.SetPurgeConsumer(model.PurgeConsumer{Name: "canal", Binlog: "mysql-bin.000003"}))
+		_, err := os.Stat(path)
+		assert.True(t, os.IsNotExist(err))
+
+		// it moves
+		assert.Nil(t, leader.SetPurgeConsumer(model.PurgeConsumer{Name: "canal", Binlog: "mysql-bin.000003"}))
+		assert.Nil(t, leader.SetPurgeConsumer(model.PurgeConsumer{Name: "debezium", GTID: uuid + ":1-110"}))
+		_, err = os.Stat(path)
+		assert.Nil(t, err)
+		_, err = os.Stat(path + ".tmp")
+		assert.True(t, os.IsNotExist(err))
+		assert.Nil(t, leader.SetPurgeConsumer(model.PurgeConsumer{Name: "debezium", GTID: uuid + ":1-100"}))
+	}
+
+	// the stale consumer is skipped
+	{
+		conf.PurgeConsumerStaleSeconds = 1
+		consumer := leader.purgeConsumers["debezium"]
+		consumer.Updated = time.Now().Add(-time.Second * 2)
+		leader.purgeConsumers["debezium"] = consumer
+		got, _ := leader.consumersGuard("mysql-bin.000004", false)
+		assert.Equal(t, "mysql-bin.000003", got)
+
+		// heartbeat
+		assert.Nil(t, leader.SetPurgeConsumer(model.PurgeConsumer{Name: "debezium", GTID: uuid + ":1-100"}))
+		got, _ = leader.consumersGuard("mysql-bin.000004", false)
+		assert.Equal(t, "mysql-bin.000002", got)
+		conf.PurgeConsumerStaleSeconds = 0
+	}
+
+	// remove the consumers
+	{
+		assert.Nil(t, leader.RemovePurgeConsumer("canal"))
+		assert.Nil(t, leader.RemovePurgeConsumer("debezium"))
+		got, reason := leader.consumersGuard("mysql-bin.000004", false)
+		assert.Equal(t, "mysql-bin.000004", got)
+		assert.Equal(t, "", reason)
+	}
+}
+
+func TestRaftPurgePlan(t *testing.T) {
+	conf := config.DefaultRaftConfig()
+	leader, ids, cleanup := mockPurgeLeader(t, conf)
+	defer cleanup()
+
+	// no guards
+	{
+		plan := leader.purgePlan(true)
+		assert.Equal(t, "", plan.Note)
+		assert.Equal(t, "mysql-bin.000004", plan.Target)
+		assert.Equal(t, []string{"mysql-bin.000001", "mysql-bin.000002", "mysql-bin.000003"}, plan.Purged)
+		assert.Equal(t, []string{"backup", "retention", "consumers", "cascade"}, planGuards(plan))
+	}
+
+	// limited by the retention and the added guard
+	{
+		conf.PurgeBinlogRetentionMB = 2
+		var checked bool
+		leader.AddPurgeBinlogGuard("archive", func(next string, check bool) string {
+			checked = check
+			return "mysql-bin.000002"
+		})
+		plan := leader.purgePlan(true)
+		// the plan of the rpc runs the guards in the check mode
+		assert.True(t, checked)
+		assert.Equal(t, "mysql-bin.000002", plan.Target)
+		assert.Equal(t, []string{"mysql-bin.000001"}, plan.Purged)
+		assert.Equal(t, []string{"backup", "retention", "consumers", "cascade", "archive"}, planGuards(plan))
+		assert.Equal(t, model.PurgePlanStep{Guard: "archive", Binlog: "mysql-bin.000002", Reason: "limited.by.the.guard"}, plan.Steps[4])
+		conf.PurgeBinlogRetentionMB = 0
+	}
+
+	// a backup is running on the follower
+	{
+		rsp := mockReplicaResponse(ids[1], "0")
+		rsp.Backuping = true
+		leader.updateReplica(rsp)
+		plan := leader.purgePlan(true)
+		assert.Equal(t, "", plan.Target)
+		assert.Nil(t, plan.Purged)
+		assert.Equal(t, model.PurgePlanStep{Guard: "backup", Reason: "backup.is.running.on[192.168.0.2:8801]"}, plan.Steps[0])
+		rsp.Backuping = false
+		leader.updateReplica(rsp)
+	}
+
+	// a backup is running on the leader
+	{
+		leader.SetBackupChecker(func() bool { return true })
+		plan := leader.purgePlan(true)
+		assert.Equal(t, "", plan.Target)
+		assert.Equal(t, 1, len(plan.Steps))
+		leader.SetBackupChecker(func() bool { return false })
+	}
+
+	// the notes
+	{
+		leader.SetSkipPurgeBinlog(true)
+		assert.Equal(t, "skipPurgeBinlog.is.true", leader.purgePlan(true).Note)
+		leader.SetSkipPurgeBinlog(false)
+
+		leader.nextPuregeBinlog = ""
+		assert.Equal(t, "waiting.for.the.responses.of.all.members", leader.purgePlan(true).Note)
+
+		leader.setState(FOLLOWER)
+		assert.Equal(t, "only.the.leader.purges.binlogs", leader.purgePlan(true).Note)
+	}
+}
+
+func TestRaftSyncPurgeConsumers(t *testing.T) {
+	conf := config.DefaultRaftConfig()
+	leader, _, cleanup := mockPurgeLeader(t, conf)
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "xenon-consumers-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	followerConf := *conf
+	followerConf.MetaDatadir = dir
+	follower := NewRaft("192.168.0.5:8801", &followerConf, 10000, leader.log, leader.mysql, FOLLOWER)
+	path := filepath.Join(dir, purgeConsumersFile)
+
+	// the follower was down when the consumers were set, it follows the leader in the heartbeat
+	{
+		assert.Nil(t, leader.SetPurgeConsumer(model.PurgeConsumer{Name: "canal", Binlog: "mysql-bin.000003"}))
+		assert.Nil(t, leader.SetPurgeConsumer(model.PurgeConsumer{Name: "debezium", Binlog: "mysql-bin.000002"}))
+		follower.syncPurgeConsumers(leader.GetPurgeConsumers())
+		assert.Equal(t, positions(leader.GetPurgeConsumers()), positions(follower.GetPurgeConsumers()))
+		restarted := NewRaft(follower.getID(), &followerConf, 10000, leader.log, leader.mysql, FOLLOWER)
+		assert.Equal(t, positions(leader.GetPurgeConsumers()), positions(restarted.GetPurgeConsumers()))
+	}
+
+	// the heartbeat times are synced in memory
+	{
+		assert.Nil(t, os.Remove(path))
+		assert.Nil(t, leader.SetPurgeConsumer(model.PurgeConsumer{Name: "canal", Binlog: "mysql-bin.000003"}))
+		follower.syncPurgeConsumers(leader.GetPurgeConsumers())
+		assert.Equal(t, leader.GetPurgeConsumers(), follower.GetPurgeConsumers())
+		_, err := os.Stat(path)
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	// the removed consumer is removed from the follower
+	{
+		assert.Nil(t, leader.RemovePurgeConsumer("debezium"))
+		follower.syncPurgeConsumers(leader.GetPurgeConsumers())
+		want := []model.PurgeConsumer{{Name: "canal", Binlog: "mysql-bin.000003"}}
+		assert.Equal(t, want, positions(follower.GetPurgeConsumers()))
+		restarted := NewRaft(follower.getID(), &followerConf, 10000, leader.log, leader.mysql, FOLLOWER)
+		assert.Equal(t, want, positions(restarted.GetPurgeConsumers()))
+	}
+}
+
+func TestRaftLeaderCarriesPurgeConsumers(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, cleanup := MockRafts(log, port, 3, -1)
+	defer cleanup()
+
+	for _, raft := range rafts {
+		raft.Start()
+	}
+	MockWaitLeaderEggs(rafts, 1)
+	var leader *Raft
+	for _, raft := range rafts {
+		if raft.getState() == LEADER {
+			leader = raft
+		}
+	}
+
+	// the members follow the consumers of the leader
+	consumer := model.PurgeConsumer{Name: "canal", Binlog: "mysql-bin.000003"}
+	assert.Nil(t, leader.SetPurgeConsumer(consumer))
+	MockWaitLeaderEggs(rafts, 0)
+	for _, raft := range rafts {
+		assert.Equal(t, []model.PurgeConsumer{consumer}, positions(raft.GetPurgeConsumers()))
+	}
+
+	assert.Nil(t, leader.RemovePurgeConsumer("canal"))
+	MockWaitLeaderEggs(rafts, 0)
+	for _, raft := range rafts {
+		assert.Equal(t, 0, len(raft.GetPurgeConsumers()))
+	}
+}
+
+func planGuards(plan *model.PurgePlan) []string {
+	var guards []string
+	for _, step := range plan.Steps {
+		guards = append(guards, step.Guard)
+	}
+	return guards
+}
+
+// positions returns the consumers without the heartbeat time.
+func positions(consumers []model.PurgeConsumer) []model.PurgeConsumer {
+	for i := range consumers {
+		consumers[i].Updated = time.Time{}
+	}
+	return consumers
+}
@@ -41,6 +41,9 @@ type RaftMeta struct {
 
 	// The SuperIDLE Peers(endpoint)
 	IdlePeers []string
+
+	// The read replicas(endpoint), they never vote
+	Replicas []string
 }
 
 // Raft tuple.
@@ -51,6 +54,10 @@ type Raft struct {
 	conf                     *config.RaftConfig
 	initRole                 State // The temporary role specified on the first startup
 	leader                   string
+	upstream                 string        // the upstream follower which my mysql replicates from, empty is the leader
+	leaderChanged            chan struct{} // closed when the leader changes
+	leaderChangedMu          sync.Mutex
+	leaderMysql              map[string]model.Repl // the mysql endpoints of the heartbeat senders
 	votedFor                 string
 	id                       string
 	fired                    chan bool
@@ -70,16 +77,39 @@ type Raft struct {
 	I                        *Idle
 	IV                       *Invalid
 	LN                       *Learner
+	R                        *Replica
 	peers                    map[string]*Peer // all peers expect SuperIDLE
 	idlePeers                map[string]*Peer // all SuperIDLE peers
+	readReplicas             map[string]*Peer // all read replicas
 	stats                    model.RaftStats
 	skipPurgeBinlog          bool   // if true, purge binlog will skipped
 	skipCheckSemiSync        bool   // if true, check semi-sync will skipped
 	semiSyncTimeoutFor2Nodes uint64 // It only works if peers are 2
 	isBrainSplit             bool   // if true, follower can upgrade to candidate
+	semiSyncDegraded         bool   // if true, the leader semi-sync has fallen back to async
 	gtid                     model.GTID
+	purgeBinlogGuards        []purgeGuard
+	backupChecker            func() bool // returns true if a backup is running on this node
+	purgeConsumers           map[string]model.PurgeConsumer
+	purgeConsumersMu         sync.Mutex
+	peerLabels               map[string]peerLabels // the labels of the peers we heard from
+	peerLabelsMu             sync.Mutex
+	lastLeader               peerHeartbeat // the leader whose heartbeat we got last, guarded by peerLabelsMu
 }
 
+const (
+	// SemiSyncDegradeAlert only counts and logs the semi-sync fallbacks.
+	SemiSyncDegradeAlert = "alert"
+
+	// SemiSyncDegradeRefuseFailover refuses the automatic failover while the leader runs async.
+	SemiSyncDegradeRefuseFailover = "refuse-failover"
+)
+
+// PurgeBinlogGuard returns the binlog which is safe for the leader to purge to,
+// it should be the next or an older one, empty means nothing can be purged.
+// The check is true if it's only for the purge plan, the guard must not change anything then.
+type PurgeBinlogGuard func(next string, check bool) string
+
 // NewRaft creates the new raft.
 func NewRaft(id string, conf *config.RaftConfig, semiSyncTimeout uint64, log *xlog.Log, mysql *mysql.Mysql, state State) *Raft {
 	r := &Raft{
@@ -94,6 +124,11 @@ func NewRaft(id string, conf *config.RaftConfig, semiSyncTimeout uint64, log *xl
 		meta:                     &RaftMeta{},
 		peers:                    make(map[string]*Peer),
 		idlePeers:                make(map[string]*Peer),
+		readReplicas:             make(map[string]*Peer),
+		peerLabels:               make(map[string]peerLabels),
+		purgeConsumers:           make(map[string]model.PurgeConsumer),
+		leaderChanged:            make(chan struct{}),
+		leaderMysql:              make(map[string]model.Repl),
 		skipCheckSemiSync:        false,
 		semiSyncTimeoutFor2Nodes: semiSyncTimeout,
 	}
@@ -105,6 +140,7 @@ func NewRaft(id string, conf *config.RaftConfig, semiSyncTimeout uint64, log *xl
 	r.I = NewIdle(r)
 	r.IV = NewInvalid(r)
 	r.LN = NewLearner(r)
+	r.R = NewReplica(r)
 
 	// setup raft timeout
 	r.resetHeartbeatTimeout()
@@ -114,10 +150,15 @@ func NewRaft(id string, conf *config.RaftConfig, semiSyncTimeout uint64, log *xl
 	// setup peers
 	r.initPeers()
 
+	if err := checkDurability(conf.Durability); err != nil {
+		log.Panic("%v", err)
+	}
+
 	// setup meta datadir
 	if err := os.MkdirAll(r.conf.MetaDatadir, 0777); err != nil {
 		log.Panic("create.meta.dir[%v].error[%v]", r.conf.MetaDatadir, err)
 	}
+	r.initPurgeConsumers()
 	return r
 }
 
@@ -131,10 +172,23 @@ func (r *Raft) Start() error {
 	if r.conf.SuperIDLE {
 		r.setState(IDLE)
 		r.WARNING("start.as.super.IDLE")
+	} else if r.conf.Replica {
+		r.setState(REPLICA)
+		r.WARNING("start.as.REPLICA")
 	} else {
 		r.setState(FOLLOWER)
 	}
 
+	// the read replica never takes the init role, the delayed replica never starts as the leader
+	if r.conf.Replica && r.initRole != UNKNOW {
+		r.WARNING("replica.ignore.the.init.role[%v]", r.initRole)
+		r.initRole = UNKNOW
+	}
+	if r.isDelayed() && r.initRole == LEADER {
+		r.WARNING("delayed.replica.ignore.the.init.role[%v]", r.initRole)
+		r.initRole = UNKNOW
+	}
+
 	// set state by init role
 	r.WARNING("raft.init.role.is.[%v]", r.initRole)
 	switch r.initRole {
@@ -189,10 +243,11 @@ func (r *Raft) initPeers() {
 	if _, err := os.Stat(metaPath); os.IsNotExist(err) {
 		r.WARNING("peers.json.file[%v].does.not.exist", metaPath)
 	} else {
-		peers, idlePeers, _ := readPeersJSON(filepath.Join(r.conf.MetaDatadir, metaFile))
+		peers, idlePeers, replicas, _ := readPeersJSON(filepath.Join(r.conf.MetaDatadir, metaFile))
 		r.meta.Peers = append(r.meta.Peers, peers...)
 		r.meta.IdlePeers = append(r.meta.IdlePeers, idlePeers...)
-		r.WARNING("prepare.to.recovery.peers.from.[%v].peers[%v].idlePeers[%v]", r.conf.MetaDatadir, r.meta.Peers, r.meta.IdlePeers)
+		r.meta.Replicas = append(r.meta.Replicas, replicas...)
+		r.WARNING("prepare.to.recovery.peers.from.[%v].peers[%v].idlePeers[%v].replicas[%v]", r.conf.MetaDatadir, r.meta.Peers, r.meta.IdlePeers, r.meta.Replicas)
 	}
 
 	// create peers
@@ -204,7 +259,7 @@ func (r *Raft) initPeers() {
 	}
 
 	// if peers is empty, append this peer
-	if len(r.meta.Peers) == 0 && !r.conf.SuperIDLE {
+	if len(r.meta.Peers) == 0 && !r.conf.SuperIDLE && !r.conf.Replica {
 		r.meta.Peers = append(r.meta.Peers, r.getID())
 	}
 
@@ -220,6 +275,19 @@ func (r *Raft) initPeers() {
 	if len(r.meta.IdlePeers) == 0 && r.conf.SuperIDLE {
 		r.meta.IdlePeers = append(r.meta.IdlePeers, r.getID())
 	}
+
+	// create replicas
+	for _, connStr := range r.meta.Replicas {
+		if connStr != r.getID() {
+			p := NewPeer(r, connStr, r.conf.RequestTimeout, r.conf.HeartbeatTimeout)
+			r.readReplicas[connStr] = p
+		}
+	}
+
+	// if replicas is empty, append this peer
+	if len(r.meta.Replicas) == 0 && r.conf.Replica {
+		r.meta.Replicas = append(r.meta.Replicas, r.getID())
+	}
 }
 
 // free all peers
@@ -279,6 +347,8 @@ func (r *Raft) stateLoop() {
 			r.IV.Loop()
 		case LEARNER:
 			r.LN.Loop()
+		case REPLICA:
+			r.R.Loop()
 		}
 		state = r.getState()
 	}
@@ -295,12 +365,12 @@ func (r *Raft) updateView(viewid uint64, leader string) {
 	r.WARNING("do.updateViewID[FROM:%v TO:%v]", r.meta.ViewID, viewid)
 
 	// update leader and viewid
-	r.leader = leader
+	r.setLeader(leader)
 	r.votedFor = noVote
 	r.meta.ViewID = viewid
 }
 
-func (r *Raft) updateEpoch(epochid uint64, peers []string, idlePeers []string) {
+func (r *Raft) updateEpoch(epochid uint64, peers []string, idlePeers []string, replicas []string) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 	mark := make(map[string]bool)
@@ -343,13 +413,32 @@ func (r *Raft) updateEpoch(epochid uint64, peers []string, idlePeers []string) {
 	}
 	r.meta.IdlePeers = idlePeers
 
+	// update replicas
+	for _, name := range replicas {
+		if r.readReplicas[name] == nil {
+			if name != r.getID() {
+				p := NewPeer(r, name, r.conf.RequestTimeout, r.conf.HeartbeatTimeout)
+				r.readReplicas[name] = p
+			}
+		}
+		mark[name] = true
+	}
+
+	for name, peer := range r.readReplicas {
+		if _, ok := mark[name]; !ok {
+			peer.freePeer()
+			delete(r.readReplicas, name)
+		}
+	}
+	r.meta.Replicas = replicas
+
 	r.meta.EpochID = epochid
 	r.writePeersJSON()
 }
 
 func (r *Raft) writePeersJSON() {
 	metaPath := filepath.Join(r.conf.MetaDatadir, metaFile)
-	if err := writePeersJSON(metaPath, r.meta.Peers, r.meta.IdlePeers); err != nil {
+	if err := writePeersJSON(metaPath, r.meta.Peers, r.meta.IdlePeers, r.meta.Replicas); err != nil {
 		r.PANIC("writePeers[%v].to[%v].error[%+v]", metaPath, r.meta.Peers, err)
 	}
 
@@ -388,9 +477,35 @@ func (r *Raft) resetCheckVotesTimeout() {
 
 // SetSkipPurgeBinlog used to set purge binlog or not.
 func (r *Raft) SetSkipPurgeBinlog(v bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 	r.skipPurgeBinlog = v
 }
 
+// AddPurgeBinlogGuard used to add a guard which checked before the leader purges binlogs,
+// the name shows in the purge plan.
+func (r *Raft) AddPurgeBinlogGuard(name string, guard PurgeBinlogGuard) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.purgeBinlogGuards = append(r.purgeBinlogGuards, newPurgeGuard(name, guard))
+}
+
+// SetBackupChecker used to set the checker which returns true if a backup is running on this node,
+// the leader doesn't purge binlogs while any member is running a backup.
+func (r *Raft) SetBackupChecker(checker func() bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.backupChecker = checker
+}
+
+// isBackupRunning returns true if a backup is running on this node.
+func (r *Raft) isBackupRunning() bool {
+	r.mutex.RLock()
+	checker := r.backupChecker
+	r.mutex.RUnlock()
+	return checker != nil && checker()
+}
+
 // SetSkipCheckSemiSync used to set check semi-sync or not.
 func (r *Raft) SetSkipCheckSemiSync(v bool) {
 	r.skipCheckSemiSync = v
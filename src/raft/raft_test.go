@@ -10,8 +10,10 @@ package raft
 
 import (
 	"config"
+	"database/sql"
 	"model"
 	"mysql"
+	"sync"
 	"testing"
 	"time"
 	"xbase/common"
@@ -1525,6 +1527,30 @@ func TestRaftLeaderPurgeBinlog(t *testing.T) {
 		assert.NotEqual(t, want, got)
 	}
 
+	// purge binlog blocked by the guard
+	{
+		var mu sync.Mutex
+		guarded := 0
+		rafts[2].AddPurgeBinlogGuard("test", func(next string, check bool) string {
+			mu.Lock()
+			defer mu.Unlock()
+			guarded++
+			return ""
+		})
+		MockWaitLeaderEggs(rafts, 0)
+		purged := rafts[2].stats.LeaderPurgeBinlogs
+
+		MockWaitLeaderEggs(rafts, 0)
+		MockWaitLeaderEggs(rafts, 0)
+
+		want := purged
+		got := rafts[2].stats.LeaderPurgeBinlogs
+		assert.Equal(t, want, got)
+		mu.Lock()
+		assert.NotZero(t, guarded)
+		mu.Unlock()
+	}
+
 	// disable purge by setting conf.PurgeBinlogDisabled=true
 	{
 		conf.PurgeBinlogDisabled = true
@@ -1616,6 +1642,97 @@ func TestRaftLeaderCheckSemiSync(t *testing.T) {
 	}
 }
 
+// TEST EFFECTS:
+// test the leader semi-sync fallback with the refuse-failover policy
+//
+// TEST PROCESSES:
+// 1. set rafts GTID
+//    1.0 rafts[0]  with MockGTID_X1{Master_Log_File = "mysql-bin.000001", Read_Master_Log_Pos = 123}
+//    1.1 rafts[1]  with MockGTID_X3{Master_Log_File = "mysql-bin.000003", Read_Master_Log_Pos = 123}
+//    1.2 rafts[2]  with MockGTID_X5{Master_Log_File = "mysql-bin.000005", Read_Master_Log_Pos = 123}
+// 2. Start 3 rafts state as FOLLOWER
+// 3. wait rafts[2] elected as leader
+// 4. rafts[2] semi-sync falls back to async and recovers
+// 5. rafts[2] semi-sync falls back to async again and stops
+// 6. check the followers refuse to promote
+func TestRaftLeaderSemiSyncDegraded(t *testing.T) {
+	conf := config.DefaultRaftConfig()
+	conf.MetaDatadir = "/tmp/"
+	conf.SemiSyncDegradePolicy = SemiSyncDegradeRefuseFailover
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, cleanup := MockRaftsWithConfig(log, conf, port, 3, -1)
+	defer cleanup()
+
+	// 1. set rafts GTID
+	//    1.0 rafts[0]  with MockGTIDB{Master_Log_File = "mysql-bin.000001", Read_Master_Log_Pos = 123}
+	//    1.1 rafts[1]  with MockGTIDB{Master_Log_File = "mysql-bin.000003", Read_Master_Log_Pos = 123}
+	//    1.2 rafts[2]  with MockGTIDC{Master_Log_File = "mysql-bin.000005", Read_Master_Log_Pos = 123}
+	mock := mysql.NewMockGTIDX5()
+	{
+		rafts[0].mysql.SetMysqlHandler(mysql.NewMockGTIDX1())
+		rafts[1].mysql.SetMysqlHandler(mysql.NewMockGTIDX3())
+		rafts[2].mysql.SetMysqlHandler(mock)
+	}
+
+	// 2. Start 3 rafts state as FOLLOWER
+	for _, raft := range rafts {
+		raft.Start()
+	}
+
+	// 3. leader
+	{
+		MockWaitLeaderEggs(rafts, 1)
+		assert.Equal(t, LEADER, rafts[2].getState())
+		assert.False(t, rafts[2].getStats().SemiSyncDegraded)
+	}
+
+	semiSyncStatus := func(status string) func(db *sql.DB) (*model.SemiSyncStatus, error) {
+		return func(db *sql.DB) (*model.SemiSyncStatus, error) {
+			return &model.SemiSyncStatus{MasterStatus: status, WaitPoint: "AFTER_SYNC"}, nil
+		}
+	}
+
+	// 4. fall back to async and recover
+	{
+		mock.GetSemiSyncStatusFn = semiSyncStatus("OFF")
+		MockWaitLeaderEggs(rafts, 0)
+		MockWaitLeaderEggs(rafts, 0)
+		assert.Equal(t, uint64(1), rafts[2].getStats().LeaderSemiSyncFallbacks)
+		for _, raft := range rafts {
+			assert.True(t, raft.getStats().SemiSyncDegraded)
+		}
+
+		mock.GetSemiSyncStatusFn = semiSyncStatus("ON")
+		MockWaitLeaderEggs(rafts, 0)
+		MockWaitLeaderEggs(rafts, 0)
+		assert.Equal(t, uint64(1), rafts[2].getStats().LeaderSemiSyncFallbacks)
+		for _, raft := range rafts {
+			assert.False(t, raft.getStats().SemiSyncDegraded)
+		}
+	}
+
+	// 5. fall back to async again and the leader is gone
+	{
+		mock.GetSemiSyncStatusFn = semiSyncStatus("OFF")
+		MockWaitLeaderEggs(rafts, 0)
+		MockWaitLeaderEggs(rafts, 0)
+		assert.Equal(t, uint64(2), rafts[2].getStats().LeaderSemiSyncFallbacks)
+		rafts[2].Stop()
+	}
+
+	// 6. the followers refuse to promote
+	{
+		time.Sleep(time.Millisecond * time.Duration(rafts[0].getElectionTimeout()*6))
+		for _, raft := range rafts[:2] {
+			assert.Equal(t, FOLLOWER, raft.getState())
+			// counted once, not on every election timeout
+			assert.Equal(t, uint64(1), raft.getStats().FollowerRefusedFailovers)
+		}
+	}
+}
+
 // TEST EFFECTS:
 // test the follower change master to failed
 //
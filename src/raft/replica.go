@@ -0,0 +1,250 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018-2019 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"model"
+)
+
+// REPLICA is a special STATE for the asynchronous read replicas, such as the analytics ones.
+// It follows the leader by heartbeats like IDLE, but it is listed in the replicas instead of the peers, so
+// it is excluded from the quorums
+// it never votes(the vote will be filtered out by CANDIDATEs) or promotes
+// its mysql never acks the semi-sync, and it can be delayed by replication-delay-seconds
+
+// Replica tuple.
+type Replica struct {
+	*Raft
+
+	// replica process heartbeat request handler
+	processHeartbeatRequestHandler func(*model.RaftRPCRequest) *model.RaftRPCResponse
+
+	// replica process voterequest request handler
+	processRequestVoteRequestHandler func(*model.RaftRPCRequest) *model.RaftRPCResponse
+
+	// replica process ping request handler
+	processPingRequestHandler func(*model.RaftRPCRequest) *model.RaftRPCResponse
+}
+
+// NewReplica creates new Replica.
+func NewReplica(r *Raft) *Replica {
+	R := &Replica{Raft: r}
+	R.initHandlers()
+	return R
+}
+
+// Loop used to start the loop of the state machine.
+// --------------------------------------
+// State Machine
+// --------------------------------------
+// in REPLICA state, we never do leader election
+func (r *Replica) Loop() {
+	// update begin
+	r.updateStateBegin()
+	r.stateInit()
+
+	for r.getState() == REPLICA {
+		select {
+		case <-r.fired:
+			r.WARNING("state.machine.loop.got.fired")
+		case e := <-r.c:
+			switch e.Type {
+			// 1) Heartbeat
+			case MsgRaftHeartbeat:
+				req := e.request.(*model.RaftRPCRequest)
+				rsp := r.processHeartbeatRequestHandler(req)
+				e.response <- rsp
+
+			// 2) RequestVote
+			case MsgRaftRequestVote:
+				req := e.request.(*model.RaftRPCRequest)
+				rsp := r.processRequestVoteRequestHandler(req)
+				e.response <- rsp
+
+			// 3) Ping
+			case MsgRaftPing:
+				req := e.request.(*model.RaftRPCRequest)
+				rsp := r.processPingRequestHandler(req)
+				e.response <- rsp
+
+			default:
+				r.ERROR("get.unknown.request[%v]", e.Type)
+			}
+		}
+	}
+}
+
+// checkReplicaRequest checks the request comes from this cluster,
+// a new replica doesn't know the peers, it trusts the leader which lists it as a replica.
+func (r *Replica) checkReplicaRequest(req *model.RaftRPCRequest) bool {
+	if r.checkRequest(req) {
+		return true
+	}
+
+	var isReplica, fromPeer bool
+	for _, id := range req.GetReplicas() {
+		if id == r.getID() {
+			isReplica = true
+		}
+	}
+	for _, id := range req.GetPeers() {
+		if id == req.GetFrom() {
+			fromPeer = true
+		}
+	}
+	return isReplica && fromPeer
+}
+
+// processHeartbeatRequest
+// EFFECT
+// handles the heartbeat request from the leader
+// In REPLICA state, we only handle the master changed
+func (r *Replica) processHeartbeatRequest(req *model.RaftRPCRequest) *model.RaftRPCResponse {
+	rsp := model.NewRaftRPCResponse(model.OK)
+	rsp.Raft.From = r.getID()
+	rsp.Raft.ViewID = r.getViewID()
+	rsp.Raft.EpochID = r.getEpochID()
+	rsp.Raft.State = r.state.String()
+	rsp.Relay_Master_Log_File = r.mysql.RelayMasterLogFile()
+	rsp.Seconds_Behind_Master = r.mysql.SecondsBehindMaster()
+	rsp.Backuping = r.isBackupRunning()
+	r.setCascadeResponse(rsp)
+
+	if !r.checkReplicaRequest(req) {
+		rsp.RetCode = model.ErrorInvalidRequest
+		return rsp
+	}
+
+	viewdiff := (int)(r.getViewID() - req.GetViewID())
+	epochdiff := (int)(r.getEpochID() - req.GetEpochID())
+	switch {
+	case viewdiff <= 0:
+		// MySQL1: disable master semi-sync because I am a slave
+		if err := r.mysql.DisableSemiSyncMaster(); err != nil {
+			r.ERROR("mysql.DisableSemiSyncMaster.error[%v]", err)
+		}
+
+		// MySQL2: set mysql readonly(mysql maybe down and up then the LEADER changes)
+		if err := r.mysql.SetReadOnly(); err != nil {
+			r.ERROR("mysql.SetReadOnly.error[%v]", err)
+		}
+
+		// MySQL3: start slave
+		if err := r.mysql.StartSlave(); err != nil {
+			r.ERROR("mysql.StartSlave.error[%v]", err)
+		}
+
+		// MySQL4: change master with my delay, the leader or my upstream changes
+		if r.getLeader() != req.GetFrom() || r.getUpstream() != req.Upstream {
+			r.WARNING("get.heartbeat.from[N:%v, V:%v, E:%v].change.mysql.master[%+v].upstream[%v].delay[%v]", req.GetFrom(), req.GetViewID(), req.GetEpochID(), req.GetGTID(), req.Upstream, r.conf.ReplicationDelaySeconds)
+
+			req.Repl.Master_Delay = r.conf.ReplicationDelaySeconds
+			if err := r.mysql.ChangeMasterTo(&req.Repl); err != nil {
+				r.ERROR("change.master.to[FROM:%v, GTID:%v].error[%v]", req.GetFrom(), req.GetRepl(), err)
+				rsp.RetCode = model.ErrorChangeMaster
+				return rsp
+			}
+			r.setLeader(req.GetFrom())
+			r.setUpstream(req.Upstream)
+		}
+
+		// view change
+		if viewdiff < 0 {
+			r.WARNING("get.heartbeat.from[N:%v, V:%v, E:%v].update.view", req.GetFrom(), req.GetViewID(), req.GetEpochID())
+			r.updateView(req.GetViewID(), req.GetFrom())
+		}
+
+		// epoch change
+		if epochdiff != 0 {
+			r.WARNING("get.heartbeat.from[N:%v, V:%v, E:%v].update.epoch", req.GetFrom(), req.GetViewID(), req.GetEpochID())
+			r.updateEpoch(req.GetEpochID(), req.GetPeers(), req.GetIdlePeers(), req.GetReplicas())
+		}
+
+		// the external binlog consumers of the leader
+		r.syncPurgeConsumers(req.PurgeConsumers)
+	}
+	return rsp
+}
+
+// processRequestVoteRequest
+// EFFECT
+// handles the requestvote request from other CANDIDATEs
+// REPLICA is special, it returns OK expect Request Denied
+//
+// RETURN
+// 1. OK: give a vote, but the Candidate will abandon the Replica's vote.
+func (r *Replica) processRequestVoteRequest(req *model.RaftRPCRequest) *model.RaftRPCResponse {
+	rsp := model.NewRaftRPCResponse(model.OK)
+	rsp.Raft.From = r.getID()
+	rsp.Raft.ViewID = r.getViewID()
+	rsp.Raft.EpochID = r.getEpochID()
+	rsp.Raft.State = r.state.String()
+
+	if !r.checkRequest(req) {
+		rsp.RetCode = model.ErrorInvalidRequest
+		return rsp
+	}
+	return rsp
+}
+
+func (r *Replica) processPingRequest(req *model.RaftRPCRequest) *model.RaftRPCResponse {
+	rsp := model.NewRaftRPCResponse(model.OK)
+	rsp.Raft.From = r.getID()
+	rsp.Raft.ViewID = r.getViewID()
+	rsp.Raft.EpochID = r.getEpochID()
+	rsp.Raft.State = r.state.String()
+	return rsp
+}
+
+func (r *Replica) stateInit() {
+	// 1. stop vip
+	if err := r.leaderStopShellCommand(); err != nil {
+		// TODO(array): what todo?
+		r.ERROR("stopshell.error[%v]", err)
+	}
+
+	// MySQL1: set readonly
+	if err := r.mysql.SetReadOnly(); err != nil {
+		r.ERROR("mysql.SetReadOnly.error[%v]", err)
+	}
+
+	// MySQL2. set mysql slave system variables
+	if err := r.mysql.SetSlaveGlobalSysVar(); err != nil {
+		r.ERROR("mysql.SetSlaveGlobalSysVar.error[%v]", err)
+	}
+
+	// MySQL3. never ack the semi-sync, it works after the 'change master to'
+	if err := r.mysql.DisableSemiSyncSlave(); err != nil {
+		r.ERROR("mysql.DisableSemiSyncSlave.error[%v]", err)
+	}
+
+	// fire the 'change master to' with my delay when the next heartbeat comes
+	r.setLeader(noLeader)
+	r.WARNING("state.machine.run")
+}
+
+// handlers
+func (r *Replica) initHandlers() {
+	r.setProcessHeartbeatRequestHandler(r.processHeartbeatRequest)
+	r.setProcessRequestVoteRequestHandler(r.processRequestVoteRequest)
+	r.setProcessPingRequestHandler(r.processPingRequest)
+}
+
+// for tests
+func (r *Replica) setProcessHeartbeatRequestHandler(f func(*model.RaftRPCRequest) *model.RaftRPCResponse) {
+	r.processHeartbeatRequestHandler = f
+}
+
+func (r *Replica) setProcessRequestVoteRequestHandler(f func(*model.RaftRPCRequest) *model.RaftRPCResponse) {
+	r.processRequestVoteRequestHandler = f
+}
+
+func (r *Replica) setProcessPingRequestHandler(f func(*model.RaftRPCRequest) *model.RaftRPCResponse) {
+	r.processPingRequestHandler = f
+}
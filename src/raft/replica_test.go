@@ -0,0 +1,165 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018-2019 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"config"
+	"database/sql"
+	"model"
+	"mysql"
+	"sync/atomic"
+	"testing"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TEST EFFECTS:
+// test the read replica follows the leader and never votes
+//
+// TEST PROCESSES:
+//  1. rafts[3] is a read replica with replication-delay-seconds
+//  2. rafts[0], rafts[1], rafts[2] list rafts[3] as a replica
+//  3. Start 4 rafts
+//  4. wait the leader elected, the replica follows it with MASTER_DELAY
+//  5. stop the leader
+//  6. wait the new leader elected, the replica is still REPLICA and follows the new one
+func TestRaftReplica(t *testing.T) {
+	conf := config.DefaultRaftConfig()
+	conf.MetaDatadir = "/tmp/"
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	ids, rafts, cleanup := MockRaftsWithConfig(log, conf, port, 4, -1)
+	defer cleanup()
+
+	// 1. rafts[3] is a read replica
+	var delay int64
+	{
+		c := *conf
+		c.Replica = true
+		c.ReplicationDelaySeconds = 3600
+		rafts[3].conf = &c
+
+		mock := mysql.NewMockGTIDA()
+		mock.ChangeMasterToFn = func(db *sql.DB, repl *model.Repl) error {
+			atomic.StoreInt64(&delay, int64(repl.Master_Delay))
+			return nil
+		}
+		rafts[3].mysql.SetMysqlHandler(mock)
+	}
+
+	// 2. the voters list rafts[3] as a replica
+	for _, raft := range rafts[:3] {
+		raft.RemovePeer(ids[3])
+		raft.AddReplicaPeer(ids[3])
+	}
+
+	// 3. Start 4 rafts
+	for _, raft := range rafts {
+		raft.Start()
+	}
+
+	// 4. the replica follows the leader
+	var whoisleader int
+	{
+		whoisleader = MockWaitLeaderEggs(rafts, 1)
+		assert.True(t, whoisleader >= 0 && whoisleader < 3)
+		MockWaitHeartBeatTimeout()
+
+		leader := rafts[whoisleader]
+		assert.Equal(t, 2, leader.getQuorums())
+		assert.Equal(t, 3, leader.getMembers())
+		assert.Equal(t, []string{ids[3]}, leader.GetReplicas())
+
+		assert.Equal(t, REPLICA, rafts[3].getState())
+		assert.Equal(t, leader.getID(), rafts[3].GetLeader())
+		assert.Equal(t, []string{ids[3]}, rafts[3].GetReplicas())
+		assert.Equal(t, int64(3600), atomic.LoadInt64(&delay))
+	}
+
+	// 5. stop the leader
+	rafts[whoisleader].Stop()
+	atomic.StoreInt64(&delay, 0)
+
+	// 6. the new leader is one of the voters
+	{
+		whoisleader = MockWaitLeaderEggs(rafts, 1)
+		assert.True(t, whoisleader >= 0 && whoisleader < 3)
+		MockWaitHeartBeatTimeout()
+
+		assert.Equal(t, REPLICA, rafts[3].getState())
+		assert.Equal(t, rafts[whoisleader].getID(), rafts[3].GetLeader())
+		assert.Equal(t, int64(3600), atomic.LoadInt64(&delay))
+	}
+}
+
+// TEST EFFECTS:
+// test the delayed replica follows the leader with MASTER_DELAY and is never promoted
+//
+// TEST PROCESSES:
+//  1. rafts[0] is a delayed replica with the newest GTID
+//  2. Start 3 rafts state as FOLLOWER
+//  3. wait the leader elected, it's not rafts[0]
+//  4. stop the leader
+//  5. wait the new leader elected, it's not rafts[0]
+func TestRaftDelayedReplica(t *testing.T) {
+	conf := config.DefaultRaftConfig()
+	conf.MetaDatadir = "/tmp/"
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	_, rafts, cleanup := MockRaftsWithConfig(log, conf, port, 3, -1)
+	defer cleanup()
+
+	// 1. rafts[0] is a delayed replica
+	var delay int64
+	{
+		c := *conf
+		c.ReplicationDelaySeconds = 3600
+		rafts[0].conf = &c
+
+		mock := mysql.NewMockGTIDX5()
+		mock.ChangeMasterToFn = func(db *sql.DB, repl *model.Repl) error {
+			atomic.StoreInt64(&delay, int64(repl.Master_Delay))
+			return nil
+		}
+		rafts[0].mysql.SetMysqlHandler(mock)
+		rafts[1].mysql.SetMysqlHandler(mysql.NewMockGTIDX3())
+		rafts[2].mysql.SetMysqlHandler(mysql.NewMockGTIDX3())
+	}
+
+	// 2. Start 3 rafts state as FOLLOWER
+	for _, raft := range rafts {
+		raft.Start()
+	}
+
+	// 3. the delayed replica follows the leader
+	var whoisleader int
+	{
+		whoisleader = MockWaitLeaderEggs(rafts, 1)
+		assert.True(t, whoisleader > 0)
+		MockWaitHeartBeatTimeout()
+
+		assert.Equal(t, FOLLOWER, rafts[0].getState())
+		assert.Equal(t, rafts[whoisleader].getID(), rafts[0].GetLeader())
+		assert.Equal(t, int64(3600), atomic.LoadInt64(&delay))
+	}
+
+	// 4. stop the leader
+	rafts[whoisleader].Stop()
+
+	// 5. the other one is elected
+	{
+		whoisleader = MockWaitLeaderEggs(rafts, 1)
+		assert.True(t, whoisleader > 0)
+		assert.Equal(t, FOLLOWER, rafts[0].getState())
+	}
+}
@@ -41,10 +41,10 @@ func (h *HARPC) HADisable(req *model.HARPCRequest, rsp *model.HARPCResponse) err
 func (h *HARPC) HASetLearner(req *model.HARPCRequest, rsp *model.HARPCResponse) error {
 	h.raft.WARNING("RPC.HASetLearner.call.from[%v]", req.GetFrom())
 
-	// except state STOPPED
+	// except state STOPPED/REPLICA
 	state := h.raft.getState()
 	switch state {
-	case STOPPED:
+	case STOPPED, REPLICA:
 		rsp.RetCode = model.ErrorInvalidRequest
 		return nil
 	}
@@ -65,6 +65,10 @@ func (h *HARPC) HAEnable(req *model.HARPCRequest, rsp *model.HARPCResponse) erro
 		if h.raft.conf.SuperIDLE {
 			// Set SuperIDLE to noLeader to fire the 'change master to'.
 			h.raft.setLeader(noLeader)
+		} else if h.raft.conf.Replica {
+			// The read replica never goes back to FOLLOWER.
+			h.raft.setState(REPLICA)
+			h.raft.loopFired()
 		} else {
 			h.raft.setState(FOLLOWER)
 			h.raft.loopFired()
@@ -100,6 +104,9 @@ func (h *HARPC) HATryToLeader(req *model.HARPCRequest, rsp *model.HARPCResponse)
 	case IDLE:
 		rsp.RetCode = model.ErrorInvalidRequest
 		return nil
+	case REPLICA:
+		rsp.RetCode = model.ErrorInvalidRequest
+		return nil
 	case INVALID:
 		rsp.RetCode = model.ErrorInvalidRequest
 		return nil
@@ -107,7 +114,8 @@ func (h *HARPC) HATryToLeader(req *model.HARPCRequest, rsp *model.HARPCResponse)
 	// promotable cases:
 	// 1. MySQL is MYSQL_ALIVE
 	// 2. Slave_SQL_RNNNING is OK
-	if h.raft.mysql.Promotable() {
+	// 3. not a delayed replica
+	if h.raft.mysql.Promotable() && !h.raft.isDelayed() {
 		h.raft.WARNING("RPC.TryToLeader.promote.to.candidate")
 		// stop io thread
 		// it will re-start again when heartbeat received
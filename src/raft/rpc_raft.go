@@ -11,6 +11,7 @@ package raft
 import (
 	"model"
 	"strconv"
+	"time"
 )
 
 // RaftRPC tuple.
@@ -21,31 +22,38 @@ type RaftRPC struct {
 // Ping rpc.
 // send MsgRaftPing
 func (r *RaftRPC) Ping(req *model.RaftRPCRequest, rsp *model.RaftRPCResponse) error {
+	r.raft.updatePeerLabels(req.GetFrom(), req.Raft.Labels)
 	ret, err := r.raft.send(MsgRaftPing, req, r.raft.getHeartbeatTimeout())
 	if err != nil {
 		return err
 	}
 	*rsp = *ret.(*model.RaftRPCResponse)
+	rsp.Raft.Labels = r.raft.GetLabels()
 	return nil
 }
 
 // Heartbeat rpc.
 func (r *RaftRPC) Heartbeat(req *model.RaftRPCRequest, rsp *model.RaftRPCResponse) error {
+	r.raft.updatePeerLabels(req.GetFrom(), req.Raft.Labels)
+	r.raft.setLeaderMysql(req.GetFrom(), req.Mysql)
 	ret, err := r.raft.send(MsgRaftHeartbeat, req, r.raft.getHeartbeatTimeout())
 	if err != nil {
 		return err
 	}
 	*rsp = *ret.(*model.RaftRPCResponse)
+	rsp.Raft.Labels = r.raft.GetLabels()
 	return nil
 }
 
 // RequestVote rpc.
 func (r *RaftRPC) RequestVote(req *model.RaftRPCRequest, rsp *model.RaftRPCResponse) error {
+	r.raft.updatePeerLabels(req.GetFrom(), req.Raft.Labels)
 	ret, err := r.raft.send(MsgRaftRequestVote, req, r.raft.getHeartbeatTimeout())
 	if err != nil {
 		return err
 	}
 	*rsp = *ret.(*model.RaftRPCResponse)
+	rsp.Raft.Labels = r.raft.GetLabels()
 	return nil
 }
 
@@ -81,3 +89,42 @@ func (r *RaftRPC) DisableCheckSemiSync(req *model.RaftStatusRPCRequest, rsp *mod
 	r.raft.SetSkipCheckSemiSync(true)
 	return nil
 }
+
+// PurgePlan rpc.
+// shows what the leader would purge and why, without purging.
+func (r *RaftRPC) PurgePlan(req *model.RaftStatusRPCRequest, rsp *model.RaftPurgePlanRPCResponse) error {
+	rsp.RetCode = model.OK
+	rsp.Plan = r.raft.L.purgePlan(true)
+	return nil
+}
+
+// SetPurgeConsumer rpc.
+func (r *RaftRPC) SetPurgeConsumer(req *model.RaftPurgeConsumerRPCRequest, rsp *model.RaftPurgeConsumerRPCResponse) error {
+	rsp.RetCode = model.OK
+	if err := r.raft.SetPurgeConsumer(req.Consumer); err != nil {
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	rsp.Consumers = r.raft.GetPurgeConsumers()
+	return nil
+}
+
+// RemovePurgeConsumer rpc.
+func (r *RaftRPC) RemovePurgeConsumer(req *model.RaftPurgeConsumerRPCRequest, rsp *model.RaftPurgeConsumerRPCResponse) error {
+	rsp.RetCode = model.OK
+	if err := r.raft.RemovePurgeConsumer(req.Consumer.Name); err != nil {
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	rsp.Consumers = r.raft.GetPurgeConsumers()
+	return nil
+}
+
+// WatchLeader rpc.
+// returns when the leader isn't the req.Leader, or after req.Timeout.
+func (r *RaftRPC) WatchLeader(req *model.RaftWatchLeaderRPCRequest, rsp *model.RaftWatchLeaderRPCResponse) error {
+	rsp.RetCode = model.OK
+	rsp.View = r.raft.WatchLeader(req.Leader, time.Duration(req.Timeout)*time.Millisecond)
+	rsp.Changed = rsp.View.Leader != req.Leader
+	return nil
+}
@@ -59,6 +59,16 @@ func (s *Raft) IncCandidateDegrades() {
 	atomic.AddUint64(&s.stats.CandidateDegrades, 1)
 }
 
+// IncLeaderSemiSyncFallbacks counter.
+func (s *Raft) IncLeaderSemiSyncFallbacks() {
+	atomic.AddUint64(&s.stats.LeaderSemiSyncFallbacks, 1)
+}
+
+// IncFollowerRefusedFailovers counter.
+func (s *Raft) IncFollowerRefusedFailovers() {
+	atomic.AddUint64(&s.stats.FollowerRefusedFailovers, 1)
+}
+
 // SetRaftMysqlStatus used to set mysql status.
 func (s *Raft) SetRaftMysqlStatus(rms model.RAFTMYSQL_STATUS) {
 	s.stats.RaftMysqlStatus = rms
@@ -81,6 +91,9 @@ func (s *Raft) getStats() *model.RaftStats {
 		LessHearbeatAcks:           atomic.LoadUint64(&s.stats.LessHearbeatAcks),
 		CandidatePromotes:          atomic.LoadUint64(&s.stats.CandidatePromotes),
 		CandidateDegrades:          atomic.LoadUint64(&s.stats.CandidateDegrades),
+		LeaderSemiSyncFallbacks:    atomic.LoadUint64(&s.stats.LeaderSemiSyncFallbacks),
+		FollowerRefusedFailovers:   atomic.LoadUint64(&s.stats.FollowerRefusedFailovers),
+		SemiSyncDegraded:           s.getSemiSyncDegraded(),
 		StateUptimes:               uint64(time.Since(s.stateBegin).Seconds()),
 		RaftMysqlStatus:            s.stats.RaftMysqlStatus,
 	}
@@ -0,0 +1,56 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018-2019 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"model"
+	"time"
+)
+
+// setLeaderMysql records the mysql endpoint of the heartbeat sender before the heartbeat is processed,
+// so the watchers waked up by the leader change get it.
+func (r *Raft) setLeaderMysql(id string, repl model.Repl) {
+	r.leaderChangedMu.Lock()
+	defer r.leaderChangedMu.Unlock()
+	r.leaderMysql[id] = repl
+}
+
+// leaderView returns the leader and its mysql endpoint, the caller must hold leaderChangedMu.
+func (r *Raft) leaderView() model.LeaderView {
+	view := model.LeaderView{Leader: r.leader, ViewID: r.getViewID()}
+	switch {
+	case r.leader == noLeader:
+	case r.leader == r.getID():
+		repl := r.mysql.GetRepl()
+		view.MysqlHost, view.MysqlPort = repl.Master_Host, repl.Master_Port
+	default:
+		repl := r.leaderMysql[r.leader]
+		view.MysqlHost, view.MysqlPort = repl.Master_Host, repl.Master_Port
+	}
+	return view
+}
+
+// WatchLeader returns the leader view at once if the leader isn't the known one,
+// otherwise it waits for the leader change at most timeout.
+func (r *Raft) WatchLeader(known string, timeout time.Duration) model.LeaderView {
+	r.leaderChangedMu.Lock()
+	view, changed := r.leaderView(), r.leaderChanged
+	r.leaderChangedMu.Unlock()
+	if view.Leader != known {
+		return view
+	}
+
+	select {
+	case <-changed:
+	case <-time.After(timeout):
+	}
+	r.leaderChangedMu.Lock()
+	defer r.leaderChangedMu.Unlock()
+	return r.leaderView()
+}
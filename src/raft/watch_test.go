@@ -0,0 +1,56 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018-2019 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package raft
+
+import (
+	"config"
+	"model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRaftWatchLeader(t *testing.T) {
+	conf := config.DefaultRaftConfig()
+	leader, ids, cleanup := mockDurabilityLeader(t, conf)
+	defer cleanup()
+	raft := leader.Raft
+
+	// the leader isn't the known one
+	raft.setLeaderMysql(ids[1], model.Repl{Master_Host: "192.168.0.2", Master_Port: 3306})
+	raft.setLeader(ids[1])
+	got := raft.WatchLeader("", time.Second)
+	want := model.LeaderView{Leader: ids[1], ViewID: raft.getViewID(), MysqlHost: "192.168.0.2", MysqlPort: 3306}
+	assert.Equal(t, want, got)
+
+	// timeout
+	start := time.Now()
+	got = raft.WatchLeader(ids[1], time.Millisecond*100)
+	assert.Equal(t, want, got)
+	assert.True(t, time.Since(start) >= time.Millisecond*100)
+
+	// the leader changes, its mysql endpoint is unknown
+	go func() {
+		time.Sleep(time.Millisecond * 100)
+		raft.updateView(raft.getViewID()+1, ids[2])
+	}()
+	start = time.Now()
+	got = raft.WatchLeader(ids[1], time.Second*10)
+	want = model.LeaderView{Leader: ids[2], ViewID: raft.getViewID()}
+	assert.Equal(t, want, got)
+	assert.True(t, time.Since(start) < time.Second*10)
+
+	// I'm the leader
+	raft.setLeader(ids[0])
+	got = raft.WatchLeader(ids[2], time.Second)
+	repl := raft.mysql.GetRepl()
+	want = model.LeaderView{Leader: ids[0], ViewID: raft.getViewID(), MysqlHost: repl.Master_Host, MysqlPort: repl.Master_Port}
+	assert.Equal(t, want, got)
+}
@@ -0,0 +1,80 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package server
+
+import (
+	"cli/callx"
+	"model"
+	"raft"
+	"sort"
+	"strconv"
+)
+
+const (
+	// the follower lags behind more than this is not healthy for the scheduled backup
+	backupMaxSecondsBehindMaster = 100
+)
+
+// isHealthyFollower checks the node is a follower with the replication running well.
+func isHealthyFollower(node string) bool {
+	raftRsp, err := callx.GetRaftStatusRPC(node)
+	if err != nil || raftRsp.RetCode != model.OK || raftRsp.State != raft.FOLLOWER.String() {
+		return false
+	}
+
+	mysqlRsp, err := callx.GetMysqlStatusRPC(node)
+	if err != nil || mysqlRsp.RetCode != model.OK || mysqlRsp.Status != string(model.MysqlAlive) {
+		return false
+	}
+	gtid := mysqlRsp.GTID
+	if !gtid.Slave_IO_Running || !gtid.Slave_SQL_Running {
+		return false
+	}
+	lag, err := strconv.Atoi(gtid.Seconds_Behind_Master)
+	if err != nil || lag >= backupMaxSecondsBehindMaster {
+		return false
+	}
+	return true
+}
+
+// chooseBackupNode returns the node to take the scheduled backup:
+// the first healthy follower sorted by the endpoint, or the leader if none.
+func (s *Server) chooseBackupNode() string {
+	peers := append([]string{}, s.raft.GetPeers()...)
+	sort.Strings(peers)
+	for _, peer := range peers {
+		if isHealthyFollower(peer) {
+			return peer
+		}
+	}
+	return s.raft.GetLeader()
+}
+
+// backupChooser used by the backup scheduler, all the nodes fire at the same time,
+// only the leader chooses the node and dispatches the backup to it, so exactly one takes the backup.
+// The leader takes it itself if the dispatch fails.
+func (s *Server) backupChooser(incremental bool) bool {
+	log := s.log
+	self := s.conf.Server.Endpoint
+	if s.raft.GetState() != raft.LEADER {
+		return false
+	}
+
+	chosen := s.chooseBackupNode()
+	log.Info("server.backup.chooser.chosen[%v].self[%v]", chosen, self)
+	if chosen == self {
+		return true
+	}
+	rsp, err := callx.ScheduledBackupRPC(chosen, incremental)
+	if err != nil || rsp.RetCode != model.OK {
+		log.Error("server.backup.chooser.dispatch.to[%v].error[%v].rsp[%+v].take.it.myself", chosen, err, rsp)
+		return true
+	}
+	return false
+}
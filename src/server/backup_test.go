@@ -0,0 +1,46 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package server
+
+import (
+	"raft"
+	"testing"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TEST EFFECTS:
+// test the scheduled backup is chosen only by the leader
+//
+// TEST PROCESSES:
+// 1. Start 3 servers and wait for the leader
+// 2. the followers never take the backup
+// 3. the leader takes the backup if it chooses itself, otherwise dispatches it
+func TestServerBackupChooser(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	servers, cleanup := MockServers(log, port, 3)
+	defer cleanup()
+	MockWaitLeaderEggs(servers, 1)
+
+	var leader *Server
+	for _, server := range servers {
+		if server.raft.GetState() == raft.LEADER {
+			leader = server
+			continue
+		}
+		assert.False(t, server.backupChooser(false))
+	}
+	assert.NotNil(t, leader)
+
+	want := leader.chooseBackupNode()
+	assert.Equal(t, want == leader.conf.Server.Endpoint, leader.backupChooser(false))
+}
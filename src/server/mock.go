@@ -33,6 +33,8 @@ func MockServers(log *xlog.Log, port int, count int) ([]*Server, func()) {
 	ip, _ := common.GetLocalIP()
 
 	os.Remove("peers.json")
+	os.Remove("rebuild.json")
+	os.Remove("purge-consumers.json")
 	for i := 0; i < count; i++ {
 		name := fmt.Sprintf("%s:%d", ip, port+i)
 		names = append(names, name)
@@ -67,6 +69,8 @@ func MockServers(log *xlog.Log, port int, count int) ([]*Server, func()) {
 
 	return servers, func() {
 		os.Remove("peers.json")
+		os.Remove("rebuild.json")
+		os.Remove("purge-consumers.json")
 		for i, s := range servers {
 			log.Info("mock.server[%v].shutdown", names[i])
 			s.Shutdown()
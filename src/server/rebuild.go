@@ -0,0 +1,976 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package server
+
+import (
+	"cli/callx"
+	"config"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"model"
+	"mysql"
+	"mysqld"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	rebuildJSONFile = "rebuild.json"
+)
+
+// The steps index(1-based) which the rebuild job cares about.
+const (
+	rebuildStepCheckBackup   = 3
+	rebuildStepSetLearner    = 4
+	rebuildStepStopMonitor   = 5
+	rebuildStepRecheckBackup = 7
+	rebuildStepClearDatadir  = 8
+	rebuildStepBackup        = 9
+	rebuildStepApplyLog      = 10
+	rebuildStepStartMysqld   = 11
+	rebuildStepEnableRaft    = 16
+)
+
+// datadirTouched returns true if the clear.datadir(or the move.datadir of the restore) step may have been started.
+// The job.Step counts the done steps, a job failed or interrupted at Step rebuildStepClearDatadir-1
+// stopped in the middle of clearing the datadir.
+// The logical dump keeps the datadir until the load step drops the user databases.
+func datadirTouched(job *model.RebuildJob) bool {
+	if isRestore(job) {
+		return job.Step >= restoreStepMoveDatadir-1
+	}
+	return job.Step >= touchedStep(job)-1
+}
+
+// touchedStep returns the step which starts to change the local data.
+func touchedStep(job *model.RebuildJob) int {
+	if isLogical(job) {
+		return rebuildStepApplyLog
+	}
+	return rebuildStepClearDatadir
+}
+
+type rebuildStep struct {
+	name string
+	do   func(job *model.RebuildJob) error
+}
+
+// Rebuild tuple.
+// It runs the rebuildme(or the point-in-time restore) steps on this node and persists the step state,
+// so that the job can be resumed or rolled back after xenon restarts.
+type Rebuild struct {
+	log      *xlog.Log
+	conf     *config.Config
+	path     string
+	mutex    sync.RWMutex
+	job      model.RebuildJob
+	running  bool
+	canceled bool
+	done     chan struct{}
+	steps    []*rebuildStep
+
+	// restoreSteps used by the point-in-time restore job
+	restoreSteps []*rebuildStep
+
+	// rollbackHandler used to undo the steps which have been done,
+	// settable for tests
+	rollbackHandler func(job *model.RebuildJob) error
+}
+
+// NewRebuild creates the new Rebuild.
+func NewRebuild(conf *config.Config, log *xlog.Log) *Rebuild {
+	r := &Rebuild{
+		log:  log,
+		conf: conf,
+		path: path.Join(conf.Raft.MetaDatadir, rebuildJSONFile),
+		job:  model.RebuildJob{State: model.REBUILD_NONE},
+	}
+	r.steps = []*rebuildStep{
+		{"check.raft.leader", r.checkLeader},
+		{"check.bestone", r.checkBestone},
+		{"check.bestone.backuping", r.checkBestoneBackuping},
+		{"set.learner", r.setLearner},
+		{"stop.monitor", r.stopMonitor},
+		{"kill.mysqld", r.killMysqld},
+		{"check.bestone.backuping", r.checkBestoneBackuping},
+		{"clear.datadir", r.clearDatadir},
+		{"xtrabackup", r.backup},
+		{"apply-log", r.applyLog},
+		{"start.mysqld", r.startMysqld},
+		{"wait.mysqld.running", r.waitMysqldRunning},
+		{"wait.mysql.working", r.waitMysqlWorking},
+		{"stop.and.reset.slave", r.resetSlaveAll},
+		{"set.gtid_purged", r.setGtidPurged},
+		{"enable.raft", r.enableRaft},
+		{"wait.change.to.master", r.waitChangeToMaster},
+		{"start.slave", r.startSlave},
+	}
+	r.restoreSteps = []*rebuildStep{
+		{"check.restore", r.checkRestore},
+		{"disable.raft", r.disableRaft},
+		{"stop.monitor", r.stopMonitor},
+		{"kill.mysqld", r.killMysqld},
+		{"move.datadir", r.moveDatadir},
+		{"restore.archive", r.restoreArchive},
+		{"start.mysqld", r.startMysqld},
+		{"wait.mysqld.running", r.waitMysqldRunning},
+		{"wait.mysql.working", r.waitMysqlWorking},
+		{"stop.and.reset.slave", r.resetSlaveAll},
+		{"set.gtid_purged", r.setRestoreGtidPurged},
+		{"replay.binlogs", r.replayBinlogs},
+	}
+	r.rollbackHandler = r.rollback
+	return r
+}
+
+// Load used to load the rebuild job from the meta dir.
+// A job which was running when xenon went away is marked as interrupted.
+func (r *Rebuild) Load() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	job, err := readRebuildJSON(r.path)
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			return nil
+		}
+		return err
+	}
+	if job.State == model.REBUILD_RUNNING {
+		job.State = model.REBUILD_INTERRUPTED
+		job.LastError = "rebuild.interrupted.by.xenon.restart"
+		job.Update = time.Now().Unix()
+		if err := writeRebuildJSON(r.path, job); err != nil {
+			return err
+		}
+	}
+	r.job = *job
+	return nil
+}
+
+// Recover used to keep the node out of the raft election and replication
+// when xenon restarts in the middle of a job.
+func (r *Rebuild) Recover() {
+	log := r.log
+	self := r.conf.Server.Endpoint
+	job := r.Status()
+	if isRestore(&job) {
+		r.recoverRestore(&job)
+		return
+	}
+	if job.State != model.REBUILD_INTERRUPTED {
+		return
+	}
+
+	log.Warning("rebuild.recover.interrupted.job[%+v]", job)
+	if job.Step >= rebuildStepSetLearner && job.Step < rebuildStepEnableRaft {
+		if _, err := callx.SetLearnerRPC(self); err != nil {
+			log.Error("rebuild.recover.set.learner.error[%v]", err)
+		}
+	}
+	if job.Step >= rebuildStepStopMonitor && job.Step < rebuildStepStartMysqld {
+		if _, err := callx.StopMonitorRPC(self); err != nil {
+			log.Error("rebuild.recover.stop.monitor.error[%v]", err)
+		}
+	}
+}
+
+// Start used to start a new rebuild job in background,
+// the rate limits the backup stream which requires the xenon transport.
+func (r *Rebuild) Start(from string, force bool, rate int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.checkStartable(); err != nil {
+		return err
+	}
+	if rate > 0 && r.conf.Backup.Transport != model.BACKUP_TRANSPORT_XENON {
+		return errors.Errorf("rebuild.rate.requires.backup-transport[%v].but.got[%v]", model.BACKUP_TRANSPORT_XENON, r.conf.Backup.Transport)
+	}
+	if err := r.checkBackupMethod(); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	r.job = model.RebuildJob{
+		State:  model.REBUILD_RUNNING,
+		From:   from,
+		Force:  force,
+		Rate:   rate,
+		Steps:  len(r.steps),
+		Begin:  now,
+		Update: now,
+	}
+	return r.goRun()
+}
+
+// checkStartable must be called with the mutex held, the failed or interrupted job must be resumed or canceled first.
+func (r *Rebuild) checkStartable() error {
+	if r.running {
+		return errors.New("rebuild.job.is.running")
+	}
+	switch r.job.State {
+	case model.REBUILD_FAILED, model.REBUILD_INTERRUPTED:
+		return errors.Errorf("rebuild.job.is.%v.please.resume.or.cancel.it.first", r.job.State)
+	}
+	return nil
+}
+
+// Resume used to continue the failed or interrupted job.
+func (r *Rebuild) Resume() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.running {
+		return errors.New("rebuild.job.is.running")
+	}
+	switch r.job.State {
+	case model.REBUILD_FAILED, model.REBUILD_INTERRUPTED:
+	default:
+		return errors.Errorf("rebuild.job.is.%v.nothing.to.resume", r.job.State)
+	}
+
+	job := &r.job
+	switch {
+	case isRestore(job):
+		// The restore steps can be retried, start over if the datadir is untouched.
+		if !datadirTouched(job) {
+			job.Step = 0
+		}
+	case !datadirTouched(job):
+		// The datadir is untouched, check the bestone and stop the local mysqld again.
+		if job.Step > rebuildStepCheckBackup-1 {
+			job.Step = rebuildStepCheckBackup - 1
+		}
+	case job.Step < rebuildStepApplyLog:
+		// The datadir is partial, we must clear it and do the backup again.
+		job.Step = rebuildStepRecheckBackup - 1
+	}
+	r.log.Warning("rebuild.resume.from.step[%v]", job.Step+1)
+	job.State = model.REBUILD_RUNNING
+	job.LastError = ""
+	job.Update = time.Now().Unix()
+	return r.goRun()
+}
+
+// Cancel used to cancel the running job, or roll back the failed one.
+// The job which has cleared the datadir can't be rolled back, it must be resumed.
+func (r *Rebuild) Cancel() error {
+	log := r.log
+
+	r.mutex.Lock()
+	if r.running {
+		r.canceled = true
+		step := r.job.Step + 1
+		from := r.job.From
+		restore := isRestore(&r.job)
+		r.mutex.Unlock()
+
+		log.Warning("rebuild.cancel.running.job.at.step[%v]", step)
+		if step == rebuildStepBackup && !restore {
+			if _, err := callx.BackupCancelRPC(from); err != nil {
+				log.Error("rebuild.cancel.backup.on[%v].error[%v]", from, err)
+			}
+		}
+		return nil
+	}
+	defer r.mutex.Unlock()
+
+	switch r.job.State {
+	case model.REBUILD_FAILED, model.REBUILD_INTERRUPTED:
+	default:
+		return errors.Errorf("rebuild.job.is.%v.nothing.to.cancel", r.job.State)
+	}
+	if err := r.rollbackHandler(&r.job); err != nil {
+		return err
+	}
+	r.job.State = model.REBUILD_CANCELED
+	r.job.Update = time.Now().Unix()
+	return writeRebuildJSON(r.path, &r.job)
+}
+
+// Status returns the copy of the job.
+func (r *Rebuild) Status() model.RebuildJob {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.job
+}
+
+// Wait used to wait for the running job exits, only for tests.
+func (r *Rebuild) Wait() {
+	r.mutex.RLock()
+	done := r.done
+	r.mutex.RUnlock()
+	if done != nil {
+		<-done
+	}
+}
+
+// goRun must be called with the mutex held.
+func (r *Rebuild) goRun() error {
+	if err := writeRebuildJSON(r.path, &r.job); err != nil {
+		return err
+	}
+	r.running = true
+	r.canceled = false
+	r.done = make(chan struct{})
+	go r.run(r.job, r.done)
+	return nil
+}
+
+func (r *Rebuild) run(job model.RebuildJob, done chan struct{}) {
+	log := r.log
+	defer close(done)
+
+	finish := func(state model.REBUILD_STATE, lastError string) {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		job.State = state
+		job.LastError = lastError
+		job.Update = time.Now().Unix()
+		r.job = job
+		r.running = false
+		if err := writeRebuildJSON(r.path, &r.job); err != nil {
+			log.Error("rebuild.write.json.error[%v]", err)
+		}
+	}
+
+	steps := r.steps
+	if isRestore(&job) {
+		steps = r.restoreSteps
+	}
+	for job.Step < len(steps) {
+		step := steps[job.Step]
+
+		r.mutex.Lock()
+		canceled := r.canceled
+		job.StepName = step.name
+		job.Update = time.Now().Unix()
+		r.job = job
+		r.mutex.Unlock()
+
+		if canceled {
+			if datadirTouched(&job) {
+				log.Error("rebuild.canceled.after.datadir.cleared.at.step[%v]", job.Step+1)
+				finish(model.REBUILD_FAILED, "rebuild.canceled.after.datadir.cleared.please.resume")
+				return
+			}
+			if err := r.rollbackHandler(&job); err != nil {
+				log.Error("rebuild.rollback.error[%v]", err)
+				finish(model.REBUILD_FAILED, err.Error())
+				return
+			}
+			log.Warning("rebuild.canceled.at.step[%v]", job.Step+1)
+			finish(model.REBUILD_CANCELED, "")
+			return
+		}
+
+		log.Warning("S%d-->%s.begin....", job.Step+1, step.name)
+		if err := step.do(&job); err != nil {
+			log.Error("S%d-->%s.error[%v]", job.Step+1, step.name, err)
+			finish(model.REBUILD_FAILED, fmt.Sprintf("S%d-->%s.error[%v]", job.Step+1, step.name, err))
+			return
+		}
+		log.Warning("S%d-->%s.end....", job.Step+1, step.name)
+
+		r.mutex.Lock()
+		job.Step++
+		job.Update = time.Now().Unix()
+		r.job = job
+		if err := writeRebuildJSON(r.path, &r.job); err != nil {
+			log.Error("rebuild.write.json.error[%v]", err)
+		}
+		r.mutex.Unlock()
+	}
+
+	log.Warning("completed OK!")
+	log.Warning("rebuildme.all.done....")
+	finish(model.REBUILD_DONE, "")
+}
+
+// rollback used to bring the mysqld and raft back, only before the datadir cleared.
+func (r *Rebuild) rollback(job *model.RebuildJob) error {
+	log := r.log
+	self := r.conf.Server.Endpoint
+
+	if isRestore(job) {
+		return r.rollbackRestore(job)
+	}
+	if datadirTouched(job) {
+		return errors.Errorf("rebuild.cannot.rollback.the.datadir.has.been.cleared.at.step[%v].please.resume", touchedStep(job))
+	}
+	if job.Step >= rebuildStepStopMonitor {
+		log.Warning("rebuild.rollback.start.monitor")
+		if _, err := callx.StartMonitorRPC(self); err != nil {
+			return errors.Errorf("rebuild.rollback.start.monitor.error[%v]", err)
+		}
+		if err := callx.WaitMysqldRunningRPC(self); err != nil {
+			return errors.Errorf("rebuild.rollback.wait.mysqld.running.error[%v]", err)
+		}
+	}
+	if job.Step >= rebuildStepSetLearner {
+		if err := r.enableRaft(job); err != nil {
+			return errors.Errorf("rebuild.rollback.enable.raft.error[%v]", err)
+		}
+	}
+	log.Warning("rebuild.rollback.done")
+	return nil
+}
+
+// S1. first to check I am leader or not
+func (r *Rebuild) checkLeader(job *model.RebuildJob) error {
+	self := r.conf.Server.Endpoint
+	leader, err := callx.GetClusterLeader(self)
+	if err != nil {
+		return err
+	}
+	if leader == self {
+		return errors.Errorf("I[%v].am.leader.you.cant.rebuildme.sir", self)
+	}
+	return nil
+}
+
+// S2. find the best to backup and check gtid
+func (r *Rebuild) checkBestone(job *model.RebuildJob) error {
+	log := r.log
+	self := r.conf.Server.Endpoint
+	maxAllowedLocalTrxCount := r.conf.Backup.MaxAllowedLocalTrxCount
+
+	if job.From == "" {
+		bestone, err := callx.FindBestoneForBackup(self)
+		if err != nil {
+			return err
+		}
+		job.From = bestone
+	}
+	delayed, err := callx.IsNodeDelayed(job.From)
+	if err != nil {
+		return err
+	}
+	if delayed {
+		return errors.Errorf("rebuild.from[%v].is.a.delayed.replica.you.cant.rebuildme.sir", job.From)
+	}
+	log.Warning("S2-->prepare.rebuild.from[%v]....", job.From)
+	job.Method = r.selectMethod(job)
+	log.Warning("S2-->rebuild.method[%v]", job.Method)
+
+	// check if there are more than 2 local transactions on the local node than bestone
+	if job.Force {
+		log.Warning("S2-->the.[--force].is.specified.skip.check.gtid")
+		return nil
+	}
+	working, err := callx.MysqlIsWorkingRPC(self)
+	if err != nil {
+		return err
+	}
+	if !working {
+		return errors.New("local.mysql.is.not.working.you.cant.rebuildme.sir")
+	}
+	localTrxCount, err := getLocalTrxCount(self, job.From)
+	if err != nil {
+		return err
+	}
+	if localTrxCount > maxAllowedLocalTrxCount {
+		return errors.Errorf("I[%v].have.[%v].local.transactions.more.than.maxAllowedLocalTrxCount[%v].compared.to.from[%v].you.cant.rebuildme.sir", self, localTrxCount, maxAllowedLocalTrxCount, job.From)
+	}
+	return nil
+}
+
+// checkBackupMethod used to check the backup-method, the logical dump needs the dump-dir,
+// and the load turns off the super_read_only, which the mysql56 and the MariaDB don't have.
+func (r *Rebuild) checkBackupMethod() error {
+	switch r.conf.Backup.Method {
+	case "", model.BACKUP_METHOD_XTRABACKUP:
+		return nil
+	case model.BACKUP_METHOD_MYSQLDUMP, model.BACKUP_METHOD_MYDUMPER:
+		if r.conf.Backup.DumpDir == "" {
+			return errors.Errorf("rebuild.backup-method[%v].requires.dump-dir", r.conf.Backup.Method)
+		}
+		if !mysql.HasSuperReadOnly(r.conf.Mysql.Version) {
+			return errors.Errorf("rebuild.backup-method[%v].unsupported.on.mysql[%v]", r.conf.Backup.Method, r.conf.Mysql.Version)
+		}
+		return nil
+	}
+	return errors.Errorf("rebuild.backup-method[%v].unsupported", r.conf.Backup.Method)
+}
+
+// isLogical returns true if the job loads the logical dump.
+func isLogical(job *model.RebuildJob) bool {
+	return job.Method == model.REBUILD_METHOD_MYSQLDUMP || job.Method == model.REBUILD_METHOD_MYDUMPER
+}
+
+// selectMethod returns the logical dump if the backup-method is mysqldump/mydumper,
+// the clone if both sides are 8.0.17+ and the donor is prepared,
+// otherwise it falls back to the xtrabackup, which is also kept for the rate limited job.
+func (r *Rebuild) selectMethod(job *model.RebuildJob) string {
+	log := r.log
+	self := r.conf.Server.Endpoint
+	from := job.From
+
+	switch r.conf.Backup.Method {
+	case model.BACKUP_METHOD_MYSQLDUMP, model.BACKUP_METHOD_MYDUMPER:
+		return r.conf.Backup.Method
+	}
+	if !mysql.IsMysql80(r.conf.Mysql.Version) || job.Rate > 0 {
+		return model.REBUILD_METHOD_XTRABACKUP
+	}
+	for _, node := range []string{self, from} {
+		version, err := callx.MysqlVersionRPC(node)
+		if err != nil {
+			log.Warning("S2-->get.mysql.version.of[%v].error[%v].fallback.to.xtrabackup", node, err)
+			return model.REBUILD_METHOD_XTRABACKUP
+		}
+		if !mysql.CloneSupported(version) {
+			log.Warning("S2-->mysql.version[%v].of[%v].does.not.support.clone.fallback.to.xtrabackup", version, node)
+			return model.REBUILD_METHOD_XTRABACKUP
+		}
+	}
+	rsp, err := callx.PrepareCloneDonorRPC(from)
+	if err == nil && rsp.RetCode != model.OK {
+		err = errors.New(rsp.RetCode)
+	}
+	if err != nil {
+		log.Warning("S2-->prepare.clone.donor[%v].error[%v].fallback.to.xtrabackup", from, err)
+		return model.REBUILD_METHOD_XTRABACKUP
+	}
+	return model.REBUILD_METHOD_CLONE
+}
+
+// S3&S7. check bestone is not in BACKUPING
+func (r *Rebuild) checkBestoneBackuping(job *model.RebuildJob) error {
+	rsp, err := callx.GetMysqldStatusRPC(job.From)
+	if err != nil {
+		return err
+	}
+	if rsp.BackupStatus == model.MYSQLD_BACKUPING {
+		return errors.Errorf("bestone[%v].is.backuping....", job.From)
+	}
+	return nil
+}
+
+// S4. set learner
+func (r *Rebuild) setLearner(job *model.RebuildJob) error {
+	if _, err := callx.SetLearnerRPC(r.conf.Server.Endpoint); err != nil {
+		r.log.Error("SetLearnerRPC.error[%v]", err)
+	}
+	return nil
+}
+
+// S5. stop monitor
+func (r *Rebuild) stopMonitor(job *model.RebuildJob) error {
+	if _, err := callx.StopMonitorRPC(r.conf.Server.Endpoint); err != nil {
+		r.log.Error("StopMonitorRPC.error[%v]", err)
+	}
+	return nil
+}
+
+// S6. force kill mysqld
+func (r *Rebuild) killMysqld(job *model.RebuildJob) error {
+	self := r.conf.Server.Endpoint
+	if job.Method == model.REBUILD_METHOD_CLONE {
+		r.log.Warning("S6-->kill.mysqld.skip.the.clone.runs.on.the.local.mysqld")
+		return nil
+	}
+	if isLogical(job) {
+		r.log.Warning("S6-->kill.mysqld.skip.the.dump.is.loaded.into.the.local.mysqld")
+		return nil
+	}
+	if err := callx.KillMysqldRPC(self); err != nil {
+		return err
+	}
+	if err := callx.WaitMysqldShutdownRPC(self); err != nil {
+		return err
+	}
+	// set the mysql state to dead, avoid failure to rebuild node with small amounts of data
+	return callx.SetMysqlStateRPC(self, model.MysqlDead)
+}
+
+// S8. remove data files
+func (r *Rebuild) clearDatadir(job *model.RebuildJob) error {
+	log := r.log
+	datadir := r.conf.Backup.BackupDir
+	if job.Method == model.REBUILD_METHOD_CLONE {
+		log.Warning("S8-->clear.datadir.skip.the.clone.replaces.the.data")
+		return nil
+	}
+
+	// remove mysql data
+	cmds := "bash"
+	if isLogical(job) {
+		// the datadir is kept, only the last dump is removed
+		dumpdir := r.conf.Backup.DumpDir
+		if _, err := common.RunCommand(cmds, "-c", fmt.Sprintf("rm -rf %s/*", dumpdir)); err != nil {
+			return err
+		}
+		log.Warning("S8-->clear.dumpdir[%v]", dumpdir)
+		return nil
+	}
+	args := []string{
+		"-c",
+		fmt.Sprintf("rm -rf %s/*", datadir),
+	}
+	if _, err := common.RunCommand(cmds, args...); err != nil {
+		return err
+	}
+	log.Warning("S8-->clear.datadir[%v]", datadir)
+
+	/*
+		Remove mysql binlog and index, considering that mysql binlog or index may not be in the same directory as the data.
+		For example, The contents of file my.cnf are as follows:
+
+		#log-bin=/data/mysql-log/mysql-bin/mysql-bin
+		log-bin=./mysql-bin
+		log-bin=/data/mysql-log/mysql-bin/mysql-bin
+		#log-bin=/data/mysql/mysql-bin
+		log-bin-index=/data/mysql/mysql-bin.index
+		log-bin-index=/data/mysql-log/mysql-bin/mysql-bin.index
+		log-bin-index=./mysql-bin.index
+		#log-bin-index=/data/mysql-log/mysql-bin/mysql-bin.index
+
+		The following shell instruction resolves that the paths of log-bin and log-bin-index are
+		/data/mysql-log/mysql-bin/mysql-bin and ./mysql-bin.index respectively.
+	*/
+	args = []string{
+		"-c",
+		fmt.Sprintf("grep 'log-bin=' %s | sed -r '/^#/d' | awk -F '=' '{print $2}' | tail -n 1", r.conf.Mysql.DefaultsFile),
+	}
+	binlogPrefix, err := common.RunCommand(cmds, args...)
+	if err != nil {
+		return err
+	}
+	job.BinlogPrefix = strings.TrimSpace(binlogPrefix)
+	job.BinlogDir = ""
+	if job.BinlogPrefix != "" && strings.Index(job.BinlogPrefix, "/") == 0 {
+		job.BinlogDir = path.Dir(job.BinlogPrefix)
+		if job.BinlogDir != path.Dir(datadir+"/") {
+			log.Warning("mysql.binlog.dir[%v].is.different.from.data.dir[%v]", job.BinlogDir, datadir)
+			args = []string{
+				"-c",
+				fmt.Sprintf("rm -f %s/*", job.BinlogDir),
+			}
+			if _, err := common.RunCommand(cmds, args...); err != nil {
+				return err
+			}
+			log.Warning("S8-->clear.mysql.binlog[%v.*]", job.BinlogPrefix)
+		}
+	}
+
+	args = []string{
+		"-c",
+		fmt.Sprintf("grep 'log-bin-index=' %s | sed -r '/^#/d' | awk -F '=' '{print $2}' | tail -n 1", r.conf.Mysql.DefaultsFile),
+	}
+	indexPath, err := common.RunCommand(cmds, args...)
+	if err != nil {
+		return err
+	}
+	indexPath = strings.TrimSpace(indexPath)
+	if indexPath != "" && strings.Index(indexPath, "/") == 0 {
+		indexDir := path.Dir(indexPath)
+		if indexDir != path.Dir(datadir+"/") {
+			log.Warning("mysql.binlog.index[%v].is.not.in.data.dir[%v]", indexPath, datadir)
+			args = []string{
+				"-c",
+				fmt.Sprintf("rm -f %s", indexPath),
+			}
+			if _, err := common.RunCommand(cmds, args...); err != nil {
+				return err
+			}
+			log.Warning("S8-->clear.mysql.binlog.index[%v]", indexPath)
+		}
+	}
+	return nil
+}
+
+// S9. do backup from bestone
+func (r *Rebuild) backup(job *model.RebuildJob) error {
+	if job.Method == model.REBUILD_METHOD_CLONE {
+		return r.clone(job)
+	}
+	var rsp *model.BackupRPCResponse
+	var err error
+	if isLogical(job) {
+		rsp, err = callx.RequestDumpRPC(job.From, r.conf, r.conf.Backup.DumpDir, job.Method, job.Rate)
+	} else {
+		rsp, err = callx.RequestBackupRPC(job.From, r.conf, r.conf.Backup.BackupDir, job.Rate)
+	}
+	if err != nil {
+		return err
+	}
+	if rsp.RetCode != model.OK {
+		return errors.New(rsp.RetCode)
+	}
+	return nil
+}
+
+// S9. clone from bestone, the local mysqld restarts on the cloned data
+func (r *Rebuild) clone(job *model.RebuildJob) error {
+	log := r.log
+	self := r.conf.Server.Endpoint
+
+	working, err := callx.MysqlIsWorkingRPC(self)
+	if err != nil {
+		return err
+	}
+	if !working {
+		return errors.New("local.mysql.is.not.working.cant.clone")
+	}
+	rsp, err := callx.PrepareCloneDonorRPC(job.From)
+	if err != nil {
+		return err
+	}
+	if rsp.RetCode != model.OK {
+		return errors.New(rsp.RetCode)
+	}
+
+	log.Warning("S9-->clone.instance.from[%v:%v]....", rsp.Donor.Master_Host, rsp.Donor.Master_Port)
+	rsp1, err := callx.CloneInstanceRPC(self, &rsp.Donor)
+	if err != nil {
+		return err
+	}
+	if rsp1.RetCode != model.OK {
+		return errors.New(rsp1.RetCode)
+	}
+	log.Warning("S9-->clone.done.wait.mysqld.restart")
+	// the mysqld is restarting, don't take the stale state as working
+	return callx.SetMysqlStateRPC(self, model.MysqlDead)
+}
+
+// S10. do apply-log
+func (r *Rebuild) applyLog(job *model.RebuildJob) error {
+	log := r.log
+	datadir := r.conf.Backup.BackupDir
+	if job.Method == model.REBUILD_METHOD_CLONE {
+		log.Warning("S10-->apply-log.skip.the.cloned.data.is.ready")
+		return nil
+	}
+	if isLogical(job) {
+		log.Warning("S10-->load.dump[%v].begin....", r.conf.Backup.DumpDir)
+		return callx.LoadDumpRPC(r.conf.Server.Endpoint, r.conf.Backup.DumpDir, job.Method)
+	}
+
+	if err := callx.DoApplyLogRPC(r.conf.Server.Endpoint, r.conf, datadir); err != nil {
+		return err
+	}
+
+	if mysql.IsMysql80(r.conf.Mysql.Version) {
+		/*
+			For 5.7, mysql will not work properly if log-bin-index is specified and log-bin is not specified.
+			But For 8.0, it works fine, mysql will automatically generate a new file based on the current serial number.
+
+			Xtrabackup will copy the nearest binlog from the source to the current data directory,
+			therefore, you need to move the last binlog to the directory specified by log-bin.
+		*/
+		if job.BinlogDir != "" && strings.Index(job.BinlogDir, "/") == 0 {
+			datadir2 := path.Dir(datadir + "/")
+			// if the binlog path is absolute and different from mysql data directory, move the binlog
+			if job.BinlogDir != datadir2 {
+				log.Warning("mysql.binlog.dir[%v].is.different.from.data.dir[%v]", job.BinlogDir, datadir2)
+				binlogBase := path.Base(job.BinlogPrefix)
+				cmds := "bash"
+				args := []string{
+					"-c",
+					fmt.Sprintf("mv %s/%s.* %s", datadir2, binlogBase, job.BinlogDir),
+				}
+				if _, err := common.RunCommand(cmds, args...); err != nil {
+					return err
+				}
+				log.Warning("move.binlog[%v/%v.*].to.dir[%v]", datadir2, binlogBase, job.BinlogDir)
+			}
+		}
+	}
+	return nil
+}
+
+// S11. start mysqld
+func (r *Rebuild) startMysqld(job *model.RebuildJob) error {
+	if _, err := callx.StartMonitorRPC(r.conf.Server.Endpoint); err != nil {
+		r.log.Error("start.mysql..error[%v]", err)
+	}
+	return nil
+}
+
+// S12. wait mysqld running
+func (r *Rebuild) waitMysqldRunning(job *model.RebuildJob) error {
+	return callx.WaitMysqldRunningRPC(r.conf.Server.Endpoint)
+}
+
+// S13. wait mysql working
+func (r *Rebuild) waitMysqlWorking(job *model.RebuildJob) error {
+	return callx.WaitMysqlWorkingRPC(r.conf.Server.Endpoint)
+}
+
+// S14. stop slave and reset slave all
+func (r *Rebuild) resetSlaveAll(job *model.RebuildJob) error {
+	if _, err := callx.MysqlResetSlaveAllRPC(r.conf.Server.Endpoint); err != nil {
+		r.log.Error("mysql.stop.adn.reset.slave.error[%v]", err)
+	}
+	return nil
+}
+
+// S15. set gtid_purged
+func (r *Rebuild) setGtidPurged(job *model.RebuildJob) error {
+	log := r.log
+	self := r.conf.Server.Endpoint
+
+	if job.Method == model.REBUILD_METHOD_CLONE {
+		log.Warning("S15-->set.gtid_purged.skip.the.clone.carries.the.gtid.state")
+		return nil
+	}
+	// the local gtid state is stale after the dump is loaded, even on mysql80
+	if !isLogical(job) && mysql.IsMysql80(r.conf.Mysql.Version) {
+		log.Warning("S15-->reset.master.skip.mysql80")
+		return nil
+	}
+	callx.MysqlResetMasterRPC(self)
+	log.Warning("S15-->reset.master.end....")
+
+	var gtid string
+	var err error
+	if isLogical(job) {
+		gtid, err = mysqld.DumpGTIDPurged(r.conf.Backup.DumpDir, job.Method)
+	} else {
+		gtid, err = callx.GetXtrabackupGTIDPurged(self, r.conf.Backup.BackupDir)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Warning("S15-->set.gtid_purged[%v].begin....", gtid)
+	rsp, err := callx.SetGlobalVarRPC(self, fmt.Sprintf("SET GLOBAL gtid_purged='%s'", gtid))
+	if err != nil {
+		return err
+	}
+	if rsp.RetCode != model.OK {
+		return errors.New(rsp.RetCode)
+	}
+	return nil
+}
+
+// S16. enable raft
+func (r *Rebuild) enableRaft(job *model.RebuildJob) error {
+	log := r.log
+	self := r.conf.Server.Endpoint
+
+	// check whether the state is IDLE or not
+	if r.conf.Raft.SuperIDLE {
+		log.Warning("rebuild.disable.raft.again...")
+		if _, err := callx.DisableRaftRPC(self); err != nil {
+			log.Error("disableRaftRPC.error[%v]", err)
+		}
+		log.Warning("rebuild.run.as.IDLE...")
+		return nil
+	}
+	if _, err := callx.EnableRaftRPC(self); err != nil {
+		log.Error("enbleRaftRPC.error[%v]", err)
+	}
+	return nil
+}
+
+// S17. wait change to master
+func (r *Rebuild) waitChangeToMaster(job *model.RebuildJob) error {
+	r.log.Warning("S17-->wait[%v ms].change.to.master...", r.conf.Raft.ElectionTimeout)
+	time.Sleep(time.Millisecond * time.Duration(r.conf.Raft.ElectionTimeout))
+	return nil
+}
+
+// S18. start slave
+func (r *Rebuild) startSlave(job *model.RebuildJob) error {
+	if _, err := callx.MysqlStartSlaveRPC(r.conf.Server.Endpoint); err != nil {
+		r.log.Error("mysql.start.slave.error[%v]", err)
+	}
+	return nil
+}
+
+func getLocalTrxCount(self string, bestone string) (int, error) {
+	count := 0
+
+	rsp1, err := callx.GetGTIDRPC(bestone)
+	if err != nil {
+		return -1, fmt.Errorf("get.gtid.from.bestone[%v].failed[%v]", bestone, err)
+	} else if rsp1.GTID.Executed_GTID_Set == "" {
+		return -1, fmt.Errorf("the.Executed_GTID_Set.of.bestone[%v].is.null", bestone)
+	}
+	fromGTID := rsp1.GTID
+
+	rsp1, err = callx.GetGTIDRPC(self)
+	if err != nil {
+		return -1, fmt.Errorf("get.gtid.from.myself[%v].failed[%v]", self, err)
+	} else if rsp1.GTID.Executed_GTID_Set == "" {
+		return -1, fmt.Errorf("the.Executed_GTID_Set.of.myself[%v].is.null", self)
+	}
+	localGTID := rsp1.GTID
+
+	rsp2, err := callx.GetGTIDSubtractRPC(self, localGTID.Executed_GTID_Set, fromGTID.Executed_GTID_Set)
+	if err != nil {
+		return -1, fmt.Errorf("get.gtid.subtract.from.self[%v].failed[%v]", self, err)
+	}
+	subtract := rsp2.Subtract
+
+	// compute the number of local transactions
+	gtidSet := strings.Split(subtract, "\n")
+	for _, gtid := range gtidSet {
+		gtid = strings.TrimSpace(gtid)
+		gtid = strings.TrimSuffix(gtid, ",")
+		diffs := strings.Split(gtid, ":")[1:]
+		for _, diff := range diffs {
+			values := strings.Split(diff, "-")
+			if len(values) == 1 {
+				count += 1
+			} else {
+				s, _ := strconv.Atoi(values[0])
+				e, _ := strconv.Atoi(values[1])
+				count += e - s + 1
+			}
+		}
+	}
+
+	return count, nil
+}
+
+func writeRebuildJSON(path string, job *model.RebuildJob) error {
+	jsonStr, err := json.Marshal(job)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// written to the tmp and renamed, a crash never leaves a truncated rebuild.json
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, jsonStr, 0644); err != nil {
+		os.Remove(tmp)
+		return errors.WithStack(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func readRebuildJSON(path string) (*model.RebuildJob, error) {
+	job := &model.RebuildJob{}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if err := json.Unmarshal(buf, job); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return job, nil
+}
@@ -0,0 +1,646 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package server
+
+import (
+	"config"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"model"
+	"mysql"
+	"os"
+	"testing"
+	"xbase/common"
+	"xbase/xlog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockRebuild(t *testing.T, steps int) (*Rebuild, []int, func()) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	dir, err := ioutil.TempDir("", "xenon-rebuild")
+	assert.Nil(t, err)
+
+	conf := config.DefaultConfig()
+	conf.Raft.MetaDatadir = dir
+	r := NewRebuild(conf, log)
+
+	// calls[i] is the times the step i has been called
+	calls := make([]int, steps)
+	r.steps = r.steps[:0]
+	for i := 0; i < steps; i++ {
+		i := i
+		r.steps = append(r.steps, &rebuildStep{"mock", func(job *model.RebuildJob) error {
+			calls[i]++
+			return nil
+		}})
+	}
+	r.rollbackHandler = func(job *model.RebuildJob) error {
+		return nil
+	}
+	return r, calls, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestRebuildDone(t *testing.T) {
+	r, calls, cleanup := mockRebuild(t, 18)
+	defer cleanup()
+
+	err := r.Start("", false, 0)
+	assert.Nil(t, err)
+	r.Wait()
+
+	job := r.Status()
+	assert.Equal(t, model.REBUILD_DONE, job.State)
+	assert.Equal(t, 18, job.Step)
+	for _, call := range calls {
+		assert.Equal(t, 1, call)
+	}
+
+	// persisted.
+	got, err := readRebuildJSON(r.path)
+	assert.Nil(t, err)
+	assert.Equal(t, job, *got)
+
+	// resume a done job.
+	err = r.Resume()
+	assert.NotNil(t, err)
+
+	// start again.
+	err = r.Start("", false, 0)
+	assert.Nil(t, err)
+	r.Wait()
+	assert.Equal(t, model.REBUILD_DONE, r.Status().State)
+
+	// the rate requires the xenon transport.
+	err = r.Start("", false, 1024*1024)
+	assert.Equal(t, "rebuild.rate.requires.backup-transport[xenon].but.got[ssh]", err.Error())
+
+	r.conf.Backup.Transport = model.BACKUP_TRANSPORT_XENON
+	err = r.Start("", false, 1024*1024)
+	assert.Nil(t, err)
+	r.Wait()
+	assert.Equal(t, model.REBUILD_DONE, r.Status().State)
+	assert.Equal(t, int64(1024*1024), r.Status().Rate)
+
+	// the backup-method must be known and the logical dump needs the dump-dir.
+	r.conf.Backup.Method = "mysqlpump"
+	err = r.Start("", false, 0)
+	assert.Equal(t, "rebuild.backup-method[mysqlpump].unsupported", err.Error())
+
+	r.conf.Backup.Method = model.BACKUP_METHOD_MYSQLDUMP
+	r.conf.Backup.DumpDir = ""
+	err = r.Start("", false, 0)
+	assert.Equal(t, "rebuild.backup-method[mysqldump].requires.dump-dir", err.Error())
+
+	// the load needs the super_read_only.
+	r.conf.Backup.DumpDir = "/u01/dump"
+	r.conf.Mysql.Version = "mysql56"
+	err = r.Start("", false, 0)
+	assert.Equal(t, "rebuild.backup-method[mysqldump].unsupported.on.mysql[mysql56]", err.Error())
+
+	r.conf.Mysql.Version = "mariadb10"
+	err = r.Start("", false, 0)
+	assert.Equal(t, "rebuild.backup-method[mysqldump].unsupported.on.mysql[mariadb10]", err.Error())
+}
+
+func TestRebuildFailedAndResume(t *testing.T) {
+	r, calls, cleanup := mockRebuild(t, 18)
+	defer cleanup()
+
+	// S9 xtrabackup fails.
+	failed := true
+	do := r.steps[rebuildStepBackup-1].do
+	r.steps[rebuildStepBackup-1].do = func(job *model.RebuildJob) error {
+		if failed {
+			return errors.New("mock.backup.error")
+		}
+		return do(job)
+	}
+
+	err := r.Start("", false, 0)
+	assert.Nil(t, err)
+	r.Wait()
+
+	job := r.Status()
+	assert.Equal(t, model.REBUILD_FAILED, job.State)
+	assert.Equal(t, rebuildStepBackup-1, job.Step)
+	assert.Contains(t, job.LastError, "mock.backup.error")
+
+	// start must be refused.
+	err = r.Start("", false, 0)
+	assert.NotNil(t, err)
+
+	// rollback must be refused, the datadir has been cleared.
+	r.rollbackHandler = r.rollback
+	err = r.Cancel()
+	assert.NotNil(t, err)
+
+	// resume from S7.
+	failed = false
+	err = r.Resume()
+	assert.Nil(t, err)
+	r.Wait()
+
+	job = r.Status()
+	assert.Equal(t, model.REBUILD_DONE, job.State)
+	for i, call := range calls {
+		switch {
+		case i >= rebuildStepRecheckBackup-1 && i < rebuildStepBackup-1:
+			assert.Equal(t, 2, call)
+		default:
+			assert.Equal(t, 1, call)
+		}
+	}
+}
+
+func TestRebuildFailedInClearDatadir(t *testing.T) {
+	r, calls, cleanup := mockRebuild(t, 18)
+	defer cleanup()
+
+	// S8 clear.datadir fails halfway.
+	failed := true
+	do := r.steps[rebuildStepClearDatadir-1].do
+	r.steps[rebuildStepClearDatadir-1].do = func(job *model.RebuildJob) error {
+		if failed {
+			return errors.New("mock.clear.datadir.error")
+		}
+		return do(job)
+	}
+
+	err := r.Start("", false, 0)
+	assert.Nil(t, err)
+	r.Wait()
+
+	job := r.Status()
+	assert.Equal(t, model.REBUILD_FAILED, job.State)
+	assert.Equal(t, rebuildStepClearDatadir-1, job.Step)
+
+	// rollback must be refused, the datadir is partial.
+	r.rollbackHandler = r.rollback
+	err = r.Cancel()
+	assert.NotNil(t, err)
+	assert.Equal(t, model.REBUILD_FAILED, r.Status().State)
+
+	// resume from S7, the datadir is cleared again.
+	failed = false
+	err = r.Resume()
+	assert.Nil(t, err)
+	r.Wait()
+
+	job = r.Status()
+	assert.Equal(t, model.REBUILD_DONE, job.State)
+	for i, call := range calls {
+		switch {
+		case i == rebuildStepRecheckBackup-1:
+			assert.Equal(t, 2, call)
+		default:
+			assert.Equal(t, 1, call)
+		}
+	}
+}
+
+func TestRebuildLogicalFailedInLoad(t *testing.T) {
+	r, calls, cleanup := mockRebuild(t, 18)
+	defer cleanup()
+
+	// S2 picks the mysqldump, S9 dump fails first, then S10 load fails.
+	r.steps[1].do = func(job *model.RebuildJob) error {
+		calls[1]++
+		job.Method = model.REBUILD_METHOD_MYSQLDUMP
+		return nil
+	}
+	failed := rebuildStepBackup
+	for _, i := range []int{rebuildStepBackup, rebuildStepApplyLog} {
+		i := i
+		do := r.steps[i-1].do
+		r.steps[i-1].do = func(job *model.RebuildJob) error {
+			if failed == i {
+				return fmt.Errorf("mock.step[%v].error", i)
+			}
+			return do(job)
+		}
+	}
+
+	// the datadir is kept by the S8 clear.dumpdir and the S9 dump, it can be rolled back.
+	err := r.Start("", false, 0)
+	assert.Nil(t, err)
+	r.Wait()
+	job := r.Status()
+	assert.Equal(t, model.REBUILD_FAILED, job.State)
+	assert.Equal(t, rebuildStepBackup-1, job.Step)
+	assert.False(t, datadirTouched(&job))
+
+	rollbacks := 0
+	r.rollbackHandler = func(job *model.RebuildJob) error {
+		rollbacks++
+		return nil
+	}
+	err = r.Cancel()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, rollbacks)
+	assert.Equal(t, model.REBUILD_CANCELED, r.Status().State)
+
+	// the S10 load drops the user databases, rollback must be refused.
+	failed = rebuildStepApplyLog
+	err = r.Start("", false, 0)
+	assert.Nil(t, err)
+	r.Wait()
+	job = r.Status()
+	assert.Equal(t, model.REBUILD_FAILED, job.State)
+	assert.Equal(t, rebuildStepApplyLog-1, job.Step)
+	assert.True(t, datadirTouched(&job))
+
+	r.rollbackHandler = r.rollback
+	err = r.Cancel()
+	assert.Equal(t, "rebuild.cannot.rollback.the.datadir.has.been.cleared.at.step[10].please.resume", err.Error())
+	assert.Equal(t, model.REBUILD_FAILED, r.Status().State)
+
+	// resume from S7, the dump is taken and loaded again.
+	failed = 0
+	for i := range calls {
+		calls[i] = 0
+	}
+	err = r.Resume()
+	assert.Nil(t, err)
+	r.Wait()
+	assert.Equal(t, model.REBUILD_DONE, r.Status().State)
+	for i, call := range calls {
+		if i < rebuildStepRecheckBackup-1 {
+			assert.Equal(t, 0, call)
+		} else {
+			assert.Equal(t, 1, call)
+		}
+	}
+}
+
+func TestRebuildCancel(t *testing.T) {
+	r, _, cleanup := mockRebuild(t, 18)
+	defer cleanup()
+
+	// S5 stop monitor blocks until canceled.
+	entered := make(chan struct{})
+	wait := make(chan struct{})
+	r.steps[rebuildStepStopMonitor-1].do = func(job *model.RebuildJob) error {
+		close(entered)
+		<-wait
+		return nil
+	}
+	rollbacks := 0
+	r.rollbackHandler = func(job *model.RebuildJob) error {
+		rollbacks++
+		assert.Equal(t, rebuildStepStopMonitor, job.Step)
+		return nil
+	}
+
+	err := r.Start("", false, 0)
+	assert.Nil(t, err)
+	<-entered
+
+	err = r.Cancel()
+	assert.Nil(t, err)
+	close(wait)
+	r.Wait()
+
+	job := r.Status()
+	assert.Equal(t, model.REBUILD_CANCELED, job.State)
+	assert.Equal(t, 1, rollbacks)
+
+	// cancel again.
+	err = r.Cancel()
+	assert.NotNil(t, err)
+}
+
+func TestRebuildInterrupted(t *testing.T) {
+	r, calls, cleanup := mockRebuild(t, 18)
+	defer cleanup()
+
+	// xenon went away at S6.
+	job := &model.RebuildJob{
+		State:    model.REBUILD_RUNNING,
+		Step:     5,
+		StepName: "kill.mysqld",
+		Steps:    18,
+	}
+	err := writeRebuildJSON(r.path, job)
+	assert.Nil(t, err)
+	// no tmp is left behind
+	_, err = os.Stat(r.path + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+
+	err = r.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, model.REBUILD_INTERRUPTED, r.Status().State)
+	got, err := readRebuildJSON(r.path)
+	assert.Nil(t, err)
+	assert.Equal(t, model.REBUILD_INTERRUPTED, got.State)
+
+	// resume from S3.
+	err = r.Resume()
+	assert.Nil(t, err)
+	r.Wait()
+	assert.Equal(t, model.REBUILD_DONE, r.Status().State)
+	for i, call := range calls {
+		if i < rebuildStepCheckBackup-1 {
+			assert.Equal(t, 0, call)
+		} else {
+			assert.Equal(t, 1, call)
+		}
+	}
+
+	// no file.
+	os.Remove(r.path)
+	err = r.Load()
+	assert.Nil(t, err)
+}
+
+func TestRebuildSelectMethod(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	r, _, cleanup := mockRebuild(t, 18)
+	defer cleanup()
+
+	port := common.RandomPort(8000, 8100)
+	selfHandler := mysql.NewMockGTIDB()
+	self, _, cleanup1 := mysql.MockMysql(log, port, selfHandler)
+	defer cleanup1()
+	port = common.RandomPort(8100, 8200)
+	fromHandler := mysql.NewMockGTIDB()
+	from, _, cleanup2 := mysql.MockMysql(log, port, fromHandler)
+	defer cleanup2()
+
+	r.conf.Server.Endpoint = self
+	job := &model.RebuildJob{From: from}
+
+	// not mysql80
+	assert.Equal(t, model.REBUILD_METHOD_XTRABACKUP, r.selectMethod(job))
+
+	// both are 8.0.17+
+	r.conf.Mysql.Version = "mysql80"
+	assert.Equal(t, model.REBUILD_METHOD_CLONE, r.selectMethod(job))
+
+	// the rate limit is only for the xtrabackup
+	job.Rate = 1024
+	assert.Equal(t, model.REBUILD_METHOD_XTRABACKUP, r.selectMethod(job))
+	job.Rate = 0
+
+	// the donor is older
+	fromHandler.GetVersionFn = func(db *sql.DB) (string, error) {
+		return "8.0.16", nil
+	}
+	assert.Equal(t, model.REBUILD_METHOD_XTRABACKUP, r.selectMethod(job))
+	fromHandler.GetVersionFn = mysql.DefaultGetVersion
+
+	// the donor can't be prepared
+	fromHandler.InstallClonePluginFn = func(db *sql.DB) error {
+		return errors.New("mock.install.clone.plugin.error")
+	}
+	assert.Equal(t, model.REBUILD_METHOD_XTRABACKUP, r.selectMethod(job))
+	fromHandler.InstallClonePluginFn = mysql.DefaultInstallClonePlugin
+
+	// the local mysql is down
+	selfHandler.GetVersionFn = func(db *sql.DB) (string, error) {
+		return "", errors.New("mock.mysql.down")
+	}
+	assert.Equal(t, model.REBUILD_METHOD_XTRABACKUP, r.selectMethod(job))
+
+	// the logical dump is configured
+	r.conf.Backup.Method = model.BACKUP_METHOD_MYDUMPER
+	assert.Equal(t, model.REBUILD_METHOD_MYDUMPER, r.selectMethod(job))
+}
+
+func TestGetLocalTrxCount(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	// ok
+	{
+		// setGTID: c78e798a-cccc-cccc-cccc-525433e8e796:1-10, df24366e-inva-bbbb-bbbb-525433b6dbaa:1-30
+		port := common.RandomPort(8100, 8200)
+		from, _, cleanup2 := mysql.MockMysql(log, port, mysql.NewMockGTIDF())
+		defer cleanup2()
+
+		// subsetGTID: c78e798a-cccc-cccc-cccc-525433e8e796:1-200, df24366e-inva-bbbb-bbbb-525433b6dbaa:1-200, ef24366e-aaaa-aaaa-aaaa-525433b6deee:100
+		// result: c78e798a-cccc-cccc-cccc-525433e8e796:11-200,\ndf24366e-inva-bbbb-bbbb-525433b6dbaa:31-200,\nef24366e-aaaa-aaaa-aaaa-525433b6deee:100
+		port = common.RandomPort(8000, 8100)
+		self, _, cleanup1 := mysql.MockMysql(log, port, mysql.NewMockGTIDE1())
+		defer cleanup1()
+		count, err := getLocalTrxCount(self, from)
+		assert.Nil(t, err)
+		assert.Equal(t, 361, count)
+
+		// subsetGTID: c78e798a-cccc-cccc-cccc-525433e8e796:1-10, df24366e-inva-bbbb-bbbb-525433b6dbaa:1-40
+		// result: df24366e-inva-bbbb-bbbb-525433b6dbaa:31-40
+		port = common.RandomPort(8000, 8100)
+		self, _, cleanup1 = mysql.MockMysql(log, port, mysql.NewMockGTIDE2())
+		defer cleanup1()
+		count, err = getLocalTrxCount(self, from)
+		assert.Nil(t, err)
+		assert.Equal(t, 10, count)
+
+		// subsetGTID: df24366e-inva-bbbb-bbbb-525433b6dbaa:1-31
+		// result: df24366e-inva-bbbb-bbbb-525433b6dbaa:31
+		port = common.RandomPort(8000, 8100)
+		self, _, cleanup1 = mysql.MockMysql(log, port, mysql.NewMockGTIDE3())
+		defer cleanup1()
+		count, err = getLocalTrxCount(self, from)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, count)
+	}
+
+	// error
+	{
+		// get setGTID error
+		{
+			port := common.RandomPort(8000, 8100)
+			self, _, cleanup1 := mysql.MockMysql(log, port, mysql.NewMockGTIDE1())
+			defer cleanup1()
+			port = common.RandomPort(8100, 8200)
+			from, _, cleanup2 := mysql.MockMysql(log, port, mysql.NewMockGTIDError())
+			defer cleanup2()
+			count, err := getLocalTrxCount(self, from)
+			assert.NotNil(t, err)
+			assert.Equal(t, -1, count)
+		}
+
+		// get subsetGTID error
+		{
+			port := common.RandomPort(8000, 8100)
+			self, _, cleanup1 := mysql.MockMysql(log, port, mysql.NewMockGTIDError())
+			defer cleanup1()
+			port = common.RandomPort(8100, 8200)
+			from, _, cleanup2 := mysql.MockMysql(log, port, mysql.NewMockGTIDF())
+			defer cleanup2()
+			count, err := getLocalTrxCount(self, from)
+			assert.NotNil(t, err)
+			assert.Equal(t, -1, count)
+		}
+
+		// from.Executed_GTID_Set is null
+		{
+			port := common.RandomPort(8000, 8100)
+			self, _, cleanup1 := mysql.MockMysql(log, port, mysql.NewMockGTIDE1())
+			defer cleanup1()
+			port = common.RandomPort(8100, 8200)
+			from, _, cleanup2 := mysql.MockMysql(log, port, mysql.NewMockGTIDNull())
+			defer cleanup2()
+			count, err := getLocalTrxCount(self, from)
+			assert.NotNil(t, err)
+			assert.Equal(t, -1, count)
+		}
+
+		// self.Executed_GTID_Set is null
+		{
+			port := common.RandomPort(8000, 8100)
+			self, _, cleanup1 := mysql.MockMysql(log, port, mysql.NewMockGTIDNull())
+			defer cleanup1()
+			port = common.RandomPort(8100, 8200)
+			from, _, cleanup2 := mysql.MockMysql(log, port, mysql.NewMockGTIDF())
+			defer cleanup2()
+			count, err := getLocalTrxCount(self, from)
+			assert.NotNil(t, err)
+			assert.Equal(t, -1, count)
+		}
+
+		// GetGTIDSubtract error
+		{
+			port := common.RandomPort(8000, 8100)
+			self, _, cleanup1 := mysql.MockMysql(log, port, mysql.NewMockGTIDGetGTIDSubtractError())
+			defer cleanup1()
+			port = common.RandomPort(8100, 8200)
+			from, _, cleanup2 := mysql.MockMysql(log, port, mysql.NewMockGTIDF())
+			defer cleanup2()
+			count, err := getLocalTrxCount(self, from)
+			assert.NotNil(t, err)
+			assert.Equal(t, -1, count)
+		}
+	}
+
+}
+
+// mockRestoreSteps replaces the restore steps, calls[i] is the times the step i has been called
+// and the step i fails while fails[i] is true.
+func mockRestoreSteps(r *Rebuild) ([]int, []bool) {
+	calls := make([]int, len(r.restoreSteps))
+	fails := make([]bool, len(r.restoreSteps))
+	for i, step := range r.restoreSteps {
+		i := i
+		step.do = func(job *model.RebuildJob) error {
+			calls[i]++
+			if fails[i] {
+				return errors.New("mock.restore.error")
+			}
+			return nil
+		}
+	}
+	return calls, fails
+}
+
+func TestRestoreFailedAndResume(t *testing.T) {
+	r, _, cleanup := mockRebuild(t, 18)
+	defer cleanup()
+	calls, fails := mockRestoreSteps(r)
+	r.rollbackHandler = r.rollback
+	id := "20200601120000"
+
+	// the point in time is checked before the job starts
+	{
+		err := r.StartRestore(id, "", "")
+		assert.Equal(t, "replay.stop.datetime.and.gtid.are.both.empty", err.Error())
+		err = r.StartRestore(id, "2020-06-01 12:00:00", "4b4b2a49-1b20-11e8-8c4d-525400a36c5e:1-20")
+		assert.Equal(t, "restore.needs.one.of.to-time.or.to-gtid", err.Error())
+		err = r.StartRestore(id, "", "4b4b2a49-1b20-11e8-8c4d-525400a36c5e")
+		assert.Equal(t, "gtid.set[4b4b2a49-1b20-11e8-8c4d-525400a36c5e].invalid", err.Error())
+		err = r.StartRestore("", "2020-06-01 12:00:00", "")
+		assert.Equal(t, "restore.backup.id.is.empty", err.Error())
+	}
+
+	// S6 restore.archive fails after the datadir moved
+	{
+		fails[restoreStepMoveDatadir] = true
+		err := r.StartRestore(id, "2020-06-01 12:00:00", "")
+		assert.Nil(t, err)
+		r.Wait()
+
+		job := r.Status()
+		assert.Equal(t, model.REBUILD_FAILED, job.State)
+		assert.Equal(t, restoreStepMoveDatadir, job.Step)
+		assert.Equal(t, len(r.restoreSteps), job.Steps)
+		assert.Equal(t, id, job.RestoreID)
+
+		// persisted.
+		got, err := readRebuildJSON(r.path)
+		assert.Nil(t, err)
+		assert.Equal(t, job, *got)
+
+		// start and rollback must be refused.
+		assert.NotNil(t, r.Start("", false, 0))
+		assert.NotNil(t, r.StartRestore(id, "2020-06-01 12:00:00", ""))
+		err = r.Cancel()
+		assert.Equal(t, "restore.cannot.rollback.the.datadir.has.been.moved.at.step[5].please.resume", err.Error())
+	}
+
+	// resume from the failed S6
+	{
+		fails[restoreStepMoveDatadir] = false
+		err := r.Resume()
+		assert.Nil(t, err)
+		r.Wait()
+		assert.Equal(t, model.REBUILD_DONE, r.Status().State)
+		for i, call := range calls {
+			if i == restoreStepMoveDatadir {
+				assert.Equal(t, 2, call)
+			} else {
+				assert.Equal(t, 1, call)
+			}
+		}
+	}
+}
+
+func TestRestoreFailedBeforeDatadirMoved(t *testing.T) {
+	r, _, cleanup := mockRebuild(t, 18)
+	defer cleanup()
+	calls, fails := mockRestoreSteps(r)
+
+	// S5 move.datadir fails, it may be half done
+	fails[restoreStepMoveDatadir-1] = true
+	err := r.StartRestore("20200601120000", "", "4b4b2a49-1b20-11e8-8c4d-525400a36c5e:1-20")
+	assert.Nil(t, err)
+	r.Wait()
+	job := r.Status()
+	assert.Equal(t, model.REBUILD_FAILED, job.State)
+	assert.True(t, datadirTouched(&job))
+
+	// resume from the failed S5
+	fails[restoreStepMoveDatadir-1] = false
+	err = r.Resume()
+	assert.Nil(t, err)
+	r.Wait()
+	assert.Equal(t, model.REBUILD_DONE, r.Status().State)
+	assert.Equal(t, 1, calls[0])
+	assert.Equal(t, 2, calls[restoreStepMoveDatadir-1])
+
+	// S4 kill.mysqld fails, the datadir is untouched, resume from S1
+	fails[restoreStepMoveDatadir-2] = true
+	err = r.StartRestore("20200601120000", "", "4b4b2a49-1b20-11e8-8c4d-525400a36c5e:1-20")
+	assert.Nil(t, err)
+	r.Wait()
+	job = r.Status()
+	assert.Equal(t, model.REBUILD_FAILED, job.State)
+	assert.False(t, datadirTouched(&job))
+
+	fails[restoreStepMoveDatadir-2] = false
+	err = r.Resume()
+	assert.Nil(t, err)
+	r.Wait()
+	assert.Equal(t, model.REBUILD_DONE, r.Status().State)
+	assert.Equal(t, 3, calls[0])
+	assert.Equal(t, 3, calls[restoreStepMoveDatadir-2])
+}
@@ -0,0 +1,234 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package server
+
+import (
+	"cli/callx"
+	"fmt"
+	"model"
+	"mysql"
+	"mysqld"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The point-in-time restore runs as a job of the Rebuild with its own steps,
+// so it's persisted and resumed the same way, the session of the client can go away at any step.
+
+// The restore steps index(1-based) which the restore job cares about.
+const (
+	restoreStepDisableRaft = 2
+	restoreStepStopMonitor = 3
+	restoreStepMoveDatadir = 5
+	restoreStepStartMysqld = 7
+)
+
+// isRestore returns true if the job is a point-in-time restore.
+func isRestore(job *model.RebuildJob) bool {
+	return job.RestoreID != ""
+}
+
+// StartRestore used to start a new point-in-time restore job of the local archive in background,
+// the archived binlogs are replayed until the toTime or the toGTID.
+func (r *Rebuild) StartRestore(id string, toTime string, toGTID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.checkStartable(); err != nil {
+		return err
+	}
+	if id == "" {
+		return errors.New("restore.backup.id.is.empty")
+	}
+	if toTime != "" && toGTID != "" {
+		return errors.New("restore.needs.one.of.to-time.or.to-gtid")
+	}
+	if err := mysqld.CheckReplayStop(toTime, toGTID); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	r.job = model.RebuildJob{
+		State:         model.REBUILD_RUNNING,
+		RestoreID:     id,
+		RestoreToTime: toTime,
+		RestoreToGTID: toGTID,
+		Steps:         len(r.restoreSteps),
+		Begin:         now,
+		Update:        now,
+	}
+	return r.goRun()
+}
+
+// recoverRestore used to keep the restored(or restoring) node out of the raft after xenon restarts,
+// and the mysqld down if the datadir isn't ready.
+func (r *Rebuild) recoverRestore(job *model.RebuildJob) {
+	log := r.log
+	self := r.conf.Server.Endpoint
+
+	switch job.State {
+	case model.REBUILD_NONE, model.REBUILD_CANCELED:
+		return
+	}
+	log.Warning("rebuild.recover.restore.job[%+v]", job)
+	if job.Step >= restoreStepDisableRaft-1 {
+		if _, err := callx.DisableRaftRPC(self); err != nil {
+			log.Error("rebuild.recover.restore.disable.raft.error[%v]", err)
+		}
+	}
+	if job.State != model.REBUILD_DONE && job.Step >= restoreStepStopMonitor && job.Step < restoreStepStartMysqld {
+		if _, err := callx.StopMonitorRPC(self); err != nil {
+			log.Error("rebuild.recover.restore.stop.monitor.error[%v]", err)
+		}
+	}
+}
+
+// rollbackRestore used to bring the mysqld and raft back, only before the datadir moved.
+func (r *Rebuild) rollbackRestore(job *model.RebuildJob) error {
+	log := r.log
+	self := r.conf.Server.Endpoint
+
+	if datadirTouched(job) {
+		return errors.Errorf("restore.cannot.rollback.the.datadir.has.been.moved.at.step[%v].please.resume", restoreStepMoveDatadir)
+	}
+	if job.Step >= restoreStepStopMonitor-1 {
+		log.Warning("restore.rollback.start.monitor")
+		if _, err := callx.StartMonitorRPC(self); err != nil {
+			return errors.Errorf("restore.rollback.start.monitor.error[%v]", err)
+		}
+		if err := callx.WaitMysqldRunningRPC(self); err != nil {
+			return errors.Errorf("restore.rollback.wait.mysqld.running.error[%v]", err)
+		}
+	}
+	if job.Step >= restoreStepDisableRaft-1 {
+		if _, err := callx.EnableRaftRPC(self); err != nil {
+			return errors.Errorf("restore.rollback.enable.raft.error[%v]", err)
+		}
+	}
+	log.Warning("restore.rollback.done")
+	return nil
+}
+
+// restoreDatadir returns the datadir without the trailing slash.
+func (r *Rebuild) restoreDatadir() string {
+	return strings.TrimSuffix(r.conf.Backup.BackupDir, "/")
+}
+
+// S1. check the archive is on this node and I am not the leader
+func (r *Rebuild) checkRestore(job *model.RebuildJob) error {
+	self := r.conf.Server.Endpoint
+	rsp, err := callx.ShowArchiveRPC(self, job.RestoreID)
+	if err != nil {
+		return err
+	}
+	if rsp.RetCode != model.OK {
+		return errors.New(rsp.RetCode)
+	}
+	leader, err := callx.GetClusterLeader(self)
+	if err != nil {
+		return err
+	}
+	if leader == self {
+		return errors.Errorf("I[%v].am.leader.you.cant.restore.sir", self)
+	}
+	// the dir is persisted with this step, so the move is known after xenon restarts
+	if job.RestoreAside == "" {
+		job.RestoreAside = fmt.Sprintf("%s.before-restore-%s", r.restoreDatadir(), time.Now().Format("20060102150405"))
+	}
+	r.log.Warning("S1-->restore.archive[%v].to[time:%v, gtid:%v]....", job.RestoreID, job.RestoreToTime, job.RestoreToGTID)
+	return nil
+}
+
+// S2. disable raft
+func (r *Rebuild) disableRaft(job *model.RebuildJob) error {
+	_, err := callx.DisableRaftRPC(r.conf.Server.Endpoint)
+	return err
+}
+
+// S5. move the datadir aside, it's kept until the operator removes it
+func (r *Rebuild) moveDatadir(job *model.RebuildJob) error {
+	datadir := r.restoreDatadir()
+	if _, err := os.Stat(job.RestoreAside); err == nil {
+		r.log.Warning("S5-->datadir[%v].has.been.moved.to[%v]", datadir, job.RestoreAside)
+		return nil
+	}
+	if err := os.Rename(datadir, job.RestoreAside); err != nil {
+		return errors.WithStack(err)
+	}
+	r.log.Warning("S5-->move.datadir[%v].to[%v].done....", datadir, job.RestoreAside)
+	return nil
+}
+
+// S6. restore the archive chain to the datadir and prepare it, the partial one of the last try is removed
+func (r *Rebuild) restoreArchive(job *model.RebuildJob) error {
+	datadir := r.restoreDatadir()
+	if _, err := os.Stat(job.RestoreAside); err != nil {
+		return errors.Errorf("restore.datadir[%v].is.not.moved.aside.to[%v]", datadir, job.RestoreAside)
+	}
+	if err := os.RemoveAll(datadir); err != nil {
+		return errors.WithStack(err)
+	}
+	rsp, err := callx.RestoreArchiveRPC(r.conf.Server.Endpoint, job.RestoreID, datadir)
+	if err != nil {
+		return err
+	}
+	if rsp.RetCode != model.OK {
+		return errors.New(rsp.RetCode)
+	}
+	return nil
+}
+
+// S11. set the gtid_purged of the backup
+func (r *Rebuild) setRestoreGtidPurged(job *model.RebuildJob) error {
+	self := r.conf.Server.Endpoint
+	rsp, err := callx.ShowArchiveRPC(self, job.RestoreID)
+	if err != nil {
+		return err
+	}
+	if rsp.RetCode != model.OK {
+		return errors.New(rsp.RetCode)
+	}
+	gtid := rsp.Manifests[0].GTID
+	if mysql.IsMysql80(r.conf.Mysql.Version) || gtid == "" {
+		r.log.Warning("S11-->set.gtid_purged.skip[%v]", gtid)
+		return nil
+	}
+
+	rsp1, err := callx.MysqlResetMasterRPC(self)
+	if err != nil {
+		return err
+	}
+	if rsp1.RetCode != model.OK {
+		return errors.New(rsp1.RetCode)
+	}
+	rsp2, err := callx.SetGlobalVarRPC(self, fmt.Sprintf("SET GLOBAL gtid_purged='%s'", gtid))
+	if err != nil {
+		return err
+	}
+	if rsp2.RetCode != model.OK {
+		return errors.New(rsp2.RetCode)
+	}
+	r.log.Warning("S11-->set.gtid_purged[%v].done....", gtid)
+	return nil
+}
+
+// S12. replay the archived binlogs, the replayed transactions are skipped by their GTIDs when it's retried
+func (r *Rebuild) replayBinlogs(job *model.RebuildJob) error {
+	rsp, err := callx.ReplayBinlogsRPC(r.conf.Server.Endpoint, job.RestoreID, job.RestoreToTime, job.RestoreToGTID)
+	if err != nil {
+		return err
+	}
+	if rsp.RetCode != model.OK {
+		return errors.New(rsp.RetCode)
+	}
+	return nil
+}
@@ -52,6 +52,22 @@ func (n *NodeRPC) AddIdleNodes(req *model.NodeRPCRequest, rsp *model.NodeRPCResp
 	return nil
 }
 
+func (n *NodeRPC) AddReplicaNodes(req *model.NodeRPCRequest, rsp *model.NodeRPCResponse) error {
+	log := n.server.log
+	rsp.RetCode = model.OK
+	nodes := req.GetNodes()
+
+	log.Warning("server.rpc.node.add:%+v", req)
+	for _, node := range nodes {
+		if err := n.server.raft.AddReplicaPeer(node); err != nil {
+			rsp.RetCode = err.Error()
+			log.Error("rpc.add.replica.peer[%v].error[%v]", node, err)
+			return nil
+		}
+	}
+	return nil
+}
+
 func (n *NodeRPC) RemoveNodes(req *model.NodeRPCRequest, rsp *model.NodeRPCResponse) error {
 	log := n.server.log
 	rsp.RetCode = model.OK
@@ -84,13 +100,32 @@ func (n *NodeRPC) RemoveIdleNodes(req *model.NodeRPCRequest, rsp *model.NodeRPCR
 	return nil
 }
 
+func (n *NodeRPC) RemoveReplicaNodes(req *model.NodeRPCRequest, rsp *model.NodeRPCResponse) error {
+	log := n.server.log
+	rsp.RetCode = model.OK
+	nodes := req.GetNodes()
+
+	log.Warning("server.rpc.node.remove:%+v", req)
+	for _, node := range nodes {
+		if err := n.server.raft.RemoveReplicaPeer(node); err != nil {
+			rsp.RetCode = err.Error()
+			log.Error("rpc.remove.replica.peer[%v].error[%v]", node, err)
+			return nil
+		}
+	}
+	return nil
+}
+
 func (n *NodeRPC) GetNodes(req *model.NodeRPCRequest, rsp *model.NodeRPCResponse) error {
 	rsp.RetCode = model.OK
 	rsp.Leader = n.server.raft.GetLeader()
 	rsp.ViewID = n.server.raft.GetVewiID()
 	rsp.EpochID = n.server.raft.GetEpochID()
 	rsp.State = n.server.raft.GetState().String()
+	rsp.Labels = n.server.raft.GetLabels()
+	rsp.ReplicationDelay = n.server.conf.Raft.ReplicationDelaySeconds
 	nodes := n.server.raft.GetAllPeers()
 	rsp.Nodes = append(rsp.Nodes, nodes...)
+	rsp.Replicas = append(rsp.Replicas, n.server.raft.GetReplicas()...)
 	return nil
 }
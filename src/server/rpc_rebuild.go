@@ -0,0 +1,78 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package server
+
+import (
+	"model"
+)
+
+// RebuildRPC tuple.
+type RebuildRPC struct {
+	server *Server
+}
+
+// GetRebuildRPC returns RebuildRPC tuple.
+func (s *Server) GetRebuildRPC() *RebuildRPC {
+	return &RebuildRPC{s}
+}
+
+// Start used to start the rebuild job in background.
+func (r *RebuildRPC) Start(req *model.RebuildRPCRequest, rsp *model.RebuildRPCResponse) error {
+	rsp.RetCode = model.OK
+	rebuild := r.server.rebuild
+	if err := rebuild.Start(req.RebuildFrom, req.Force, req.Rate); err != nil {
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	rsp.Job = rebuild.Status()
+	return nil
+}
+
+// Status returns the rebuild job.
+func (r *RebuildRPC) Status(req *model.RebuildRPCRequest, rsp *model.RebuildRPCResponse) error {
+	rsp.RetCode = model.OK
+	rsp.Job = r.server.rebuild.Status()
+	return nil
+}
+
+// Cancel used to cancel the running job or roll back the failed one.
+func (r *RebuildRPC) Cancel(req *model.RebuildRPCRequest, rsp *model.RebuildRPCResponse) error {
+	rsp.RetCode = model.OK
+	rebuild := r.server.rebuild
+	if err := rebuild.Cancel(); err != nil {
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	rsp.Job = rebuild.Status()
+	return nil
+}
+
+// Resume used to continue the failed or interrupted job.
+func (r *RebuildRPC) Resume(req *model.RebuildRPCRequest, rsp *model.RebuildRPCResponse) error {
+	rsp.RetCode = model.OK
+	rebuild := r.server.rebuild
+	if err := rebuild.Resume(); err != nil {
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	rsp.Job = rebuild.Status()
+	return nil
+}
+
+// Restore used to start the point-in-time restore job in background.
+func (r *RebuildRPC) Restore(req *model.RebuildRPCRequest, rsp *model.RebuildRPCResponse) error {
+	rsp.RetCode = model.OK
+	rebuild := r.server.rebuild
+	if err := rebuild.StartRestore(req.RestoreID, req.RestoreToTime, req.RestoreToGTID); err != nil {
+		rsp.RetCode = err.Error()
+		return nil
+	}
+	rsp.Job = rebuild.Status()
+	return nil
+}
@@ -0,0 +1,105 @@
+/*
+ * Xenon
+ *
+ * Copyright 2018 The Xenon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package server
+
+import (
+	"model"
+	"raft"
+	"testing"
+	"xbase/common"
+	"xbase/xlog"
+	"xbase/xrpc"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TEST EFFECTS:
+// test the rebuild job from client rpc
+//
+// TEST PROCESSES:
+// 1. Start rpc server
+// 2. start the rebuild job on the leader, it fails at S1
+// 3. cancel the failed job
+func TestServerRPCRebuild(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	port := common.RandomPort(8000, 9000)
+	servers, cleanup := MockServers(log, port, 3)
+	defer cleanup()
+	MockWaitLeaderEggs(servers, 1)
+
+	var leader *Server
+	for _, server := range servers {
+		if server.GetState() == raft.LEADER {
+			leader = server
+			break
+		}
+	}
+	name := leader.Address()
+	client, err := xrpc.NewClient(name, 100)
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// status
+	{
+		method := model.RPCRebuildStatus
+		req := model.NewRebuildRPCRequest()
+		rsp := model.NewRebuildRPCResponse(model.OK)
+		err := client.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.OK, rsp.RetCode)
+		assert.Equal(t, model.REBUILD_NONE, rsp.Job.State)
+	}
+
+	// cancel with no job
+	{
+		method := model.RPCRebuildCancel
+		req := model.NewRebuildRPCRequest()
+		rsp := model.NewRebuildRPCResponse(model.OK)
+		err := client.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.NotEqual(t, model.OK, rsp.RetCode)
+	}
+
+	// start on the leader
+	{
+		method := model.RPCRebuildStart
+		req := model.NewRebuildRPCRequest()
+		rsp := model.NewRebuildRPCResponse(model.OK)
+		err := client.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.OK, rsp.RetCode)
+		leader.rebuild.Wait()
+
+		job := leader.rebuild.Status()
+		assert.Equal(t, model.REBUILD_FAILED, job.State)
+		assert.Equal(t, 0, job.Step)
+		assert.Contains(t, job.LastError, "am.leader")
+	}
+
+	// cancel the failed job
+	{
+		method := model.RPCRebuildCancel
+		req := model.NewRebuildRPCRequest()
+		rsp := model.NewRebuildRPCResponse(model.OK)
+		err := client.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.Equal(t, model.OK, rsp.RetCode)
+		assert.Equal(t, model.REBUILD_CANCELED, rsp.Job.State)
+	}
+
+	// resume the canceled job
+	{
+		method := model.RPCRebuildResume
+		req := model.NewRebuildRPCRequest()
+		rsp := model.NewRebuildRPCResponse(model.OK)
+		err := client.Call(method, req, rsp)
+		assert.Nil(t, err)
+		assert.NotEqual(t, model.OK, rsp.RetCode)
+	}
+}
@@ -23,25 +23,30 @@ import (
 )
 
 type RPCS struct {
-	NodeRPC   *NodeRPC
-	ServerRPC *ServerRPC
-	UserRPC   *UserRPC
-	HARPC     *raft.HARPC
-	RaftRPC   *raft.RaftRPC
-	MysqldRPC *mysqld.MysqldRPC
-	BackupRPC *mysqld.BackupRPC
-	MysqlRPC  *mysql.MysqlRPC
+	NodeRPC    *NodeRPC
+	ServerRPC  *ServerRPC
+	UserRPC    *UserRPC
+	HARPC      *raft.HARPC
+	RaftRPC    *raft.RaftRPC
+	MysqldRPC  *mysqld.MysqldRPC
+	BackupRPC  *mysqld.BackupRPC
+	MysqlRPC   *mysql.MysqlRPC
+	RebuildRPC *RebuildRPC
 }
 
 type Server struct {
-	log    *xlog.Log
-	mysqld *mysqld.Mysqld
-	mysql  *mysql.Mysql
-	raft   *raft.Raft
-	conf   *config.Config
-	rpc    *xrpc.Service
-	rpcs   RPCS
-	begin  time.Time
+	log     *xlog.Log
+	mysqld  *mysqld.Mysqld
+	mysql   *mysql.Mysql
+	raft    *raft.Raft
+	rebuild *Rebuild
+	conf    *config.Config
+	rpc     *xrpc.Service
+	rpcs    RPCS
+	begin   time.Time
+
+	// the preflight is skipped if the mysql didn't work in time, it runs again before the raft starts
+	preflighted bool
 }
 
 func NewServer(conf *config.Config, log *xlog.Log, initState raft.State) *Server {
@@ -51,8 +56,16 @@ func NewServer(conf *config.Config, log *xlog.Log, initState raft.State) *Server
 	}
 
 	s.mysqld = mysqld.NewMysqld(conf.Backup, log)
+	s.mysqld.SetBackupChooser(s.backupChooser)
 	s.mysql = mysql.NewMysql(conf.Mysql, conf.Raft.ElectionTimeout, log)
+	if err := s.mysql.LoadSlaveHeld(conf.Raft.MetaDatadir); err != nil {
+		log.Panic("server.mysql.load.slave.held.error[%v]", err)
+	}
 	s.raft = raft.NewRaft(conf.Server.Endpoint, conf.Raft, conf.Mysql.SemiSyncTimeoutForTwoNodes, log, s.mysql, initState)
+	s.mysqld.SetBinlogLister(s.mysql)
+	s.raft.AddPurgeBinlogGuard("binlog-archive", s.mysqld.PurgeBinlogGuard)
+	s.raft.SetBackupChecker(s.mysqld.BackupRunning)
+	s.rebuild = NewRebuild(conf, log)
 	rpc, err := xrpc.NewService(xrpc.Log(log),
 		xrpc.ConnectionStr(conf.Server.Endpoint))
 	if err != nil {
@@ -65,6 +78,7 @@ func NewServer(conf *config.Config, log *xlog.Log, initState raft.State) *Server
 func (s *Server) Init() {
 	s.setupMysqld()
 	s.setupMysql()
+	s.setupRebuild()
 	s.setupRPC()
 }
 
@@ -84,6 +98,7 @@ func (s *Server) setupMysqld() {
 }
 
 // setupMysql used to create replication user where not exists
+// it refuses to run if the mysql is not capable of the HA, see `xenoncli mysql preflight`
 func (s *Server) setupMysql() {
 	log := s.log
 	log.Info("server.mysql.wait.for.work[maxwait:60s]")
@@ -91,6 +106,7 @@ func (s *Server) setupMysql() {
 		log.Error("server.mysql.WaitMysqlWorks.error[%v]", err)
 		return
 	}
+	s.preflight()
 
 	gtid, _ := s.mysql.GetGTID()
 	log.Info("server.mysql.gtid:%+v", gtid)
@@ -123,6 +139,39 @@ func (s *Server) setupMysql() {
 	log.Info("server.mysql.setup.done")
 }
 
+// preflight used to refuse to run the HA if the mysql is not capable of it,
+// the semi-sync checks are advisory if the durability policy is async.
+func (s *Server) preflight() {
+	log := s.log
+
+	// the semi-sync plugins are installed before the preflight checks them
+	log.Info("server.mysql.install.semisync.plugins")
+	if err := s.mysql.InstallSemiSyncPlugins(); err != nil {
+		log.Error("server.mysql.InstallSemiSyncPlugins.error[%v]", err)
+	}
+
+	log.Info("server.mysql.preflight")
+	checks, err := s.mysql.Preflight()
+	if err != nil {
+		log.Error("server.mysql.preflight.error[%v]", err)
+		return
+	}
+	if err := mysql.PreflightError(checks, raft.IsSemiSync(s.conf.Raft.Durability)); err != nil {
+		log.Panic("server.refuse.to.run.HA.%v", err)
+	}
+	s.preflighted = true
+}
+
+// setupRebuild used to load the rebuild job left by the last run
+func (s *Server) setupRebuild() {
+	log := s.log
+	if err := s.rebuild.Load(); err != nil {
+		log.Error("server.rebuild.load.error[%+v]", err)
+		return
+	}
+	log.Info("server.rebuild.job:%+v", s.rebuild.Status())
+}
+
 // setupRPC used to setup rpc handlers
 func (s *Server) setupRPC() {
 	log := s.log
@@ -135,6 +184,7 @@ func (s *Server) setupRPC() {
 	s.rpcs.MysqldRPC = s.mysqld.GetMysqldRPC()
 	s.rpcs.BackupRPC = s.mysqld.GetBackupRPC()
 	s.rpcs.MysqlRPC = s.mysql.GetMysqlRPC()
+	s.rpcs.RebuildRPC = s.GetRebuildRPC()
 
 	if err := s.rpc.RegisterService(s.rpcs.NodeRPC); err != nil {
 		log.Panic("server.rpc.RegisterService.NodeRPC.error[%+v]", err)
@@ -160,6 +210,10 @@ func (s *Server) setupRPC() {
 	if err := s.rpc.RegisterService(s.rpcs.MysqlRPC); err != nil {
 		log.Panic("server.rpc.RegisterService.MysqlRPC.error[%+v]", err)
 	}
+	if err := s.rpc.RegisterService(s.rpcs.RebuildRPC); err != nil {
+		log.Panic("server.rpc.RegisterService.RebuildRPC.error[%+v]", err)
+	}
+	s.rpc.RegisterStreamHandler(s.mysqld.HandleStream)
 	log.Info("server.RPC.setup.done")
 }
 
@@ -179,12 +233,25 @@ func (s *Server) Start() {
 	}
 
 	s.mysql.PingStart()
+	if !s.preflighted {
+		log.Warning("server.mysql.preflight.was.skipped.wait.for.work.before.raft.start[maxwait:60s]")
+		if err := s.mysql.WaitMysqlWorks(60 * 1000); err != nil {
+			log.Error("server.mysql.WaitMysqlWorks.error[%v].raft.starts.without.preflight", err)
+		} else {
+			s.preflight()
+		}
+	}
 	if err := s.raft.Start(); err != nil {
 		log.Panic("server.raft.start.error[%+v]", err)
 	}
 	if err := s.rpc.Start(); err != nil {
 		log.Panic("server.rpc.start.error[%+v]", err)
 	}
+	s.rebuild.Recover()
+	if err := s.mysqld.ScheduleStart(); err != nil {
+		log.Error("server.backup.schedule.start.error[%+v]", err)
+	}
+	s.mysqld.BinlogArchiveStart()
 	s.updateUptime()
 	log.Info("server.start.success...")
 }
@@ -195,6 +262,8 @@ func (s *Server) updateUptime() {
 
 func (s *Server) Shutdown() {
 	s.log.Info("server.prepare.to.shutdown")
+	s.mysqld.ScheduleStop()
+	s.mysqld.BinlogArchiveStop()
 	s.rpc.Stop()
 	s.raft.Stop()
 	s.mysql.PingStop()
@@ -9,6 +9,12 @@
 package server
 
 import (
+	"config"
+	"database/sql"
+	"fmt"
+	"model"
+	"mysql"
+	"raft"
 	"testing"
 	"xbase/common"
 	"xbase/xlog"
@@ -113,3 +119,76 @@ func TestServer(t *testing.T) {
 	mysqlPasswd := server.MySQLPasswd()
 	assert.Equal(t, "", mysqlPasswd)
 }
+
+// TEST EFFECTS:
+// test the server refuses to run HA if the mysql preflight failed
+//
+// TEST PROCESSES:
+// 1. preflight passed, set to readonly
+// 2. preflight failed, panic before touching the mysql
+// 3. the semi-sync plugins are missing, it's advisory with the async durability
+func TestServerPreflight(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	conf := config.DefaultConfig()
+	conf.Server.Endpoint = fmt.Sprintf("127.0.0.1:%d", common.RandomPort(8000, 9000))
+	server := NewServer(conf, log, raft.FOLLOWER)
+	mock := mysql.NewMockGTIDA()
+	server.mysql.SetMysqlHandler(mock)
+
+	var readonly bool
+	mock.SetReadOnlyFn = func(db *sql.DB, enabled bool) error {
+		readonly = enabled
+		return nil
+	}
+
+	// passed
+	{
+		server.setupMysql()
+		assert.True(t, readonly)
+	}
+
+	// failed
+	{
+		readonly = false
+		mock.PreflightFn = func(db *sql.DB) ([]model.MysqlPreflightCheck, error) {
+			return []model.MysqlPreflightCheck{
+				{Name: "log_bin", Passed: true, Detail: "log_bin is ON"},
+				{Name: "galera", Passed: false, Detail: "wsrep_on is ON, the node is in a galera cluster"},
+			}, nil
+		}
+		func() {
+			defer func() {
+				r := recover()
+				assert.Contains(t, r, "server.refuse.to.run.HA.mysql.preflight.failed[galera: wsrep_on is ON, the node is in a galera cluster]")
+			}()
+			server.setupMysql()
+		}()
+		assert.False(t, readonly)
+	}
+
+	// the semi-sync plugins are missing
+	{
+		readonly = false
+		server.preflighted = false
+		mock.PreflightFn = func(db *sql.DB) ([]model.MysqlPreflightCheck, error) {
+			return []model.MysqlPreflightCheck{
+				{Name: "log_bin", Passed: true, Detail: "log_bin is ON"},
+				{Name: "semi_sync_master", Passed: false, Detail: "the semi-sync master plugin is not installed"},
+			}, nil
+		}
+		func() {
+			defer func() {
+				r := recover()
+				assert.Contains(t, r, "server.refuse.to.run.HA.mysql.preflight.failed[semi_sync_master: the semi-sync master plugin is not installed]")
+			}()
+			server.setupMysql()
+		}()
+		assert.False(t, readonly)
+		assert.False(t, server.preflighted)
+
+		server.conf.Raft.Durability = raft.DurabilityAsync
+		server.setupMysql()
+		assert.True(t, readonly)
+		assert.True(t, server.preflighted)
+	}
+}
@@ -8,9 +8,15 @@
 
 package common
 
+import (
+	"io"
+)
+
 type Command interface {
 	Run(string, []string) error
+	RunStream(string, []string, io.Writer) error
 	Scan(string, int) error
+	ScanFunc(string, int, func(string)) error
 	Kill() error
 	RunCommand(string, []string) (string, error)
 	RunCommandWithTimeout(int, string, []string) (string, error)
@@ -16,7 +16,9 @@ import (
 	"io"
 	"os/exec"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 	"xbase/xlog"
 )
@@ -80,6 +82,8 @@ func NewLinuxCommand(log *xlog.Log) Command {
 
 func (c *LinuxCommand) Run(cmds string, args []string) error {
 	cmd := exec.Command(cmds, args...)
+	// run in a new process group, so Kill can reach the whole pipeline
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
 	c.log.Warning("LinuxCommand.prepare.to.run.cmds[%v]", strings.Join(args, " "))
 	stderr, err := cmd.StderrPipe()
@@ -101,15 +105,54 @@ func (c *LinuxCommand) Run(cmds string, args []string) error {
 	return nil
 }
 
+// RunStream same as Run, but the stdout is copied to w instead of being scanned,
+// only the stderr is scanned by Scan/ScanFunc.
+func (c *LinuxCommand) RunStream(cmds string, args []string, w io.Writer) error {
+	cmd := exec.Command(cmds, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Stdout = w
+
+	c.log.Warning("LinuxCommand.prepare.to.run.stream.cmds[%v]", strings.Join(args, " "))
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.stderr = stderr
+	c.stdout = nil
+
+	if err = cmd.Start(); err != nil {
+		return errors.WithStack(err)
+	}
+	c.cmd = cmd
+	return nil
+}
+
 // scan the substr until times reached or io.EOF got
 func (c *LinuxCommand) Scan(substr string, times int) error {
+	return c.ScanFunc(substr, times, nil)
+}
+
+// ScanFunc same as Scan, and every line of the outputs is passed to fn if it is not nil.
+// fn may be called from the stdout and stderr goroutines concurrently.
+func (c *LinuxCommand) ScanFunc(substr string, times int, fn func(string)) error {
 	var founds int32
+	var wg sync.WaitGroup
 	log := c.log
+
+	wg.Add(2)
 	go func() {
+		defer wg.Done()
+		// the stdout is copied to the writer by RunStream
+		if c.stdout == nil {
+			return
+		}
 		scanner := bufio.NewScanner(c.stdout)
 		for scanner.Scan() {
 			text := scanner.Text()
 			log.Warning("LinuxCommand.STDOUT==>%v", text)
+			if fn != nil {
+				fn(text)
+			}
 			if strings.Contains(text, substr) {
 				atomic.AddInt32(&founds, 1)
 			}
@@ -121,10 +164,14 @@ func (c *LinuxCommand) Scan(substr string, times int) error {
 	}()
 
 	go func() {
+		defer wg.Done()
 		scanner := bufio.NewScanner(c.stderr)
 		for scanner.Scan() {
 			text := scanner.Text()
 			c.log.Warning("LinuxCommand.STDERR==>%v", text)
+			if fn != nil {
+				fn(text)
+			}
 			if strings.Contains(text, substr) {
 				atomic.AddInt32(&founds, 1)
 			}
@@ -135,9 +182,10 @@ func (c *LinuxCommand) Scan(substr string, times int) error {
 		}
 	}()
 
-	// wait the cmd to finish.
+	// drain the outputs before waiting the cmd to finish, Wait closes the pipes.
+	wg.Wait()
 	c.cmd.Wait()
-	if int(founds) != times {
+	if int(atomic.LoadInt32(&founds)) != times {
 		return errors.Errorf("cmd.outs.[%v].found[%v]!=expects[%v]", substr, founds, times)
 	}
 	return nil
@@ -145,7 +193,10 @@ func (c *LinuxCommand) Scan(substr string, times int) error {
 
 func (c *LinuxCommand) Kill() error {
 	if c.cmd != nil {
-		return c.cmd.Process.Kill()
+		// kill the process group, the children hold the pipes too
+		if err := syscall.Kill(-c.cmd.Process.Pid, syscall.SIGKILL); err != nil {
+			return c.cmd.Process.Kill()
+		}
 	}
 	return nil
 }
@@ -9,8 +9,12 @@
 package common
 
 import (
+	"bytes"
 	"runtime"
+	"sort"
+	"sync"
 	"testing"
+	"time"
 	"xbase/xlog"
 
 	"github.com/stretchr/testify/assert"
@@ -92,3 +96,61 @@ func TestCommand(t *testing.T) {
 	_, err = cmd.RunCommandWithTimeout(1, cmds, args)
 	assert.NotNil(t, err)
 }
+
+func TestCommandScanFunc(t *testing.T) {
+	var mu sync.Mutex
+	var lines []string
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	cmd := NewLinuxCommand(log)
+	args := []string{"-c", "echo line1; echo line2 1>&2; echo completed OK!"}
+	err := cmd.Run("bash", args)
+	assert.Nil(t, err)
+
+	err = cmd.ScanFunc("completed OK!", 1, func(text string) {
+		mu.Lock()
+		defer mu.Unlock()
+		lines = append(lines, text)
+	})
+	assert.Nil(t, err)
+	sort.Strings(lines)
+	assert.Equal(t, []string{"completed OK!", "line1", "line2"}, lines)
+}
+
+func TestCommandRunStream(t *testing.T) {
+	var lines []string
+	var out bytes.Buffer
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	cmd := NewLinuxCommand(log)
+	args := []string{"-c", "echo stream; echo completed OK! 1>&2"}
+	err := cmd.RunStream("bash", args, &out)
+	assert.Nil(t, err)
+
+	err = cmd.ScanFunc("completed OK!", 1, func(text string) {
+		lines = append(lines, text)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"completed OK!"}, lines)
+	assert.Equal(t, "stream\n", out.String())
+}
+
+func TestCommandKillPipeline(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	cmd := NewLinuxCommand(log)
+	args := []string{"-c", "sleep 30 | cat"}
+	err := cmd.Run("bash", args)
+	assert.Nil(t, err)
+
+	cmd.Kill()
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.ScanFunc("completed OK!", 0, nil)
+	}()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(time.Second * 10):
+		t.Fatal("scan.after.kill.timeout")
+	}
+}
@@ -10,7 +10,12 @@ package common
 
 import (
 	"fmt"
+	"io"
 	"net"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
 )
 
 // mock command
@@ -35,11 +40,19 @@ func (c *MockCommand) Run(cmds string, args []string) error {
 	return nil
 }
 
+func (c *MockCommand) RunStream(cmds string, args []string, w io.Writer) error {
+	return c.Run(cmds, args)
+}
+
 func (c *MockCommand) Scan(substr string, times int) error {
 	fmt.Println("mock.Scan")
 	return nil
 }
 
+func (c *MockCommand) ScanFunc(substr string, times int, fn func(string)) error {
+	return c.Scan(substr, times)
+}
+
 func (c *MockCommand) Kill() error {
 	fmt.Println("mock.Kill")
 	close(c.c)
@@ -67,11 +80,19 @@ func (c *MockACommand) Run(cmds string, args []string) error {
 	return nil
 }
 
+func (c *MockACommand) RunStream(cmds string, args []string, w io.Writer) error {
+	return c.Run(cmds, args)
+}
+
 func (c *MockACommand) Scan(substr string, times int) error {
 	fmt.Println("mock.Scan")
 	return nil
 }
 
+func (c *MockACommand) ScanFunc(substr string, times int, fn func(string)) error {
+	return c.Scan(substr, times)
+}
+
 func (c *MockACommand) Kill() error {
 	fmt.Println("mock.Kill")
 	return nil
@@ -99,11 +120,19 @@ func (c *MockBCommand) Run(cmds string, args []string) error {
 	return nil
 }
 
+func (c *MockBCommand) RunStream(cmds string, args []string, w io.Writer) error {
+	return c.Run(cmds, args)
+}
+
 func (c *MockBCommand) Scan(substr string, times int) error {
 	fmt.Println("mock.Scan")
 	return nil
 }
 
+func (c *MockBCommand) ScanFunc(substr string, times int, fn func(string)) error {
+	return c.Scan(substr, times)
+}
+
 func (c *MockBCommand) Kill() error {
 	fmt.Println("mock.Kill")
 	return nil
@@ -118,6 +147,74 @@ func (c *MockBCommand) RunCommandWithTimeout(to int, cmds string, args []string)
 	return "", nil
 }
 
+// MockReplayCommand replays the recorded outputs to the scanner,
+// the args of Run/RunCommand are recorded in order.
+type MockReplayCommand struct {
+	mutex sync.Mutex
+	outs  []string
+	calls [][]string
+}
+
+func NewMockReplayCommand(outs []string) *MockReplayCommand {
+	return &MockReplayCommand{outs: outs}
+}
+
+// Calls returns the args of the commands which have been run.
+func (c *MockReplayCommand) Calls() [][]string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.calls
+}
+
+func (c *MockReplayCommand) record(args []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.calls = append(c.calls, args)
+}
+
+func (c *MockReplayCommand) Run(cmds string, args []string) error {
+	c.record(args)
+	return nil
+}
+
+func (c *MockReplayCommand) RunStream(cmds string, args []string, w io.Writer) error {
+	c.record(args)
+	return nil
+}
+
+func (c *MockReplayCommand) Scan(substr string, times int) error {
+	return c.ScanFunc(substr, times, nil)
+}
+
+func (c *MockReplayCommand) ScanFunc(substr string, times int, fn func(string)) error {
+	var founds int
+	for _, out := range c.outs {
+		if fn != nil {
+			fn(out)
+		}
+		if strings.Contains(out, substr) {
+			founds++
+		}
+	}
+	if founds != times {
+		return errors.Errorf("cmd.outs.[%v].found[%v]!=expects[%v]", substr, founds, times)
+	}
+	return nil
+}
+
+func (c *MockReplayCommand) Kill() error {
+	return nil
+}
+
+func (c *MockReplayCommand) RunCommand(cmds string, args []string) (string, error) {
+	c.record(args)
+	return "", nil
+}
+
+func (c *MockReplayCommand) RunCommandWithTimeout(to int, cmds string, args []string) (string, error) {
+	return c.RunCommand(cmds, args)
+}
+
 // get local  ip for test only
 func GetLocalIP() (string, error) {
 	ifaces, err := net.Interfaces()
@@ -9,6 +9,7 @@
 package xrpc
 
 import (
+	"bufio"
 	"net"
 	"net/rpc"
 	"time"
@@ -16,11 +17,19 @@ import (
 	"github.com/pkg/errors"
 )
 
+// StreamMagic is the first byte of a stream connection, a gob message never starts with it,
+// so that the raw streams share the same port with the rpc.
+const StreamMagic byte = 0xEE
+
+// StreamHandler handles the stream connection, the magic byte has been consumed.
+type StreamHandler func(conn net.Conn)
+
 type Service struct {
 	registered bool
 	opts       *Options
 	server     *rpc.Server  // rpc server
 	listener   net.Listener // net listener
+	stream     StreamHandler
 }
 
 // creates a new Service with options
@@ -43,6 +52,44 @@ func (s *Service) RegisterService(rcvr interface{}) error {
 	return nil
 }
 
+// register the handler for the stream connections
+func (s *Service) RegisterStreamHandler(handler StreamHandler) {
+	s.stream = handler
+}
+
+// bufferedConn is the conn whose first bytes have been peeked by the reader.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// serveConn dispatches the conn to the stream handler if it starts with the StreamMagic,
+// otherwise serves it as the rpc connection.
+func (s *Service) serveConn(conn net.Conn) {
+	if s.stream == nil {
+		s.server.ServeConn(conn)
+		return
+	}
+
+	reader := bufio.NewReader(conn)
+	magic, err := reader.Peek(1)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	bc := &bufferedConn{Conn: conn, reader: reader}
+	if magic[0] == StreamMagic {
+		reader.Discard(1)
+		s.stream(bc)
+		return
+	}
+	s.server.ServeConn(bc)
+}
+
 // accepts incoming connections
 func (s *Service) Start() error {
 	if !s.registered {
@@ -62,7 +109,7 @@ func (s *Service) Start() error {
 				s.opts.Log.Error("xrpc.accept.error[%v]", err)
 				return
 			}
-			go s.server.ServeConn(conn)
+			go s.serveConn(conn)
 		}
 	}()
 	s.opts.Log.Warning("xrpc.Start.listening.on[%v]", s.listener.Addr())
@@ -149,6 +196,19 @@ func (c *Client) CallTimeout(timeout int, method string, args interface{}, reply
 	}
 }
 
+// DialStream dials the stream connection to the service and writes the StreamMagic.
+func DialStream(connStr string, timeout int) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", connStr, time.Duration(timeout)*time.Millisecond)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if _, err := conn.Write([]byte{StreamMagic}); err != nil {
+		conn.Close()
+		return nil, errors.WithStack(err)
+	}
+	return conn, nil
+}
+
 // close the client connection
 func (c *Client) Close() error {
 	if c.rpcClient != nil {
@@ -10,6 +10,8 @@ package xrpc
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net"
 	"strings"
 	"testing"
 	"time"
@@ -186,6 +188,42 @@ func TestRpcCallTimeout(t *testing.T) {
 	server.stop()
 }
 
+func TestRpcStream(t *testing.T) {
+	port := common.RandomPort(6000, 6670)
+	conn := fmt.Sprintf("127.0.0.1:%v", port)
+	method := "TestServer.Ping"
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	xrpc, err := NewService(ConnectionStr(conn), Log(log))
+	assert.Nil(t, err)
+	server := &TestServer{conn: conn, count: 1, rpc: xrpc}
+	assert.Nil(t, xrpc.RegisterService(server))
+
+	got := make(chan string, 1)
+	xrpc.RegisterStreamHandler(func(c net.Conn) {
+		defer c.Close()
+		b, _ := ioutil.ReadAll(c)
+		got <- string(b)
+	})
+	assert.Nil(t, xrpc.Start())
+	defer server.stop()
+
+	// stream
+	{
+		c, err := DialStream(conn, 100)
+		assert.Nil(t, err)
+		c.Write([]byte("xbstream"))
+		c.Close()
+		assert.Equal(t, "xbstream", <-got)
+	}
+
+	// the rpc shares the same port
+	{
+		err := client_call_ForTest(conn, method)
+		assert.Nil(t, err)
+	}
+}
+
 func client_call_ForTest(svrConn string, method string) error {
 	var rsp Response
 	client, err := NewClient(svrConn, 100)